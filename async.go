@@ -186,7 +186,8 @@ func (ac *AsyncCollection) ReplaceOne(queueKey, taskName string, record any, fil
 // Tasks in different queues will be executed in parallel.
 func (ac *AsyncCollection) SetFields(queueKey, taskName string, filter, update M) {
 	ac.push(queueKey, taskName, "set_fields", func(ctx context.Context) error {
-		return ac.coll.SetFields(ctx, filter, update)
+		_, err := ac.coll.SetFields(ctx, filter, update)
+		return err
 	})
 }
 
@@ -199,7 +200,8 @@ func (ac *AsyncCollection) SetFields(queueKey, taskName string, filter, update M
 // Tasks in different queues will be executed in parallel.
 func (ac *AsyncCollection) UpdateOne(queueKey, taskName string, filter, update M) {
 	ac.push(queueKey, taskName, "update_one", func(ctx context.Context) error {
-		return ac.coll.UpdateOne(ctx, filter, update)
+		_, err := ac.coll.UpdateOne(ctx, filter, update)
+		return err
 	})
 }
 
@@ -231,9 +233,9 @@ func (ac *AsyncCollection) UpdateMany(queueKey, taskName string, filter, update
 // It start retrying in case of error for DefaultAsyncRetries times.
 // It filters errors and won't retry in case of ErrNotFound, ErrInvalidArgument and some other errors.
 // Tasks in different queues will be executed in parallel.
-func (ac *AsyncCollection) UpdateOneFromDiff(queueKey, taskName string, filter M, diff any) {
+func (ac *AsyncCollection) UpdateOneFromDiff(queueKey, taskName string, filter M, diff any, opts ...DiffOptions) {
 	ac.push(queueKey, taskName, "update_from_diff", func(ctx context.Context) error {
-		return ac.coll.UpdateOneFromDiff(ctx, filter, diff)
+		return ac.coll.UpdateOneFromDiff(ctx, filter, diff, opts...)
 	})
 }
 
@@ -282,15 +284,46 @@ func (ac *AsyncCollection) BulkWrite(queueKey, taskName string, models []mongo.W
 	})
 }
 
+// BulkWriteCallback executes bulk write operations in the collection asynchronously like [AsyncCollection.BulkWrite],
+// but calls cb with the result of the operation when it is done.
+// Callback is called once: after a successful attempt, after a non-retriable error or after the last failed retry.
+// It is not called if the task is thrown from the queue on shutdown. Nil cb makes it the same as BulkWrite.
+func (ac *AsyncCollection) BulkWriteCallback(queueKey, taskName string, models []mongo.WriteModel, isOrdered bool, cb func(BulkResult, error)) {
+	if cb == nil {
+		ac.BulkWrite(queueKey, taskName, models, isOrdered)
+		return
+	}
+	var res BulkResult
+	ac.pushWithDone(queueKey, taskName, "bulk_write", func(ctx context.Context) (err error) {
+		res, err = ac.coll.BulkWrite(ctx, models, isOrdered)
+		return err
+	}, func(err error) {
+		cb(res, err)
+	})
+}
+
 func (ac *AsyncCollection) push(queueKey, taskName, opName string, f gorder.TaskFunc) {
+	ac.pushWithDone(queueKey, taskName, opName, f, nil)
+}
+
+// pushWithDone pushes a task to the queue and calls done with the final error of the task,
+// when it won't be retried anymore. Tasks in a queue are executed sequentially, so attempts counter is not guarded.
+func (ac *AsyncCollection) pushWithDone(queueKey, taskName, opName string, f gorder.TaskFunc, done func(error)) {
 	if queueKey == "" {
 		queueKey = ac.coll.coll.Name()
 	}
 	if taskName == "" {
 		taskName = ac.coll.coll.Name() + "_" + opName
 	}
+	var attempts int
 	ac.queue.Push(queueKey, taskName, func(ctx context.Context) error {
-		return ac.HandleRetryError(f(ctx), taskName)
+		err := f(ctx)
+		retryErr := ac.HandleRetryError(err, taskName)
+		attempts++
+		if done != nil && (retryErr == nil || attempts > DefaultAsyncRetries) {
+			done(err)
+		}
+		return retryErr
 	})
 }
 
@@ -445,8 +478,8 @@ func (qc *QueueCollection) UpdateMany(filter, update M) {
 // It returns ErrNotFound if no document is updated.
 // It start retrying in case of error for DefaultAsyncRetries times.
 // It filters errors and won't retry in case of ErrNotFound, ErrInvalidArgument and some other errors.
-func (qc *QueueCollection) UpdateOneFromDiff(filter M, diff any) {
-	qc.AsyncCollection.UpdateOneFromDiff(qc.name, "", filter, diff)
+func (qc *QueueCollection) UpdateOneFromDiff(filter M, diff any, opts ...DiffOptions) {
+	qc.AsyncCollection.UpdateOneFromDiff(qc.name, "", filter, diff, opts...)
 }
 
 // DeleteFields deletes fields in a document in the collection asynchronously without waiting for it to complete.
@@ -480,3 +513,10 @@ func (qc *QueueCollection) DeleteMany(filter M) {
 func (qc *QueueCollection) BulkWrite(models []mongo.WriteModel, isOrdered bool) {
 	qc.AsyncCollection.BulkWrite(qc.name, "", models, isOrdered)
 }
+
+// BulkWriteCallback executes bulk write operations in the collection asynchronously like [QueueCollection.BulkWrite],
+// but calls cb with the result of the operation when it is done.
+// Callback is called once: after a successful attempt, after a non-retriable error or after the last failed retry.
+func (qc *QueueCollection) BulkWriteCallback(models []mongo.WriteModel, isOrdered bool, cb func(BulkResult, error)) {
+	qc.AsyncCollection.BulkWriteCallback(qc.name, "", models, isOrdered, cb)
+}
@@ -2,6 +2,8 @@ package mongox
 
 import (
 	"fmt"
+	"maps"
+	"reflect"
 	"sync"
 
 	"github.com/maxbolgarin/lang"
@@ -13,7 +15,62 @@ import (
 // It is thread-safe. Empty builder is ready to use.
 type BulkBuilder struct {
 	models []mongo.WriteModel
+	keys   map[int]string
 	mu     sync.Mutex
+
+	// root and key are set in a view returned by Keyed
+	root *BulkBuilder
+	key  string
+}
+
+// WriteOutcome is a result of a single keyed model of [BulkBuilder] returned by [Collection.BulkWriteTracked].
+type WriteOutcome struct {
+	// Upserted is true if the model inserted a new document because of upsert.
+	Upserted bool
+	// UpsertedID is the _id of the document inserted by upsert, it is nil if Upserted is false.
+	UpsertedID any
+	// Err is the error of the model, e.g. ErrDuplicate. It is nil if the model was applied successfully.
+	Err error
+}
+
+// BulkResult is a result of [Collection.BulkWrite].
+type BulkResult struct {
+	// InsertedCount is the number of documents inserted.
+	InsertedCount int64
+	// MatchedCount is the number of documents matched by filters in update and replace operations.
+	MatchedCount int64
+	// ModifiedCount is the number of documents modified by update and replace operations.
+	ModifiedCount int64
+	// DeletedCount is the number of documents deleted.
+	DeletedCount int64
+	// UpsertedCount is the number of documents upserted by update and replace operations.
+	UpsertedCount int64
+	// UpsertedIDs is a map from the index of the operation to the ObjectID of the upserted document.
+	// Upserted IDs that are not ObjectID are not presented here, use Raw to get them.
+	UpsertedIDs map[int64]bson.ObjectID
+	// Raw is the result returned by the driver, it is nil if nothing was written.
+	Raw *mongo.BulkWriteResult
+}
+
+func newBulkResult(res *mongo.BulkWriteResult) BulkResult {
+	if res == nil {
+		return BulkResult{}
+	}
+	out := BulkResult{
+		InsertedCount: res.InsertedCount,
+		MatchedCount:  res.MatchedCount,
+		ModifiedCount: res.ModifiedCount,
+		DeletedCount:  res.DeletedCount,
+		UpsertedCount: res.UpsertedCount,
+		UpsertedIDs:   make(map[int64]bson.ObjectID, len(res.UpsertedIDs)),
+		Raw:           res,
+	}
+	for i, id := range res.UpsertedIDs {
+		if oid, ok := id.(bson.ObjectID); ok {
+			out.UpsertedIDs[i] = oid
+		}
+	}
+	return out
 }
 
 // NewBulkBuilder returns a new instance of [BulkBuilder].
@@ -21,11 +78,30 @@ func NewBulkBuilder() *BulkBuilder {
 	return &BulkBuilder{}
 }
 
+// Keyed returns a view of the builder that tags every model added through it with the key, e.g.
+//
+//	builder.Keyed("user-1").Upsert(user, mongox.M{"id": "user-1"})
+//
+// Models are added to the original builder, use [Collection.BulkWriteTracked] to get the outcome of every key.
+// Keys should be unique, one key should tag one model.
+func (b *BulkBuilder) Keyed(key string) *BulkBuilder {
+	return &BulkBuilder{root: b.rootBuilder(), key: key}
+}
+
 // Models returns the list of models added to the builder.
 func (b *BulkBuilder) Models() []mongo.WriteModel {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	return b.models
+	root := b.rootBuilder()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	return root.models
+}
+
+// Validate checks models of the builder before sending them to the server and returns ErrInvalidArgument
+// with the index of the first invalid model, e.g. "model 3: DeleteMany requires a filter".
+// A model is invalid if it is nil, has no filter, or has no document, replacement or update.
+// BulkWrite and BulkWriteTracked run the same check, so calling Validate is needed only to check models in advance.
+func (b *BulkBuilder) Validate() error {
+	return validateModels(b.Models())
 }
 
 // Insert adds [mongo.InsertOneModel] to the [BulkBuilder] for every record in the variadic argument.
@@ -35,12 +111,11 @@ func (b *BulkBuilder) Insert(records ...any) {
 
 // InsertMany adds [mongo.InsertOneModel] to the [BulkBuilder] for every record in the slice.
 func (b *BulkBuilder) InsertMany(records []any) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
+	models := make([]mongo.WriteModel, 0, len(records))
 	for _, r := range records {
-		b.models = append(b.models, mongo.NewInsertOneModel().SetDocument(r))
+		models = append(models, mongo.NewInsertOneModel().SetDocument(r))
 	}
+	b.addModel(models...)
 }
 
 // Upsert adds [mongo.ReplaceOneModel] to the [BulkBuilder] for record with filter and upsert == true.
@@ -81,6 +156,15 @@ func (b *BulkBuilder) UpdateMany(filter, update M) {
 	b.addModel(m)
 }
 
+// UpdateManyUpsert adds [mongo.UpdateManyModel] to the [BulkBuilder] for update with filter and upsert == true.
+// It updates all matched documents or inserts a single new document built from the equality fields
+// of the filter and the update if no document matches the filter.
+// Update map/document must contain key beginning with '$', e.g. {$set: {key1: value1}}.
+func (b *BulkBuilder) UpdateManyUpsert(filter, update M) {
+	m := mongo.NewUpdateManyModel().SetUpsert(true).SetFilter(filter.Prepare()).SetUpdate(update.Prepare())
+	b.addModel(m)
+}
+
 // UpdateOneFromDiff adds [mongo.UpdateOneModel] to the [BulkBuilder] for diff with filter.
 // Diff structure is a map of pointers to field names with their new values.
 // E.g. if you have structure:
@@ -91,9 +175,10 @@ func (b *BulkBuilder) UpdateMany(filter, update M) {
 //
 //	type MyStructDiff struct {name *string, index *int}
 //
+// Use DiffOptions to set the names of fields without a bson tag.
 // It returns error if diff structure is invalid.
-func (b *BulkBuilder) UpdateOneFromDiff(filter M, diff any) error {
-	update, err := diffToUpdates(diff)
+func (b *BulkBuilder) UpdateOneFromDiff(filter M, diff any, opts ...DiffOptions) error {
+	update, err := diffToUpdates(diff, opts...)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrInvalidArgument, err)
 	}
@@ -102,6 +187,20 @@ func (b *BulkBuilder) UpdateOneFromDiff(filter M, diff any) error {
 	return nil
 }
 
+// UpsertFromDiff adds [mongo.UpdateOneModel] to the [BulkBuilder] for diff with filter and upsert == true.
+// It works like [BulkBuilder.UpdateOneFromDiff], but inserts a new document if no document matches the filter.
+// Equality fields of the filter and fields from the diff will be set in the inserted document.
+// It returns error if diff structure is invalid.
+func (b *BulkBuilder) UpsertFromDiff(filter M, diff any, opts ...DiffOptions) error {
+	update, err := diffToUpdates(diff, opts...)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	m := mongo.NewUpdateOneModel().SetUpsert(true).SetFilter(filter.Prepare()).SetUpdate(update)
+	b.addModel(m)
+	return nil
+}
+
 // DeleteFields adds [mongo.UpdateOneModel] to the [BulkBuilder] for update with filter and fields.
 // For example: [key1, key2] becomes {$unset: {key1: "", key2: ""}}.
 func (b *BulkBuilder) DeleteFields(filter M, fields ...string) {
@@ -125,8 +224,103 @@ func (b *BulkBuilder) DeleteMany(filter M) {
 	b.addModel(m)
 }
 
-func (b *BulkBuilder) addModel(model mongo.WriteModel) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	b.models = append(b.models, model)
+func (b *BulkBuilder) addModel(models ...mongo.WriteModel) {
+	root := b.rootBuilder()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	for _, model := range models {
+		if b.key != "" {
+			if root.keys == nil {
+				root.keys = make(map[int]string)
+			}
+			root.keys[len(root.models)] = b.key
+		}
+		root.models = append(root.models, model)
+	}
+}
+
+// keyedModels returns models and keys of models by index.
+func (b *BulkBuilder) keyedModels() ([]mongo.WriteModel, map[int]string) {
+	root := b.rootBuilder()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	return root.models, maps.Clone(root.keys)
+}
+
+// validateModels returns ErrInvalidArgument with the index of the first invalid model.
+func validateModels(models []mongo.WriteModel) error {
+	for i, model := range models {
+		var name string
+		var filter, doc any
+		switch m := model.(type) {
+		case *mongo.InsertOneModel:
+			if m == nil || isNilValue(m.Document) {
+				return fmt.Errorf("%w: model %d: InsertOne requires a document", ErrInvalidArgument, i)
+			}
+			continue
+		case *mongo.UpdateOneModel:
+			if m != nil {
+				filter, doc = m.Filter, m.Update
+			}
+			name = "UpdateOne"
+		case *mongo.UpdateManyModel:
+			if m != nil {
+				filter, doc = m.Filter, m.Update
+			}
+			name = "UpdateMany"
+		case *mongo.ReplaceOneModel:
+			if m != nil {
+				filter, doc = m.Filter, m.Replacement
+			}
+			name = "ReplaceOne"
+		case *mongo.DeleteOneModel:
+			if m == nil || isNilValue(m.Filter) {
+				return fmt.Errorf("%w: model %d: DeleteOne requires a filter", ErrInvalidArgument, i)
+			}
+			continue
+		case *mongo.DeleteManyModel:
+			if m == nil || isNilValue(m.Filter) {
+				return fmt.Errorf("%w: model %d: DeleteMany requires a filter", ErrInvalidArgument, i)
+			}
+			continue
+		case nil:
+			return fmt.Errorf("%w: model %d: nil model", ErrInvalidArgument, i)
+		default:
+			continue
+		}
+
+		if isNilValue(filter) {
+			return fmt.Errorf("%w: model %d: %s requires a filter", ErrInvalidArgument, i, name)
+		}
+		if isNilValue(doc) {
+			what := lang.If(name == "ReplaceOne", "a replacement", "an update")
+			return fmt.Errorf("%w: model %d: %s requires %s", ErrInvalidArgument, i, name, what)
+		}
+		// An empty replacement is valid, it removes all fields except _id
+		if update, ok := doc.(bson.D); ok && len(update) == 0 && name != "ReplaceOne" {
+			return fmt.Errorf("%w: model %d: %s requires a non-empty update", ErrInvalidArgument, i, name)
+		}
+	}
+	return nil
+}
+
+// isNilValue reports whether v is nil or a nil pointer, map or slice.
+func isNilValue(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func (b *BulkBuilder) rootBuilder() *BulkBuilder {
+	if b.root != nil {
+		return b.root
+	}
+	return b
 }
@@ -2,12 +2,14 @@ package mongox
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 
 	"github.com/maxbolgarin/gorder"
 	"github.com/maxbolgarin/lang"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"go.mongodb.org/mongo-driver/v2/x/mongo/driver/auth"
@@ -17,8 +19,9 @@ import (
 // The Client type opens and closes connections automatically and maintains a pool of idle connections.
 // It is safe for concurrent use by multiple goroutines.
 type Client struct {
-	client *mongo.Client
-	config Config
+	client    *mongo.Client
+	config    Config
+	overrides ConfigOverrides
 
 	dbs  map[string]*Database
 	adbs map[string]*AsyncDatabase
@@ -37,6 +40,14 @@ func Connect(ctx context.Context, cfg Config) (*Client, error) {
 	lang.IfV(cfg.ReplicaSetName, func() { opts.SetReplicaSet(cfg.ReplicaSetName) })
 	lang.IfF(len(cfg.Compressors) > 0, func() { opts.SetCompressors(cfg.Compressors) })
 
+	if cfg.ReadPreference != "" {
+		pref, err := newReadPref(cfg.ReadPreference, cfg.ReadPreferenceTags...)
+		if err != nil {
+			return nil, fmt.Errorf("validate options: %w", err)
+		}
+		opts.SetReadPreference(pref)
+	}
+
 	if cfg.Connection != nil {
 		lang.IfV(cfg.Connection.ConnectTimeout, func() { opts.SetConnectTimeout(*cfg.Connection.ConnectTimeout) })
 		lang.IfV(cfg.Connection.MaxConnIdleTime, func() { opts.SetMaxConnIdleTime(*cfg.Connection.MaxConnIdleTime) })
@@ -51,7 +62,7 @@ func Connect(ctx context.Context, cfg Config) (*Client, error) {
 	}
 
 	if cfg.BSONOptions != nil {
-		opts.SetBSONOptions(buildBSONOptions(cfg))
+		opts.SetBSONOptions(buildBSONOptions(*cfg.BSONOptions))
 	}
 
 	if err := opts.Validate(); err != nil {
@@ -92,6 +103,107 @@ func (m *Client) Ping(ctx context.Context) error {
 	return m.client.Ping(ctx, nil)
 }
 
+// Warmup opens up to n connections to the deployment by issuing n concurrent pings,
+// so the connection pool is filled before the first requests instead of lazily.
+// The number of connections is bounded by MaxPoolSize (100 by default).
+// It returns the first ping error or the context error if context is canceled.
+func (m *Client) Warmup(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	maxPoolSize := uint64(DefaultMaxPoolSize)
+	if m.config.Connection != nil && m.config.Connection.MaxPoolSize != nil && *m.config.Connection.MaxPoolSize > 0 {
+		maxPoolSize = *m.config.Connection.MaxPoolSize
+	}
+	n = int(min(uint64(n), maxPoolSize))
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for range n {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.client.Ping(ctx, nil); err != nil {
+				once.Do(func() { firstErr = err })
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return HandleMongoError(firstErr)
+}
+
+// GetFCV returns the feature compatibility version of the deployment, e.g. "7.0", using getParameter admin command.
+// Check it before and after upgrading binaries: FCV should be raised only after all members run the new version.
+// It returns ErrUnauthorized if the user doesn't have the getParameter privilege.
+func (m *Client) GetFCV(ctx context.Context) (string, error) {
+	cmd := bson.D{{Key: "getParameter", Value: 1}, {Key: "featureCompatibilityVersion", Value: 1}}
+	var res struct {
+		FCV struct {
+			Version string `bson:"version"`
+		} `bson:"featureCompatibilityVersion"`
+	}
+	if err := m.client.Database("admin").RunCommand(ctx, cmd).Decode(&res); err != nil {
+		return "", HandleMongoError(err)
+	}
+	return res.FCV.Version, nil
+}
+
+// SetFCV sets the feature compatibility version of the deployment, e.g. "7.0", using setFeatureCompatibilityVersion
+// admin command. Setting the current version is a no-op. Downgrade may be impossible after new features are used,
+// read the upgrade notes of the version before running it. Confirmation required by MongoDB 7.0+ is sent automatically.
+// It returns ErrInvalidArgument (together with ErrUnknownFeatureCompatibilityVersion if the server reports it)
+// if the version is not supported by the deployment, and ErrUnauthorized if the user doesn't have the privilege.
+func (m *Client) SetFCV(ctx context.Context, v string) error {
+	if v == "" {
+		return fmt.Errorf("%w: empty feature compatibility version", ErrInvalidArgument)
+	}
+	admin := m.client.Database("admin")
+	cmd := bson.D{{Key: "setFeatureCompatibilityVersion", Value: v}}
+
+	needConfirm, err := serverVersionAtLeast(ctx, admin, 7, 0)
+	if err != nil {
+		return err
+	}
+	lang.IfF(needConfirm, func() { cmd = append(cmd, bson.E{Key: "confirm", Value: true}) })
+
+	err = HandleMongoError(admin.RunCommand(ctx, cmd).Err())
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrUnknownFeatureCompatibilityVersion), errors.Is(err, ErrBadValue):
+		return fmt.Errorf("%w: unsupported feature compatibility version %q: %w", ErrInvalidArgument, v, err)
+	case errors.Is(err, ErrUnauthorized):
+		return fmt.Errorf("%w: setFeatureCompatibilityVersion requires the privilege on the cluster", err)
+	}
+	return err
+}
+
+// WithConfig returns a lightweight client handle that applies the overrides (read preference, read concern,
+// write concern) as defaults to databases and collections obtained from it, e.g. for a tenant that needs
+// majority writes or reads from secondaries. Overrides are merged with the overrides of the client, if any.
+// The handle shares the underlying *mongo.Client and its connection pool with the original client,
+// so it doesn't open new connections. Disconnect on any of them closes the pool for all of them.
+func (m *Client) WithConfig(overrides ConfigOverrides) *Client {
+	return &Client{
+		client:    m.client,
+		config:    m.config,
+		overrides: m.overrides.merge(overrides),
+		dbs:       make(map[string]*Database),
+		adbs:      make(map[string]*AsyncDatabase),
+	}
+}
+
 // IsTLS returns whether the client is using TLS for its connections.
 // This is a helper method to determine if the connection is secure.
 func (m *Client) IsTLS() bool {
@@ -109,7 +221,8 @@ func (m *Client) Database(name string) *Database {
 	}
 
 	db = &Database{
-		db:    m.client.Database(name),
+		db:    m.client.Database(name, m.overrides.databaseOptions()),
+		cfg:   &m.config,
 		colls: make(map[string]*Collection),
 	}
 
@@ -151,7 +264,7 @@ func buildURL(cfg Config) string {
 	out := strings.Builder{}
 	out.WriteString("mongodb://")
 	if cfg.Address == "" && len(cfg.Hosts) == 0 {
-		cfg.Address = "localhost:27017"
+		cfg.Address = DefaultAddress
 	}
 	if cfg.Address != "" {
 		out.WriteString(cfg.Address)
@@ -225,22 +338,22 @@ func buildCredential(cfg Config) options.Credential {
 	}
 }
 
-func buildBSONOptions(cfg Config) *options.BSONOptions {
+func buildBSONOptions(opts BSONOptions) *options.BSONOptions {
 	return &options.BSONOptions{
-		UseJSONStructTags:       cfg.BSONOptions.UseJSONStructTags,
-		ErrorOnInlineDuplicates: cfg.BSONOptions.ErrorOnInlineDuplicates,
-		IntMinSize:              cfg.BSONOptions.IntMinSize,
-		NilMapAsEmpty:           cfg.BSONOptions.NilMapAsEmpty,
-		NilSliceAsEmpty:         cfg.BSONOptions.NilSliceAsEmpty,
-		NilByteSliceAsEmpty:     cfg.BSONOptions.NilByteSliceAsEmpty,
-		OmitZeroStruct:          cfg.BSONOptions.OmitZeroStruct,
-		StringifyMapKeysWithFmt: cfg.BSONOptions.StringifyMapKeysWithFmt,
-		AllowTruncatingDoubles:  cfg.BSONOptions.AllowTruncatingDoubles,
-		BinaryAsSlice:           cfg.BSONOptions.BinaryAsSlice,
-		DefaultDocumentM:        cfg.BSONOptions.DefaultDocumentM,
-		ObjectIDAsHexString:     cfg.BSONOptions.ObjectIDAsHexString,
-		UseLocalTimeZone:        cfg.BSONOptions.UseLocalTimeZone,
-		ZeroMaps:                cfg.BSONOptions.ZeroMaps,
-		ZeroStructs:             cfg.BSONOptions.ZeroStructs,
+		UseJSONStructTags:       opts.UseJSONStructTags,
+		ErrorOnInlineDuplicates: opts.ErrorOnInlineDuplicates,
+		IntMinSize:              opts.IntMinSize,
+		NilMapAsEmpty:           opts.NilMapAsEmpty,
+		NilSliceAsEmpty:         opts.NilSliceAsEmpty,
+		NilByteSliceAsEmpty:     opts.NilByteSliceAsEmpty,
+		OmitZeroStruct:          opts.OmitZeroStruct,
+		StringifyMapKeysWithFmt: opts.StringifyMapKeysWithFmt,
+		AllowTruncatingDoubles:  opts.AllowTruncatingDoubles,
+		BinaryAsSlice:           opts.BinaryAsSlice,
+		DefaultDocumentM:        opts.DefaultDocumentM,
+		ObjectIDAsHexString:     opts.ObjectIDAsHexString,
+		UseLocalTimeZone:        opts.UseLocalTimeZone,
+		ZeroMaps:                opts.ZeroMaps,
+		ZeroStructs:             opts.ZeroStructs,
 	}
 }
@@ -2,6 +2,7 @@ package mongox_test
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,6 +13,69 @@ import (
 	"github.com/maxbolgarin/mongox"
 )
 
+func TestConfigDefaultsAndValidate(t *testing.T) {
+	t.Run("ApplyDefaults", func(t *testing.T) {
+		cfg := mongox.Config{Auth: &mongox.AuthConfig{Username: "root", Password: "password"}}
+		cfg.ApplyDefaults()
+
+		if cfg.Address != mongox.DefaultAddress {
+			t.Errorf("expected %v, got %v", mongox.DefaultAddress, cfg.Address)
+		}
+		if cfg.Connection == nil || lang.Deref(cfg.Connection.ConnectTimeout) != mongox.DefaultConnectTimeout {
+			t.Errorf("expected connect timeout %v, got %v", mongox.DefaultConnectTimeout, cfg.Connection)
+		}
+		if lang.Deref(cfg.Connection.MaxPoolSize) != mongox.DefaultMaxPoolSize {
+			t.Errorf("expected max pool size %v, got %v", mongox.DefaultMaxPoolSize, cfg.Connection.MaxPoolSize)
+		}
+		if cfg.Auth.AuthMechanism != mongox.DefaultAuthMechanism {
+			t.Errorf("expected %v, got %v", mongox.DefaultAuthMechanism, cfg.Auth.AuthMechanism)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("expected defaulted config to be valid, got %v", err)
+		}
+
+		uriCfg := mongox.Config{URI: "mongodb://localhost:27017/?maxPoolSize=10"}
+		uriCfg.ApplyDefaults()
+		if uriCfg.Address != "" || uriCfg.Connection != nil {
+			t.Errorf("expected URI config to be unchanged, got %v", uriCfg)
+		}
+	})
+
+	t.Run("Read", func(t *testing.T) {
+		t.Setenv("MONGO_ADDRESS", "mongo:27018")
+
+		var cfg mongox.Config
+		if err := cfg.Read(); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Address != "mongo:27018" {
+			t.Errorf("expected %v, got %v", "mongo:27018", cfg.Address)
+		}
+		if cfg.Connection == nil || cfg.Connection.MaxPoolSize == nil {
+			t.Error("expected defaults to be applied")
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		invalid := []mongox.Config{
+			{URI: "mongodb://localhost:27017", Hosts: []string{"localhost:27018"}},
+			{Compressors: []string{"lz4"}},
+			{Connection: &mongox.ConnectionConfig{MinPoolSize: lang.Ptr(uint64(10)), MaxPoolSize: lang.Ptr(uint64(5))}},
+			{Auth: &mongox.AuthConfig{AuthMechanism: "MONGODB-X509", Password: "password"}},
+			{MaxDocumentSize: -1},
+			{ReadPreference: "secondary_preferred"},
+			{ReadPreferenceTags: []map[string]string{{"region": "eu"}}},
+			{ReadPreference: "primary", ReadPreferenceTags: []map[string]string{{"region": "eu"}}},
+			{ReadPreference: "nearest", ReadPreferenceTags: []map[string]string{{"region": ""}}},
+		}
+		for i, cfg := range invalid {
+			if err := cfg.Validate(); !errors.Is(err, mongox.ErrInvalidArgument) {
+				t.Errorf("config %d: expected error %v, got %v", i, mongox.ErrInvalidArgument, err)
+			}
+		}
+	})
+}
+
 func TestBuildURLWithTLS(t *testing.T) {
 	// This test verifies that TLS configuration is properly added to the connection URL
 	tests := []struct {
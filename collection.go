@@ -1,14 +1,21 @@
 package mongox
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/maxbolgarin/lang"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/tag"
 )
 
 // FindOptions is used to configure FindOne, Find and FindAll operations.
@@ -32,14 +39,228 @@ type FindOptions struct {
 	// Whether or not pipelines that require more than 100 megabytes of memory to execute write to temporary files on disk.
 	// No-op in FindOne.
 	AllowDiskUse bool
+	// The field that is appended as a final ascending sort key to break ties, e.g. "_id".
+	// It makes results deterministic when the primary sort field has duplicates.
+	// It is not appended if the sort already contains this field. Sort should contain one field when it is used.
+	StableSortField string
+}
+
+// FindOneAndOptions is used to configure FindOneAndDelete, FindOneAndReplace and FindOneAndUpdate operations.
+type FindOneAndOptions struct {
+	// The order of the documents to choose which document is modified if many documents match the filter.
+	// Every M should contain one field, e.g. []mongox.M{{"created_at": mongox.Ascending}}.
+	Sort []M
+	// The fields of the returned document, e.g. mongox.M{"name": 1}. Nil means all fields.
+	Projection M
+	// Whether to insert a new document if no document matches the filter.
+	// There is no original document if it is inserted, so without ReturnUpdated the operation
+	// returns ErrNotFound even though the document is inserted. No-op in FindOneAndDelete.
+	Upsert bool
+	// Whether to return the document after the modification (options.After) instead of the original one
+	// (options.Before, default), e.g. to get the new value of a counter in a single round trip.
+	// No-op in FindOneAndDelete.
+	ReturnUpdated bool
+}
+
+// AggregateOptions is used to configure Aggregate operation.
+type AggregateOptions struct {
+	// Whether or not pipelines that require more than 100 megabytes of memory to execute write to temporary files on disk.
+	AllowDiskUse bool
+	// The maximum number of documents to be included in each batch returned by the server.
+	// Zero means server default.
+	BatchSize int
+	// The maximum amount of time the aggregation can run including reading of all batches. It is a deadline
+	// of the context on the client side: the driver doesn't send maxTimeMS for aggregate, because it returns a cursor,
+	// so the server doesn't know the limit and the driver abandons the operation on the deadline.
+	// The operation returns ErrMaxTimeMSExpired if it takes longer. In AggregateEach the time spent in the callback
+	// is counted too. Zero means no limit.
+	MaxTime time.Duration
+}
+
+// WriteOptions is used to configure UpdateMany, DeleteMany and BulkWrite operations.
+type WriteOptions struct {
+	// DryRun makes the operation count documents it would affect instead of modifying them,
+	// e.g. to preview a destructive migration before running it. Nothing is written.
+	// UpdateMany and DeleteMany return the number of documents matching the filter.
+	// BulkWrite approximates the result by counting documents matching the filter of every model.
+	// Models are counted independently, so effects of previous models of the same bulk are not taken into account,
+	// and updates are reported as matched, not modified.
+	DryRun bool
+	// The maximum amount of time the operation can run, the server aborts it after that and the method returns
+	// ErrMaxTimeMSExpired, so a runaway mass update or delete doesn't hold resources indefinitely.
+	// Documents written before the abort stay written. Zero means no limit.
+	MaxTime time.Duration
+}
+
+// UpdateOptions is used to configure UpdateOne and SetFields operations.
+type UpdateOptions struct {
+	// Upsert makes the operation insert a new document if no document matches the filter instead of returning
+	// ErrNotFound. The new document is built from equality fields of the filter with the update applied,
+	// so the update keeps $set/$inc semantics, e.g. {$inc: {counter: 1}} inserts a document with counter 1.
+	// The method returns the ID of the inserted document.
+	Upsert bool
+}
+
+// DistinctOptions is used to configure DistinctWithOptions operation.
+type DistinctOptions struct {
+	// The index to use for the operation, the index name or the index key specification, e.g. mongox.M{"field": 1}.
+	// Distinct is a covered query and doesn't read documents only if the index contains the distinct field
+	// and all fields of the filter, e.g. a compound index {status: 1, field: 1} for the filter on status.
+	Hint any
+	// The maximum amount of time the server can spend on the operation, it is sent as maxTimeMS.
+	// The operation returns ErrMaxTimeMSExpired if it takes longer. Zero means no limit.
+	MaxTime time.Duration
+}
+
+// UpsertResult is a result of UpsertUpdate and UpsertWithDefaults operations.
+type UpsertResult struct {
+	// Inserted is true if no document matched the filter and a new document was inserted.
+	Inserted bool
+	// Changed is true if a new document was inserted or the matched document was modified.
+	// It is false if the matched document already had the same values, so the server didn't modify it,
+	// e.g. to skip emitting a change event.
+	Changed bool
+	// ID is the _id of the inserted document. It is nil if no document was inserted or _id is not an ObjectID.
+	ID *bson.ObjectID
+}
+
+// CopyOptions is used to configure CopyTo operation.
+type CopyOptions struct {
+	// Whether to remove _id from copied documents, so the target collection generates new ones.
+	// By default _id is preserved.
+	RegenerateID bool
+	// The number of documents inserted into the target collection in one batch.
+	// Default is DefaultCopyBatchSize.
+	BatchSize int
+}
+
+// DefaultCopyBatchSize is the default number of documents inserted in one batch in CopyTo.
+const DefaultCopyBatchSize = 1000
+
+// ScanOptions is used to configure ScanAll operation.
+type ScanOptions struct {
+	// The key to resume scanning from: only documents with the sort field greater than it are scanned.
+	// Set it to the last key passed to the callback of the previous run to continue after a crash.
+	After any
+	// The maximum number of reconnect attempts if a batch query fails with a retryable error (see [IsRetryableRead]),
+	// e.g. a network error during failover or CursorNotFound. The batch is re-read from the last key after
+	// a backoff delay (see DefaultReconnectDelay), the counter is reset after every successful batch.
+	// Zero means no reconnect.
+	MaxRetries int
+}
+
+// WatchOptions is used to configure Watch, WatchInserts, WatchUpdates and WatchDeletes operations.
+type WatchOptions struct {
+	// The resume token to start the change stream after, e.g. ChangeEvent.ResumeToken of the last event processed
+	// before a restart. Nil means start from the current moment. The server returns ErrChangeStreamHistoryLost
+	// if the oplog doesn't contain the event anymore.
+	ResumeAfter bson.Raw
+	// Whether to include the current version of the document in update events: "updateLookup" to fetch it after
+	// the update, "whenAvailable" or "required" to use pre- and post-images (they must be enabled on the collection).
+	// Empty means the server default, the full document is included only for inserts and replaces.
+	FullDocument options.FullDocument
+	// The maximum number of reconnect attempts if the change stream fails with a transient error (see [IsTransient]),
+	// e.g. a network error during failover. The stream is reopened after a backoff delay (see DefaultReconnectDelay)
+	// from the resume token of the last processed event, so no event is lost or repeated. The counter is reset
+	// after every processed event. The driver resumes once by itself, this option covers longer outages.
+	// Zero means no reconnect.
+	MaxRetries int
+}
+
+// ChangeEvent is an event of a change stream passed to the callback of Watch.
+type ChangeEvent struct {
+	// OperationType is a type of the change, e.g. "insert", "update", "replace", "delete", "drop" or "invalidate".
+	OperationType string `bson:"operationType"`
+	// Namespace is the database and the collection of the changed document.
+	Namespace ChangeNamespace `bson:"ns"`
+	// DocumentKey is the _id of the changed document (and the shard key for sharded collections), e.g. {"_id": ...}.
+	DocumentKey bson.Raw `bson:"documentKey"`
+	// FullDocument is the document after the change. It is set for inserts and replaces and for updates if
+	// WatchOptions.FullDocument is set, it is nil for deletes.
+	FullDocument bson.Raw `bson:"-"`
+	// UpdateDescription describes changed fields of updates.
+	UpdateDescription *UpdateDescription `bson:"updateDescription,omitempty"`
+	// ClusterTime is the time of the oplog entry of the change.
+	ClusterTime bson.Timestamp `bson:"clusterTime"`
+	// ResumeToken is the token to resume the change stream after this event with WatchOptions.ResumeAfter.
+	ResumeToken bson.Raw `bson:"-"`
+}
+
+// ChangeNamespace is a namespace of a change event.
+type ChangeNamespace struct {
+	Database   string `bson:"db"`
+	Collection string `bson:"coll"`
+}
+
+// UpdateDescription is a description of fields changed by an update event.
+type UpdateDescription struct {
+	// UpdatedFields are new values of updated fields with dotted paths as keys.
+	UpdatedFields bson.Raw `bson:"updatedFields"`
+	// RemovedFields are names of removed fields.
+	RemovedFields []string `bson:"removedFields"`
+}
+
+// DefaultIDField is the default name of the id field of documents.
+const DefaultIDField = "_id"
+
+// CollectionOptions is used to configure a collection with [Collection.WithOptions].
+type CollectionOptions struct {
+	// The name of the logical id field of documents, e.g. "id" for collections keyed by a business field.
+	// It is used by id-based helpers, e.g. FindByID, IDFilter and InsertAndRead. Default is DefaultIDField.
+	IDField string
+	// The collation that is applied to every find, count, distinct, aggregate, update, replace and delete operation
+	// of the collection, e.g. &options.Collation{Locale: "en", Strength: 2} for case-insensitive comparison.
+	// Indexes created with CreateIndex and CreateIndexWithOptions get it too: the server uses an index for a query
+	// only if their collations match, so create indexes from the collection with the same DefaultCollation.
+	// Text indexes and models of BulkWrite don't get it. Default is nil, the collation of the server collection is used.
+	DefaultCollation *options.Collation
 }
 
 // Collection handles interactions with a MongoDB collection.
 // It is safe for concurrent use by multiple goroutines.
 type Collection struct {
-	coll *mongo.Collection
+	coll      *mongo.Collection
+	cfg       *Config
+	comment   string
+	timeout   time.Duration
+	idField   string
+	collation *options.Collation
+	bsonOpts  *BSONOptions
+}
+
+// CollectionAPI is a set of basic read and write operations of [Collection].
+// Depend on it in your code to replace a real collection with [MemoryCollection] in unit tests.
+// Generic functions of the package accept only [Collection].
+type CollectionAPI interface {
+	Name() string
+	FindOne(ctx context.Context, dest any, filter M, opts ...FindOptions) error
+	Find(ctx context.Context, dest any, filter M, opts ...FindOptions) error
+	FindAll(ctx context.Context, dest any, opts ...FindOptions) error
+	Count(ctx context.Context, filter M) (int64, error)
+	Distinct(ctx context.Context, dest any, field string, filter M) error
+	InsertOne(ctx context.Context, record any, isStrictID ...bool) (bson.ObjectID, error)
+	Insert(ctx context.Context, records ...any) ([]bson.ObjectID, error)
+	InsertStrict(ctx context.Context, records ...any) ([]bson.ObjectID, error)
+	InsertMany(ctx context.Context, records []any, isStrictID ...bool) ([]bson.ObjectID, error)
+	Upsert(ctx context.Context, record any, filter M) (*bson.ObjectID, error)
+	ReplaceOne(ctx context.Context, record any, filter M) error
+	SetFields(ctx context.Context, filter, update M, opts ...UpdateOptions) (*bson.ObjectID, error)
+	UpdateOne(ctx context.Context, filter, update M, opts ...UpdateOptions) (*bson.ObjectID, error)
+	UpdateMany(ctx context.Context, filter, update M, opts ...WriteOptions) (int, error)
+	UpdateOneFromDiff(ctx context.Context, filter M, diff any, opts ...DiffOptions) error
+	IncFields(ctx context.Context, filter M, deltas map[string]int64) error
+	MulFields(ctx context.Context, filter M, factors map[string]int64) error
+	DeleteFields(ctx context.Context, filter M, fields ...string) error
+	DeleteOne(ctx context.Context, filter M) error
+	DeleteMany(ctx context.Context, filter M, opts ...WriteOptions) (int, error)
+	Truncate(ctx context.Context) error
 }
 
+var (
+	_ CollectionAPI = (*Collection)(nil)
+	_ CollectionAPI = (*MemoryCollection)(nil)
+)
+
 // Name returns the name of the collection.
 func (m *Collection) Name() string {
 	return m.coll.Name()
@@ -50,34 +271,256 @@ func (m *Collection) Collection() *mongo.Collection {
 	return m.coll
 }
 
+// WithReadPreference returns a copy of the collection that uses the provided read preference for read operations.
+// Read preference is one of "primary", "primaryPreferred", "secondary", "secondaryPreferred" or "nearest".
+// It is useful to route reads of some collections (e.g. analytics) to secondaries while others read from primary.
+// Optional tag sets select replica set members in the order of preference, e.g. {"region": "eu"} for zone-aware
+// reads, see Config.ReadPreferenceTags.
+// The original collection is not modified, the copy shares the connection pool with it.
+// It returns ErrInvalidArgument if the read preference is not supported or tag sets are invalid.
+func (m *Collection) WithReadPreference(rp string, tagSets ...map[string]string) (*Collection, error) {
+	pref, err := newReadPref(rp, tagSets...)
+	if err != nil {
+		return nil, err
+	}
+	out := m.clone()
+	out.coll = m.coll.Clone(options.Collection().SetReadPreference(pref))
+	return out, nil
+}
+
+// WithBSONOptions returns a copy of the collection that uses the BSON options instead of Config.BSONOptions
+// for marshaling and unmarshaling documents, e.g. DefaultDocumentM for a single query that decodes into any.
+// Options are not merged with the client ones, unset fields mean default driver behavior.
+// Creating the copy is cheap: it doesn't build a new registry or a client, the options are applied
+// by the encoders and decoders of every operation of the copy. Reuse the copy instead of creating it per call.
+// The original collection is not modified, the copy shares the connection pool with it.
+func (m *Collection) WithBSONOptions(opts BSONOptions) *Collection {
+	out := m.clone()
+	out.coll = m.coll.Clone(options.Collection().SetBSONOptions(buildBSONOptions(opts)))
+	out.bsonOpts = &opts
+	return out
+}
+
+// useJSONStructTags reports whether the driver falls back to json tags for fields without bson tags.
+func (m *Collection) useJSONStructTags() bool {
+	opts := m.bsonOptions()
+	return opts != nil && opts.UseJSONStructTags
+}
+
+// bsonOptions returns the BSON options of the collection or nil if the driver defaults are used.
+func (m *Collection) bsonOptions() *BSONOptions {
+	if m.bsonOpts != nil {
+		return m.bsonOpts
+	}
+	if m.cfg != nil {
+		return m.cfg.BSONOptions
+	}
+	return nil
+}
+
+// marshal encodes doc to BSON the same way the driver does for operations of the collection.
+// It is used for raw commands, they are encoded by the database that doesn't know the collection options.
+func (m *Collection) marshal(doc any) (bson.Raw, error) {
+	buf := new(bytes.Buffer)
+	enc := bson.NewEncoder(bson.NewDocumentWriter(buf))
+	if opts := m.bsonOptions(); opts != nil {
+		lang.IfF(opts.UseJSONStructTags, enc.UseJSONStructTags)
+		lang.IfF(opts.ErrorOnInlineDuplicates, enc.ErrorOnInlineDuplicates)
+		lang.IfF(opts.IntMinSize, enc.IntMinSize)
+		lang.IfF(opts.NilMapAsEmpty, enc.NilMapAsEmpty)
+		lang.IfF(opts.NilSliceAsEmpty, enc.NilSliceAsEmpty)
+		lang.IfF(opts.NilByteSliceAsEmpty, enc.NilByteSliceAsEmpty)
+		lang.IfF(opts.OmitZeroStruct, enc.OmitZeroStruct)
+		lang.IfF(opts.StringifyMapKeysWithFmt, enc.StringifyMapKeysWithFmt)
+	}
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshal decodes raw into dest the same way the driver does for operations of the collection.
+func (m *Collection) unmarshal(raw bson.Raw, dest any) error {
+	dec := bson.NewDecoder(bson.NewDocumentReader(bytes.NewReader(raw)))
+	if opts := m.bsonOptions(); opts != nil {
+		lang.IfF(opts.UseJSONStructTags, dec.UseJSONStructTags)
+		lang.IfF(opts.AllowTruncatingDoubles, dec.AllowTruncatingDoubles)
+		lang.IfF(opts.BinaryAsSlice, dec.BinaryAsSlice)
+		lang.IfF(opts.DefaultDocumentM, dec.DefaultDocumentM)
+		lang.IfF(opts.ObjectIDAsHexString, dec.ObjectIDAsHexString)
+		lang.IfF(opts.UseLocalTimeZone, dec.UseLocalTimeZone)
+		lang.IfF(opts.ZeroMaps, dec.ZeroMaps)
+		lang.IfF(opts.ZeroStructs, dec.ZeroStructs)
+	}
+	return dec.Decode(dest)
+}
+
+// WithComment returns a copy of the collection that attaches the comment to all subsequent operations.
+// Comment is included in server logs, profiling logs and currentOp output and helps to trace operations.
+// The original collection is not modified, the copy shares the connection pool with it.
+func (m *Collection) WithComment(comment string) *Collection {
+	out := m.clone()
+	out.comment = comment
+	return out
+}
+
+// WithTimeout returns a copy of the collection that limits every subsequent operation with the timeout.
+// It is applied on top of the context deadline, so the shortest of them wins. Zero timeout means no limit.
+// The original collection is not modified, the copy shares the connection pool with it.
+func (m *Collection) WithTimeout(timeout time.Duration) *Collection {
+	out := m.clone()
+	out.timeout = timeout
+	return out
+}
+
+// WithOptions returns a copy of the collection configured with the options, e.g. with the id field
+// for collections keyed by a business field instead of _id. Empty fields of the options are not applied.
+// The original collection is not modified, the copy shares the connection pool with it.
+func (m *Collection) WithOptions(opts CollectionOptions) *Collection {
+	out := m.clone()
+	lang.IfF(opts.IDField != "", func() { out.idField = opts.IDField })
+	lang.IfF(opts.DefaultCollation != nil, func() { out.collation = opts.DefaultCollation })
+	return out
+}
+
+// IDField returns the name of the id field of documents set with CollectionOptions, DefaultIDField by default.
+func (m *Collection) IDField() string {
+	return lang.If(m.idField != "", m.idField, DefaultIDField)
+}
+
+// IDFilter returns a filter that matches the document by the id field of the collection: {IDField: id}.
+// Use it to build filters for helpers that take a filter, e.g. UpdateOneFromDiff, without hardcoding _id.
+func (m *Collection) IDFilter(id any) M {
+	return M{m.IDField(): id}
+}
+
+// IndexOptions is used to configure CreateIndexWithOptions operation.
+type IndexOptions struct {
+	// Whether the index is unique: it rejects documents with duplicate values of the indexed fields.
+	Unique bool
+	// The filter that limits the index to documents that match it, e.g. mongox.M{"deleted": false}.
+	// Unique partial index applies the uniqueness constraint only to the matching documents.
+	PartialFilter M
+	// The name of the index. If it is empty, the name is generated from the collection name, field names
+	// and options, e.g. "coll_field1_field2_unique_index". Set it to adopt an existing index with another name.
+	Name string
+	// Whether to build the index hidden from the query planner. The index is maintained on writes, but not used
+	// by queries, so it can be built on a large collection and exposed later with [Collection.SetIndexHidden]
+	// after checking that it doesn't hurt. Hiding an existing index is the safe way to test its removal.
+	Hidden bool
+	// The number of data-bearing voting members of the replica set that must finish the build before the primary
+	// commits the index: int for a number of members, "majority", "votingMembers" (default of the server) or a tag
+	// set name. Zero value means the default of the server. Standalone servers don't support it.
+	// It returns ErrUnsatisfiableCommitQuorum if the replica set doesn't have enough members.
+	CommitQuorum any
+}
+
 // CreateIndex creates an index for a collection with the given field names.
 // Field names are required and must be unique.
 func (m *Collection) CreateIndex(ctx context.Context, isUnique bool, fieldNames ...string) error {
+	return m.CreateIndexWithOptions(ctx, IndexOptions{Unique: isUnique}, fieldNames...)
+}
+
+// CreateIndexWithOptions creates an index for a collection with the given field names and options.
+// Field names are required and must be unique.
+func (m *Collection) CreateIndexWithOptions(ctx context.Context, opts IndexOptions, fieldNames ...string) error {
 	if len(fieldNames) == 0 {
 		return fmt.Errorf("%w: no field names provided", ErrInvalidArgument)
 	}
 
+	indexOpts := collate(m.collation, options.Index().SetUnique(opts.Unique).SetName(m.indexName(opts, fieldNames)))
+	if len(opts.PartialFilter) > 0 {
+		indexOpts.SetPartialFilterExpression(opts.PartialFilter.Prepare())
+	}
+	lang.IfF(opts.Hidden, func() { indexOpts.SetHidden(true) })
+
+	createOpts := options.CreateIndexes()
+	switch quorum := opts.CommitQuorum.(type) {
+	case nil:
+	case int:
+		createOpts.SetCommitQuorumInt(int32(quorum))
+	case int32:
+		createOpts.SetCommitQuorumInt(quorum)
+	case string:
+		lang.IfF(quorum != "", func() { createOpts.SetCommitQuorumString(quorum) })
+	default:
+		return fmt.Errorf("%w: commit quorum must be int or string, got %T", ErrInvalidArgument, opts.CommitQuorum)
+	}
+
 	indexModel := mongo.IndexModel{
-		Options: options.Index().SetUnique(isUnique).SetName(
-			m.coll.Name() + "_" + strings.Join(fieldNames, "_") + lang.If(isUnique, "_unique", "") + "_index"),
+		Keys:    indexKeys(fieldNames),
+		Options: indexOpts,
 	}
 
-	keys := make(bson.D, 0, len(fieldNames))
-	for _, field := range fieldNames {
-		keys = append(keys, bson.E{
-			Key:   field,
-			Value: 1,
-		})
+	_, err := m.coll.Indexes().CreateOne(ctx, indexModel, createOpts)
+	err = HandleMongoError(err)
+	if errors.Is(err, ErrUnsatisfiableCommitQuorum) {
+		return fmt.Errorf("%w: replica set doesn't have enough members for commit quorum %v", err, opts.CommitQuorum)
 	}
-	indexModel.Keys = keys
+	return err
+}
 
-	if _, err := m.coll.Indexes().CreateOne(ctx, indexModel); err != nil {
+// SetIndexHidden hides the index with the provided name from the query planner or exposes it back using collMod
+// command. A hidden index is still maintained on writes, so it can be exposed at once without rebuilding.
+// It returns ErrIndexNotFound if there is no such index.
+func (m *Collection) SetIndexHidden(ctx context.Context, name string, hidden bool) error {
+	if name == "" {
+		return fmt.Errorf("%w: empty index name", ErrInvalidArgument)
+	}
+	cmd := bson.D{
+		{Key: "collMod", Value: m.coll.Name()},
+		{Key: "index", Value: bson.D{{Key: "name", Value: name}, {Key: "hidden", Value: hidden}}},
+	}
+	if err := m.coll.Database().RunCommand(ctx, cmd).Err(); err != nil {
 		return HandleMongoError(err)
 	}
-
 	return nil
 }
 
+// CreateIndexIdempotent creates an index like CreateIndexWithOptions, but it is safe to call it many times, e.g. in migrations.
+// It returns nil if an identical index already exists. If an index with the same name or the same keys
+// already exists with different options, it returns ErrIndexOptionsConflict or ErrIndexKeySpecsConflict
+// with the names of the differing options, e.g. "unique" or "partial filter".
+func (m *Collection) CreateIndexIdempotent(ctx context.Context, opts IndexOptions, fieldNames ...string) error {
+	err := m.CreateIndexWithOptions(ctx, opts, fieldNames...)
+	if err == nil || !(errors.Is(err, ErrIndexOptionsConflict) ||
+		errors.Is(err, ErrIndexKeySpecsConflict) ||
+		errors.Is(err, ErrIndexAlreadyExists)) {
+		return err
+	}
+
+	existing, err2 := m.listIndexSpecs(ctx)
+	if err2 != nil {
+		return errors.Join(err, err2)
+	}
+
+	name := m.indexName(opts, fieldNames)
+	keys := indexKeys(fieldNames)
+	for _, spec := range existing {
+		if spec.Name != name && !spec.hasKeys(keys) {
+			continue
+		}
+		diff := spec.diff(name, keys, opts)
+		if len(diff) == 0 {
+			return nil
+		}
+		sentinel := lang.If(spec.hasKeys(keys), ErrIndexOptionsConflict, ErrIndexKeySpecsConflict)
+		return fmt.Errorf("%w: index %q already exists with different %s", sentinel, spec.Name, strings.Join(diff, ", "))
+	}
+
+	return err
+}
+
+// CreatePartialUniqueIndex creates a unique index for the field that applies only to documents matching the filter.
+// E.g. CreatePartialUniqueIndex(ctx, "email", mongox.M{"deleted": false}) makes email unique among non-deleted documents.
+// It returns ErrInvalidArgument if the filter is empty.
+func (m *Collection) CreatePartialUniqueIndex(ctx context.Context, field string, filter M) error {
+	if len(filter) == 0 {
+		return fmt.Errorf("%w: empty partial filter", ErrInvalidArgument)
+	}
+	return m.CreateIndexWithOptions(ctx, IndexOptions{Unique: true, PartialFilter: filter}, field)
+}
+
 // CreateTextIndex creates a text index for a collection with the given field names and language code.
 // You should create a text index to use text search. Field names are required and must be unique.
 // If the language code is not provided, "en" will be used by default.
@@ -113,11 +556,101 @@ func (m *Collection) CreateTextIndex(ctx context.Context, languageCode string, f
 	return nil
 }
 
+// DropTextIndex drops the text index of the collection, there can be only one text index per collection.
+// Use it to recreate the text index with another language or fields, because CreateTextIndex
+// returns a conflict error while the old text index exists.
+// It returns ErrIndexNotFound if the collection has no text index.
+func (m *Collection) DropTextIndex(ctx context.Context) error {
+	specs, err := m.listIndexSpecs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if !spec.isText() {
+			continue
+		}
+		if err := m.coll.Indexes().DropOne(ctx, spec.Name); err != nil {
+			return HandleMongoError(err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: no text index in collection %q", ErrIndexNotFound, m.coll.Name())
+}
+
+// SetValidator sets or replaces the JSON schema validator of the existing collection using collMod command.
+// Validator is a JSON schema, e.g. mongox.M{"bsonType": "object", "required": []string{"name"}},
+// it will be wrapped into {$jsonSchema: validator} if it doesn't contain $jsonSchema key already.
+// Nil validator removes validation rules from the collection.
+// Level is one of "off", "strict" or "moderate", action is one of "error" or "warn".
+// Empty level and action means server defaults ("strict" and "error").
+func (m *Collection) SetValidator(ctx context.Context, validator M, level, action string) error {
+	if level != "" && !validationLevels[level] {
+		return fmt.Errorf("%w: unsupported validation level %q", ErrInvalidArgument, level)
+	}
+	if action != "" && !validationActions[action] {
+		return fmt.Errorf("%w: unsupported validation action %q", ErrInvalidArgument, action)
+	}
+
+	schema := M{}
+	if _, ok := validator[JsonSchema]; ok {
+		schema = validator
+	} else if len(validator) > 0 {
+		schema = M{JsonSchema: validator}
+	}
+
+	cmd := bson.D{
+		{Key: "collMod", Value: m.coll.Name()},
+		{Key: "validator", Value: schema},
+	}
+	if level != "" {
+		cmd = append(cmd, bson.E{Key: "validationLevel", Value: level})
+	}
+	if action != "" {
+		cmd = append(cmd, bson.E{Key: "validationAction", Value: action})
+	}
+
+	if err := m.coll.Database().RunCommand(ctx, cmd).Err(); err != nil {
+		return HandleMongoError(err)
+	}
+
+	return nil
+}
+
+// Compact runs compact command on the collection to release unused disk space to the operating system,
+// e.g. after an archival job deleted many documents, because WiredTiger doesn't return space by itself.
+// Compact may block other operations on the collection (before MongoDB 4.4 it blocks the whole database),
+// so run it in a maintenance window. It can take a long time on big collections, use the context to limit it.
+// It requires the compact privilege action on the collection, e.g. hostManager role, and returns
+// ErrUnauthorized without it. It returns ErrCommandNotSupported if the deployment doesn't support compact,
+// e.g. some managed services, and ErrNamespaceNotFound if the collection doesn't exist.
+func (m *Collection) Compact(ctx context.Context) error {
+	cmd := bson.D{{Key: "compact", Value: m.coll.Name()}}
+	err := HandleMongoError(m.coll.Database().RunCommand(ctx, cmd).Err())
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrUnauthorized):
+		return fmt.Errorf("%w: compact requires the compact privilege action on the collection", err)
+	case errors.Is(err, ErrCommandNotSupported):
+		return fmt.Errorf("%w: compact is not supported by the deployment", err)
+	case errors.Is(err, ErrCommandNotFound):
+		return fmt.Errorf("%w: compact is not supported by the deployment: %w", ErrCommandNotSupported, err)
+	}
+	return err
+}
+
 // FindOne finds a one document in the collection using filter.
 // It returns ErrNotFound if NO document is found.
 // Limit and AllowDiskUse options are no-op.
 func (m *Collection) FindOne(ctx context.Context, dest any, filter M, rawOpts ...FindOptions) error {
-	res := m.coll.FindOne(ctx, filter.Prepare(), setFindOneOptions(rawOpts...))
+	ctx, done := m.start(ctx, "find_one", filter)
+	defer done()
+
+	opts := setFindOneOptions(rawOpts...)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	res := m.coll.FindOne(ctx, filter.Prepare(), collate(m.collation, opts))
 	if err := res.Err(); err != nil {
 		return HandleMongoError(err)
 	}
@@ -127,22 +660,81 @@ func (m *Collection) FindOne(ctx context.Context, dest any, filter M, rawOpts ..
 	return nil
 }
 
+// FindByID finds a document in the collection by the id field of the collection, see [CollectionOptions].
+// It returns ErrNotFound if NO document is found.
+func (m *Collection) FindByID(ctx context.Context, dest any, id any, opts ...FindOptions) error {
+	return m.FindOne(ctx, dest, m.IDFilter(id), opts...)
+}
+
 // Find finds many documents in the collection using filter.
+// Dest must be a pointer to a slice of structs, maps, bson.M, bson.D, bson.Raw or pointers to them,
+// e.g. *[]User or *[]*User. Elements of a slice of pointers are always newly allocated, so decoding into
+// a reused slice doesn't overwrite documents the caller still holds.
 // It does NOT return any error if no document is found.
 func (m *Collection) Find(ctx context.Context, dest any, filter M, opts ...FindOptions) error {
+	ctx, done := m.start(ctx, "find", filter)
+	defer done()
+
 	return m.find(ctx, dest, filter.Prepare(), opts...)
 }
 
+// FindAfterClusterTime finds many documents like Find, but the server reads data at least as recent as afterTime,
+// waiting for the replication to catch up if needed. Pass the operation time of a write
+// (mongo.SessionFromContext(sctx).OperationTime() after the write made with sctx) to another service,
+// so its reads observe the write even from a secondary (monotonic reads across service boundaries).
+// It returns ErrInvalidArgument if afterTime is zero and ErrCausalConsistencyUnsupported if the server is standalone.
+// It does NOT return any error if no document is found.
+func (m *Collection) FindAfterClusterTime(ctx context.Context, dest any, filter M, afterTime bson.Timestamp, opts ...FindOptions) error {
+	if afterTime.IsZero() {
+		return fmt.Errorf("%w: zero cluster time", ErrInvalidArgument)
+	}
+	client := m.coll.Database().Client()
+	isReplicaSet, err := queryReplicaSet(ctx, client)
+	if err != nil {
+		return err
+	}
+	if !isReplicaSet {
+		return ErrCausalConsistencyUnsupported
+	}
+
+	session, err := client.StartSession(options.Session().SetCausalConsistency(true))
+	if err != nil {
+		return HandleMongoError(err)
+	}
+	defer session.EndSession(ctx)
+
+	// The driver sends the operation time of a causally consistent session as afterClusterTime of the read concern
+	if err := session.AdvanceOperationTime(&afterTime); err != nil {
+		return HandleMongoError(err)
+	}
+	return m.Find(mongo.NewSessionContext(ctx, session), dest, filter, opts...)
+}
+
 // FindAll finds all documents in the collection.
 // It does NOT return any error if no document is found.
 func (m *Collection) FindAll(ctx context.Context, dest any, opts ...FindOptions) error {
+	ctx, done := m.start(ctx, "find_all", nil)
+	defer done()
+
 	return m.find(ctx, dest, bson.D{}, opts...)
 }
 
 // FindOneAndDelete finds a document in the collection using filter and deletes it.
+// Use Sort option to choose which document is deleted if many documents match the filter.
+// Upsert and ReturnUpdated options are no-op.
 // It returns ErrNotFound if no document is found.
-func (m *Collection) FindOneAndDelete(ctx context.Context, dest any, filter M) error {
-	res := m.coll.FindOneAndDelete(ctx, filter.Prepare())
+func (m *Collection) FindOneAndDelete(ctx context.Context, dest any, filter M, rawOpts ...FindOneAndOptions) error {
+	ctx, done := m.start(ctx, "find_one_and_delete", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "FindOneAndDelete", filter); err != nil {
+		return err
+	}
+	opts := setFindOneAndDeleteOptions(rawOpts...)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	res := m.coll.FindOneAndDelete(ctx, filter.Prepare(), collate(m.collation, opts))
 	if err := res.Err(); err != nil {
 		return HandleMongoError(err)
 	}
@@ -153,9 +745,20 @@ func (m *Collection) FindOneAndDelete(ctx context.Context, dest any, filter M) e
 }
 
 // FindOneAndReplace finds a document in the collection using filter and replaces it.
+// It decodes the original document into dest, use ReturnUpdated option to get the replaced one.
 // It returns ErrNotFound if no document is found.
-func (m *Collection) FindOneAndReplace(ctx context.Context, dest any, filter M, replacement any) error {
-	res := m.coll.FindOneAndReplace(ctx, filter.Prepare(), replacement)
+func (m *Collection) FindOneAndReplace(ctx context.Context, dest any, filter M, replacement any, rawOpts ...FindOneAndOptions) error {
+	ctx, done := m.start(ctx, "find_one_and_replace", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "FindOneAndReplace", filter); err != nil {
+		return err
+	}
+	opts := setFindOneAndReplaceOptions(rawOpts...)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	res := m.coll.FindOneAndReplace(ctx, filter.Prepare(), replacement, collate(m.collation, opts))
 	if err := res.Err(); err != nil {
 		return HandleMongoError(err)
 	}
@@ -165,10 +768,77 @@ func (m *Collection) FindOneAndReplace(ctx context.Context, dest any, filter M,
 	return nil
 }
 
+// ReplaceOrInsert replaces a document matching the filter with doc or inserts doc if there is no such document,
+// and decodes the stored document into dest. It is the "PUT" semantics of a REST resource in one atomic call.
+// It returns true if a new document is inserted and false if an existing one is replaced.
+// It returns ErrInvalidArgument if doc contains update operators.
+func (m *Collection) ReplaceOrInsert(ctx context.Context, dest any, filter M, doc any) (bool, error) {
+	ctx, done := m.start(ctx, "replace_or_insert", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "ReplaceOrInsert", filter); err != nil {
+		return false, err
+	}
+	replacement, err := m.marshal(doc)
+	if err != nil {
+		return false, HandleMongoError(err)
+	}
+	elems, err := replacement.Elements()
+	if err != nil {
+		return false, HandleMongoError(err)
+	}
+	for _, elem := range elems {
+		if strings.HasPrefix(elem.Key(), "$") {
+			return false, fmt.Errorf("%w: replacement document must not contain update operators", ErrInvalidArgument)
+		}
+	}
+
+	// findAndModify command returns lastErrorObject, so it is known whether the document is inserted,
+	// driver's FindOneAndReplace doesn't expose it
+	cmd := bson.D{
+		{Key: "findAndModify", Value: m.coll.Name()},
+		{Key: "query", Value: filter.Prepare()},
+		{Key: "update", Value: replacement},
+		{Key: "upsert", Value: true},
+		{Key: "new", Value: true},
+	}
+	if comment := m.commentFor(ctx); comment != "" {
+		cmd = append(cmd, bson.E{Key: "comment", Value: comment})
+	}
+	if m.collation != nil {
+		cmd = append(cmd, bson.E{Key: "collation", Value: m.collation})
+	}
+
+	var res struct {
+		LastErrorObject struct {
+			UpdatedExisting bool `bson:"updatedExisting"`
+		} `bson:"lastErrorObject"`
+		Value bson.Raw `bson:"value"`
+	}
+	if err := m.coll.Database().RunCommand(ctx, cmd).Decode(&res); err != nil {
+		return false, HandleMongoError(err)
+	}
+	if err := m.unmarshal(res.Value, dest); err != nil {
+		return false, HandleMongoError(err)
+	}
+	return !res.LastErrorObject.UpdatedExisting, nil
+}
+
 // FindOneAndUpdate finds a document in the collection using filter and updates it.
+// It decodes the original document into dest, use ReturnUpdated option to get the updated one.
 // It returns ErrNotFound if no document is found.
-func (m *Collection) FindOneAndUpdate(ctx context.Context, dest any, filter M, update any) error {
-	res := m.coll.FindOneAndUpdate(ctx, filter.Prepare(), update)
+func (m *Collection) FindOneAndUpdate(ctx context.Context, dest any, filter M, update any, rawOpts ...FindOneAndOptions) error {
+	ctx, done := m.start(ctx, "find_one_and_update", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "FindOneAndUpdate", filter); err != nil {
+		return err
+	}
+	opts := setFindOneAndUpdateOptions(rawOpts...)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	res := m.coll.FindOneAndUpdate(ctx, filter.Prepare(), update, collate(m.collation, opts))
 	if err := res.Err(); err != nil {
 		return HandleMongoError(err)
 	}
@@ -181,7 +851,33 @@ func (m *Collection) FindOneAndUpdate(ctx context.Context, dest any, filter M, u
 // Count counts the number of documents in the collection using filter.
 // Nil filter means count all documents.
 func (m *Collection) Count(ctx context.Context, filter M) (int64, error) {
-	count, err := m.coll.CountDocuments(ctx, filter.Prepare())
+	ctx, done := m.start(ctx, "count", filter)
+	defer done()
+
+	opts := options.Count()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	count, err := m.coll.CountDocuments(ctx, filter.Prepare(), collate(m.collation, opts))
+	if err != nil {
+		return 0, HandleMongoError(err)
+	}
+	return count, nil
+}
+
+// EstimatedCount returns the number of documents in the collection from the collection metadata,
+// without scanning documents, so it is fast on huge collections unlike Count with nil filter.
+// The estimate may be inaccurate after an unclean shutdown until the collection is validated,
+// and in sharded clusters it can include orphaned documents. Use Count for an accurate or filtered count.
+func (m *Collection) EstimatedCount(ctx context.Context) (int64, error) {
+	ctx, done := m.start(ctx, "estimated_count", nil)
+	defer done()
+
+	opts := options.EstimatedDocumentCount()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	count, err := m.coll.EstimatedDocumentCount(ctx, opts)
 	if err != nil {
 		return 0, HandleMongoError(err)
 	}
@@ -190,12 +886,37 @@ func (m *Collection) Count(ctx context.Context, filter M) (int64, error) {
 
 // Distinct finds distinct values for the specified field in the collection using filter.
 func (m *Collection) Distinct(ctx context.Context, dest any, field string, filter M) error {
+	ctx, done := m.start(ctx, "distinct", filter)
+	defer done()
+
+	return m.distinct(ctx, dest, field, filter, DistinctOptions{})
+}
+
+// DistinctWithOptions finds distinct values for the specified field in the collection using filter like Distinct,
+// but allows to set an index hint and a time limit of the operation, e.g. to make Distinct a covered query
+// on a big collection. It returns ErrMaxTimeMSExpired if the operation takes longer than MaxTime.
+func (m *Collection) DistinctWithOptions(ctx context.Context, dest any, field string, filter M, opts DistinctOptions) error {
+	ctx, done := m.start(ctx, "distinct_with_options", filter)
+	defer done()
+
+	return m.distinct(ctx, dest, field, filter, opts)
+}
+
+func (m *Collection) distinct(ctx context.Context, dest any, field string, filter M, rawOpts DistinctOptions) error {
 	if field == "" {
 		return fmt.Errorf("%w: no field name provided", ErrInvalidArgument)
 	}
-	res := m.coll.Distinct(ctx, field, filter.Prepare())
+	opts := options.Distinct()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+	lang.IfF(rawOpts.Hint != nil, func() { opts.SetHint(rawOpts.Hint) })
+
+	opCtx, cancel := withMaxTime(ctx, rawOpts.MaxTime)
+	defer cancel()
+
+	res := m.coll.Distinct(opCtx, field, filter.Prepare(), collate(m.collation, opts))
 	if err := res.Err(); err != nil {
-		return HandleMongoError(err)
+		return maxTimeError(ctx, rawOpts.MaxTime, HandleMongoError(err))
 	}
 	if err := res.Decode(dest); err != nil {
 		return HandleMongoError(err)
@@ -203,87 +924,491 @@ func (m *Collection) Distinct(ctx context.Context, dest any, field string, filte
 	return nil
 }
 
-// InsertOne inserts a document into the collection.
-// It returns ID of the inserted document.
-// If isStrictID is true, it will return an error if the inserted ID is not an ObjectID.
-// It returns ErrInternal if no inserted ID is returned.
-// If you provide your own ID, it is assumed you already know it, so it will not be returned.
-func (m *Collection) InsertOne(ctx context.Context, record any, isStrictID ...bool) (id bson.ObjectID, err error) {
-	ids, err := m.InsertMany(ctx, []any{record}, isStrictID...)
+// Aggregate executes an aggregation pipeline and decodes all resulting documents into dest.
+// Use [PipelineBuilder] to create the pipeline. It does NOT return any error if no document is returned.
+// It returns ErrQueryExceededMemoryLimitNoDiskUseAllowed if a stage exceeds the memory limit
+// and writing to temporary files on disk is not allowed, set AllowDiskUse option to fix it.
+// It returns ErrMaxTimeMSExpired if the aggregation takes longer than MaxTime option.
+func (m *Collection) Aggregate(ctx context.Context, dest any, pipeline []M, rawOpts ...AggregateOptions) error {
+	ctx, done := m.start(ctx, "aggregate", nil)
+	defer done()
+
+	opts := setAggregateOptions(rawOpts...)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	maxTime := aggregateMaxTime(rawOpts...)
+	opCtx, cancel := withMaxTime(ctx, maxTime)
+	defer cancel()
+
+	cur, err := m.coll.Aggregate(opCtx, preparePipeline(pipeline), collate(m.collation, opts))
 	if err != nil {
-		return bson.ObjectID{}, err
+		return maxTimeError(ctx, maxTime, handleAggregateError(err))
 	}
-	if len(ids) == 0 {
-		return bson.ObjectID{}, fmt.Errorf("%w: no inserted ID", ErrInternal)
+	defer cur.Close(ctx)
+
+	resetPointerSlice(dest)
+	if err := cur.All(opCtx, dest); err != nil {
+		return maxTimeError(ctx, maxTime, handleAggregateError(err))
 	}
-	return ids[0], nil
-}
 
-// Insert inserts a document or many documents into the collection.
-// It returns IDs of the inserted documents. Internally InsertMany uses bulk write.
-// It NOT returns an error if inserted IDs are not ObjectID, so it is NOT strict.
-// If inserted ID is not an ObjectID, it will be returned as empty bson.ObjectID.
-// If you provide your own ID, it is assumed you already know it, so it will not be returned.
-func (m *Collection) Insert(ctx context.Context, records ...any) (ids []bson.ObjectID, err error) {
-	return m.InsertMany(ctx, records)
+	return nil
 }
 
-// InsertStrict inserts a document or many documents into the collection.
-// It returns IDs of the inserted documents. Internally InsertMany uses bulk write.
-// It returns an error if inserted IDs are not ObjectID.
-func (m *Collection) InsertStrict(ctx context.Context, records ...any) (ids []bson.ObjectID, err error) {
-	return m.InsertMany(ctx, records, true)
-}
+// AggregateEach executes an aggregation pipeline and calls fn for every resulting document one at a time.
+// Call decode inside fn to decode the current document into a pointer, e.g. decode(&row).
+// It is useful for pipelines with large output that shouldn't be loaded into memory at once,
+// use BatchSize option to tune the number of documents in a batch returned by the server.
+// Next batches are requested with getMore when the current one is exhausted, so all results are iterated.
+// If the server kills the cursor between batches, e.g. because it was idle too long while fn was slow,
+// it returns ErrCursorNotFound, it is retryable (see [IsRetryableRead]) and the aggregation can be restarted.
+// Iteration stops when fn returns an error and this error is returned. The cursor is always closed.
+func (m *Collection) AggregateEach(ctx context.Context, pipeline []M, fn func(decode func(any) error) error, rawOpts ...AggregateOptions) error {
+	ctx, done := m.start(ctx, "aggregate_each", nil)
+	defer done()
 
-// InsertMany inserts many documents into the collection.
-// It returns IDs of the inserted documents.
-// Internally InsertMany uses bulk write.
-// If isStrictID is true, it will return an error if the inserted ID is not an ObjectID.
-// If isStrictID is false and if inserted ID is not an ObjectID, it will be returned as empty bson.ObjectID.
-// If you provide your own ID, it is assumed you already know it, so it will not be returned.
-func (m *Collection) InsertMany(ctx context.Context, records []any, isStrictID ...bool) (ids []bson.ObjectID, err error) {
-	if len(records) == 0 {
-		return nil, nil
+	if fn == nil {
+		return fmt.Errorf("%w: nil callback", ErrInvalidArgument)
 	}
 
-	ids = make([]bson.ObjectID, len(records))
-	var ok bool
-	if len(records) == 1 {
-		res, err := m.coll.InsertOne(ctx, records[0])
-		if err != nil {
-			return nil, HandleMongoError(err)
-		}
-		ids[0], ok = res.InsertedID.(bson.ObjectID)
-		if !ok && len(isStrictID) > 0 && isStrictID[0] {
-			return nil, fmt.Errorf("%w: expected ObjectID, got %T, %v", ErrInvalidArgument, res.InsertedID, res.InsertedID)
-		}
+	opts := setAggregateOptions(rawOpts...)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
 
-	} else {
-		var errs []string
-		res, err := m.coll.InsertMany(ctx, records)
-		if err != nil {
-			return nil, HandleMongoError(err)
-		}
-		for i, id := range res.InsertedIDs {
-			ids[i], ok = id.(bson.ObjectID)
-			if !ok && len(isStrictID) > 0 && isStrictID[0] {
-				errs = append(errs, fmt.Sprintf("expected ObjectID, got %T, %v", id, id))
-			}
-		}
-		if len(errs) > 0 {
-			return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, strings.Join(errs, ", "))
+	maxTime := aggregateMaxTime(rawOpts...)
+	opCtx, cancel := withMaxTime(ctx, maxTime)
+	defer cancel()
+
+	cur, err := m.coll.Aggregate(opCtx, preparePipeline(pipeline), collate(m.collation, opts))
+	if err != nil {
+		return maxTimeError(ctx, maxTime, handleAggregateError(err))
+	}
+	defer cur.Close(ctx)
+
+	decode := func(dest any) error {
+		return HandleMongoError(cur.Decode(dest))
+	}
+	for cur.Next(opCtx) {
+		if err := fn(decode); err != nil {
+			return err
 		}
 	}
-	return ids, nil
+
+	if err := cur.Err(); err != nil {
+		return maxTimeError(ctx, maxTime, handleAggregateError(err))
+	}
+
+	return nil
 }
 
-// Upsert replaces a document in the collection or inserts it if it doesn't exist.
-// It returns ID of the interserted document.
-// If existing document is updated (no new inserted), it returns nil ID and nil error.
+// Sample decodes up to size random documents matching the filter into dest using $match and $sample stages,
+// e.g. to pick users for an A/B test, without fetching all documents and shuffling them. Nil filter means
+// all documents. The server reads documents randomly only if $sample is the first stage and size is less than
+// 5% of the collection, with a filter it reads all matching documents and sorts them randomly, so a not selective
+// filter leads to a scan of the collection. The same document is never returned twice in one call.
+// It returns ErrInvalidArgument if size is not positive.
+func (m *Collection) Sample(ctx context.Context, dest any, size int, filter M) error {
+	ctx, done := m.start(ctx, "sample", filter)
+	defer done()
+
+	if size <= 0 {
+		return fmt.Errorf("%w: sample size must be positive, got %d", ErrInvalidArgument, size)
+	}
+	pipeline := make([]M, 0, 2)
+	lang.IfF(len(filter) > 0, func() { pipeline = append(pipeline, M{StageMatch: filter}) })
+	pipeline = append(pipeline, M{StageSample: M{"size": size}})
+
+	opts := options.Aggregate()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	cur, err := m.coll.Aggregate(ctx, preparePipeline(pipeline), collate(m.collation, opts))
+	if err != nil {
+		return handleAggregateError(err)
+	}
+	defer cur.Close(ctx)
+
+	resetPointerSlice(dest)
+	if err := cur.All(ctx, dest); err != nil {
+		return handleAggregateError(err)
+	}
+	return nil
+}
+
+// CountPipeline executes an aggregation pipeline with an additional $count stage and returns the number
+// of documents the pipeline yields, e.g. the number of groups after $group, without decoding all of them.
+// It returns 0 if the pipeline yields nothing. The provided pipeline is not modified.
+func (m *Collection) CountPipeline(ctx context.Context, pipeline []M) (int64, error) {
+	ctx, done := m.start(ctx, "count_pipeline", nil)
+	defer done()
+
+	countPipeline := make([]M, 0, len(pipeline)+1)
+	countPipeline = append(countPipeline, pipeline...)
+	countPipeline = append(countPipeline, M{StageCount: "n"})
+
+	opts := options.Aggregate()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	cur, err := m.coll.Aggregate(ctx, preparePipeline(countPipeline), collate(m.collation, opts))
+	if err != nil {
+		return 0, handleAggregateError(err)
+	}
+	defer cur.Close(ctx)
+
+	var res []struct {
+		N int64 `bson:"n"`
+	}
+	if err := cur.All(ctx, &res); err != nil {
+		return 0, handleAggregateError(err)
+	}
+	if len(res) == 0 {
+		return 0, nil
+	}
+	return res[0].N, nil
+}
+
+// WatchInserts opens a change stream on the collection and calls fn with the inserted document for every insert.
+// It blocks until ctx is canceled, fn returns an error or the stream fails. Cancellation of ctx is not an error,
+// so it returns nil in that case. The collection timeout is not applied, because the stream is long-running.
+// Change streams are available only for replica sets and sharded clusters.
+// Set WatchOptions.MaxRetries to reconnect after transient errors, e.g. during failover.
+func (m *Collection) WatchInserts(ctx context.Context, fn func(doc bson.Raw) error, opts ...WatchOptions) error {
+	return m.watchOperation(ctx, "insert", "fullDocument", fn, opts, "insert")
+}
+
+// WatchUpdates opens a change stream on the collection and calls fn with the current version of the document
+// for every update and replace. Full document lookup is enabled, so the document is fetched after the update;
+// it is nil if the document was deleted before the lookup. It blocks until ctx is canceled, fn returns an error
+// or the stream fails. Change streams are available only for replica sets and sharded clusters.
+// Set WatchOptions.MaxRetries to reconnect after transient errors, e.g. during failover.
+func (m *Collection) WatchUpdates(ctx context.Context, fn func(doc bson.Raw) error, opts ...WatchOptions) error {
+	return m.watchOperation(ctx, "update", "fullDocument", fn, opts, "update", "replace")
+}
+
+// WatchDeletes opens a change stream on the collection and calls fn with the key of every deleted document
+// (e.g. {"_id": ...}), because deleted documents are not available in the change stream. It blocks until ctx
+// is canceled, fn returns an error or the stream fails. Change streams are available only for replica sets
+// and sharded clusters. Set WatchOptions.MaxRetries to reconnect after transient errors, e.g. during failover.
+func (m *Collection) WatchDeletes(ctx context.Context, fn func(doc bson.Raw) error, opts ...WatchOptions) error {
+	return m.watchOperation(ctx, "delete", "documentKey", fn, opts, "delete")
+}
+
+// Watch opens a change stream on the collection and calls fn for every change event passed through the pipeline,
+// e.g. []M{{"$match": M{"operationType": "insert"}}}. Nil pipeline means all events. It blocks until ctx is canceled,
+// fn returns an error or the stream fails. Cancellation of ctx is not an error, so it returns nil in that case.
+// Save ChangeEvent.ResumeToken and pass it to WatchOptions.ResumeAfter to continue after a restart.
+// Change streams are available only for replica sets and sharded clusters.
+// Set WatchOptions.MaxRetries to reconnect after transient errors, e.g. during failover.
+func (m *Collection) Watch(ctx context.Context, pipeline []M, fn func(event ChangeEvent) error, opts ...WatchOptions) error {
+	if fn == nil {
+		return fmt.Errorf("%w: nil callback", ErrInvalidArgument)
+	}
+	csOpts := options.ChangeStream()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { csOpts.SetComment(comment) })
+
+	return watchChanges(ctx, m.coll, preparePipeline(pipeline), csOpts, opts, func(cur *mongo.ChangeStream) error {
+		event, err := decodeChangeEvent(cur)
+		if err != nil {
+			return err
+		}
+		return fn(event)
+	})
+}
+
+func (m *Collection) watchOperation(ctx context.Context, op, docField string, fn func(doc bson.Raw) error, rawOpts []WatchOptions, ops ...string) error {
+	if fn == nil {
+		return fmt.Errorf("%w: nil callback", ErrInvalidArgument)
+	}
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.D{
+		{Key: "operationType", Value: bson.D{{Key: In, Value: ops}}},
+	}}}}
+
+	opts := options.ChangeStream()
+	lang.IfF(op == "update", func() { opts.SetFullDocument(options.UpdateLookup) })
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	return watchChanges(ctx, m.coll, pipeline, opts, rawOpts, func(cur *mongo.ChangeStream) error {
+		doc, _ := cur.Current.Lookup(docField).DocumentOK()
+		return fn(doc)
+	})
+}
+
+// changeStreamer is a source of change streams, it is implemented by [mongo.Collection] and [mongo.Database].
+type changeStreamer interface {
+	Watch(ctx context.Context, pipeline any, opts ...options.Lister[options.ChangeStreamOptions]) (*mongo.ChangeStream, error)
+}
+
+// watchChanges calls fn for every event of the change stream and reopens the stream from the resume token
+// of the last processed event after transient errors, up to WatchOptions.MaxRetries times in a row.
+func watchChanges(ctx context.Context, w changeStreamer, pipeline any, opts *options.ChangeStreamOptionsBuilder,
+	rawOpts []WatchOptions, fn func(cur *mongo.ChangeStream) error) error {
+
+	var (
+		maxRetries  int
+		resumeToken bson.Raw
+	)
+	if len(rawOpts) > 0 {
+		maxRetries = rawOpts[0].MaxRetries
+		resumeToken = rawOpts[0].ResumeAfter
+		lang.IfF(rawOpts[0].FullDocument != "", func() { opts.SetFullDocument(rawOpts[0].FullDocument) })
+	}
+
+	for attempt := 0; ; {
+		lang.IfF(resumeToken != nil, func() { opts.SetResumeAfter(resumeToken) })
+
+		processed, err := watchStream(ctx, w, pipeline, opts, fn, &resumeToken)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			return nil
+		}
+		var fnErr watchCallbackError
+		if errors.As(err, &fnErr) {
+			return fnErr.err
+		}
+		lang.IfF(processed, func() { attempt = 0 })
+		if !IsTransient(err) || attempt >= maxRetries {
+			return err
+		}
+		attempt++
+		if err := waitReconnect(ctx, attempt); err != nil {
+			return nil
+		}
+	}
+}
+
+// watchCallbackError is an error returned by the callback of the change stream, it is never retried.
+type watchCallbackError struct{ err error }
+
+func (e watchCallbackError) Error() string { return e.err.Error() }
+
+// watchStream opens the change stream and calls fn for every event until the stream fails.
+// It saves the resume token of every processed event and reports whether any event is processed.
+func watchStream(ctx context.Context, w changeStreamer, pipeline any, opts *options.ChangeStreamOptionsBuilder,
+	fn func(cur *mongo.ChangeStream) error, resumeToken *bson.Raw) (bool, error) {
+
+	stream, err := w.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return false, HandleMongoError(err)
+	}
+	defer stream.Close(context.WithoutCancel(ctx))
+
+	var processed bool
+	for stream.Next(ctx) {
+		if err := fn(stream); err != nil {
+			return processed, watchCallbackError{err: err}
+		}
+		*resumeToken = stream.ResumeToken()
+		processed = true
+	}
+	return processed, HandleMongoError(stream.Err())
+}
+
+// decodeChangeEvent decodes the current event of the change stream.
+func decodeChangeEvent(cur *mongo.ChangeStream) (ChangeEvent, error) {
+	var event ChangeEvent
+	if err := cur.Decode(&event); err != nil {
+		return ChangeEvent{}, HandleMongoError(err)
+	}
+	// Full document is null for deletes and for updates of documents deleted before the lookup
+	event.FullDocument, _ = cur.Current.Lookup("fullDocument").DocumentOK()
+	event.ResumeToken = cur.ResumeToken()
+	return event, nil
+}
+
+// CopyTo copies documents matching the filter into the target collection and returns the number of copied documents.
+// Documents are streamed from the source and inserted into the target in batches, so the target may be
+// in another database. Nil filter means copy all documents. _id is preserved unless RegenerateID option is set,
+// so copying the same document twice returns ErrDuplicate. Documents are not deleted from the source,
+// use it with DeleteMany in a transaction to archive documents.
+func (m *Collection) CopyTo(ctx context.Context, target *Collection, filter M, rawOpts ...CopyOptions) (int, error) {
+	ctx, done := m.start(ctx, "copy_to", filter)
+	defer done()
+
+	if target == nil {
+		return 0, fmt.Errorf("%w: nil target collection", ErrInvalidArgument)
+	}
+	var opts CopyOptions
+	if len(rawOpts) > 0 {
+		opts = rawOpts[0]
+	}
+	batchSize := lang.Check(opts.BatchSize, DefaultCopyBatchSize)
+
+	findOpts := options.Find().SetBatchSize(int32(batchSize))
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { findOpts.SetComment(comment) })
+
+	cur, err := m.coll.Find(ctx, filter.Prepare(), collate(m.collation, findOpts))
+	if err != nil {
+		return 0, HandleMongoError(err)
+	}
+	defer cur.Close(ctx)
+
+	var copied int
+	batch := make([]any, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := target.coll.InsertMany(ctx, batch); err != nil {
+			return HandleMongoError(err)
+		}
+		copied += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for cur.Next(ctx) {
+		doc, err := copyDocument(cur.Current, opts.RegenerateID)
+		if err != nil {
+			return copied, HandleMongoError(err)
+		}
+		batch = append(batch, doc)
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return copied, err
+			}
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return copied, HandleMongoError(err)
+	}
+	if err := flush(); err != nil {
+		return copied, err
+	}
+
+	return copied, nil
+}
+
+// InsertOne inserts a document into the collection.
+// It returns ID of the inserted document.
+// If isStrictID is true, it will return an error if the inserted ID is not an ObjectID.
+// It returns ErrInternal if no inserted ID is returned.
+// If you provide your own ID, it is assumed you already know it, so it will not be returned.
+func (m *Collection) InsertOne(ctx context.Context, record any, isStrictID ...bool) (id bson.ObjectID, err error) {
+	ids, err := m.InsertMany(ctx, []any{record}, isStrictID...)
+	if err != nil {
+		return bson.ObjectID{}, err
+	}
+	if len(ids) == 0 {
+		return bson.ObjectID{}, fmt.Errorf("%w: no inserted ID", ErrInternal)
+	}
+	return ids[0], nil
+}
+
+// Insert inserts a document or many documents into the collection.
+// It returns IDs of the inserted documents. Internally InsertMany uses bulk write.
+// It NOT returns an error if inserted IDs are not ObjectID, so it is NOT strict.
+// If inserted ID is not an ObjectID, it will be returned as empty bson.ObjectID.
+// If you provide your own ID, it is assumed you already know it, so it will not be returned.
+func (m *Collection) Insert(ctx context.Context, records ...any) (ids []bson.ObjectID, err error) {
+	return m.InsertMany(ctx, records)
+}
+
+// InsertStrict inserts a document or many documents into the collection.
+// It returns IDs of the inserted documents. Internally InsertMany uses bulk write.
+// It returns an error if inserted IDs are not ObjectID.
+func (m *Collection) InsertStrict(ctx context.Context, records ...any) (ids []bson.ObjectID, err error) {
+	return m.InsertMany(ctx, records, true)
+}
+
+// InsertMany inserts many documents into the collection.
+// It returns IDs of the inserted documents.
+// Internally InsertMany uses bulk write.
+// If isStrictID is true, it will return an error if the inserted ID is not an ObjectID.
+// If isStrictID is false and if inserted ID is not an ObjectID, it will be returned as empty bson.ObjectID.
+// If you provide your own ID, it is assumed you already know it, so it will not be returned.
+func (m *Collection) InsertMany(ctx context.Context, records []any, isStrictID ...bool) (ids []bson.ObjectID, err error) {
+	insertedIDs, err := m.insertMany(ctx, records)
+	if err != nil {
+		return nil, err
+	}
+	if len(insertedIDs) == 0 {
+		return nil, nil
+	}
+
+	var errs []string
+	ids = make([]bson.ObjectID, len(insertedIDs))
+	for i, id := range insertedIDs {
+		var ok bool
+		ids[i], ok = id.(bson.ObjectID)
+		if !ok && len(isStrictID) > 0 && isStrictID[0] {
+			errs = append(errs, fmt.Sprintf("expected ObjectID, got %T, %v", id, id))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, strings.Join(errs, ", "))
+	}
+	return ids, nil
+}
+
+// InsertIgnoreDuplicates inserts many documents into the collection skipping duplicates.
+// Documents are inserted with unordered bulk write, so a duplicate key error doesn't abort the whole batch.
+// It returns number of inserted documents and number of documents skipped because of duplicate key errors.
+// Duplicates are not returned as an error, but any other write error is returned.
+func (m *Collection) InsertIgnoreDuplicates(ctx context.Context, records []any) (inserted int, skipped int, err error) {
+	ctx, done := m.start(ctx, "insert_ignore_duplicates", nil)
+	defer done()
+
+	if len(records) == 0 {
+		return 0, 0, nil
+	}
+	if err := m.checkDocumentSizes(records); err != nil {
+		return 0, 0, err
+	}
+
+	opts := options.InsertMany().SetOrdered(false)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	_, err = m.coll.InsertMany(ctx, records, opts)
+	if err == nil {
+		return len(records), 0, nil
+	}
+
+	var bwe mongo.BulkWriteException
+	if !errors.As(err, &bwe) || bwe.WriteConcernError != nil || len(bwe.WriteErrors) == 0 {
+		return 0, 0, HandleMongoError(err)
+	}
+
+	var failed []mongo.BulkWriteError
+	for _, we := range bwe.WriteErrors {
+		if isDuplicateKeyCode(we.Code) {
+			skipped++
+			continue
+		}
+		failed = append(failed, we)
+	}
+	inserted = len(records) - len(bwe.WriteErrors)
+
+	if len(failed) > 0 {
+		bwe.WriteErrors = failed
+		return inserted, skipped, HandleMongoError(bwe)
+	}
+
+	return inserted, skipped, nil
+}
+
+// Upsert replaces a document in the collection or inserts it if it doesn't exist.
+// It returns ID of the interserted document.
+// If existing document is updated (no new inserted), it returns nil ID and nil error.
 // If no document is updated, it returns nil ID and ErrNotFound.
 func (m *Collection) Upsert(ctx context.Context, record any, filter M) (*bson.ObjectID, error) {
+	ctx, done := m.start(ctx, "upsert", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "Upsert", filter); err != nil {
+		return nil, err
+	}
 	opts := options.Replace().SetUpsert(true)
-	upd, err := m.coll.ReplaceOne(ctx, filter.Prepare(), record, opts)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	upd, err := m.coll.ReplaceOne(ctx, filter.Prepare(), record, collate(m.collation, opts))
 	if err != nil {
 		return nil, HandleMongoError(err)
 	}
@@ -299,10 +1424,74 @@ func (m *Collection) Upsert(ctx context.Context, record any, filter M) (*bson.Ob
 	return nil, nil
 }
 
+// UpsertUpdate updates a document in the collection with update operators or inserts a new one if no document
+// matches the filter. Unlike Upsert, it doesn't replace the whole document, e.g. {$inc: {counter: 1}} increments
+// the counter of an existing document and inserts {filter fields..., counter: 1} if there is no one.
+// Update map/document must contain key beginning with '$', e.g. {$set: {key1: value1}}.
+// It returns the result with Inserted flag and ID of the inserted document, Changed flag is false
+// if the matched document already had the same values.
+func (m *Collection) UpsertUpdate(ctx context.Context, filter, update M) (UpsertResult, error) {
+	ctx, done := m.start(ctx, "upsert_update", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "UpsertUpdate", filter); err != nil {
+		return UpsertResult{}, err
+	}
+	return m.upsertUpdate(ctx, filter.Prepare(), update.Prepare())
+}
+
+// UpsertWithDefaults works like UpsertUpdate, but also sets defaults with $setOnInsert,
+// so they are written only if a new document is inserted and never overwrite fields of an existing document.
+// Defaults should not contain fields of the update, the server returns ErrConflictingUpdateOperators otherwise.
+func (m *Collection) UpsertWithDefaults(ctx context.Context, filter, update, defaults M) (UpsertResult, error) {
+	ctx, done := m.start(ctx, "upsert_with_defaults", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "UpsertWithDefaults", filter); err != nil {
+		return UpsertResult{}, err
+	}
+	if len(defaults) > 0 {
+		update = Update(update, M{SetOnInsert: defaults})
+	}
+	return m.upsertUpdate(ctx, filter.Prepare(), update.Prepare())
+}
+
+func (m *Collection) upsertUpdate(ctx context.Context, filter, update bson.D) (UpsertResult, error) {
+	opts := options.UpdateOne().SetUpsert(true)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	res, err := m.coll.UpdateOne(ctx, filter, update, collate(m.collation, opts))
+	if err != nil {
+		return UpsertResult{}, HandleMongoError(err)
+	}
+	if res == nil {
+		return UpsertResult{}, nil
+	}
+	if res.UpsertedCount == 0 {
+		return UpsertResult{Changed: res.ModifiedCount > 0}, nil
+	}
+	out := UpsertResult{Inserted: true, Changed: true}
+	if id, ok := res.UpsertedID.(bson.ObjectID); ok {
+		out.ID = &id
+	}
+	return out, nil
+}
+
 // ReplaceOne replaces a document in the collection.
 // It returns ErrNotFound if no document is updated.
 func (m *Collection) ReplaceOne(ctx context.Context, record any, filter M) error {
-	upd, err := m.coll.ReplaceOne(ctx, filter.Prepare(), record)
+	ctx, done := m.start(ctx, "replace", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "ReplaceOne", filter); err != nil {
+		return err
+	}
+	opts := options.Replace()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	upd, err := m.coll.ReplaceOne(ctx, filter.Prepare(), record, collate(m.collation, opts))
 	if err != nil {
 		return HandleMongoError(err)
 	}
@@ -314,28 +1503,104 @@ func (m *Collection) ReplaceOne(ctx context.Context, record any, filter M) error
 
 // SetFields sets fields in a document in the collection using updates map.
 // For example: {key1: value1, key2: value2} becomes {$set: {key1: value1, key2: value2}}.
-// It returns ErrNotFound if no document is updated.
-func (m *Collection) SetFields(ctx context.Context, filter, update M) error {
-	return m.updateOne(ctx, filter.Prepare(), lang.If(update != nil, prepareUpdates(update, Set), bson.D{}))
+// It returns ErrNotFound if no document is updated, with UpdateOptions.Upsert it inserts a new document instead
+// and returns its ID. The ID is nil if an existing document is updated or the inserted _id is not an ObjectID.
+func (m *Collection) SetFields(ctx context.Context, filter, update M, opts ...UpdateOptions) (*bson.ObjectID, error) {
+	ctx, done := m.start(ctx, "set_fields", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "SetFields", filter); err != nil {
+		return nil, err
+	}
+	return m.updateOneID(ctx, filter.Prepare(), lang.If(update != nil, prepareUpdates(update, Set), bson.D{}), updateOneOptions(opts)...)
 }
 
 // UpdateOne updates a document in the collection.
 // Update map/document must contain key beginning with '$', e.g. {$set: {key1: value1}}.
 // Modifiers operate on fields. For example: {$mod: {<field>: ...}}.
 // You can use predefined options from mongox, e.g. mongox.M{mongox.Inc: mongox.M{"number": 1}}.
-// It returns ErrNotFound if no document is updated.
-func (m *Collection) UpdateOne(ctx context.Context, filter, update M) error {
-	return m.updateOne(ctx, filter.Prepare(), update.Prepare())
+// It returns ErrNotFound if no document is updated, with UpdateOptions.Upsert it inserts a new document instead
+// and returns its ID. The ID is nil if an existing document is updated or the inserted _id is not an ObjectID.
+func (m *Collection) UpdateOne(ctx context.Context, filter, update M, opts ...UpdateOptions) (*bson.ObjectID, error) {
+	ctx, done := m.start(ctx, "update_one", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "UpdateOne", filter); err != nil {
+		return nil, err
+	}
+	return m.updateOneID(ctx, filter.Prepare(), update.Prepare(), updateOneOptions(opts)...)
 }
 
 // UpdateMany updates multi documents in the collection.
 // Update map/document must contain key beginning with '$', e.g. {$set: {key1: value1}}.
 // Modifiers operate on fields. For example: {$mod: {<field>: ...}}.
 // You can use predefined options from mongox, e.g. mongox.M{mongox.Inc: mongox.M{"number": 1}}.
-// It returns number of updated documents.
+// It returns number of updated documents. With WriteOptions.DryRun it returns number of matched documents
+// and doesn't update them.
 // It returns ErrNotFound if no document is updated.
-func (m *Collection) UpdateMany(ctx context.Context, filter, update M) (int, error) {
-	updateResult, err := m.coll.UpdateMany(ctx, filter.Prepare(), update.Prepare())
+func (m *Collection) UpdateMany(ctx context.Context, filter, update M, rawOpts ...WriteOptions) (int, error) {
+	ctx, done := m.start(ctx, "update_many", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "UpdateMany", filter); err != nil {
+		return 0, err
+	}
+	if len(rawOpts) > 0 && rawOpts[0].DryRun {
+		return m.dryRunCount(ctx, filter.Prepare())
+	}
+	opts := options.UpdateMany()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	var maxTime time.Duration
+	if len(rawOpts) > 0 {
+		maxTime = rawOpts[0].MaxTime
+	}
+	opCtx, cancel := withMaxTime(ctx, maxTime)
+	defer cancel()
+
+	updateResult, err := m.coll.UpdateMany(opCtx, filter.Prepare(), update.Prepare(), collate(m.collation, opts))
+	if err != nil {
+		return 0, maxTimeError(ctx, maxTime, HandleMongoError(err))
+	}
+	if updateResult != nil && updateResult.MatchedCount == 0 {
+		return 0, ErrNotFound
+	}
+	return int(updateResult.ModifiedCount), nil
+}
+
+// RenameField renames the field in all documents matching the filter using $rename operator,
+// e.g. in a schema migration. Documents without the old field are not touched.
+// It returns number of renamed documents and ErrNotFound if no document matches the filter and has the old field.
+// It returns ErrInvalidArgument if names are empty, equal, start with '$' or one of them is _id.
+func (m *Collection) RenameField(ctx context.Context, filter M, oldName, newName string) (int, error) {
+	ctx, done := m.start(ctx, "rename_field", filter)
+	defer done()
+
+	switch {
+	case oldName == "" || newName == "":
+		return 0, fmt.Errorf("%w: empty field name", ErrInvalidArgument)
+	case oldName == newName:
+		return 0, fmt.Errorf("%w: old and new names are the same %q", ErrInvalidArgument, oldName)
+	case strings.HasPrefix(oldName, "$") || strings.HasPrefix(newName, "$"):
+		return 0, fmt.Errorf("%w: field name cannot start with '$'", ErrInvalidArgument)
+	case oldName == "_id" || newName == "_id":
+		return 0, fmt.Errorf("%w: _id field cannot be renamed", ErrInvalidArgument)
+	}
+	if err := m.guardUnboundedWrite(ctx, "RenameField", filter); err != nil {
+		return 0, err
+	}
+
+	hasField := M{oldName: M{Exists: true}}
+	if len(filter) > 0 {
+		hasField = AndFilter(filter, hasField)
+	}
+	opts := options.UpdateMany()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	update := bson.D{{Key: Rename, Value: bson.D{{Key: oldName, Value: newName}}}}
+	updateResult, err := m.coll.UpdateMany(ctx, hasField.Prepare(), update, collate(m.collation, opts))
 	if err != nil {
 		return 0, HandleMongoError(err)
 	}
@@ -355,79 +1620,408 @@ func (m *Collection) UpdateMany(ctx context.Context, filter, update M) (int, err
 //
 //	type MyStructDiff struct {name *string, index *int}
 //
+// Use DiffOptions to set the names of fields without a bson tag.
 // It returns ErrNotFound if no document is updated.
-func (m *Collection) UpdateOneFromDiff(ctx context.Context, filter M, diff any) error {
-	update, err := diffToUpdates(diff)
+func (m *Collection) UpdateOneFromDiff(ctx context.Context, filter M, diff any, opts ...DiffOptions) error {
+	ctx, done := m.start(ctx, "update_from_diff", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "UpdateOneFromDiff", filter); err != nil {
+		return err
+	}
+	update, err := diffToUpdates(diff, opts...)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrInvalidArgument, err)
 	}
 	return m.updateOne(ctx, filter.Prepare(), update)
 }
 
-// DeleteFields deletes fields in a document in the collection.
-// For example: [key1, key2] becomes {$unset: {key1: "", key2: ""}}.
-// It returns ErrNotFound if no document is updated.
-func (m *Collection) DeleteFields(ctx context.Context, filter M, fields ...string) error {
-	updateInfo := make(map[string]any, len(fields))
-	for _, f := range fields {
-		updateInfo[f] = ""
+// UpdateOneFromDiffTracked works like UpdateOneFromDiff, but also returns the sorted list of fields
+// that are set by the update, e.g. ["name", "struct.number"]. Nested fields are returned as dotted paths,
+// exactly as the keys of the $set operator. It is useful for audit logs and change events.
+// It returns ErrNotFound if no document is updated, the list of fields is returned in that case too.
+func (m *Collection) UpdateOneFromDiffTracked(ctx context.Context, filter M, diff any, opts ...DiffOptions) ([]string, error) {
+	ctx, done := m.start(ctx, "update_from_diff_tracked", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "UpdateOneFromDiffTracked", filter); err != nil {
+		return nil, err
 	}
-	return m.updateOne(ctx, filter.Prepare(), prepareUpdates(updateInfo, Unset))
+	update, err := diffToUpdates(diff, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	return updatedFields(update), m.updateOne(ctx, filter.Prepare(), update)
 }
 
-// DeleteOne deletes a document in the collection based on the filter.
-// It returns ErrNotFound if no document is deleted.
-func (m *Collection) DeleteOne(ctx context.Context, filter M) error {
-	del, err := m.coll.DeleteOne(ctx, filter.Prepare())
-	if err != nil {
-		return HandleMongoError(err)
+// IncFields increments fields in a document in the collection by the provided deltas.
+// For example: {key1: 1, key2: -5} becomes {$inc: {key1: int64(1), key2: int64(-5)}}.
+// Deltas are always int64, so it is not possible to pass a string or float delta by mistake.
+// It returns ErrInvalidArgument if deltas are empty and ErrNotFound if no document is updated.
+func (m *Collection) IncFields(ctx context.Context, filter M, deltas map[string]int64) error {
+	ctx, done := m.start(ctx, "inc_fields", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "IncFields", filter); err != nil {
+		return err
 	}
-	if del != nil && del.DeletedCount == 0 {
-		return ErrNotFound
+	update, err := typedUpdate(Inc, deltas)
+	if err != nil {
+		return err
 	}
-	return nil
+	return m.updateOne(ctx, filter.Prepare(), update)
 }
 
-// DeleteMany deletes many documents in the collection based on the filter.
-// It returns number of deleted documents.
-// It returns ErrNotFound if no document is deleted.
-func (m *Collection) DeleteMany(ctx context.Context, filter M) (int, error) {
-	del, err := m.coll.DeleteMany(ctx, filter.Prepare())
-	if err != nil {
-		return 0, HandleMongoError(err)
+// MulFields multiplies fields in a document in the collection by the provided factors.
+// For example: {key1: 2, key2: 10} becomes {$mul: {key1: int64(2), key2: int64(10)}}.
+// Factors are always int64, so it is not possible to pass a string factor by mistake.
+// It returns ErrInvalidArgument if factors are empty and ErrNotFound if no document is updated.
+func (m *Collection) MulFields(ctx context.Context, filter M, factors map[string]int64) error {
+	ctx, done := m.start(ctx, "mul_fields", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "MulFields", filter); err != nil {
+		return err
 	}
-	if del != nil && del.DeletedCount == 0 {
-		return 0, ErrNotFound
+	update, err := typedUpdate(Mul, factors)
+	if err != nil {
+		return err
 	}
-	return int(del.DeletedCount), nil
+	return m.updateOne(ctx, filter.Prepare(), update)
 }
 
-// BulkWrite executes bulk write operations in the collection.
-// Use [BulkBuilder] to create models for bulk write operations.
-// IsOrdered==true means that all operations are executed in the order they are added to the [BulkBuilder]
-// and if any of them fails, the whole operation fails. Error is not returning.
+// AddToSetEach adds values to an array field of a document only if they do not already exist in the array:
+// {$addToSet: {field: {$each: values}}}. Passing a slice to $addToSet without $each adds the slice itself
+// as a single element, so use this method to add many unique elements at once.
+// It returns ErrInvalidArgument if field is empty and ErrNotFound if no document is updated.
+func (m *Collection) AddToSetEach(ctx context.Context, filter M, field string, values []any) error {
+	ctx, done := m.start(ctx, "add_to_set_each", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "AddToSetEach", filter); err != nil {
+		return err
+	}
+	if field == "" {
+		return fmt.Errorf("%w: empty field", ErrInvalidArgument)
+	}
+	update := bson.D{{Key: AddToSet, Value: bson.D{
+		{Key: field, Value: bson.D{{Key: Each, Value: lang.If(values == nil, []any{}, values)}}},
+	}}}
+	return m.updateOne(ctx, filter.Prepare(), update)
+}
+
+// SetFieldOnce sets the field of a document only if the field doesn't exist yet, e.g. to stamp a creation time
+// or an owner on the first write and never overwrite it: it updates the document matching both the filter
+// and {field: {$exists: false}} with {$set: {field: value}}. Unlike upsert, it never inserts a new document.
+// It returns true if the field is set and false if the document already has the field, even with a null value.
+// It returns ErrInvalidArgument if field is empty and ErrNotFound if no document matches the filter.
+func (m *Collection) SetFieldOnce(ctx context.Context, filter M, field string, value any) (set bool, err error) {
+	ctx, done := m.start(ctx, "set_field_once", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "SetFieldOnce", filter); err != nil {
+		return false, err
+	}
+	if field == "" {
+		return false, fmt.Errorf("%w: empty field", ErrInvalidArgument)
+	}
+
+	guarded := AndFilter(filter, M{field: M{Exists: false}})
+	err = m.updateOne(ctx, guarded.Prepare(), bson.D{{Key: Set, Value: bson.D{{Key: field, Value: value}}}})
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+
+	n, err := m.coll.CountDocuments(ctx, filter.Prepare(), collate(m.collation, options.Count().SetLimit(1)))
+	if err != nil {
+		return false, HandleMongoError(err)
+	}
+	if n == 0 {
+		return false, ErrNotFound
+	}
+	return false, nil
+}
+
+// DeleteFields deletes fields in a document in the collection.
+// For example: [key1, key2] becomes {$unset: {key1: "", key2: ""}}.
+// It returns ErrNotFound if no document is updated.
+func (m *Collection) DeleteFields(ctx context.Context, filter M, fields ...string) error {
+	ctx, done := m.start(ctx, "delete_fields", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "DeleteFields", filter); err != nil {
+		return err
+	}
+	updateInfo := make(map[string]any, len(fields))
+	for _, f := range fields {
+		updateInfo[f] = ""
+	}
+	return m.updateOne(ctx, filter.Prepare(), prepareUpdates(updateInfo, Unset))
+}
+
+// PatchFields sets and deletes fields of a document in the collection in one update.
+// For example: set {key1: value1} and unset [key2] becomes {$set: {key1: value1}, $unset: {key2: ""}}.
+// It returns ErrInvalidArgument if both set and unset are empty or if the same field (or its parent)
+// is set and unset, the server rejects such update with ConflictingUpdateOperators.
+// It returns ErrNotFound if no document is updated.
+func (m *Collection) PatchFields(ctx context.Context, filter, set M, unset ...string) error {
+	ctx, done := m.start(ctx, "patch_fields", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "PatchFields", filter); err != nil {
+		return err
+	}
+	update, err := patchUpdate(set, unset)
+	if err != nil {
+		return err
+	}
+	return m.updateOne(ctx, filter.Prepare(), update)
+}
+
+func patchUpdate(set M, unset []string) (bson.D, error) {
+	if len(set) == 0 && len(unset) == 0 {
+		return nil, fmt.Errorf("%w: empty fields to set and unset", ErrInvalidArgument)
+	}
+	for _, u := range unset {
+		for s := range set {
+			if s == u || strings.HasPrefix(s, u+".") || strings.HasPrefix(u, s+".") {
+				return nil, fmt.Errorf("%w: conflicting update of %q and %q", ErrInvalidArgument, s, u)
+			}
+		}
+	}
+	update := make(bson.D, 0, 2)
+	if len(set) > 0 {
+		update = append(update, prepareUpdates(set, Set)...)
+	}
+	if len(unset) > 0 {
+		unsetInfo := make(map[string]any, len(unset))
+		for _, f := range unset {
+			unsetInfo[f] = ""
+		}
+		update = append(update, prepareUpdates(unsetInfo, Unset)...)
+	}
+	return update, nil
+}
+
+// DeleteOne deletes a document in the collection based on the filter.
+// It returns ErrNotFound if no document is deleted.
+func (m *Collection) DeleteOne(ctx context.Context, filter M) error {
+	ctx, done := m.start(ctx, "delete_one", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "DeleteOne", filter); err != nil {
+		return err
+	}
+	opts := options.DeleteOne()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	del, err := m.coll.DeleteOne(ctx, filter.Prepare(), collate(m.collation, opts))
+	if err != nil {
+		return HandleMongoError(err)
+	}
+	if del != nil && del.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteMany deletes many documents in the collection based on the filter.
+// It returns number of deleted documents. With WriteOptions.DryRun it returns number of matched documents
+// and doesn't delete them.
+// It returns ErrNotFound if no document is deleted.
+func (m *Collection) DeleteMany(ctx context.Context, filter M, rawOpts ...WriteOptions) (int, error) {
+	ctx, done := m.start(ctx, "delete_many", filter)
+	defer done()
+
+	if err := m.guardUnboundedWrite(ctx, "DeleteMany", filter); err != nil {
+		return 0, err
+	}
+	if len(rawOpts) > 0 && rawOpts[0].DryRun {
+		return m.dryRunCount(ctx, filter.Prepare())
+	}
+	opts := options.DeleteMany()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	var maxTime time.Duration
+	if len(rawOpts) > 0 {
+		maxTime = rawOpts[0].MaxTime
+	}
+	opCtx, cancel := withMaxTime(ctx, maxTime)
+	defer cancel()
+
+	del, err := m.coll.DeleteMany(opCtx, filter.Prepare(), collate(m.collation, opts))
+	if err != nil {
+		return 0, maxTimeError(ctx, maxTime, HandleMongoError(err))
+	}
+	if del != nil && del.DeletedCount == 0 {
+		return 0, ErrNotFound
+	}
+	return int(del.DeletedCount), nil
+}
+
+// Truncate deletes all documents in the collection.
+// Unlike DeleteMany, it does NOT return ErrNotFound if the collection is already empty.
+// Indexes and validation rules of the collection are kept, because the collection is not dropped.
+func (m *Collection) Truncate(ctx context.Context) error {
+	ctx, done := m.start(ctx, "truncate", nil)
+	defer done()
+
+	opts := options.DeleteMany()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	if _, err := m.coll.DeleteMany(ctx, bson.D{}, opts); err != nil {
+		return HandleMongoError(err)
+	}
+	return nil
+}
+
+// BulkWrite executes bulk write operations in the collection.
+// Use [BulkBuilder] to create models for bulk write operations.
+// IsOrdered==true means that all operations are executed in the order they are added to the [BulkBuilder]
+// and if any of them fails, the whole operation fails. Error is not returning.
 // IsOrdered==false means that all operations are executed in parallel and if any of them fails,
 // the whole operation continues. Error is not returning.
+// With WriteOptions.DryRun it counts documents matching the filters of models instead of writing them,
+// see [WriteOptions] for details.
+// It returns ErrInvalidArgument with the index of the first invalid model before sending models to the server,
+// e.g. if a model has no filter, see [BulkBuilder.Validate].
 // It returns ErrNotFound if no document is matched/inserted/updated/deleted.
-func (m *Collection) BulkWrite(ctx context.Context, models []mongo.WriteModel, isOrdered bool) (mongo.BulkWriteResult, error) {
+func (m *Collection) BulkWrite(ctx context.Context, models []mongo.WriteModel, isOrdered bool, rawOpts ...WriteOptions) (BulkResult, error) {
+	ctx, done := m.start(ctx, "bulk_write", nil)
+	defer done()
+
+	if err := validateModels(models); err != nil {
+		return BulkResult{}, err
+	}
+	if len(rawOpts) > 0 && rawOpts[0].DryRun {
+		return m.bulkDryRun(ctx, models)
+	}
+
+	if err := m.checkDocumentSizes(insertedDocuments(models)); err != nil {
+		return BulkResult{}, err
+	}
 	opts := options.BulkWrite().SetOrdered(isOrdered)
-	res, err := m.coll.BulkWrite(ctx, models, opts)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	var maxTime time.Duration
+	if len(rawOpts) > 0 {
+		maxTime = rawOpts[0].MaxTime
+	}
+	opCtx, cancel := withMaxTime(ctx, maxTime)
+	defer cancel()
+
+	res, err := m.coll.BulkWrite(opCtx, models, opts)
 	if err != nil {
-		return mongo.BulkWriteResult{}, HandleMongoError(err)
+		return BulkResult{}, maxTimeError(ctx, maxTime, HandleMongoError(err))
 	}
 	if res != nil && res.MatchedCount+res.DeletedCount+res.InsertedCount+res.ModifiedCount+res.UpsertedCount == 0 {
-		return mongo.BulkWriteResult{}, ErrNotFound
+		return BulkResult{}, ErrNotFound
 	}
-	return lang.Deref(res), nil
+	return newBulkResult(res), nil
+}
+
+// BulkWriteTx executes bulk write operations like [Collection.BulkWrite] as a part of the transaction,
+// sctx must be the context of the [Database.WithTransaction] callback, so all models are committed or aborted together.
+// In a transaction any failed model aborts the whole transaction regardless of isOrdered:
+// isOrdered==true stops on the first failed model, isOrdered==false executes other models,
+// but their writes are discarded with the abort anyway.
+// Returned error should be returned from the callback to abort the transaction.
+// It returns ErrInvalidArgument if sctx has no session.
+// It returns ErrNotFound if no document is matched/inserted/updated/deleted.
+func (m *Collection) BulkWriteTx(sctx context.Context, models []mongo.WriteModel, isOrdered bool) (BulkResult, error) {
+	if mongo.SessionFromContext(sctx) == nil {
+		return BulkResult{}, fmt.Errorf("%w: BulkWriteTx requires a session context, use it inside WithTransaction", ErrInvalidArgument)
+	}
+	return m.BulkWrite(sctx, models, isOrdered)
+}
+
+// BulkWriteTracked executes models of the builder like [Collection.BulkWrite] and returns the outcome
+// of every model tagged with [BulkBuilder.Keyed]. Untagged models are executed but not returned.
+// Outcome has Upserted flag and ID of the upserted document and the error of the model, if any.
+// In ordered mode models after the failed one are not executed and not presented in the result.
+// It returns the outcomes together with the error of the whole operation, so partial results are available on error.
+// It returns ErrInvalidArgument if the same key tags more than one model.
+func (m *Collection) BulkWriteTracked(ctx context.Context, b *BulkBuilder, isOrdered bool) (map[string]WriteOutcome, error) {
+	ctx, done := m.start(ctx, "bulk_write_tracked", nil)
+	defer done()
+
+	if b == nil {
+		return nil, fmt.Errorf("%w: nil bulk builder", ErrInvalidArgument)
+	}
+	models, keys := b.keyedModels()
+	if err := validateModels(models); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			return nil, fmt.Errorf("%w: duplicate bulk key %q", ErrInvalidArgument, key)
+		}
+		seen[key] = struct{}{}
+	}
+
+	if err := m.checkDocumentSizes(insertedDocuments(models)); err != nil {
+		return nil, err
+	}
+	opts := options.BulkWrite().SetOrdered(isOrdered)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	res, err := m.coll.BulkWrite(ctx, models, opts)
+
+	failed := make(map[int]error)
+	lastExecuted := len(models) - 1
+	var bwe mongo.BulkWriteException
+	if errors.As(err, &bwe) {
+		for _, we := range bwe.WriteErrors {
+			failed[we.Index] = HandleMongoError(mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{we}})
+			if isOrdered {
+				lastExecuted = we.Index
+			}
+		}
+	} else if err != nil {
+		return nil, HandleMongoError(err)
+	}
+
+	out := make(map[string]WriteOutcome, len(keys))
+	for i, key := range keys {
+		if i > lastExecuted {
+			continue
+		}
+		var outcome WriteOutcome
+		if res != nil {
+			outcome.UpsertedID, outcome.Upserted = res.UpsertedIDs[int64(i)]
+		}
+		outcome.Err = failed[i]
+		out[key] = outcome
+	}
+
+	if err != nil {
+		return out, HandleMongoError(err)
+	}
+	return out, nil
 }
 
 func (m *Collection) find(ctx context.Context, dest any, filter bson.D, rawOpts ...FindOptions) error {
-	cur, err := m.coll.Find(ctx, filter, setFindOptions(rawOpts...))
+	opts := setFindOptions(rawOpts...)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	cur, err := m.coll.Find(ctx, filter, collate(m.collation, opts))
 	if err != nil {
 		return HandleMongoError(err)
 	}
 	defer cur.Close(ctx)
 
+	resetPointerSlice(dest)
 	if err := cur.All(ctx, dest); err != nil {
 		return HandleMongoError(err)
 	}
@@ -439,17 +2033,603 @@ func (m *Collection) find(ctx context.Context, dest any, filter bson.D, rawOpts
 	return nil
 }
 
+// ScanAll pages through all documents of the collection in ascending order of sortField and calls fn for every batch
+// of at most batchSize documents with the value of sortField of the last document in the batch. Every batch is
+// a separate query that starts after the last key (keyset pagination), so there is no long-lived cursor to expire
+// in multi-hour jobs. Save the last key as a checkpoint and pass it in ScanOptions.After to resume the scan.
+// The sortField must be unique and present in every document, e.g. _id, otherwise documents with equal keys
+// on the border of batches are skipped. Create an index on sortField for large collections.
+// The collection timeout is applied to every batch query, not to the whole scan, so callbacks and reconnect
+// delays are not limited by it, use ctx to limit the whole scan.
+// It stops and returns the error of fn, if any. It does NOT return any error if the collection is empty.
+// It returns ErrInvalidArgument if sortField is empty, batchSize is less than 1 or a document has no sortField.
+func (m *Collection) ScanAll(ctx context.Context, sortField string, batchSize int, fn func(batch []bson.Raw, lastKey any) error, rawOpts ...ScanOptions) error {
+	if sortField == "" || batchSize < 1 || fn == nil {
+		return fmt.Errorf("%w: sortField, positive batchSize and fn are required", ErrInvalidArgument)
+	}
+	var lastKey any
+	if len(rawOpts) > 0 {
+		lastKey = rawOpts[0].After
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: sortField, Value: Ascending}}).SetLimit(int64(batchSize))
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	var maxRetries int
+	if len(rawOpts) > 0 {
+		maxRetries = rawOpts[0].MaxRetries
+	}
+
+	path := strings.Split(sortField, ".")
+	for attempt := 0; ; {
+		filter := bson.D{}
+		if lastKey != nil {
+			filter = bson.D{{Key: sortField, Value: bson.D{{Key: Gt, Value: lastKey}}}}
+		}
+
+		batch, err := m.scanBatch(ctx, filter, opts)
+		if err != nil {
+			if !IsRetryableRead(err) || attempt >= maxRetries || ctx.Err() != nil {
+				return err
+			}
+			attempt++
+			if err := waitReconnect(ctx, attempt); err != nil {
+				return HandleMongoError(err)
+			}
+			continue
+		}
+		attempt = 0
+		if len(batch) == 0 {
+			return nil
+		}
+
+		value, err := batch[len(batch)-1].LookupErr(path...)
+		if err != nil {
+			return fmt.Errorf("%w: document has no sort field %q", ErrInvalidArgument, sortField)
+		}
+		var key any
+		if err := value.Unmarshal(&key); err != nil {
+			return HandleMongoError(err)
+		}
+		lastKey = key
+
+		if err := fn(batch, lastKey); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+	}
+}
+
+// resetPointerSlice sets dest to nil if it is a pointer to a slice of pointers, e.g. *[]*T.
+// The driver decodes into elements of the existing slice, so without it documents would be decoded
+// into structs of the previous result that may be still referenced by the caller.
+func resetPointerSlice(dest any) {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return
+	}
+	slice := v.Elem()
+	if slice.Kind() == reflect.Slice && slice.Type().Elem().Kind() == reflect.Ptr && slice.CanSet() {
+		slice.SetZero()
+	}
+}
+
+// scanBatch reads one batch of ScanAll, the collection timeout is applied to it.
+func (m *Collection) scanBatch(ctx context.Context, filter bson.D, opts *options.FindOptionsBuilder) ([]bson.Raw, error) {
+	ctx, done := m.start(ctx, "scan_all", nil)
+	defer done()
+
+	cur, err := m.coll.Find(ctx, filter, collate(m.collation, opts))
+	if err != nil {
+		return nil, HandleMongoError(err)
+	}
+	var batch []bson.Raw
+	if err := cur.All(ctx, &batch); err != nil {
+		return nil, HandleMongoError(err)
+	}
+	return batch, nil
+}
+
+// findEach finds documents using filter and calls fn for every document without loading all of them in memory.
+func (m *Collection) findEach(ctx context.Context, filter bson.D, fn func(decode func(any) error) error, rawOpts ...FindOptions) error {
+	opts := setFindOptions(rawOpts...)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	cur, err := m.coll.Find(ctx, filter, collate(m.collation, opts))
+	if err != nil {
+		return HandleMongoError(err)
+	}
+	defer cur.Close(ctx)
+
+	decode := func(dest any) error {
+		return HandleMongoError(cur.Decode(dest))
+	}
+	for cur.Next(ctx) {
+		if err := fn(decode); err != nil {
+			return err
+		}
+	}
+
+	if err := cur.Err(); err != nil {
+		return HandleMongoError(err)
+	}
+
+	return nil
+}
+
+// aggregateMaxTime returns AggregateOptions.MaxTime or zero if options are not provided.
+func aggregateMaxTime(rawOpts ...AggregateOptions) time.Duration {
+	if len(rawOpts) == 0 {
+		return 0
+	}
+	return rawOpts[0].MaxTime
+}
+
+// handleAggregateError is like HandleMongoError, but adds a hint to the memory limit error.
+func handleAggregateError(err error) error {
+	err = HandleMongoError(err)
+	if errors.Is(err, ErrQueryExceededMemoryLimitNoDiskUseAllowed) {
+		return fmt.Errorf("%w (set AggregateOptions.AllowDiskUse to allow writing temporary files on disk)", err)
+	}
+	return err
+}
+
+// insertMany inserts records and returns inserted IDs as they are returned by the driver.
+func (m *Collection) insertMany(ctx context.Context, records []any) ([]any, error) {
+	ctx, done := m.start(ctx, "insert_many", nil)
+	defer done()
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+	if err := m.checkDocumentSizes(records); err != nil {
+		return nil, err
+	}
+
+	if len(records) == 1 {
+		opts := options.InsertOne()
+		comment := m.commentFor(ctx)
+		lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+		res, err := m.coll.InsertOne(ctx, records[0], opts)
+		if err != nil {
+			return nil, HandleMongoError(err)
+		}
+		return []any{res.InsertedID}, nil
+	}
+
+	opts := options.InsertMany()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	res, err := m.coll.InsertMany(ctx, records, opts)
+	if err != nil {
+		return nil, HandleMongoError(err)
+	}
+	return res.InsertedIDs, nil
+}
+
 func (m *Collection) updateOne(ctx context.Context, filter, update bson.D, opts ...options.Lister[options.UpdateOneOptions]) error {
+	_, err := m.updateOneID(ctx, filter, update, opts...)
+	return err
+}
+
+// updateOneID works like updateOne, but returns the _id of the upserted document if it is an ObjectID.
+func (m *Collection) updateOneID(ctx context.Context, filter, update bson.D, opts ...options.Lister[options.UpdateOneOptions]) (*bson.ObjectID, error) {
+	if comment := m.commentFor(ctx); comment != "" {
+		opts = append(opts, options.UpdateOne().SetComment(comment))
+	}
+	if m.collation != nil {
+		opts = append(opts, options.UpdateOne().SetCollation(m.collation))
+	}
 	updateResult, err := m.coll.UpdateOne(ctx, filter, update, opts...)
 	if err != nil {
-		return HandleMongoError(err)
+		return nil, HandleMongoError(err)
 	}
-	if updateResult != nil && updateResult.MatchedCount == 0 {
-		return ErrNotFound
+	if updateResult == nil {
+		return nil, nil
+	}
+	if updateResult.MatchedCount == 0 && updateResult.UpsertedCount == 0 {
+		return nil, ErrNotFound
+	}
+	if id, ok := updateResult.UpsertedID.(bson.ObjectID); ok {
+		return &id, nil
+	}
+	return nil, nil
+}
+
+func updateOneOptions(opts []UpdateOptions) []options.Lister[options.UpdateOneOptions] {
+	if len(opts) == 0 || !opts[0].Upsert {
+		return nil
+	}
+	return []options.Lister[options.UpdateOneOptions]{options.UpdateOne().SetUpsert(true)}
+}
+
+// exists reports whether any document matches the filter, it stops counting at the first one.
+func (m *Collection) exists(ctx context.Context, filter any) (bool, error) {
+	opts := options.Count().SetLimit(1)
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	n, err := m.coll.CountDocuments(ctx, filter, collate(m.collation, opts))
+	if err != nil {
+		return false, HandleMongoError(err)
+	}
+	return n > 0, nil
+}
+
+// dryRunCount returns number of documents matching the filter or ErrNotFound if there are none.
+func (m *Collection) dryRunCount(ctx context.Context, filter any, limit ...int64) (int, error) {
+	opts := options.Count()
+	comment := m.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+	lang.IfF(len(limit) > 0, func() { opts.SetLimit(limit[0]) })
+
+	n, err := m.coll.CountDocuments(ctx, filter, collate(m.collation, opts))
+	if err != nil {
+		return 0, HandleMongoError(err)
+	}
+	if n == 0 {
+		return 0, ErrNotFound
+	}
+	return int(n), nil
+}
+
+// bulkDryRun approximates the result of the bulk write by counting documents matching the filter of every model.
+func (m *Collection) bulkDryRun(ctx context.Context, models []mongo.WriteModel) (BulkResult, error) {
+	if len(models) == 0 {
+		return BulkResult{}, fmt.Errorf("%w: empty models", ErrInvalidArgument)
+	}
+
+	var res BulkResult
+	count := func(filter any, upsert *bool, limit ...int64) error {
+		n, err := m.dryRunCount(ctx, filter, limit...)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		res.MatchedCount += int64(n)
+		if n == 0 && lang.Deref(upsert) {
+			res.UpsertedCount++
+		}
+		return nil
+	}
+
+	for i, model := range models {
+		var err error
+		switch model := model.(type) {
+		case *mongo.InsertOneModel:
+			res.InsertedCount++
+		case *mongo.UpdateOneModel:
+			err = count(model.Filter, model.Upsert, 1)
+		case *mongo.UpdateManyModel:
+			err = count(model.Filter, model.Upsert)
+		case *mongo.ReplaceOneModel:
+			err = count(model.Filter, model.Upsert, 1)
+		case *mongo.DeleteOneModel:
+			n, countErr := m.dryRunCount(ctx, model.Filter, 1)
+			res.DeletedCount += int64(n)
+			err = lang.If(errors.Is(countErr, ErrNotFound), nil, countErr)
+		case *mongo.DeleteManyModel:
+			n, countErr := m.dryRunCount(ctx, model.Filter)
+			res.DeletedCount += int64(n)
+			err = lang.If(errors.Is(countErr, ErrNotFound), nil, countErr)
+		default:
+			return BulkResult{}, fmt.Errorf("%w: unsupported model %T at index %d", ErrInvalidArgument, model, i)
+		}
+		if err != nil {
+			return BulkResult{}, err
+		}
+	}
+
+	if res.MatchedCount+res.DeletedCount+res.InsertedCount+res.UpsertedCount == 0 {
+		return BulkResult{}, ErrNotFound
+	}
+	return res, nil
+}
+
+type allowUnboundedWritesKey struct{}
+
+// AllowUnboundedWrites returns a copy of the context that allows write methods with an empty filter
+// when Config.GuardUnboundedWrites is enabled. Use it for intentional operations on all documents of a collection
+// or on an arbitrary document, e.g. DeleteOne of any document from a queue.
+func AllowUnboundedWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allowUnboundedWritesKey{}, true)
+}
+
+type commentKey struct{}
+
+// ContextWithComment returns a copy of the context that attaches the comment to all collection operations
+// called with it, e.g. a request or trace id to find the queries of the request in server logs and profiling data.
+// A comment set with [Collection.WithComment] takes precedence over the comment from the context.
+func ContextWithComment(ctx context.Context, comment string) context.Context {
+	return context.WithValue(ctx, commentKey{}, comment)
+}
+
+// commentFor returns the comment of the collection or the comment from the context if the collection has none.
+func (m *Collection) commentFor(ctx context.Context) string {
+	if m.comment != "" {
+		return m.comment
+	}
+	comment, _ := ctx.Value(commentKey{}).(string)
+	return comment
+}
+
+// guardUnboundedWrite returns ErrEmptyFilter if Config.GuardUnboundedWrites is enabled, the filter is empty
+// and the context doesn't allow unbounded writes.
+func (m *Collection) guardUnboundedWrite(ctx context.Context, op string, filter M) error {
+	if m.cfg == nil || !m.cfg.GuardUnboundedWrites || len(filter) > 0 {
+		return nil
+	}
+	if allowed, _ := ctx.Value(allowUnboundedWritesKey{}).(bool); allowed {
+		return nil
+	}
+	return fmt.Errorf("%w in %s, use Truncate or AllowUnboundedWrites to modify documents without a filter", ErrEmptyFilter, op)
+}
+
+// checkDocumentSizes returns ErrInvalidArgument wrapping ErrBSONObjectTooLarge if Config.CheckDocumentSize is enabled
+// and any of the records is larger than Config.MaxDocumentSize. Records that cannot be marshaled are left to the driver.
+func (m *Collection) checkDocumentSizes(records []any) error {
+	if m.cfg == nil || !m.cfg.CheckDocumentSize {
+		return nil
+	}
+	limit := lang.Check(m.cfg.MaxDocumentSize, DefaultMaxDocumentSize)
+	for i, record := range records {
+		raw, err := bson.Marshal(record)
+		if err != nil || len(raw) <= limit {
+			continue
+		}
+		return fmt.Errorf("%w: %w: document %d has size %d bytes, limit is %d bytes, largest fields: %s",
+			ErrInvalidArgument, ErrBSONObjectTooLarge, i, len(raw), limit, largestFields(raw, 3))
 	}
 	return nil
 }
 
+// insertedDocuments returns documents of insert models to check their sizes.
+func insertedDocuments(models []mongo.WriteModel) []any {
+	var docs []any
+	for _, model := range models {
+		if insert, ok := model.(*mongo.InsertOneModel); ok {
+			docs = append(docs, insert.Document)
+		}
+	}
+	return docs
+}
+
+// largestFields returns top-level fields of the document with the largest sizes in the form "key=size, ...".
+func largestFields(raw bson.Raw, n int) string {
+	elems, err := raw.Elements()
+	if err != nil {
+		return "unknown"
+	}
+	sort.Slice(elems, func(i, j int) bool { return len(elems[i]) > len(elems[j]) })
+
+	fields := make([]string, 0, n)
+	for _, elem := range elems[:min(n, len(elems))] {
+		fields = append(fields, fmt.Sprintf("%s=%d", elem.Key(), len(elem)))
+	}
+	return strings.Join(fields, ", ")
+}
+
+// copyDocument returns a copy of the raw document that doesn't share memory with the cursor buffer.
+func copyDocument(raw bson.Raw, removeID bool) (any, error) {
+	if !removeID {
+		return bson.Raw(append([]byte(nil), raw...)), nil
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	out := doc[:0]
+	for _, e := range doc {
+		if e.Key != "_id" {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// indexName returns the name of the index from the options or generated one, e.g. "coll_field1_field2_unique_index".
+func (m *Collection) indexName(opts IndexOptions, fieldNames []string) string {
+	if opts.Name != "" {
+		return opts.Name
+	}
+	return m.coll.Name() + "_" + strings.Join(fieldNames, "_") + lang.If(opts.Unique, "_unique", "") +
+		lang.If(len(opts.PartialFilter) > 0, "_partial", "") + "_index"
+}
+
+func indexKeys(fieldNames []string) bson.D {
+	keys := make(bson.D, 0, len(fieldNames))
+	for _, field := range fieldNames {
+		keys = append(keys, bson.E{
+			Key:   field,
+			Value: 1,
+		})
+	}
+	return keys
+}
+
+// indexSpec is a part of index specification returned by listIndexes command.
+type indexSpec struct {
+	Name                    string `bson:"name"`
+	Key                     bson.D `bson:"key"`
+	Unique                  bool   `bson:"unique"`
+	PartialFilterExpression bson.D `bson:"partialFilterExpression"`
+	Hidden                  bool   `bson:"hidden"`
+}
+
+func (m *Collection) listIndexSpecs(ctx context.Context) ([]indexSpec, error) {
+	cur, err := m.coll.Indexes().List(ctx)
+	if err != nil {
+		return nil, HandleMongoError(err)
+	}
+	defer cur.Close(ctx)
+
+	var specs []indexSpec
+	if err := cur.All(ctx, &specs); err != nil {
+		return nil, HandleMongoError(err)
+	}
+	return specs, nil
+}
+
+func (s indexSpec) hasKeys(keys bson.D) bool {
+	if len(s.Key) != len(keys) {
+		return false
+	}
+	for i := range keys {
+		if s.Key[i].Key != keys[i].Key || fmt.Sprint(s.Key[i].Value) != fmt.Sprint(keys[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// isText reports whether the index is a text index, the server stores text keys as {_fts: "text", _ftsx: 1}.
+func (s indexSpec) isText() bool {
+	for _, e := range s.Key {
+		if e.Key == "_fts" || e.Value == "text" {
+			return true
+		}
+	}
+	return false
+}
+
+// diff returns names of options that differ between the existing index and the requested one.
+func (s indexSpec) diff(name string, keys bson.D, opts IndexOptions) []string {
+	var out []string
+	if s.Name != name {
+		out = append(out, "name")
+	}
+	if !s.hasKeys(keys) {
+		out = append(out, "keys")
+	}
+	if s.Unique != opts.Unique {
+		out = append(out, "unique")
+	}
+	if !equalDocuments(s.PartialFilterExpression, opts.PartialFilter.Prepare()) {
+		out = append(out, "partial filter")
+	}
+	if s.Hidden != opts.Hidden {
+		out = append(out, "hidden")
+	}
+	return out
+}
+
+// equalDocuments reports whether two documents are equal ignoring the order of keys.
+func equalDocuments(a, b bson.D) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	return reflect.DeepEqual(normalizeDocument(a), normalizeDocument(b))
+}
+
+// normalizeDocument converts the document to a map with canonical BSON values, so it can be compared with reflect.DeepEqual.
+func normalizeDocument(d bson.D) any {
+	raw, err := bson.Marshal(d)
+	if err != nil {
+		return d
+	}
+	var decoded bson.D
+	if err := bson.Unmarshal(raw, &decoded); err != nil {
+		return d
+	}
+	return normalizeValue(decoded)
+}
+
+func normalizeValue(v any) any {
+	switch val := v.(type) {
+	case bson.D:
+		out := make(map[string]any, len(val))
+		for _, e := range val {
+			out[e.Key] = normalizeValue(e.Value)
+		}
+		return out
+	case bson.A:
+		out := make([]any, 0, len(val))
+		for _, e := range val {
+			out = append(out, normalizeValue(e))
+		}
+		return out
+	}
+	return v
+}
+
+// clone returns a shallow copy of the collection that can be modified without affecting the original one.
+func (m *Collection) clone() *Collection {
+	out := *m
+	return &out
+}
+
+// withMaxTime returns a context with the maxTime deadline, the driver sends the remaining time as maxTimeMS,
+// so the server aborts the operation when the time is up. Zero maxTime returns the context as is.
+func withMaxTime(ctx context.Context, maxTime time.Duration) (context.Context, context.CancelFunc) {
+	if maxTime <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, maxTime)
+}
+
+// maxTimeError wraps the timeout error with ErrMaxTimeMSExpired if it is caused by the maxTime of the operation
+// and not by the deadline of the parent context.
+func maxTimeError(parent context.Context, maxTime time.Duration, err error) error {
+	if maxTime <= 0 || parent.Err() != nil || !errors.Is(err, ErrTimeout) || errors.Is(err, ErrMaxTimeMSExpired) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrMaxTimeMSExpired, err)
+}
+
+// start prepares the context of the operation and starts measuring it. It returns a function that should be deferred.
+// It applies the timeout of the collection to the context
+// and calls Config.OnSlowOperation if the operation takes longer than Config.SlowQueryThreshold.
+func (m *Collection) start(ctx context.Context, op string, filter M) (context.Context, func()) {
+	cancel := context.CancelFunc(func() {})
+	if m.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	}
+	if m.cfg == nil || m.cfg.OnSlowOperation == nil {
+		return ctx, cancel
+	}
+	start := time.Now()
+	return ctx, func() {
+		cancel()
+		dur := time.Since(start)
+		if dur < lang.Check(m.cfg.SlowQueryThreshold, DefaultSlowQueryThreshold) {
+			return
+		}
+		m.cfg.OnSlowOperation(op, m.coll.Name(), dur, redactFilter(filter))
+	}
+}
+
+func newReadPref(rp string, tagSets ...map[string]string) (*readpref.ReadPref, error) {
+	mode, ok := readPreferenceModes[rp]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported read preference %q", ErrInvalidArgument, rp)
+	}
+	var opts []readpref.Option
+	if len(tagSets) > 0 {
+		if !validTagSets(tagSets) {
+			return nil, fmt.Errorf("%w: empty key or value in read preference tags %v", ErrInvalidArgument, tagSets)
+		}
+		opts = append(opts, readpref.WithTagSets(tag.NewTagSetsFromMaps(tagSets)...))
+	}
+	pref, err := readpref.New(mode, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	return pref, nil
+}
+
+// collate sets the collation to the options of the operation if it is not nil.
+func collate[B interface{ SetCollation(*options.Collation) B }](collation *options.Collation, opts B) B {
+	if collation == nil {
+		return opts
+	}
+	return opts.SetCollation(collation)
+}
+
 func setFindOneOptions(rawOpts ...FindOptions) *options.FindOneOptionsBuilder {
 	findOneOpts := options.FindOne()
 	if len(rawOpts) > 0 {
@@ -457,16 +2637,9 @@ func setFindOneOptions(rawOpts ...FindOptions) *options.FindOneOptionsBuilder {
 		lang.IfF(opts.Skip > 0, func() { findOneOpts.SetSkip(int64(opts.Skip)) })
 		lang.IfF(opts.AllowPartialResults, func() { findOneOpts.SetAllowPartialResults(opts.AllowPartialResults) })
 
-		lang.IfF(len(opts.SortMany) > 0, func() {
-			sortMany := make(bson.D, 0, len(opts.SortMany))
-			for _, sort := range opts.SortMany {
-				for k, v := range sort {
-					sortMany = append(sortMany, bson.E{Key: k, Value: v})
-				}
-			}
-			findOneOpts.SetSort(sortMany)
-		})
+		lang.IfF(len(opts.SortMany) > 0, func() { findOneOpts.SetSort(sortManyToD(opts.SortMany)) })
 		lang.IfF(len(opts.Sort) > 0, func() { findOneOpts.SetSort(opts.Sort) }) // Sort has priority over SortMany
+		lang.IfF(opts.StableSortField != "", func() { findOneOpts.SetSort(stableSort(opts)) })
 	}
 	return findOneOpts
 }
@@ -479,16 +2652,67 @@ func setFindOptions(rawOpts ...FindOptions) *options.FindOptionsBuilder {
 		lang.IfF(opts.Skip > 0, func() { findOpts.SetSkip(int64(opts.Skip)) })
 		lang.IfF(opts.AllowPartialResults, func() { findOpts.SetAllowPartialResults(opts.AllowPartialResults) })
 		lang.IfF(opts.AllowDiskUse, func() { findOpts.SetAllowDiskUse(opts.AllowDiskUse) })
-		lang.IfF(len(opts.SortMany) > 0, func() {
-			sortMany := make(bson.D, 0, len(opts.SortMany))
-			for _, sort := range opts.SortMany {
-				for k, v := range sort {
-					sortMany = append(sortMany, bson.E{Key: k, Value: v})
-				}
-			}
-			findOpts.SetSort(sortMany)
-		})
+		lang.IfF(len(opts.SortMany) > 0, func() { findOpts.SetSort(sortManyToD(opts.SortMany)) })
 		lang.IfF(len(opts.Sort) > 0, func() { findOpts.SetSort(opts.Sort) }) // Sort has priority over SortMany
+		lang.IfF(opts.StableSortField != "", func() { findOpts.SetSort(stableSort(opts)) })
 	}
 	return findOpts
 }
+
+// stableSort returns sort from the options with StableSortField appended as a final tie-breaker.
+func stableSort(opts FindOptions) bson.D {
+	sort := sortManyToD(opts.SortMany)
+	if len(opts.Sort) > 0 {
+		sort = opts.Sort.Prepare() // Sort has priority over SortMany
+	}
+	for _, e := range sort {
+		if e.Key == opts.StableSortField {
+			return sort
+		}
+	}
+	return append(sort, bson.E{Key: opts.StableSortField, Value: Ascending})
+}
+
+func setFindOneAndDeleteOptions(rawOpts ...FindOneAndOptions) *options.FindOneAndDeleteOptionsBuilder {
+	deleteOpts := options.FindOneAndDelete()
+	if len(rawOpts) > 0 {
+		opts := rawOpts[0]
+		lang.IfF(len(opts.Sort) > 0, func() { deleteOpts.SetSort(sortManyToD(opts.Sort)) })
+		lang.IfF(opts.Projection != nil, func() { deleteOpts.SetProjection(opts.Projection) })
+	}
+	return deleteOpts
+}
+
+func setFindOneAndReplaceOptions(rawOpts ...FindOneAndOptions) *options.FindOneAndReplaceOptionsBuilder {
+	replaceOpts := options.FindOneAndReplace()
+	if len(rawOpts) > 0 {
+		opts := rawOpts[0]
+		lang.IfF(len(opts.Sort) > 0, func() { replaceOpts.SetSort(sortManyToD(opts.Sort)) })
+		lang.IfF(opts.Projection != nil, func() { replaceOpts.SetProjection(opts.Projection) })
+		lang.IfF(opts.Upsert, func() { replaceOpts.SetUpsert(opts.Upsert) })
+		lang.IfF(opts.ReturnUpdated, func() { replaceOpts.SetReturnDocument(options.After) })
+	}
+	return replaceOpts
+}
+
+func setFindOneAndUpdateOptions(rawOpts ...FindOneAndOptions) *options.FindOneAndUpdateOptionsBuilder {
+	updateOpts := options.FindOneAndUpdate()
+	if len(rawOpts) > 0 {
+		opts := rawOpts[0]
+		lang.IfF(len(opts.Sort) > 0, func() { updateOpts.SetSort(sortManyToD(opts.Sort)) })
+		lang.IfF(opts.Projection != nil, func() { updateOpts.SetProjection(opts.Projection) })
+		lang.IfF(opts.Upsert, func() { updateOpts.SetUpsert(opts.Upsert) })
+		lang.IfF(opts.ReturnUpdated, func() { updateOpts.SetReturnDocument(options.After) })
+	}
+	return updateOpts
+}
+
+func setAggregateOptions(rawOpts ...AggregateOptions) *options.AggregateOptionsBuilder {
+	aggOpts := options.Aggregate()
+	if len(rawOpts) > 0 {
+		opts := rawOpts[0]
+		lang.IfF(opts.AllowDiskUse, func() { aggOpts.SetAllowDiskUse(opts.AllowDiskUse) })
+		lang.IfF(opts.BatchSize > 0, func() { aggOpts.SetBatchSize(int32(opts.BatchSize)) })
+	}
+	return aggOpts
+}
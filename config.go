@@ -1,11 +1,38 @@
 package mongox
 
 import (
+	"fmt"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/maxbolgarin/lang"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver/auth"
+)
+
+const (
+	// DefaultAddress is the default address of MongoDB server.
+	DefaultAddress = "localhost:27017"
+
+	// DefaultConnectTimeout is the default maximum amount of time to wait for a connection to be established.
+	DefaultConnectTimeout = 30 * time.Second
+
+	// DefaultMaxPoolSize is the default maximum number of connections in the driver's connection pool to each server.
+	DefaultMaxPoolSize = 100
+
+	// DefaultAuthMechanism is the default authentication mechanism.
+	DefaultAuthMechanism = "SCRAM-SHA-256"
+
+	// DefaultSlowQueryThreshold is the default duration after which an operation is considered slow.
+	DefaultSlowQueryThreshold = 100 * time.Millisecond
+
+	// DefaultMaxDocumentSize is the default maximum size of an inserted document in bytes, the BSON limit of MongoDB.
+	DefaultMaxDocumentSize = 16 * 1024 * 1024
 )
 
 // Config contains database configuration for creating MongoDB client.
@@ -24,6 +51,15 @@ type Config struct {
 	// ReplicaSetName is the name of the replica set to connect to.
 	ReplicaSetName string `yaml:"replica_set_name" json:"replica_set_name" env:"MONGO_REPLICA_SET_NAME"`
 
+	// ReadPreference determines which servers are considered for reads by default, one of "primary",
+	// "primaryPreferred", "secondary", "secondaryPreferred" or "nearest". Default is primary.
+	ReadPreference string `yaml:"read_preference" json:"read_preference" env:"MONGO_READ_PREFERENCE"`
+
+	// ReadPreferenceTags is the list of tag sets of replica set members in the order of preference,
+	// e.g. [{"region": "eu"}, {}] to read from EU members first and from any member if there are none.
+	// An empty tag set matches any member. Tags cannot be used with "primary" read preference.
+	ReadPreferenceTags []map[string]string `yaml:"read_preference_tags" json:"read_preference_tags"`
+
 	// Compressors that can be used when communicating with a server.
 	// Valid values are: "snappy", "zlib", "zstd".
 	Compressors []string `yaml:"compressors" json:"compressors" env:"MONGO_COMPRESSORS"`
@@ -39,6 +75,60 @@ type Config struct {
 
 	// URI is a MongoDB connection string. You can provide it insted of all other settings.
 	URI string `yaml:"uri" json:"uri" env:"MONGO_URI"`
+
+	// GuardUnboundedWrites makes write methods (UpdateOne, UpdateMany, DeleteOne, DeleteMany, ReplaceOne, Upsert,
+	// SetFields, FindOneAndUpdate, etc.) with a nil or empty filter return ErrEmptyFilter to prevent accidental
+	// mass mutations or mutations of an arbitrary document. Reads with an empty filter are not affected.
+	// Use Truncate or a context from AllowUnboundedWrites to modify documents without a filter intentionally.
+	GuardUnboundedWrites bool `yaml:"guard_unbounded_writes" json:"guard_unbounded_writes" env:"MONGO_GUARD_UNBOUNDED_WRITES"`
+
+	// CheckDocumentSize makes insert methods and BulkWrite marshal inserted documents before sending them to the server
+	// and return ErrInvalidArgument wrapping ErrBSONObjectTooLarge with sizes of the largest fields
+	// if a document is larger than MaxDocumentSize. It is disabled by default, because documents are marshaled twice.
+	CheckDocumentSize bool `yaml:"check_document_size" json:"check_document_size" env:"MONGO_CHECK_DOCUMENT_SIZE"`
+
+	// MaxDocumentSize is the maximum size of an inserted document in bytes, it is used if CheckDocumentSize is enabled.
+	// Default is 16MB.
+	MaxDocumentSize int `yaml:"max_document_size" json:"max_document_size" env:"MONGO_MAX_DOCUMENT_SIZE"`
+
+	// SlowQueryThreshold is the duration after which an operation is considered slow and OnSlowOperation is called.
+	// Default is 100 milliseconds.
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold" json:"slow_query_threshold" env:"MONGO_SLOW_QUERY_THRESHOLD"`
+
+	// OnSlowOperation is called after a collection operation that takes longer than SlowQueryThreshold.
+	// Operation name is in snake case, e.g. "find_one" or "update_many".
+	// Filter is a redacted copy of the operation filter: it keeps keys and operators, but values are replaced with "?".
+	// It is called synchronously, so it should be fast. Nil means no slow operation tracking.
+	OnSlowOperation func(op, collection string, dur time.Duration, filter M) `yaml:"-" json:"-"`
+}
+
+// ConfigOverrides contains per-operation defaults for a client handle returned by [Client.WithConfig].
+// Nil fields mean the defaults of the parent client are used.
+type ConfigOverrides struct {
+	// ReadPreference determines which servers are considered for reads, e.g. readpref.SecondaryPreferred().
+	ReadPreference *readpref.ReadPref
+
+	// ReadConcern determines the consistency and isolation of the read data, e.g. readconcern.Majority().
+	ReadConcern *readconcern.ReadConcern
+
+	// WriteConcern determines the level of acknowledgment requested for writes, e.g. writeconcern.Majority().
+	WriteConcern *writeconcern.WriteConcern
+}
+
+// merge returns overrides with non-nil fields of other replacing the fields of o.
+func (o ConfigOverrides) merge(other ConfigOverrides) ConfigOverrides {
+	lang.IfF(other.ReadPreference != nil, func() { o.ReadPreference = other.ReadPreference })
+	lang.IfF(other.ReadConcern != nil, func() { o.ReadConcern = other.ReadConcern })
+	lang.IfF(other.WriteConcern != nil, func() { o.WriteConcern = other.WriteConcern })
+	return o
+}
+
+func (o ConfigOverrides) databaseOptions() *options.DatabaseOptionsBuilder {
+	opts := options.Database()
+	lang.IfF(o.ReadPreference != nil, func() { opts.SetReadPreference(o.ReadPreference) })
+	lang.IfF(o.ReadConcern != nil, func() { opts.SetReadConcern(o.ReadConcern) })
+	lang.IfF(o.WriteConcern != nil, func() { opts.SetWriteConcern(o.WriteConcern) })
+	return opts
 }
 
 // ConnectionConfig contains connection pool configuration for creating MongoDB client.
@@ -227,11 +317,85 @@ type BSONOptions struct {
 	ZeroStructs bool `yaml:"zero_structs" json:"zero_structs"`
 }
 
+// Read reads the config from the file if it is provided or from environment variables otherwise.
+// It applies defaults to the read config, see [Config.ApplyDefaults].
 func (cfg *Config) Read(fileName ...string) error {
+	var err error
 	if len(fileName) > 0 {
-		return cleanenv.ReadConfig(fileName[0], cfg)
+		err = cleanenv.ReadConfig(fileName[0], cfg)
+	} else {
+		err = cleanenv.ReadEnv(cfg)
+	}
+	if err != nil {
+		return err
+	}
+	cfg.ApplyDefaults()
+	return nil
+}
+
+// ApplyDefaults fills unset fields of the config with documented defaults: Address if there are no URI and Hosts,
+// Connection.ConnectTimeout and Connection.MaxPoolSize if there is no URI and Auth.AuthMechanism if Auth is provided.
+// These are the values that are used by Connect anyway, so the config is self-consistent for logging and validation.
+// Connection settings are not filled if URI is provided, because they would override options from the URI.
+func (cfg *Config) ApplyDefaults() {
+	if cfg.Auth != nil && cfg.Auth.AuthMechanism == "" {
+		cfg.Auth.AuthMechanism = DefaultAuthMechanism
+	}
+	if cfg.URI != "" {
+		return
+	}
+	if cfg.Address == "" && len(cfg.Hosts) == 0 {
+		cfg.Address = DefaultAddress
 	}
-	return cleanenv.ReadEnv(cfg)
+	if cfg.Connection == nil {
+		cfg.Connection = &ConnectionConfig{}
+	}
+	if cfg.Connection.ConnectTimeout == nil {
+		cfg.Connection.ConnectTimeout = lang.Ptr(DefaultConnectTimeout)
+	}
+	if cfg.Connection.MaxPoolSize == nil {
+		cfg.Connection.MaxPoolSize = lang.Ptr(uint64(DefaultMaxPoolSize))
+	}
+}
+
+// Validate returns ErrInvalidArgument if the config contains contradictory or invalid settings,
+// e.g. URI together with Address or Hosts or MinPoolSize greater than MaxPoolSize.
+func (cfg *Config) Validate() error {
+	var errs []string
+	if cfg.URI != "" && (cfg.Address != "" || len(cfg.Hosts) > 0) {
+		errs = append(errs, "URI cannot be used together with Address or Hosts")
+	}
+	for _, c := range cfg.Compressors {
+		if !supportedCompressors[c] {
+			errs = append(errs, fmt.Sprintf("unsupported compressor %q", c))
+		}
+	}
+	if conn := cfg.Connection; conn != nil && conn.MinPoolSize != nil && conn.MaxPoolSize != nil &&
+		*conn.MaxPoolSize > 0 && *conn.MinPoolSize > *conn.MaxPoolSize {
+		errs = append(errs, fmt.Sprintf("MinPoolSize %d is greater than MaxPoolSize %d", *conn.MinPoolSize, *conn.MaxPoolSize))
+	}
+	if cfg.Auth != nil && cfg.Auth.AuthMechanism == auth.MongoDBX509 && cfg.Auth.Password != "" {
+		errs = append(errs, "Password must not be specified for MONGODB-X509 authentication")
+	}
+	if _, ok := readPreferenceModes[cfg.ReadPreference]; cfg.ReadPreference != "" && !ok {
+		errs = append(errs, fmt.Sprintf("unsupported read preference %q", cfg.ReadPreference))
+	}
+	if len(cfg.ReadPreferenceTags) > 0 && (cfg.ReadPreference == "" || cfg.ReadPreference == "primary") {
+		errs = append(errs, "ReadPreferenceTags cannot be used with primary read preference")
+	}
+	if !validTagSets(cfg.ReadPreferenceTags) {
+		errs = append(errs, "ReadPreferenceTags cannot contain empty keys or values")
+	}
+	if cfg.SlowQueryThreshold < 0 {
+		errs = append(errs, "SlowQueryThreshold cannot be negative")
+	}
+	if cfg.MaxDocumentSize < 0 {
+		errs = append(errs, "MaxDocumentSize cannot be negative")
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidArgument, strings.Join(errs, ", "))
+	}
+	return nil
 }
 
 // ExportedBuildURL is a wrapper around buildURL for testing purposes
@@ -314,3 +478,40 @@ var supportedLanguages = map[string]bool{
 	"turkish":    true,
 	"tr":         true,
 }
+
+var supportedCompressors = map[string]bool{
+	"snappy": true,
+	"zlib":   true,
+	"zstd":   true,
+}
+
+var validationLevels = map[string]bool{
+	"off":      true,
+	"strict":   true,
+	"moderate": true,
+}
+
+var validationActions = map[string]bool{
+	"error": true,
+	"warn":  true,
+}
+
+// validTagSets reports whether all tags of the read preference tag sets have non-empty keys and values.
+func validTagSets(tagSets []map[string]string) bool {
+	for _, set := range tagSets {
+		for k, v := range set {
+			if k == "" || v == "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var readPreferenceModes = map[string]readpref.Mode{
+	"primary":            readpref.PrimaryMode,
+	"primaryPreferred":   readpref.PrimaryPreferredMode,
+	"secondary":          readpref.SecondaryMode,
+	"secondaryPreferred": readpref.SecondaryPreferredMode,
+	"nearest":            readpref.NearestMode,
+}
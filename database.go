@@ -2,19 +2,64 @@ package mongox
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"sync"
+	"time"
 
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
+// Delays between attempts in WithTransactionRetry.
+const (
+	// DefaultTransactionRetryDelay is the delay before the second attempt, it doubles with every next attempt.
+	DefaultTransactionRetryDelay = 20 * time.Millisecond
+	// MaxTransactionRetryDelay is the maximum delay between attempts.
+	MaxTransactionRetryDelay = 2 * time.Second
+)
+
+// Delays between reconnect attempts of long-lived reads: ScanAll, WatchInserts, WatchUpdates and WatchDeletes.
+const (
+	// DefaultReconnectDelay is the delay before the first reconnect, it doubles with every next attempt.
+	DefaultReconnectDelay = 100 * time.Millisecond
+	// MaxReconnectDelay is the maximum delay between reconnect attempts.
+	MaxReconnectDelay = 5 * time.Second
+)
+
+// OpInfo is a description of an operation currently running on the server, returned by [Database.CurrentOps].
+type OpInfo struct {
+	// OpID is the identifier of the operation, pass it to [Database.KillOp] to terminate the operation.
+	OpID int64 `bson:"opid"`
+	// Op is the type of the operation, e.g. "query", "update", "command".
+	Op string `bson:"op"`
+	// Namespace is the target of the operation in the form "database.collection".
+	Namespace string `bson:"ns"`
+	// Active is true if the operation has started.
+	Active bool `bson:"active"`
+	// SecsRunning is the duration of the operation in seconds.
+	SecsRunning int64 `bson:"secs_running"`
+	// Client is the address of the client that runs the operation.
+	Client string `bson:"client"`
+	// Desc is the description of the client or the internal thread.
+	Desc string `bson:"desc"`
+	// Command is the full command document of the operation.
+	Command bson.Raw `bson:"command"`
+	// Comment is the comment attached to the command, e.g. set with [Collection.WithComment].
+	Comment string `bson:"-"`
+}
+
 // Database is a database client with open connection that creates collections and handles transactions.
 // It is safe for concurrent use by multiple goroutines.
 type Database struct {
-	db *mongo.Database
+	db  *mongo.Database
+	cfg *Config
 
-	colls map[string]*Collection
-	mu    sync.RWMutex
+	colls      map[string]*Collection
+	replicaSet *bool
+	mu         sync.RWMutex
 }
 
 // Database returns the underlying mongo database.
@@ -35,6 +80,7 @@ func (m *Database) Collection(name string) *Collection {
 
 	db := &Collection{
 		coll: m.db.Collection(name),
+		cfg:  m.cfg,
 	}
 
 	m.mu.Lock()
@@ -44,11 +90,146 @@ func (m *Database) Collection(name string) *Collection {
 	return db
 }
 
+// Watch opens a change stream on all collections of the database and calls fn for every change event passed
+// through the pipeline, ChangeEvent.Namespace tells the changed collection. It works like [Collection.Watch]:
+// it blocks until ctx is canceled (returning nil), fn returns an error or the stream fails.
+// Change streams are available only for replica sets and sharded clusters.
+func (m *Database) Watch(ctx context.Context, pipeline []M, fn func(event ChangeEvent) error, opts ...WatchOptions) error {
+	if fn == nil {
+		return fmt.Errorf("%w: nil callback", ErrInvalidArgument)
+	}
+	return watchChanges(ctx, m.db, preparePipeline(pipeline), options.ChangeStream(), opts, func(cur *mongo.ChangeStream) error {
+		event, err := decodeChangeEvent(cur)
+		if err != nil {
+			return err
+		}
+		return fn(event)
+	})
+}
+
+// IsReplicaSet reports whether the database is served by a replica set or a sharded cluster,
+// so it supports transactions and change streams, unlike a standalone server.
+// The result is cached after the first successful check, so it doesn't make a round trip on every call.
+func (m *Database) IsReplicaSet(ctx context.Context) (bool, error) {
+	m.mu.RLock()
+	cached := m.replicaSet
+	m.mu.RUnlock()
+	if cached != nil {
+		return *cached, nil
+	}
+
+	isReplicaSet, err := queryReplicaSet(ctx, m.db.Client())
+	if err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	m.replicaSet = &isReplicaSet
+	m.mu.Unlock()
+
+	return isReplicaSet, nil
+}
+
+// queryReplicaSet asks the server whether it is a member of a replica set or a mongos router.
+func queryReplicaSet(ctx context.Context, client *mongo.Client) (bool, error) {
+	var res struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&res)
+	if err != nil {
+		return false, HandleMongoError(err)
+	}
+	// Replica set members report the name of the set, mongos reports "isdbgrid"
+	return res.SetName != "" || res.Msg == "isdbgrid", nil
+}
+
+// serverVersionAtLeast reports whether the version of the server is at least major.minor.
+func serverVersionAtLeast(ctx context.Context, db *mongo.Database, major, minor int32) (bool, error) {
+	var res struct {
+		VersionArray []int32 `bson:"versionArray"`
+	}
+	if err := db.RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&res); err != nil {
+		return false, HandleMongoError(err)
+	}
+	if len(res.VersionArray) < 2 {
+		return false, fmt.Errorf("%w: unexpected server version %v", ErrBadServer, res.VersionArray)
+	}
+	v := res.VersionArray
+	return v[0] > major || (v[0] == major && v[1] >= minor), nil
+}
+
+// BatchReads runs fn with a context bound to a single session, so all reads made with sctx reuse one server session
+// instead of checking out an implicit session per operation. Use it for a burst of related reads to reduce
+// the session churn on the server, which can end with ErrTooManyLogicalSessions under high concurrency.
+// The session is causally consistent, so reads observe writes made with sctx before them.
+// It is for reads, not for transactions: operations are not atomic, use WithTransaction for that.
+// The session is ended after fn returns, fn should not use sctx after that. It returns the error of fn.
+func (m *Database) BatchReads(ctx context.Context, fn func(sctx context.Context) error) error {
+	if fn == nil {
+		return fmt.Errorf("%w: nil function", ErrInvalidArgument)
+	}
+
+	session, err := m.db.Client().StartSession()
+	if err != nil {
+		return HandleMongoError(err)
+	}
+	defer session.EndSession(ctx)
+
+	return fn(mongo.NewSessionContext(ctx, session))
+}
+
+// SnapshotReads runs fn with a context bound to a session with "snapshot" read concern, so all reads made with sctx
+// see the same point-in-time snapshot of the data, even across collections, e.g. for consistent reporting.
+// The snapshot is taken at the time of the first read in fn. Writes are not allowed in fn.
+// It returns ErrTransactionsUnsupported without calling fn if the server is standalone.
+// The session is ended after fn returns, fn should not use sctx after that. It returns the error of fn.
+func (m *Database) SnapshotReads(ctx context.Context, fn func(sctx context.Context) error) error {
+	if fn == nil {
+		return fmt.Errorf("%w: nil function", ErrInvalidArgument)
+	}
+	isReplicaSet, err := m.IsReplicaSet(ctx)
+	if err != nil {
+		return err
+	}
+	if !isReplicaSet {
+		return ErrTransactionsUnsupported
+	}
+
+	session, err := m.db.Client().StartSession(options.Session().SetSnapshot(true))
+	if err != nil {
+		return HandleMongoError(err)
+	}
+	defer session.EndSession(ctx)
+
+	return fn(mongo.NewSessionContext(ctx, session))
+}
+
 // WithTransaction executes a transaction.
 // It will create a new session and execute a function inside a transaction.
 // The fn callback may be run multiple times during WithTransaction due to retry attempts, so it must be idempotent.
 // Warning! Transactions in MongoDB is available only for replica sets or Sharded Clusters, not for standalone servers.
+// It returns ErrTransactionsUnsupported without calling fn if the server is standalone.
+// Server errors of the transaction are mapped to errors of the package, e.g. ErrWriteConflict,
+// so a manual optimistic retry loop doesn't need to match error messages:
+//
+//	for attempt := 0; attempt < 5; attempt++ {
+//		_, err = db.WithTransaction(ctx, fn)
+//		if !errors.Is(err, mongox.ErrWriteConflict) {
+//			break
+//		}
+//	}
+//
+// Use WithTransactionRetry to retry transient transaction errors with backoff.
 func (m *Database) WithTransaction(ctx context.Context, fn func(context.Context) (any, error)) (any, error) {
+	isReplicaSet, err := m.IsReplicaSet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !isReplicaSet {
+		return nil, ErrTransactionsUnsupported
+	}
+
 	session, err := m.db.Client().StartSession()
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrNetwork, err)
@@ -58,8 +239,176 @@ func (m *Database) WithTransaction(ctx context.Context, fn func(context.Context)
 	// It commits the transaction.
 	result, err := session.WithTransaction(ctx, fn)
 	if err != nil {
-		return nil, err
+		return nil, transactionError(err)
 	}
 
 	return result, nil
 }
+
+// transactionError maps the server error of the transaction to the error of the package, e.g. ErrWriteConflict.
+// The original error is kept in the chain, so its error labels are still available for IsTransient.
+func transactionError(err error) error {
+	var ce mongo.CommandError
+	if !errors.As(err, &ce) {
+		return err
+	}
+	sentinel, ok := ErrorFromCode(ce.Code)
+	if !ok || errors.Is(err, sentinel) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
+
+// isTransientTransaction reports whether the whole transaction can be re-run after the error.
+// Unlike IsTransient, it doesn't treat network errors as transient: the driver adds TransientTransactionError label
+// to network errors of operations inside the transaction, and a network error of the commit may hide a successful commit.
+func isTransientTransaction(err error) bool {
+	if err == nil {
+		return false
+	}
+	var se mongo.ServerError
+	if errors.As(err, &se) {
+		if se.HasErrorLabel(unknownCommitResultLabel) {
+			return false
+		}
+		if se.HasErrorLabel(transientTransactionLabel) || se.HasErrorCode(112) || se.HasErrorCode(251) {
+			return true
+		}
+	}
+	return errors.Is(err, ErrWriteConflict) || errors.Is(err, ErrNoSuchTransaction)
+}
+
+// WithTransactionRetry executes a transaction like WithTransaction, but re-runs it if it fails with an error
+// with TransientTransactionError label, WriteConflict under contention or NoSuchTransaction, up to maxAttempts times in total.
+// Network errors without the label and errors with UnknownTransactionCommitResult label are not retried,
+// because the transaction may be already committed and re-running fn would apply its writes twice.
+// Delay between attempts starts from DefaultTransactionRetryDelay and doubles with every attempt up to MaxTransactionRetryDelay,
+// random jitter is added to spread competing transactions. The fn callback must be idempotent.
+// It returns the error of the last attempt or the context error if the context is done while waiting.
+// It returns ErrInvalidArgument if maxAttempts is less than 1.
+func (m *Database) WithTransactionRetry(ctx context.Context, maxAttempts int, fn func(context.Context) (any, error)) (any, error) {
+	if maxAttempts < 1 {
+		return nil, fmt.Errorf("%w: maxAttempts must be positive, got %d", ErrInvalidArgument, maxAttempts)
+	}
+
+	var err error
+	for attempt := range maxAttempts {
+		if attempt > 0 {
+			timer := time.NewTimer(transactionRetryDelay(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, HandleMongoError(ctx.Err())
+			case <-timer.C:
+			}
+		}
+
+		var result any
+		result, err = m.WithTransaction(ctx, fn)
+		if !isTransientTransaction(err) {
+			return result, err
+		}
+	}
+	return nil, err
+}
+
+// RunCommand runs the database command and decodes the result into dest, e.g. {dbStats: 1}.
+// Command can be M with a single key or bson.D, use bson.D for commands with options,
+// because the command name must be the first key and M doesn't preserve order.
+// Nil dest means that the result is not decoded, only the error is checked.
+// It returns ErrInvalidArgument if the command is empty or M has several keys.
+func (m *Database) RunCommand(ctx context.Context, cmd any, dest any) error {
+	return m.runCommand(ctx, cmd, dest)
+}
+
+// RunCommandOn runs the database command like RunCommand, but with the provided read preference, e.g.
+// "secondaryPreferred" for reporting commands that shouldn't load the primary. Supported read preferences are
+// "primary", "primaryPreferred", "secondary", "secondaryPreferred" and "nearest".
+// It returns ErrInvalidArgument if the read preference is not supported.
+func (m *Database) RunCommandOn(ctx context.Context, rp string, cmd any, dest any) error {
+	pref, err := newReadPref(rp)
+	if err != nil {
+		return err
+	}
+	return m.runCommand(ctx, cmd, dest, options.RunCmd().SetReadPreference(pref))
+}
+
+func (m *Database) runCommand(ctx context.Context, cmd any, dest any, opts ...options.Lister[options.RunCmdOptions]) error {
+	if filter, ok := cmd.(M); ok {
+		if len(filter) > 1 {
+			return fmt.Errorf("%w: command with several keys must be bson.D to keep the command name first", ErrInvalidArgument)
+		}
+		cmd = filter.Prepare()
+	}
+	if cmd == nil {
+		return fmt.Errorf("%w: empty command", ErrInvalidArgument)
+	}
+	if d, ok := cmd.(bson.D); ok && len(d) == 0 {
+		return fmt.Errorf("%w: empty command", ErrInvalidArgument)
+	}
+
+	res := m.db.RunCommand(ctx, cmd, opts...)
+	if dest == nil {
+		return HandleMongoError(res.Err())
+	}
+	return HandleMongoError(res.Decode(dest))
+}
+
+// CurrentOps returns operations that are currently running on the server using currentOp admin command.
+// Use OpInfo.Comment to find an operation started with [Collection.WithComment] and terminate it with KillOp.
+// It returns ErrUnauthorized if the user doesn't have the inprog privilege.
+func (m *Database) CurrentOps(ctx context.Context) ([]OpInfo, error) {
+	var res struct {
+		InProg []OpInfo `bson:"inprog"`
+	}
+	err := m.db.Client().Database("admin").RunCommand(ctx, bson.D{{Key: "currentOp", Value: 1}}).Decode(&res)
+	if err != nil {
+		return nil, HandleMongoError(err)
+	}
+	for i, op := range res.InProg {
+		if comment, ok := op.Command.Lookup("comment").StringValueOK(); ok {
+			res.InProg[i].Comment = comment
+		}
+	}
+	return res.InProg, nil
+}
+
+// KillOp terminates the operation with the provided ID using killOp admin command.
+// Use [Database.CurrentOps] to get ID of the operation. The operation is terminated at the next interrupt point,
+// so it can be still running for a short time after KillOp returns.
+// It returns ErrUnauthorized if the user doesn't have the killop privilege.
+func (m *Database) KillOp(ctx context.Context, opid int64) error {
+	cmd := bson.D{{Key: "killOp", Value: 1}, {Key: "op", Value: opid}}
+	if err := m.db.Client().Database("admin").RunCommand(ctx, cmd).Err(); err != nil {
+		return HandleMongoError(err)
+	}
+	return nil
+}
+
+// transactionRetryDelay returns exponential delay before the attempt with jitter in range [delay/2, delay].
+func transactionRetryDelay(attempt int) time.Duration {
+	return backoffDelay(DefaultTransactionRetryDelay, MaxTransactionRetryDelay, attempt)
+}
+
+// backoffDelay returns delay before the attempt that starts from base and doubles with every attempt up to maxDelay,
+// with jitter in range [delay/2, delay].
+func backoffDelay(base, maxDelay time.Duration, attempt int) time.Duration {
+	delay := maxDelay
+	if attempt < 16 {
+		delay = min(base<<(attempt-1), maxDelay)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// waitReconnect waits for the backoff delay before the reconnect attempt of a long-lived read.
+// It returns the error of the context if it is done while waiting.
+func waitReconnect(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(backoffDelay(DefaultReconnectDelay, MaxReconnectDelay, attempt))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
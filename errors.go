@@ -1,11 +1,15 @@
 package mongox
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"regexp"
 	"strings"
 	"sync"
 
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
@@ -20,6 +24,26 @@ var (
 	ErrTimeout             = errors.New("timeout")
 	ErrBadServer           = errors.New("bad server")
 	ErrUnsupportedLanguage = errors.New("unsupported language")
+	// ErrEmptyFilter is returned by write methods called with a nil or empty filter when Config.GuardUnboundedWrites
+	// is enabled. Reads are permissive: nil or empty filter in FindOne, Find, Count, Distinct, etc. matches all documents.
+	// It wraps ErrInvalidArgument, so errors.Is(err, ErrInvalidArgument) is true for it.
+	ErrEmptyFilter = fmt.Errorf("%w: empty filter", ErrInvalidArgument)
+	// ErrTransactionsUnsupported is returned by WithTransaction and SnapshotReads when the server is a standalone instance.
+	ErrTransactionsUnsupported = errors.New("transactions are not supported: they require a replica set or a sharded cluster, " +
+		"run a single-node replica set for development")
+	// ErrCausalConsistencyUnsupported is returned by FindAfterClusterTime when the server is a standalone instance,
+	// because it doesn't have a cluster time to wait for.
+	ErrCausalConsistencyUnsupported = errors.New("reads after cluster time are not supported: they require a replica set " +
+		"or a sharded cluster")
+	// ErrWriteConcernTimeout is returned when the write concern is not satisfied in time (wtimeout).
+	// Unlike a write error, the write was applied on the primary and likely persists, it just wasn't acknowledged
+	// by enough members yet, so don't treat it as a failed write. It wraps ErrWriteConcernFailed.
+	ErrWriteConcernTimeout = fmt.Errorf("%w: write concern timeout", ErrWriteConcernFailed)
+	// ErrCappedConstraint is returned when a write violates the constraints of a capped collection, e.g. a document
+	// is larger than the whole collection (DocTooLargeForCapped) or an update changes the size of a document on servers
+	// before 6.0 (CannotGrowDocumentInCappedNamespace). Inserts don't fail when the collection is full:
+	// the oldest documents are evicted to make room, so don't treat eviction as an error in log and metrics buffers.
+	ErrCappedConstraint = errors.New("capped collection constraint")
 )
 
 // Mongo errors from codes
@@ -133,7 +157,7 @@ var (
 	ErrInitialSyncFailure                                          = errors.New("InitialSyncFailure, code 113")
 	ErrInitialSyncOplogSourceMissing                               = errors.New("InitialSyncOplogSourceMissing, code 114")
 	ErrCommandNotSupported                                         = errors.New("CommandNotSupported, code 115")
-	ErrDocTooLargeForCapped                                        = errors.New("DocTooLargeForCapped, code 116")
+	ErrDocTooLargeForCapped                                        = fmt.Errorf("%w: DocTooLargeForCapped, code 116", ErrCappedConstraint)
 	ErrConflictingOperationInProgress                              = errors.New("ConflictingOperationInProgress, code 117")
 	ErrNamespaceNotSharded                                         = errors.New("NamespaceNotSharded, code 118")
 	ErrInvalidSyncSource                                           = errors.New("InvalidSyncSource, code 119")
@@ -344,7 +368,7 @@ var (
 	ErrMechanismUnavailable                                        = errors.New("MechanismUnavailable, code 334")
 	ErrTenantMigrationForgotten                                    = errors.New("TenantMigrationForgotten, code 335")
 	ErrSocketException                                             = errors.New("SocketException, code 9001")
-	ErrCannotGrowDocumentInCappedNamespace                         = errors.New("CannotGrowDocumentInCappedNamespace, code 10003")
+	ErrCannotGrowDocumentInCappedNamespace                         = fmt.Errorf("%w: CannotGrowDocumentInCappedNamespace, code 10003", ErrCappedConstraint)
 	ErrNotWritablePrimary                                          = errors.New("NotWritablePrimary, code 10107")
 	ErrBSONObjectTooLarge                                          = errors.New("BSONObjectTooLarge, code 10334")
 	ErrDuplicateKey                                                = errors.New("DuplicateKey, code 11000")
@@ -690,6 +714,206 @@ var errorMap = map[int32]error{
 
 var mu sync.RWMutex
 
+// isDuplicateKeyCode reports whether the code is one of the MongoDB duplicate key error codes.
+func isDuplicateKeyCode(code int) bool {
+	return code == 11000 || code == 11001 || code == 12582
+}
+
+// DuplicateKeyError is returned when a write violates a unique index.
+// It matches ErrDuplicate with errors.Is, so checking for ErrDuplicate is enough if you don't need the details.
+type DuplicateKeyError struct {
+	// Keys contains values of the index keys that collided, e.g. {"email": "user@example.com"}.
+	// It is nil if the server didn't return the key values.
+	Keys map[string]any
+
+	err error
+}
+
+// Error returns the error message.
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrDuplicate, e.err)
+}
+
+// Unwrap returns ErrDuplicate and the original MongoDB error.
+func (e *DuplicateKeyError) Unwrap() []error {
+	return []error{ErrDuplicate, e.err}
+}
+
+// DecodeError is returned when a document cannot be decoded into the destination, e.g. a field of the document
+// has a BSON type that doesn't match the type of the struct field after a schema drift.
+// It matches ErrInvalidArgument with errors.Is.
+type DecodeError struct {
+	// Field is the path of the failed field in dot notation, e.g. "struct.number".
+	Field string
+	// Actual is the BSON type of the value in the document, e.g. "string".
+	// It is empty if it cannot be found in the error of the driver.
+	Actual string
+	// Expected is the description of the destination type, e.g. "an integer type".
+	// It is empty if it cannot be found in the error of the driver.
+	Expected string
+
+	err error
+}
+
+// Error returns the error message, e.g. `invalid argument: field "age": cannot decode string into an integer type`.
+func (e *DecodeError) Error() string {
+	if e.Actual != "" && e.Expected != "" {
+		return fmt.Sprintf("%v: field %q: cannot decode %s into %s", ErrInvalidArgument, e.Field, e.Actual, e.Expected)
+	}
+	return fmt.Sprintf("%v: field %q: %v", ErrInvalidArgument, e.Field, errors.Unwrap(e.err))
+}
+
+// Unwrap returns ErrInvalidArgument and the original decode error.
+func (e *DecodeError) Unwrap() []error {
+	return []error{ErrInvalidArgument, e.err}
+}
+
+// decodeMessageRegexp matches messages of the driver decoders, e.g. "cannot decode string into an integer type".
+var decodeMessageRegexp = regexp.MustCompile(`cannot decode (.+?) into (.+)$`)
+
+func newDecodeError(err *bson.DecodeError) *DecodeError {
+	out := &DecodeError{Field: strings.Join(err.Keys(), "."), err: err}
+	if m := decodeMessageRegexp.FindStringSubmatch(errors.Unwrap(err).Error()); m != nil {
+		out.Actual, out.Expected = m[1], m[2]
+	}
+	return out
+}
+
+// duplicateKeys returns values of the collided keys from the first duplicate key error.
+func duplicateKeys(err error) map[string]any {
+	var raws []bson.Raw
+
+	var writeError mongo.WriteException
+	if errors.As(err, &writeError) {
+		for _, we := range writeError.WriteErrors {
+			if isDuplicateKeyCode(we.Code) {
+				raws = append(raws, we.Raw)
+			}
+		}
+	}
+	var bwe mongo.BulkWriteException
+	if errors.As(err, &bwe) {
+		for _, we := range bwe.WriteErrors {
+			if isDuplicateKeyCode(we.Code) {
+				raws = append(raws, we.Raw)
+			}
+		}
+	}
+	var ce mongo.CommandError
+	if errors.As(err, &ce) {
+		raws = append(raws, ce.Raw)
+	}
+
+	for _, raw := range raws {
+		keyValue, ok := raw.Lookup("keyValue").DocumentOK()
+		if !ok {
+			continue
+		}
+		var keys map[string]any
+		if err := bson.Unmarshal(keyValue, &keys); err == nil {
+			return keys
+		}
+	}
+	return nil
+}
+
+// transientTransactionLabel is the error label of errors after which the whole transaction can be retried.
+const transientTransactionLabel = "TransientTransactionError"
+
+// unknownCommitResultLabel is the error label of commit errors after which it is unknown whether the transaction is committed.
+const unknownCommitResultLabel = "UnknownTransactionCommitResult"
+
+// IsTransient reports whether the error is temporary and the operation or the transaction can be retried,
+// e.g. WriteConflict, NoSuchTransaction, an error with TransientTransactionError label or a network error.
+// Use IsRetryableRead for cursor reads, it also treats CursorNotFound as temporary.
+// It works both with errors from MongoDB driver and errors returned by methods of the package,
+// so it is a single classification for retry loops of transactions and optimistic concurrency updates.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	var se mongo.ServerError
+	if errors.As(err, &se) && (se.HasErrorLabel(transientTransactionLabel) ||
+		se.HasErrorCode(112) || se.HasErrorCode(251)) {
+		return true
+	}
+	return errors.Is(err, ErrWriteConflict) ||
+		errors.Is(err, ErrNoSuchTransaction) ||
+		errors.Is(err, ErrNetwork) ||
+		mongo.IsNetworkError(err)
+}
+
+// IsRetryableRead reports whether a cursor read, e.g. AggregateEach or a batch of ScanAll, can be restarted
+// after the error. It is a transient error (see [IsTransient]) or CursorNotFound: the cursor of a long read
+// was killed or timed out on the server between batches.
+// Don't use it for transactions, a killed cursor doesn't mean the transaction can be re-run.
+func IsRetryableRead(err error) bool {
+	if IsTransient(err) {
+		return true
+	}
+	var se mongo.ServerError
+	return errors.Is(err, ErrCursorNotFound) || (errors.As(err, &se) && se.HasErrorCode(43))
+}
+
+// httpStatuses maps errors of the package to HTTP status codes, the first matching error wins.
+var httpStatuses = []struct {
+	err    error
+	status int
+}{
+	{ErrNotFound, http.StatusNotFound},
+	{ErrNamespaceNotFound, http.StatusNotFound},
+	{ErrDuplicate, http.StatusConflict},
+	{ErrDuplicateKey, http.StatusConflict},
+	{ErrWriteConflict, http.StatusConflict},
+	{ErrInvalidArgument, http.StatusBadRequest},
+	{ErrBadValue, http.StatusBadRequest},
+	{ErrFailedToParse, http.StatusBadRequest},
+	{ErrTypeMismatch, http.StatusBadRequest},
+	{ErrDocumentValidationFailure, http.StatusUnprocessableEntity},
+	{ErrAuthenticationFailed, http.StatusUnauthorized},
+	{ErrUnauthorized, http.StatusForbidden},
+	{ErrTimeout, http.StatusGatewayTimeout},
+	{ErrMaxTimeMSExpired, http.StatusGatewayTimeout},
+	{ErrExceededTimeLimit, http.StatusGatewayTimeout},
+	{ErrNetworkTimeout, http.StatusGatewayTimeout},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout},
+	{ErrNetwork, http.StatusServiceUnavailable},
+	{ErrHostUnreachable, http.StatusServiceUnavailable},
+	{ErrNotWritablePrimary, http.StatusServiceUnavailable},
+}
+
+// HTTPStatus returns the HTTP status code for the error returned by methods of the package, so API services
+// don't need to repeat the mapping, e.g. 404 for ErrNotFound, 409 for ErrDuplicate and ErrWriteConflict,
+// 400 for ErrInvalidArgument, 422 for ErrDocumentValidationFailure, 504 for timeouts and 503 for network errors.
+// It returns 200 for nil error and 500 for any other error.
+func HTTPStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	for _, s := range httpStatuses {
+		if errors.Is(err, s.err) {
+			return s.status
+		}
+	}
+	if IsTransient(err) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// writeConcernError maps the write concern error to the error of the package.
+// The timeout of the write concern is reported by the wtimeout flag in errInfo and it is mapped to ErrWriteConcernTimeout.
+func writeConcernError(wce *mongo.WriteConcernError) error {
+	if wtimeout, ok := wce.Details.Lookup("wtimeout").BooleanOK(); ok && wtimeout {
+		return fmt.Errorf("%w: %v", ErrWriteConcernTimeout, wce)
+	}
+	errFromCode, ok := ErrorFromCode(int32(wce.Code))
+	if !ok {
+		return wce
+	}
+	return fmt.Errorf("%w: %v", errFromCode, wce)
+}
+
 // ErrorFromCode returns an error variable from a MongoDB error code.
 func ErrorFromCode(code int32) (error, bool) {
 	mu.RLock()
@@ -704,12 +928,17 @@ func HandleMongoError(err error) error {
 		return nil
 	}
 
+	var decodeErr *bson.DecodeError
+	if errors.As(err, &decodeErr) {
+		return newDecodeError(decodeErr)
+	}
+
 	switch {
 	case errors.Is(err, mongo.ErrNoDocuments):
 		return ErrNotFound
 
 	case mongo.IsDuplicateKeyError(err):
-		return fmt.Errorf("%w: %v", ErrDuplicate, err)
+		return &DuplicateKeyError{Keys: duplicateKeys(err), err: err}
 
 	case mongo.IsNetworkError(err) ||
 		errors.Is(err, mongo.ErrWrongClient) ||
@@ -774,12 +1003,7 @@ func HandleMongoError(err error) error {
 			errs = append(errs, fmt.Errorf("%w: %v", errFromCode, we))
 		}
 		if we := writeError.WriteConcernError; we != nil {
-			errFromCode, ok := ErrorFromCode(int32(we.Code))
-			if !ok {
-				errs = append(errs, we)
-			} else {
-				errs = append(errs, fmt.Errorf("%w: %v", errFromCode, we))
-			}
+			errs = append(errs, writeConcernError(we))
 		}
 		return errors.Join(errs...)
 	}
@@ -805,6 +1029,9 @@ func HandleMongoError(err error) error {
 			}
 			errs = append(errs, fmt.Errorf("%w: %v", errFromCode, we))
 		}
+		if we := bwe.WriteConcernError; we != nil {
+			errs = append(errs, writeConcernError(we))
+		}
 		return errors.Join(errs...)
 	}
 
@@ -2,9 +2,17 @@ package mongox
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 
+	"github.com/maxbolgarin/lang"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
 )
 
 // Name returns the name of the collection.
@@ -18,6 +26,31 @@ func CreateIndex(ctx context.Context, coll *Collection, isUnique bool, fieldName
 	return coll.CreateIndex(ctx, isUnique, fieldNames...)
 }
 
+// CreateIndexWithOptions creates an index for a collection with the given field names and options.
+// Field names are required and must be unique.
+func CreateIndexWithOptions(ctx context.Context, coll *Collection, opts IndexOptions, fieldNames ...string) error {
+	return coll.CreateIndexWithOptions(ctx, opts, fieldNames...)
+}
+
+// CreateIndexIdempotent creates an index like CreateIndexWithOptions, but it is safe to call it many times, e.g. in migrations.
+// It returns nil if an identical index already exists and a descriptive ErrIndexOptionsConflict or
+// ErrIndexKeySpecsConflict error if an index with the same name or keys has different options.
+func CreateIndexIdempotent(ctx context.Context, coll *Collection, opts IndexOptions, fieldNames ...string) error {
+	return coll.CreateIndexIdempotent(ctx, opts, fieldNames...)
+}
+
+// SetIndexHidden hides the index with the provided name from the query planner or exposes it back.
+// It returns ErrIndexNotFound if there is no such index.
+func SetIndexHidden(ctx context.Context, coll *Collection, name string, hidden bool) error {
+	return coll.SetIndexHidden(ctx, name, hidden)
+}
+
+// CreatePartialUniqueIndex creates a unique index for the field that applies only to documents matching the filter.
+// It returns ErrInvalidArgument if the filter is empty.
+func CreatePartialUniqueIndex(ctx context.Context, coll *Collection, field string, filter M) error {
+	return coll.CreatePartialUniqueIndex(ctx, field, filter)
+}
+
 // CreateTextIndex creates a text index for a collection with the given field names and language code.
 // You should create a text index to use text search. Field names are required and must be unique.
 // If the language code is not provided, "en" will be used by default.
@@ -25,6 +58,27 @@ func CreateTextIndex(ctx context.Context, coll *Collection, languageCode string,
 	return coll.CreateTextIndex(ctx, languageCode, fieldNames...)
 }
 
+// DropTextIndex drops the text index of the collection, e.g. to recreate it with another language.
+// It returns ErrIndexNotFound if the collection has no text index.
+func DropTextIndex(ctx context.Context, coll *Collection) error {
+	return coll.DropTextIndex(ctx)
+}
+
+// Compact runs compact command on the collection to release unused disk space to the operating system.
+// It may block other operations on the collection, so run it in a maintenance window.
+// It returns ErrUnauthorized without the compact privilege and ErrCommandNotSupported if compact is not supported.
+func Compact(ctx context.Context, coll *Collection) error {
+	return coll.Compact(ctx)
+}
+
+// SetValidator sets or replaces the JSON schema validator of the existing collection using collMod command.
+// Validator is a JSON schema, it will be wrapped into {$jsonSchema: validator} if it doesn't contain $jsonSchema key already.
+// Nil validator removes validation rules from the collection.
+// Level is one of "off", "strict" or "moderate", action is one of "error" or "warn".
+func SetValidator(ctx context.Context, coll *Collection, validator M, level, action string) error {
+	return coll.SetValidator(ctx, validator, level, action)
+}
+
 // FindOne finds a one document in the collection using filter.
 // It returns ErrNotFound if NO document is found.
 // Limit and AllowDiskUse options are no-op.
@@ -36,6 +90,51 @@ func FindOne[T any](ctx context.Context, coll *Collection, filter M, opts ...Fin
 	return result, nil
 }
 
+// FindByID finds a document in the collection by the id field of the collection, see [CollectionOptions].
+// It returns ErrNotFound if NO document is found.
+func FindByID[T any](ctx context.Context, coll *Collection, id any, opts ...FindOptions) (T, error) {
+	var result T
+	if err := coll.FindByID(ctx, &result, id, opts...); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// FindOneProjected finds a one document in the collection using filter and returns only the provided fields:
+// {field1: 1, field2: 1, ...}. Only these fields and _id are populated in the result,
+// other fields of T keep zero values, so don't mistake them for real data. Fields may be nested, e.g. "struct.name".
+// It returns ErrInvalidArgument if fields are empty or a field is not present in bson tags of T
+// and ErrNotFound if NO document is found.
+func FindOneProjected[T any](ctx context.Context, coll *Collection, filter M, fields []string) (T, error) {
+	ctx, done := coll.start(ctx, "find_one_projected", filter)
+	defer done()
+
+	var result T
+	if len(fields) == 0 {
+		return result, fmt.Errorf("%w: no fields provided", ErrInvalidArgument)
+	}
+	projection := make(bson.D, 0, len(fields))
+	for _, field := range fields {
+		if field == "" || !hasBSONPath(reflect.TypeOf(&result), strings.Split(field, "."), coll.useJSONStructTags()) {
+			return result, fmt.Errorf("%w: field %q is not present in %T", ErrInvalidArgument, field, result)
+		}
+		projection = append(projection, bson.E{Key: field, Value: 1})
+	}
+
+	opts := options.FindOne().SetProjection(projection)
+	comment := coll.commentFor(ctx)
+	lang.IfF(comment != "", func() { opts.SetComment(comment) })
+
+	res := coll.coll.FindOne(ctx, filter.Prepare(), collate(coll.collation, opts))
+	if err := res.Err(); err != nil {
+		return result, HandleMongoError(err)
+	}
+	if err := res.Decode(&result); err != nil {
+		return result, HandleMongoError(err)
+	}
+	return result, nil
+}
+
 // Find finds many documents in the collection using filter.
 // It does NOT return any error if no document is found.
 func Find[T any](ctx context.Context, coll *Collection, filter M, opts ...FindOptions) ([]T, error) {
@@ -46,6 +145,39 @@ func Find[T any](ctx context.Context, coll *Collection, filter M, opts ...FindOp
 	return result, nil
 }
 
+// FindAfterClusterTime finds many documents like Find, but the server reads data at least as recent as afterTime,
+// e.g. the operation time of a write made by another service.
+// It returns ErrInvalidArgument if afterTime is zero and ErrCausalConsistencyUnsupported if the server is standalone.
+// It does NOT return any error if no document is found.
+func FindAfterClusterTime[T any](ctx context.Context, coll *Collection, filter M, afterTime bson.Timestamp, opts ...FindOptions) ([]T, error) {
+	var result []T
+	if err := coll.FindAfterClusterTime(ctx, &result, filter, afterTime, opts...); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// FindBy finds many documents in the collection with the field equal to the value: {field: value}.
+// It does NOT return any error if no document is found.
+// It returns ErrInvalidArgument if field is empty.
+func FindBy[T any](ctx context.Context, coll *Collection, field string, value any, opts ...FindOptions) ([]T, error) {
+	if field == "" {
+		return nil, fmt.Errorf("%w: no field name provided", ErrInvalidArgument)
+	}
+	return Find[T](ctx, coll, M{field: value}, opts...)
+}
+
+// FindOneBy finds a one document in the collection with the field equal to the value: {field: value},
+// e.g. mongox.FindOneBy[User](ctx, coll, "email", email).
+// It returns ErrNotFound if NO document is found and ErrInvalidArgument if field is empty.
+func FindOneBy[T any](ctx context.Context, coll *Collection, field string, value any, opts ...FindOptions) (T, error) {
+	if field == "" {
+		var result T
+		return result, fmt.Errorf("%w: no field name provided", ErrInvalidArgument)
+	}
+	return FindOne[T](ctx, coll, M{field: value}, opts...)
+}
+
 // FindAll finds all documents in the collection.
 // It does NOT return any error if no document is found.
 func FindAll[T any](ctx context.Context, coll *Collection, opts ...FindOptions) ([]T, error) {
@@ -56,31 +188,159 @@ func FindAll[T any](ctx context.Context, coll *Collection, opts ...FindOptions)
 	return result, nil
 }
 
+// FindMap finds many documents in the collection using filter and converts every document with fn, e.g. into a DTO.
+// Documents are decoded one by one from the cursor, so a full slice of T is never materialized.
+// It does NOT return any error if no document is found. It returns ErrInvalidArgument if fn is nil.
+func FindMap[T any, R any](ctx context.Context, coll *Collection, filter M, fn func(T) R, opts ...FindOptions) ([]R, error) {
+	ctx, done := coll.start(ctx, "find_map", filter)
+	defer done()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: nil map function", ErrInvalidArgument)
+	}
+
+	var result []R
+	err := coll.findEach(ctx, filter.Prepare(), func(decode func(any) error) error {
+		var doc T
+		if err := decode(&doc); err != nil {
+			return err
+		}
+		result = append(result, fn(doc))
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindByIDs finds documents with idField value in ids: {idField: {$in: ids}}.
+// Unlike [Find], result has the same length and order as ids, so the i-th element is the document for ids[i].
+// It is useful for batch loading (e.g. GraphQL dataloaders) where results must match the input keys.
+// If there is no document for an id, the element at its position is a zero value of T, no error is returned.
+// Ids are matched by BSON type and value, so use the same Go types as stored in the collection (e.g. int32 vs int64).
+// IdField may be a nested field in dot notation, e.g. "meta.key".
+func FindByIDs[T any](ctx context.Context, coll *Collection, idField string, ids []any) ([]T, error) {
+	if idField == "" {
+		return nil, fmt.Errorf("%w: empty id field", ErrInvalidArgument)
+	}
+	result := make([]T, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var docs []bson.Raw
+	if err := coll.Find(ctx, &docs, M{idField: M{In: ids}}); err != nil {
+		return nil, err
+	}
+
+	path := strings.Split(idField, ".")
+	byID := make(map[string]bson.Raw, len(docs))
+	for _, doc := range docs {
+		v, err := doc.LookupErr(path...)
+		if err != nil {
+			continue
+		}
+		key := rawIDKey(v.Type, v.Value)
+		if _, ok := byID[key]; !ok {
+			byID[key] = doc
+		}
+	}
+
+	for i, id := range ids {
+		typ, data, err := bson.MarshalValue(id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid id at position %d: %v", ErrInvalidArgument, i, err)
+		}
+		doc, ok := byID[rawIDKey(typ, data)]
+		if !ok {
+			continue
+		}
+		if err := bson.Unmarshal(doc, &result[i]); err != nil {
+			return nil, HandleMongoError(err)
+		}
+	}
+
+	return result, nil
+}
+
+// recordID returns the value of the id field of the record, idField may be a nested field in dot notation.
+func recordID(record any, idField string) (bson.RawValue, error) {
+	raw, err := bson.Marshal(record)
+	if err != nil {
+		return bson.RawValue{}, HandleMongoError(err)
+	}
+	id, err := bson.Raw(raw).LookupErr(strings.Split(idField, ".")...)
+	if err != nil {
+		return bson.RawValue{}, fmt.Errorf("%w: record has no id field %q", ErrInvalidArgument, idField)
+	}
+	return id, nil
+}
+
+// convertID converts the ID value to the dest type using BSON encoding, e.g. int to int64.
+func convertID[ID any](id any, dest *ID) error {
+	raw, err := bson.Marshal(bson.D{{Key: "id", Value: id}})
+	if err != nil {
+		return err
+	}
+	var holder struct {
+		ID ID `bson:"id"`
+	}
+	if err := bson.Unmarshal(raw, &holder); err != nil {
+		return err
+	}
+	*dest = holder.ID
+	return nil
+}
+
+func rawIDKey(typ bson.Type, data []byte) string {
+	return string(byte(typ)) + string(data)
+}
+
 // FindOneAndDelete finds a document in the collection using filter and deletes it.
+// Use Sort option to choose which document is deleted if many documents match the filter.
 // It returns ErrNotFound if no document is found.
-func FindOneAndDelete[T any](ctx context.Context, coll *Collection, filter M) (T, error) {
+func FindOneAndDelete[T any](ctx context.Context, coll *Collection, filter M, opts ...FindOneAndOptions) (T, error) {
 	var result T
-	if err := coll.FindOneAndDelete(ctx, &result, filter); err != nil {
+	if err := coll.FindOneAndDelete(ctx, &result, filter, opts...); err != nil {
 		return result, err
 	}
 	return result, nil
 }
 
+// FindOneAndDeleteIf finds the first document in the collection using filter and sort and deletes it.
+// Sort chooses which document is deleted when many documents match the filter,
+// e.g. []mongox.M{{"created_at": mongox.Ascending}} deletes the oldest one, so it can be used as a simple job queue pop.
+// Every M in sort should contain one field. It returns ErrNotFound if no document is found.
+func FindOneAndDeleteIf[T any](ctx context.Context, coll *Collection, filter M, sort []M) (T, error) {
+	return FindOneAndDelete[T](ctx, coll, filter, FindOneAndOptions{Sort: sort})
+}
+
 // FindOneAndReplace finds a document in the collection using filter and replaces it.
+// It returns the original document, use ReturnUpdated option to get the replaced one.
 // It returns ErrNotFound if no document is found.
-func FindOneAndReplace[T any](ctx context.Context, coll *Collection, filter M, replacement any) (T, error) {
+func FindOneAndReplace[T any](ctx context.Context, coll *Collection, filter M, replacement any, opts ...FindOneAndOptions) (T, error) {
 	var result T
-	if err := coll.FindOneAndReplace(ctx, &result, filter, replacement); err != nil {
+	if err := coll.FindOneAndReplace(ctx, &result, filter, replacement, opts...); err != nil {
 		return result, err
 	}
 	return result, nil
 }
 
+// ReplaceOrInsert replaces a document matching the filter with doc or inserts doc if there is no such document
+// in one atomic call, like a PUT of a REST resource. It returns the stored document and true if it is created.
+// It returns ErrInvalidArgument if doc contains update operators.
+func ReplaceOrInsert[T any](ctx context.Context, coll *Collection, filter M, doc T) (result T, created bool, err error) {
+	created, err = coll.ReplaceOrInsert(ctx, &result, filter, doc)
+	return result, created, err
+}
+
 // FindOneAndUpdate finds a document in the collection using filter and updates it.
+// It returns the original document, use ReturnUpdated option to get the updated one.
 // It returns ErrNotFound if no document is found.
-func FindOneAndUpdate[T any](ctx context.Context, coll *Collection, filter M, update any) (T, error) {
+func FindOneAndUpdate[T any](ctx context.Context, coll *Collection, filter M, update any, opts ...FindOneAndOptions) (T, error) {
 	var result T
-	if err := coll.FindOneAndUpdate(ctx, &result, filter, update); err != nil {
+	if err := coll.FindOneAndUpdate(ctx, &result, filter, update, opts...); err != nil {
 		return result, err
 	}
 	return result, nil
@@ -92,6 +352,40 @@ func Count(ctx context.Context, coll *Collection, filter M) (int64, error) {
 	return coll.Count(ctx, filter)
 }
 
+// Aggregate executes an aggregation pipeline and decodes all resulting documents into a slice of T.
+// Use [PipelineBuilder] to create the pipeline. It does NOT return any error if no document is returned.
+// It returns ErrMaxTimeMSExpired if the aggregation takes longer than MaxTime option.
+func Aggregate[T any](ctx context.Context, coll *Collection, pipeline []M, opts ...AggregateOptions) ([]T, error) {
+	var result []T
+	if err := coll.Aggregate(ctx, &result, pipeline, opts...); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// EstimatedCount returns the number of documents in the collection from the collection metadata without scanning.
+// The estimate may be inaccurate after an unclean shutdown, use Count for an accurate or filtered count.
+func EstimatedCount(ctx context.Context, coll *Collection) (int64, error) {
+	return coll.EstimatedCount(ctx)
+}
+
+// Sample returns up to size random documents matching the filter using $match and $sample stages.
+// A not selective filter leads to a scan of the collection, see [Collection.Sample].
+// It returns ErrInvalidArgument if size is not positive.
+func Sample[T any](ctx context.Context, coll *Collection, size int, filter M) ([]T, error) {
+	var result []T
+	if err := coll.Sample(ctx, &result, size, filter); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// CountPipeline executes an aggregation pipeline with an additional $count stage and returns the number
+// of documents the pipeline yields. It returns 0 if the pipeline yields nothing.
+func CountPipeline(ctx context.Context, coll *Collection, pipeline []M) (int64, error) {
+	return coll.CountPipeline(ctx, pipeline)
+}
+
 // Distinct finds distinct values for the specified field in the collection.
 // You can use predefined options from mongox, e.g. mongox.M{mongox.Inc: mongox.M{"number": 1}}.
 func Distinct[T any](ctx context.Context, coll *Collection, field string, filter M) ([]T, error) {
@@ -102,6 +396,290 @@ func Distinct[T any](ctx context.Context, coll *Collection, field string, filter
 	return result, nil
 }
 
+// DistinctWithOptions finds distinct values for the specified field in the collection like Distinct,
+// but allows to set an index hint and a time limit of the operation.
+// It returns ErrMaxTimeMSExpired if the operation takes longer than MaxTime.
+func DistinctWithOptions[T any](ctx context.Context, coll *Collection, field string, filter M, opts DistinctOptions) ([]T, error) {
+	var result []T
+	if err := coll.DistinctWithOptions(ctx, &result, field, filter, opts); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// DistinctValues finds distinct values for the specified field in the collection like Distinct, but reports nulls
+// separately instead of decoding them into the zero value of T. hasNull is true if any document matching the filter
+// has the field with null value or doesn't have the field at all, e.g. a product without an assigned category.
+// Null is not included in the values. Absent fields are checked with an additional query limited to one document.
+// It returns ErrTypeMismatch if any not-null value cannot be decoded into T.
+func DistinctValues[T any](ctx context.Context, coll *Collection, field string, filter M) (values []T, hasNull bool, err error) {
+	var raw []bson.RawValue
+	if err := coll.Distinct(ctx, &raw, field, filter); err != nil {
+		return nil, false, err
+	}
+
+	values = make([]T, 0, len(raw))
+	for _, v := range raw {
+		if v.Type == bson.TypeNull || v.Type == bson.TypeUndefined {
+			hasNull = true
+			continue
+		}
+		var value T
+		if err := v.Unmarshal(&value); err != nil {
+			return nil, false, fmt.Errorf("%w: cannot decode %s value of %q into %T: %v", ErrTypeMismatch, v.Type, field, value, err)
+		}
+		values = append(values, value)
+	}
+	if hasNull {
+		return values, true, nil
+	}
+
+	// Distinct skips documents without the field, {field: null} matches both null and absent field
+	nullFilter := M{field: nil}
+	if len(filter) > 0 {
+		nullFilter = AndFilter(filter, nullFilter)
+	}
+	hasNull, err = coll.exists(ctx, nullFilter.Prepare())
+	if err != nil {
+		return nil, false, err
+	}
+	return values, hasNull, nil
+}
+
+// DistinctArray finds distinct elements of the array field in the collection, e.g. distinct tags of []string field.
+// Every element is decoded into T separately, nested arrays are flattened, duplicates are removed.
+// It returns ErrTypeMismatch if any element cannot be decoded into T.
+func DistinctArray[T any](ctx context.Context, coll *Collection, field string, filter M) ([]T, error) {
+	var values []bson.RawValue
+	if err := coll.Distinct(ctx, &values, field, filter); err != nil {
+		return nil, err
+	}
+
+	result := make([]T, 0, len(values))
+	seen := make(map[string]struct{}, len(values))
+	var decode func(v bson.RawValue) error
+	decode = func(v bson.RawValue) error {
+		if arr, ok := v.ArrayOK(); ok {
+			elems, err := arr.Values()
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrTypeMismatch, err)
+			}
+			for _, elem := range elems {
+				if err := decode(elem); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		key := rawIDKey(v.Type, v.Value)
+		if _, ok := seen[key]; ok {
+			return nil
+		}
+		seen[key] = struct{}{}
+
+		var elem T
+		if err := v.Unmarshal(&elem); err != nil {
+			return fmt.Errorf("%w: cannot decode %s value of %q into %T: %v", ErrTypeMismatch, v.Type, field, elem, err)
+		}
+		result = append(result, elem)
+		return nil
+	}
+
+	for _, v := range values {
+		if err := decode(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// GroupByMany groups documents matching the filter by the values of several fields and decodes
+// accumulated values of every group into V, e.g. count per (country, plan):
+//
+//	GroupByMany[Stats](ctx, coll, nil, []string{"country", "plan"}, mongox.M{"n": mongox.M{mongox.AccSum: 1}})
+//
+// The key of the result map is the JSON array of the group values in the order of groupBy fields,
+// e.g. `["US","pro"]`. A field that is missing in the document is null in the key, e.g. `["US",null]`.
+// It returns ErrInvalidArgument if groupBy is empty or contains an empty field name
+// and if accumulators use _id as an output field name. It also returns ErrInvalidArgument instead of
+// overwriting a group if values of different groups have the same JSON key, e.g. an ObjectID and its hex string.
+func GroupByMany[V any](ctx context.Context, coll *Collection, filter M, groupBy []string, accumulators M) (map[string]V, error) {
+	if len(groupBy) == 0 {
+		return nil, fmt.Errorf("%w: no group by fields provided", ErrInvalidArgument)
+	}
+	if _, ok := accumulators["_id"]; ok {
+		return nil, fmt.Errorf("%w: _id cannot be used as an output field name", ErrInvalidArgument)
+	}
+
+	groupID := make([]string, 0, len(groupBy))
+	for _, field := range groupBy {
+		if field == "" {
+			return nil, fmt.Errorf("%w: empty group by field name", ErrInvalidArgument)
+		}
+		groupID = append(groupID, "$"+field)
+	}
+	group := M{"_id": groupID}
+	for field, acc := range accumulators {
+		group[field] = acc
+	}
+	if filter == nil {
+		filter = M{}
+	}
+	pipeline := []M{
+		{StageMatch: filter},
+		{StageGroup: group},
+	}
+
+	result := make(map[string]V)
+	err := coll.AggregateEach(ctx, pipeline, func(decode func(any) error) error {
+		var id struct {
+			Values []any `bson:"_id"`
+		}
+		if err := decode(&id); err != nil {
+			return err
+		}
+		key, err := json.Marshal(id.Values)
+		if err != nil {
+			return fmt.Errorf("%w: cannot encode group key %v: %v", ErrInvalidArgument, id.Values, err)
+		}
+		if _, ok := result[string(key)]; ok {
+			return fmt.Errorf("%w: different groups have the same key %s", ErrInvalidArgument, key)
+		}
+		var value V
+		if err := decode(&value); err != nil {
+			return err
+		}
+		result[string(key)] = value
+		return nil
+	}, AggregateOptions{AllowDiskUse: true})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// TopNPerGroup groups documents matching the filter by groupField and returns n documents with the highest
+// values of sortField in every group, sorted by sortField in descending order, e.g. top 3 scores per user:
+//
+//	TopNPerGroup[Score](ctx, coll, nil, "user", "score", 3)
+//
+// The key of the result map is the group value as a string, ObjectID is converted to hex,
+// documents without groupField are in the group with an empty key. It returns ErrInvalidArgument instead of
+// overwriting a group if values of different groups have the same key, e.g. 1 and "1" or null and "".
+// On MongoDB 5.2+ it uses $topN accumulator that keeps only n documents per group in memory.
+// On older servers it falls back to $sort and $group with $push and $slice, which accumulates all documents
+// of a group before slicing them, so it is slower and uses more memory on big groups.
+// It returns ErrInvalidArgument if groupField or sortField is empty or n is not positive.
+func TopNPerGroup[T any](ctx context.Context, coll *Collection, filter M, groupField, sortField string, n int) (map[string][]T, error) {
+	if groupField == "" || sortField == "" {
+		return nil, fmt.Errorf("%w: no group or sort field name provided", ErrInvalidArgument)
+	}
+	if n < 1 {
+		return nil, fmt.Errorf("%w: n must be positive, got %d", ErrInvalidArgument, n)
+	}
+	if filter == nil {
+		filter = M{}
+	}
+
+	hasTopN, err := serverVersionAtLeast(ctx, coll.coll.Database(), 5, 2)
+	if err != nil {
+		return nil, err
+	}
+	var pipeline []M
+	if hasTopN {
+		pipeline = []M{
+			{StageMatch: filter},
+			{StageGroup: M{
+				"_id": "$" + groupField,
+				"docs": M{"$topN": M{
+					"n":      n,
+					"sortBy": bson.D{{Key: sortField, Value: Descending}},
+					"output": "$$ROOT",
+				}},
+			}},
+		}
+	} else {
+		pipeline = []M{
+			{StageMatch: filter},
+			{StageSort: bson.D{{Key: sortField, Value: Descending}}},
+			{StageGroup: M{"_id": "$" + groupField, "docs": M{AccPush: "$$ROOT"}}},
+			{StageProject: M{"docs": M{"$slice": []any{"$docs", n}}}},
+		}
+	}
+
+	result := make(map[string][]T)
+	err = coll.AggregateEach(ctx, pipeline, func(decode func(any) error) error {
+		var group struct {
+			ID   any `bson:"_id"`
+			Docs []T `bson:"docs"`
+		}
+		if err := decode(&group); err != nil {
+			return err
+		}
+		key := groupKey(group.ID)
+		if _, ok := result[key]; ok {
+			return fmt.Errorf("%w: different groups have the same key %q", ErrInvalidArgument, key)
+		}
+		result[key] = group.Docs
+		return nil
+	}, AggregateOptions{AllowDiskUse: true})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// groupKey returns the string representation of the group value.
+func groupKey(id any) string {
+	switch v := id.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bson.ObjectID:
+		return v.Hex()
+	}
+	return fmt.Sprint(id)
+}
+
+// DistinctPaged finds distinct values for the specified field in the collection like Distinct,
+// but it uses an aggregation pipeline with $group stage instead of the distinct command.
+// Values are read with a cursor, so the result is not limited by 16MB size of a single BSON document
+// and it can be used for high-cardinality fields. Array fields are unwound, so their elements are returned.
+// Unlike Distinct, documents where the field is null, missing or an empty array are excluded by $unwind,
+// so null is never returned. Use DistinctValues to detect them.
+// The order of values is not specified. It returns ErrInvalidArgument if field is empty.
+func DistinctPaged[T any](ctx context.Context, coll *Collection, field string, filter M) ([]T, error) {
+	if field == "" {
+		return nil, fmt.Errorf("%w: no field name provided", ErrInvalidArgument)
+	}
+	if filter == nil {
+		filter = M{}
+	}
+	pipeline := []M{
+		{StageMatch: filter},
+		{StageUnwind: "$" + field},
+		{StageGroup: M{"_id": "$" + field}},
+	}
+
+	var result []T
+	err := coll.AggregateEach(ctx, pipeline, func(decode func(any) error) error {
+		var group struct {
+			Value T `bson:"_id"`
+		}
+		if err := decode(&group); err != nil {
+			return err
+		}
+		result = append(result, group.Value)
+		return nil
+	}, AggregateOptions{AllowDiskUse: true})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // InsertOne inserts a document into the collection.
 // It returns ID of the inserted document.
 // If isStrictID is true, it will return an error if the inserted ID is not an ObjectID.
@@ -135,6 +713,95 @@ func InsertMany(ctx context.Context, coll *Collection, records []any) ([]bson.Ob
 	return coll.InsertMany(ctx, records)
 }
 
+// InsertTyped inserts a document or many documents into the collection and returns their IDs as ID type.
+// Use it for collections with non-ObjectID _id, e.g. string, int64 or bson.Binary UUID.
+// Provided IDs are returned as is, generated IDs are ObjectIDs, so use bson.ObjectID or any as ID in that case.
+// It returns ErrInvalidArgument if an ID cannot be converted to ID type, documents are inserted anyway.
+func InsertTyped[ID any](ctx context.Context, coll *Collection, records ...any) ([]ID, error) {
+	insertedIDs, err := coll.insertMany(ctx, records)
+	if err != nil {
+		return nil, err
+	}
+	if len(insertedIDs) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]ID, len(insertedIDs))
+	for i, id := range insertedIDs {
+		if typed, ok := id.(ID); ok {
+			ids[i] = typed
+			continue
+		}
+		if err := convertID(id, &ids[i]); err != nil {
+			return nil, fmt.Errorf("%w: cannot convert inserted ID %v of type %T to %T: %v", ErrInvalidArgument, id, id, ids[i], err)
+		}
+	}
+	return ids, nil
+}
+
+// InsertAndRead inserts the record and reads it back, guaranteeing that the read sees the written document
+// even if reads go to secondaries. The record is inserted with majority write concern and read with majority
+// read concern in a causally consistent session, so a transaction is not required.
+// If filter is empty, the document is read by the id field of the collection (see [CollectionOptions]):
+// by the inserted _id by default or by the value of the id field of the record.
+// It returns ErrNotFound if the filter doesn't match the inserted document or other documents.
+func InsertAndRead[T any](ctx context.Context, coll *Collection, record T, filter M) (T, error) {
+	var out T
+
+	session, err := coll.coll.Database().Client().StartSession(options.Session().SetCausalConsistency(true))
+	if err != nil {
+		return out, HandleMongoError(err)
+	}
+	defer session.EndSession(ctx)
+	ctx = mongo.NewSessionContext(ctx, session)
+
+	majority := coll.clone()
+	majority.coll = coll.coll.Clone(options.Collection().
+		SetWriteConcern(writeconcern.Majority()).
+		SetReadConcern(readconcern.Majority()))
+
+	ids, err := majority.insertMany(ctx, []any{record})
+	if err != nil {
+		return out, err
+	}
+	if len(filter) == 0 && len(ids) > 0 {
+		filter = M{DefaultIDField: ids[0]}
+		if idField := coll.IDField(); idField != DefaultIDField {
+			id, err := recordID(record, idField)
+			if err != nil {
+				return out, err
+			}
+			filter = M{idField: id}
+		}
+	}
+
+	if err := majority.FindOne(ctx, &out, filter); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// InsertIgnoreDuplicates inserts many documents into the collection skipping duplicates.
+// Documents are inserted with unordered bulk write, so a duplicate key error doesn't abort the whole batch.
+// It returns number of inserted documents and number of documents skipped because of duplicate key errors.
+// Duplicates are not returned as an error, but any other write error is returned.
+func InsertIgnoreDuplicates(ctx context.Context, coll *Collection, records []any) (inserted int, skipped int, err error) {
+	return coll.InsertIgnoreDuplicates(ctx, records)
+}
+
+// ScanAll pages through all documents of the collection in ascending order of the unique sortField
+// and calls fn for every batch with the last key of the batch. Pass the last key in ScanOptions.After to resume the scan.
+// It does NOT return any error if the collection is empty.
+func ScanAll(ctx context.Context, coll *Collection, sortField string, batchSize int, fn func(batch []bson.Raw, lastKey any) error, opts ...ScanOptions) error {
+	return coll.ScanAll(ctx, sortField, batchSize, fn, opts...)
+}
+
+// CopyTo copies documents matching the filter from the source collection into the target collection.
+// It returns the number of copied documents. _id is preserved unless RegenerateID option is set.
+func CopyTo(ctx context.Context, source, target *Collection, filter M, opts ...CopyOptions) (int, error) {
+	return source.CopyTo(ctx, target, filter, opts...)
+}
+
 // Upsert replaces a document in the collection or inserts it if it doesn't exist.
 // It returns ID of the inserted document.
 // If existing document is updated (no new inserted), it returns nil ID and nil error.
@@ -143,6 +810,19 @@ func Upsert(ctx context.Context, coll *Collection, record any, filter M) (*bson.
 	return coll.Upsert(ctx, record, filter)
 }
 
+// UpsertUpdate updates a document in the collection with update operators or inserts a new one if no document
+// matches the filter. Update map/document must contain key beginning with '$', e.g. {$set: {key1: value1}}.
+// It returns the result with Inserted flag and ID of the inserted document.
+func UpsertUpdate(ctx context.Context, coll *Collection, filter, update M) (UpsertResult, error) {
+	return coll.UpsertUpdate(ctx, filter, update)
+}
+
+// UpsertWithDefaults works like UpsertUpdate, but also sets defaults with $setOnInsert,
+// so they are written only if a new document is inserted.
+func UpsertWithDefaults(ctx context.Context, coll *Collection, filter, update, defaults M) (UpsertResult, error) {
+	return coll.UpsertWithDefaults(ctx, filter, update, defaults)
+}
+
 // ReplaceOne replaces a document in the collection.
 // It returns ErrNotFound if no document is updated.
 func ReplaceOne(ctx context.Context, coll *Collection, record any, filter M) error {
@@ -151,28 +831,37 @@ func ReplaceOne(ctx context.Context, coll *Collection, record any, filter M) err
 
 // SetFields sets fields in a document in the collection using updates map.
 // For example: {key1: value1, key2: value2} becomes {$set: {key1: value1, key2: value2}}.
-// It returns ErrNotFound if no document is updated.
-func SetFields(ctx context.Context, coll *Collection, filter M, update map[string]any) error {
-	return coll.SetFields(ctx, filter, update)
+// It returns ErrNotFound if no document is updated, with UpdateOptions.Upsert it inserts a new document instead
+// and returns its ID.
+func SetFields(ctx context.Context, coll *Collection, filter M, update map[string]any, opts ...UpdateOptions) (*bson.ObjectID, error) {
+	return coll.SetFields(ctx, filter, update, opts...)
 }
 
 // UpdateOne updates a document in the collection.
 // Update map/document must contain key beginning with '$', e.g. {$set: {key1: value1}}.
 // Modifiers operate on fields. For example: {$mod: {<field>: ...}}.
 // You can use predefined options from mongox, e.g. mongox.M{mongox.Inc: mongox.M{"number": 1}}.
-// It returns ErrNotFound if no document is updated.
-func UpdateOne(ctx context.Context, coll *Collection, filter, update M) error {
-	return coll.UpdateOne(ctx, filter, update)
+// It returns ErrNotFound if no document is updated, with UpdateOptions.Upsert it inserts a new document instead
+// and returns its ID.
+func UpdateOne(ctx context.Context, coll *Collection, filter, update M, opts ...UpdateOptions) (*bson.ObjectID, error) {
+	return coll.UpdateOne(ctx, filter, update, opts...)
 }
 
 // UpdateMany updates multi documents in the collection.
 // Update map/document must contain key beginning with '$', e.g. {$set: {key1: value1}}.
 // Modifiers operate on fields. For example: {$mod: {<field>: ...}}.
 // You can use predefined options from mongox, e.g. mongox.M{mongox.Inc: mongox.M{"number": 1}}.
-// It returns number of updated documents.
+// It returns number of updated documents. With WriteOptions.DryRun it returns number of matched documents
+// and doesn't update them.
 // It returns ErrNotFound if no document is updated.
-func UpdateMany(ctx context.Context, coll *Collection, filter, update M) (int, error) {
-	return coll.UpdateMany(ctx, filter, update)
+func UpdateMany(ctx context.Context, coll *Collection, filter, update M, opts ...WriteOptions) (int, error) {
+	return coll.UpdateMany(ctx, filter, update, opts...)
+}
+
+// RenameField renames the field in all documents matching the filter using $rename operator.
+// It returns number of renamed documents and ErrNotFound if no document matches the filter and has the old field.
+func RenameField(ctx context.Context, coll *Collection, filter M, oldName, newName string) (int, error) {
+	return coll.RenameField(ctx, filter, oldName, newName)
 }
 
 // UpdateOneFromDiff sets fields in a document in the collection using diff structure.
@@ -186,8 +875,42 @@ func UpdateMany(ctx context.Context, coll *Collection, filter, update M) (int, e
 //	type MyStructDiff struct {name *string, index *int}
 //
 // It returns ErrNotFound if no document is updated.
-func UpdateOneFromDiff(ctx context.Context, coll *Collection, filter M, diff any) error {
-	return coll.UpdateOneFromDiff(ctx, filter, diff)
+func UpdateOneFromDiff(ctx context.Context, coll *Collection, filter M, diff any, opts ...DiffOptions) error {
+	return coll.UpdateOneFromDiff(ctx, filter, diff, opts...)
+}
+
+// UpdateOneFromDiffTracked works like UpdateOneFromDiff, but also returns the sorted list of fields
+// that are set by the update, e.g. ["name", "struct.number"]. Nested fields are returned as dotted paths,
+// exactly as the keys of the $set operator. It is useful for audit logs and change events.
+// It returns ErrNotFound if no document is updated, the list of fields is returned in that case too.
+func UpdateOneFromDiffTracked(ctx context.Context, coll *Collection, filter M, diff any, opts ...DiffOptions) ([]string, error) {
+	return coll.UpdateOneFromDiffTracked(ctx, filter, diff, opts...)
+}
+
+// IncFields increments fields in a document in the collection by the provided int64 deltas.
+// It returns ErrInvalidArgument if deltas are empty and ErrNotFound if no document is updated.
+func IncFields(ctx context.Context, coll *Collection, filter M, deltas map[string]int64) error {
+	return coll.IncFields(ctx, filter, deltas)
+}
+
+// MulFields multiplies fields in a document in the collection by the provided int64 factors.
+// It returns ErrInvalidArgument if factors are empty and ErrNotFound if no document is updated.
+func MulFields(ctx context.Context, coll *Collection, filter M, factors map[string]int64) error {
+	return coll.MulFields(ctx, filter, factors)
+}
+
+// AddToSetEach adds values to an array field of a document only if they do not already exist in the array:
+// {$addToSet: {field: {$each: values}}}.
+// It returns ErrInvalidArgument if field is empty and ErrNotFound if no document is updated.
+func AddToSetEach(ctx context.Context, coll *Collection, filter M, field string, values []any) error {
+	return coll.AddToSetEach(ctx, filter, field, values)
+}
+
+// SetFieldOnce sets the field of a document only if the field doesn't exist yet and never overwrites it.
+// It returns true if the field is set and false if the document already has the field.
+// It returns ErrInvalidArgument if field is empty and ErrNotFound if no document matches the filter.
+func SetFieldOnce(ctx context.Context, coll *Collection, filter M, field string, value any) (bool, error) {
+	return coll.SetFieldOnce(ctx, filter, field, value)
 }
 
 // DeleteFields deletes fields in a document in the collection.
@@ -196,6 +919,13 @@ func DeleteFields(ctx context.Context, coll *Collection, filter M, fields ...str
 	return coll.DeleteFields(ctx, filter, fields...)
 }
 
+// PatchFields sets and deletes fields of a document in the collection in one update.
+// It returns ErrInvalidArgument if both set and unset are empty or if the same field is set and unset.
+// It returns ErrNotFound if no document is updated.
+func PatchFields(ctx context.Context, coll *Collection, filter, set M, unset ...string) error {
+	return coll.PatchFields(ctx, filter, set, unset...)
+}
+
 // DeleteOne deletes a document in the collection based on the filter.
 // It returns ErrNotFound if no document is deleted.
 func DeleteOne(ctx context.Context, coll *Collection, filter M) error {
@@ -203,10 +933,18 @@ func DeleteOne(ctx context.Context, coll *Collection, filter M) error {
 }
 
 // DeleteMany deletes documents in the collection based on the filter.
-// It returns number of deleted documents.
+// It returns number of deleted documents. With WriteOptions.DryRun it returns number of matched documents
+// and doesn't delete them.
 // It returns ErrNotFound if no document is deleted.
-func DeleteMany(ctx context.Context, coll *Collection, filter M) (int, error) {
-	return coll.DeleteMany(ctx, filter)
+func DeleteMany(ctx context.Context, coll *Collection, filter M, opts ...WriteOptions) (int, error) {
+	return coll.DeleteMany(ctx, filter, opts...)
+}
+
+// Truncate deletes all documents in the collection.
+// Unlike DeleteMany, it does NOT return ErrNotFound if the collection is already empty.
+// Indexes and validation rules of the collection are kept, because the collection is not dropped.
+func Truncate(ctx context.Context, coll *Collection) error {
+	return coll.Truncate(ctx)
 }
 
 // BulkWrite executes bulk write operations in the collection.
@@ -215,7 +953,23 @@ func DeleteMany(ctx context.Context, coll *Collection, filter M) (int, error) {
 // and if any of them fails, the whole operation fails. Error is not returning.
 // IsOrdered==false means that all operations are executed in parallel and if any of them fails,
 // the whole operation continues. Error is not returning.
+// With WriteOptions.DryRun it counts documents matching the filters of models instead of writing them.
 // It returns ErrNotFound if no document is matched/inserted/updated/deleted.
-func BulkWrite(ctx context.Context, coll *Collection, models []mongo.WriteModel, isOrdered bool) (mongo.BulkWriteResult, error) {
-	return coll.BulkWrite(ctx, models, isOrdered)
+func BulkWrite(ctx context.Context, coll *Collection, models []mongo.WriteModel, isOrdered bool, opts ...WriteOptions) (BulkResult, error) {
+	return coll.BulkWrite(ctx, models, isOrdered, opts...)
+}
+
+// BulkWriteTx executes bulk write operations as a part of the transaction, sctx must be the context
+// of the [Database.WithTransaction] callback. Any failed model aborts the whole transaction regardless of isOrdered.
+// It returns ErrInvalidArgument if sctx has no session.
+func BulkWriteTx(sctx context.Context, coll *Collection, models []mongo.WriteModel, isOrdered bool) (BulkResult, error) {
+	return coll.BulkWriteTx(sctx, models, isOrdered)
+}
+
+// BulkWriteTracked executes models of the builder and returns the outcome of every model tagged with [BulkBuilder.Keyed].
+// Untagged models are executed but not returned. In ordered mode models after the failed one are not presented in the result.
+// It returns the outcomes together with the error of the whole operation, so partial results are available on error.
+// It returns ErrInvalidArgument if the same key tags more than one model.
+func BulkWriteTracked(ctx context.Context, coll *Collection, b *BulkBuilder, isOrdered bool) (map[string]WriteOutcome, error) {
+	return coll.BulkWriteTracked(ctx, b, isOrdered)
 }
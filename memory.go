@@ -0,0 +1,1141 @@
+package mongox
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxbolgarin/lang"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// MemoryCollection is an in-memory implementation of [CollectionAPI] for unit tests.
+// It stores documents in a map and doesn't need a running MongoDB server.
+// It is safe for concurrent use by multiple goroutines. Empty MemoryCollection is NOT ready to use,
+// create it with [NewMemoryCollection].
+//
+// It supports only a subset of MongoDB features:
+//   - filters: equality on fields and dotted paths, $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin, $exists, $and, $or, $nor;
+//   - updates: $set, $setOnInsert, $unset, $inc, $mul, $min, $max, $currentDate, $push and $addToSet (with $each);
+//   - find options: Sort, SortMany, StableSortField, Skip and Limit, other options are ignored.
+//
+// An unsupported operator results in ErrInvalidArgument. Unique indexes are not supported,
+// only a duplicate _id results in ErrDuplicate. Comparison of values of different BSON types
+// follows MongoDB sort order only for the most common types.
+type MemoryCollection struct {
+	name  string
+	docs  map[string]bson.D
+	order []string
+	mu    sync.RWMutex
+}
+
+// NewMemoryCollection returns a new empty in-memory collection with the provided name.
+func NewMemoryCollection(name string) *MemoryCollection {
+	return &MemoryCollection{
+		name: name,
+		docs: make(map[string]bson.D),
+	}
+}
+
+// Name returns the name of the collection.
+func (m *MemoryCollection) Name() string {
+	return m.name
+}
+
+// FindOne finds a one document in the collection using filter.
+// It returns ErrNotFound if NO document is found.
+func (m *MemoryCollection) FindOne(ctx context.Context, dest any, filter M, rawOpts ...FindOptions) error {
+	opts := memoryFindOptions(rawOpts)
+	opts.Limit = 1
+
+	docs, err := m.find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return ErrNotFound
+	}
+	return decodeMemoryValue(dest, docs[0])
+}
+
+// Find finds many documents in the collection using filter.
+// It does NOT return any error if no document is found.
+func (m *MemoryCollection) Find(ctx context.Context, dest any, filter M, rawOpts ...FindOptions) error {
+	docs, err := m.find(ctx, filter, memoryFindOptions(rawOpts))
+	if err != nil {
+		return err
+	}
+	return decodeMemoryValue(dest, toBSONArray(docs))
+}
+
+// FindAll finds all documents in the collection.
+// It does NOT return any error if no document is found.
+func (m *MemoryCollection) FindAll(ctx context.Context, dest any, rawOpts ...FindOptions) error {
+	return m.Find(ctx, dest, nil, rawOpts...)
+}
+
+// Count counts the number of documents in the collection using filter.
+// Nil filter means count all documents.
+func (m *MemoryCollection) Count(ctx context.Context, filter M) (int64, error) {
+	docs, err := m.find(ctx, filter, FindOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(docs)), nil
+}
+
+// Distinct finds distinct values for the specified field in the collection using filter.
+func (m *MemoryCollection) Distinct(ctx context.Context, dest any, field string, filter M) error {
+	if field == "" {
+		return fmt.Errorf("%w: no field name provided", ErrInvalidArgument)
+	}
+	docs, err := m.find(ctx, filter, FindOptions{})
+	if err != nil {
+		return err
+	}
+
+	values := bson.A{}
+	add := func(v any) {
+		for _, existing := range values {
+			if memoryValuesEqual(existing, v) {
+				return
+			}
+		}
+		values = append(values, v)
+	}
+	for _, doc := range docs {
+		value, found := lookupMemoryPath(doc, field)
+		if !found {
+			continue
+		}
+		if arr, ok := value.(bson.A); ok {
+			for _, v := range arr {
+				add(v)
+			}
+			continue
+		}
+		add(value)
+	}
+	return decodeMemoryValue(dest, values)
+}
+
+// InsertOne inserts a document into the collection.
+// It returns ID of the inserted document.
+// If isStrictID is true, it will return an error if the inserted ID is not an ObjectID.
+func (m *MemoryCollection) InsertOne(ctx context.Context, record any, isStrictID ...bool) (bson.ObjectID, error) {
+	ids, err := m.InsertMany(ctx, []any{record}, isStrictID...)
+	if err != nil {
+		return bson.ObjectID{}, err
+	}
+	return ids[0], nil
+}
+
+// Insert inserts a document or many documents into the collection.
+// If inserted ID is not an ObjectID, it will be returned as empty bson.ObjectID.
+func (m *MemoryCollection) Insert(ctx context.Context, records ...any) ([]bson.ObjectID, error) {
+	return m.InsertMany(ctx, records)
+}
+
+// InsertStrict inserts a document or many documents into the collection.
+// It returns an error if inserted IDs are not ObjectID.
+func (m *MemoryCollection) InsertStrict(ctx context.Context, records ...any) ([]bson.ObjectID, error) {
+	return m.InsertMany(ctx, records, true)
+}
+
+// InsertMany inserts many documents into the collection. Documents are inserted in order
+// and insertion stops at the first error, like in ordered insert.
+// If isStrictID is true, it will return an error if the inserted ID is not an ObjectID.
+// If isStrictID is false and if inserted ID is not an ObjectID, it will be returned as empty bson.ObjectID.
+func (m *MemoryCollection) InsertMany(ctx context.Context, records []any, isStrictID ...bool) ([]bson.ObjectID, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, HandleMongoError(err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	docs := make([]bson.D, 0, len(records))
+	for _, record := range records {
+		doc, err := toMemoryDocument(record)
+		if err != nil {
+			return nil, err
+		}
+		if _, found := lookupMemoryPath(doc, "_id"); !found {
+			doc = append(bson.D{{Key: "_id", Value: bson.NewObjectID()}}, doc...)
+		}
+		docs = append(docs, doc)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]bson.ObjectID, len(docs))
+	for i, doc := range docs {
+		id, _ := lookupMemoryPath(doc, "_id")
+		if err := m.insert(doc); err != nil {
+			return nil, err
+		}
+		oid, ok := id.(bson.ObjectID)
+		if !ok && len(isStrictID) > 0 && isStrictID[0] {
+			return nil, fmt.Errorf("%w: expected ObjectID, got %T, %v", ErrInvalidArgument, id, id)
+		}
+		ids[i] = oid
+	}
+	return ids, nil
+}
+
+// Upsert replaces a document in the collection or inserts it if it doesn't exist.
+// It returns ID of the interserted document.
+// If existing document is updated (no new inserted), it returns nil ID and nil error.
+func (m *MemoryCollection) Upsert(ctx context.Context, record any, filter M) (*bson.ObjectID, error) {
+	return m.replace(ctx, record, filter, true)
+}
+
+// ReplaceOne replaces a document in the collection.
+// It returns ErrNotFound if no document is updated.
+func (m *MemoryCollection) ReplaceOne(ctx context.Context, record any, filter M) error {
+	_, err := m.replace(ctx, record, filter, false)
+	return err
+}
+
+// SetFields sets fields in a document in the collection using updates map.
+// For example: {key1: value1, key2: value2} becomes {$set: {key1: value1, key2: value2}}.
+// It returns ErrNotFound if no document is updated, with UpdateOptions.Upsert it inserts a new document instead
+// and returns its ID.
+func (m *MemoryCollection) SetFields(ctx context.Context, filter, update M, opts ...UpdateOptions) (*bson.ObjectID, error) {
+	if len(opts) > 0 && opts[0].Upsert {
+		return m.upsertUpdate(ctx, filter, M{Set: update})
+	}
+	_, err := m.update(ctx, filter, M{Set: update}, false)
+	return nil, err
+}
+
+// UpdateOne updates a document in the collection.
+// Update map/document must contain key beginning with '$', e.g. {$set: {key1: value1}}.
+// It returns ErrNotFound if no document is updated, with UpdateOptions.Upsert it inserts a new document instead
+// and returns its ID.
+func (m *MemoryCollection) UpdateOne(ctx context.Context, filter, update M, opts ...UpdateOptions) (*bson.ObjectID, error) {
+	if len(opts) > 0 && opts[0].Upsert {
+		return m.upsertUpdate(ctx, filter, update)
+	}
+	_, err := m.update(ctx, filter, update, false)
+	return nil, err
+}
+
+// UpdateMany updates multi documents in the collection.
+// Update map/document must contain key beginning with '$', e.g. {$set: {key1: value1}}.
+// It returns number of updated documents. With WriteOptions.DryRun it returns number of matched documents
+// and doesn't update them.
+// It returns ErrNotFound if no document is updated.
+func (m *MemoryCollection) UpdateMany(ctx context.Context, filter, update M, opts ...WriteOptions) (int, error) {
+	if len(opts) > 0 && opts[0].DryRun {
+		return m.dryRunCount(ctx, filter)
+	}
+	return m.update(ctx, filter, update, true)
+}
+
+// UpdateOneFromDiff sets fields in a document in the collection using diff structure.
+// It returns ErrNotFound if no document is updated.
+func (m *MemoryCollection) UpdateOneFromDiff(ctx context.Context, filter M, diff any, opts ...DiffOptions) error {
+	update, err := DiffToUpdate(diff, opts...)
+	if err != nil {
+		return err
+	}
+	_, err = m.update(ctx, filter, update, false)
+	return err
+}
+
+// IncFields increments fields in a document in the collection by the provided deltas.
+// It returns ErrInvalidArgument if deltas are empty and ErrNotFound if no document is updated.
+func (m *MemoryCollection) IncFields(ctx context.Context, filter M, deltas map[string]int64) error {
+	if len(deltas) == 0 {
+		return fmt.Errorf("%w: empty fields for %s", ErrInvalidArgument, Inc)
+	}
+	fields := make(M, len(deltas))
+	for k, v := range deltas {
+		fields[k] = v
+	}
+	_, err := m.update(ctx, filter, M{Inc: fields}, false)
+	return err
+}
+
+// MulFields multiplies fields in a document in the collection by the provided factors.
+// It returns ErrInvalidArgument if factors are empty and ErrNotFound if no document is updated.
+func (m *MemoryCollection) MulFields(ctx context.Context, filter M, factors map[string]int64) error {
+	if len(factors) == 0 {
+		return fmt.Errorf("%w: empty fields for %s", ErrInvalidArgument, Mul)
+	}
+	fields := make(M, len(factors))
+	for k, v := range factors {
+		fields[k] = v
+	}
+	_, err := m.update(ctx, filter, M{Mul: fields}, false)
+	return err
+}
+
+// DeleteFields deletes fields in a document in the collection.
+// It returns ErrNotFound if no document is updated.
+func (m *MemoryCollection) DeleteFields(ctx context.Context, filter M, fields ...string) error {
+	unset := make(M, len(fields))
+	for _, f := range fields {
+		unset[f] = ""
+	}
+	_, err := m.update(ctx, filter, M{Unset: unset}, false)
+	return err
+}
+
+// DeleteOne deletes a document in the collection based on the filter.
+// It returns ErrNotFound if no document is deleted.
+func (m *MemoryCollection) DeleteOne(ctx context.Context, filter M) error {
+	_, err := m.delete(ctx, filter, false)
+	return err
+}
+
+// DeleteMany deletes many documents in the collection based on the filter.
+// It returns number of deleted documents. With WriteOptions.DryRun it returns number of matched documents
+// and doesn't delete them.
+// It returns ErrNotFound if no document is deleted.
+func (m *MemoryCollection) DeleteMany(ctx context.Context, filter M, opts ...WriteOptions) (int, error) {
+	if len(opts) > 0 && opts[0].DryRun {
+		return m.dryRunCount(ctx, filter)
+	}
+	return m.delete(ctx, filter, true)
+}
+
+func (m *MemoryCollection) dryRunCount(ctx context.Context, filter M) (int, error) {
+	n, err := m.Count(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, ErrNotFound
+	}
+	return int(n), nil
+}
+
+// Truncate deletes all documents in the collection.
+// It does NOT return ErrNotFound if the collection is already empty.
+func (m *MemoryCollection) Truncate(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return HandleMongoError(err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.docs = make(map[string]bson.D)
+	m.order = nil
+	return nil
+}
+
+func (m *MemoryCollection) find(ctx context.Context, filter M, opts FindOptions) ([]bson.D, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, HandleMongoError(err)
+	}
+	f, err := toMemoryDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []bson.D
+	for _, key := range m.order {
+		doc := m.docs[key]
+		ok, err := matchMemoryDocument(doc, f)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, doc)
+		}
+	}
+
+	sortMemoryDocuments(out, memorySort(opts))
+	if opts.Skip > 0 {
+		out = out[min(opts.Skip, len(out)):]
+	}
+	if opts.Limit > 0 && len(out) > opts.Limit {
+		out = out[:opts.Limit]
+	}
+	return out, nil
+}
+
+func (m *MemoryCollection) replace(ctx context.Context, record any, filter M, upsert bool) (*bson.ObjectID, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, HandleMongoError(err)
+	}
+	f, err := toMemoryDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	replacement, err := toMemoryDocument(record)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, doc, err := m.findFirst(f)
+	if err != nil {
+		return nil, err
+	}
+	if doc != nil {
+		id, _ := lookupMemoryPath(doc, "_id")
+		newID, found := lookupMemoryPath(replacement, "_id")
+		if found && !memoryValuesEqual(id, newID) {
+			return nil, fmt.Errorf("%w: the _id field cannot be changed from %v to %v", ErrImmutableField, id, newID)
+		}
+		if !found {
+			replacement = append(bson.D{{Key: "_id", Value: id}}, replacement...)
+		}
+		m.docs[key] = replacement
+		return nil, nil
+	}
+	if !upsert {
+		return nil, ErrNotFound
+	}
+
+	if _, found := lookupMemoryPath(replacement, "_id"); !found {
+		id, ok := lookupMemoryPath(f, "_id")
+		if _, isOperator := id.(bson.D); !ok || isOperator {
+			id = bson.NewObjectID()
+		}
+		replacement = append(bson.D{{Key: "_id", Value: id}}, replacement...)
+	}
+	if err := m.insert(replacement); err != nil {
+		return nil, err
+	}
+	id, _ := lookupMemoryPath(replacement, "_id")
+	if oid, ok := id.(bson.ObjectID); ok {
+		return &oid, nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryCollection) update(ctx context.Context, filter, update M, isMany bool) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, HandleMongoError(err)
+	}
+	f, err := toMemoryDocument(filter)
+	if err != nil {
+		return 0, err
+	}
+	upd, err := toMemoryDocument(update)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matched, modified int
+	for _, key := range m.order {
+		doc := m.docs[key]
+		ok, err := matchMemoryDocument(doc, f)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		matched++
+
+		updated, err := applyMemoryUpdate(cloneMemoryDocument(doc), upd)
+		if err != nil {
+			return 0, err
+		}
+		if !memoryValuesEqual(doc, updated) {
+			m.docs[key] = updated
+			modified++
+		}
+		if !isMany {
+			break
+		}
+	}
+	if matched == 0 {
+		return 0, ErrNotFound
+	}
+	return modified, nil
+}
+
+func (m *MemoryCollection) delete(ctx context.Context, filter M, isMany bool) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, HandleMongoError(err)
+	}
+	f, err := toMemoryDocument(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order := make([]string, 0, len(m.order))
+	var deleted int
+	for _, key := range m.order {
+		if isMany || deleted == 0 {
+			ok, err := matchMemoryDocument(m.docs[key], f)
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				delete(m.docs, key)
+				deleted++
+				continue
+			}
+		}
+		order = append(order, key)
+	}
+	m.order = order
+
+	if deleted == 0 {
+		return 0, ErrNotFound
+	}
+	return deleted, nil
+}
+
+// upsertUpdate updates the first document matching the filter or inserts a new one built from equality fields
+// of the filter with the update applied, $setOnInsert fields are written only in the latter case.
+// It returns the _id of the inserted document if it is an ObjectID.
+func (m *MemoryCollection) upsertUpdate(ctx context.Context, filter, update M) (*bson.ObjectID, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, HandleMongoError(err)
+	}
+	f, err := toMemoryDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	upd, err := toMemoryDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, doc, err := m.findFirst(f)
+	if err != nil {
+		return nil, err
+	}
+	if doc != nil {
+		updated, err := applyMemoryUpdate(cloneMemoryDocument(doc), upd)
+		if err != nil {
+			return nil, err
+		}
+		m.docs[key] = updated
+		return nil, nil
+	}
+
+	seed := bson.D{}
+	for _, e := range f {
+		if strings.HasPrefix(e.Key, "$") {
+			continue
+		}
+		if cond, ok := e.Value.(bson.D); ok && len(cond) > 0 && strings.HasPrefix(cond[0].Key, "$") {
+			continue
+		}
+		if seed, err = setMemoryPath(seed, e.Key, e.Value); err != nil {
+			return nil, err
+		}
+	}
+	newDoc, err := applyMemoryUpdate(seed, upd)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range upd {
+		if op.Key != SetOnInsert {
+			continue
+		}
+		for _, e := range op.Value.(bson.D) { // checked by applyMemoryUpdate
+			if newDoc, err = setMemoryPath(newDoc, e.Key, e.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	id, found := lookupMemoryPath(newDoc, "_id")
+	if !found {
+		id = bson.NewObjectID()
+		newDoc = append(bson.D{{Key: "_id", Value: id}}, newDoc...)
+	}
+	if err := m.insert(newDoc); err != nil {
+		return nil, err
+	}
+	if oid, ok := id.(bson.ObjectID); ok {
+		return &oid, nil
+	}
+	return nil, nil
+}
+
+// findFirst returns the first document matching the filter in natural order. It should be called under lock.
+func (m *MemoryCollection) findFirst(filter bson.D) (string, bson.D, error) {
+	for _, key := range m.order {
+		ok, err := matchMemoryDocument(m.docs[key], filter)
+		if err != nil {
+			return "", nil, err
+		}
+		if ok {
+			return key, m.docs[key], nil
+		}
+	}
+	return "", nil, nil
+}
+
+// insert adds the document with _id to the collection. It should be called under lock.
+func (m *MemoryCollection) insert(doc bson.D) error {
+	id, _ := lookupMemoryPath(doc, "_id")
+	key := memoryIDKey(id)
+	if _, ok := m.docs[key]; ok {
+		return &DuplicateKeyError{
+			Keys: map[string]any{"_id": id},
+			err:  fmt.Errorf("E11000 duplicate key error collection: %s dup key: { _id: %v }", m.name, id),
+		}
+	}
+	m.docs[key] = doc
+	m.order = append(m.order, key)
+	return nil
+}
+
+// toMemoryDocument converts the value to a document with canonical BSON values, e.g. int becomes int32 or int64.
+func toMemoryDocument(v any) (bson.D, error) {
+	if filter, ok := v.(M); ok && len(filter) == 0 {
+		return bson.D{}, nil
+	}
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	return doc, nil
+}
+
+// decodeMemoryValue decodes the BSON value into dest like the driver decodes a document or a cursor.
+func decodeMemoryValue(dest any, value any) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("%w: dest must be a non-nil pointer, got %T", ErrInvalidArgument, dest)
+	}
+	holderType := reflect.StructOf([]reflect.StructField{
+		{Name: "V", Type: rv.Elem().Type(), Tag: `bson:"v"`},
+	})
+	holder := reflect.New(holderType)
+
+	raw, err := bson.Marshal(bson.D{{Key: "v", Value: value}})
+	if err != nil {
+		return HandleMongoError(err)
+	}
+	if err := bson.Unmarshal(raw, holder.Interface()); err != nil {
+		return HandleMongoError(err)
+	}
+	rv.Elem().Set(holder.Elem().Field(0))
+	return nil
+}
+
+func toBSONArray(docs []bson.D) bson.A {
+	out := make(bson.A, 0, len(docs))
+	for _, doc := range docs {
+		out = append(out, doc)
+	}
+	return out
+}
+
+func cloneMemoryDocument(doc bson.D) bson.D {
+	return cloneMemoryValue(doc).(bson.D)
+}
+
+func cloneMemoryValue(v any) any {
+	switch val := v.(type) {
+	case bson.D:
+		out := make(bson.D, 0, len(val))
+		for _, e := range val {
+			out = append(out, bson.E{Key: e.Key, Value: cloneMemoryValue(e.Value)})
+		}
+		return out
+	case bson.A:
+		out := make(bson.A, 0, len(val))
+		for _, e := range val {
+			out = append(out, cloneMemoryValue(e))
+		}
+		return out
+	}
+	return v
+}
+
+func memoryIDKey(id any) string {
+	if f, ok := memoryFloat(id); ok {
+		return "number:" + strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return fmt.Sprintf("%T:%v", id, normalizeValue(id))
+}
+
+func lookupMemoryPath(doc bson.D, path string) (any, bool) {
+	key, rest, nested := strings.Cut(path, ".")
+	for _, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if !nested {
+			return e.Value, true
+		}
+		return lookupMemoryValue(e.Value, rest)
+	}
+	return nil, false
+}
+
+func lookupMemoryValue(v any, path string) (any, bool) {
+	switch val := v.(type) {
+	case bson.D:
+		return lookupMemoryPath(val, path)
+	case bson.A:
+		key, rest, nested := strings.Cut(path, ".")
+		if i, err := strconv.Atoi(key); err == nil {
+			if i < 0 || i >= len(val) {
+				return nil, false
+			}
+			if !nested {
+				return val[i], true
+			}
+			return lookupMemoryValue(val[i], rest)
+		}
+		// Path through an array of documents collects values of all elements
+		out := bson.A{}
+		for _, elem := range val {
+			if found, ok := lookupMemoryValue(elem, path); ok {
+				out = append(out, found)
+			}
+		}
+		return out, len(out) > 0
+	}
+	return nil, false
+}
+
+func setMemoryPath(doc bson.D, path string, value any) (bson.D, error) {
+	key, rest, nested := strings.Cut(path, ".")
+	for i, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if !nested {
+			doc[i].Value = value
+			return doc, nil
+		}
+		sub, ok := e.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot create field %q in element {%s: %v}", ErrPathNotViable, rest, key, e.Value)
+		}
+		sub, err := setMemoryPath(sub, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		doc[i].Value = sub
+		return doc, nil
+	}
+	if !nested {
+		return append(doc, bson.E{Key: key, Value: value}), nil
+	}
+	sub, err := setMemoryPath(bson.D{}, rest, value)
+	if err != nil {
+		return nil, err
+	}
+	return append(doc, bson.E{Key: key, Value: sub}), nil
+}
+
+func unsetMemoryPath(doc bson.D, path string) bson.D {
+	key, rest, nested := strings.Cut(path, ".")
+	for i, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if !nested {
+			return append(doc[:i], doc[i+1:]...)
+		}
+		if sub, ok := e.Value.(bson.D); ok {
+			doc[i].Value = unsetMemoryPath(sub, rest)
+		}
+		return doc
+	}
+	return doc
+}
+
+func matchMemoryDocument(doc, filter bson.D) (bool, error) {
+	for _, e := range filter {
+		ok, err := matchMemoryElement(doc, e)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchMemoryElement(doc bson.D, e bson.E) (bool, error) {
+	switch e.Key {
+	case And, Or, Nor:
+		conditions, ok := e.Value.(bson.A)
+		if !ok || len(conditions) == 0 {
+			return false, fmt.Errorf("%w: %s must be a nonempty array", ErrInvalidArgument, e.Key)
+		}
+		var matched int
+		for _, c := range conditions {
+			condition, ok := c.(bson.D)
+			if !ok {
+				return false, fmt.Errorf("%w: %s entries must be documents", ErrInvalidArgument, e.Key)
+			}
+			ok, err := matchMemoryDocument(doc, condition)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				matched++
+			}
+		}
+		switch e.Key {
+		case And:
+			return matched == len(conditions), nil
+		case Or:
+			return matched > 0, nil
+		}
+		return matched == 0, nil
+	}
+	if strings.HasPrefix(e.Key, "$") {
+		return false, fmt.Errorf("%w: operator %s is not supported by MemoryCollection", ErrInvalidArgument, e.Key)
+	}
+
+	value, found := lookupMemoryPath(doc, e.Key)
+	ops, ok := e.Value.(bson.D)
+	if !ok || len(ops) == 0 || !strings.HasPrefix(ops[0].Key, "$") {
+		return matchMemoryEqual(value, found, e.Value), nil
+	}
+	for _, op := range ops {
+		ok, err := matchMemoryOperator(value, found, op)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchMemoryOperator(value any, found bool, op bson.E) (bool, error) {
+	switch op.Key {
+	case Eq:
+		return matchMemoryEqual(value, found, op.Value), nil
+	case Ne:
+		return !matchMemoryEqual(value, found, op.Value), nil
+	case Gt:
+		return matchMemoryCompare(value, found, op.Value, func(c int) bool { return c > 0 }), nil
+	case Gte:
+		return matchMemoryCompare(value, found, op.Value, func(c int) bool { return c >= 0 }), nil
+	case Lt:
+		return matchMemoryCompare(value, found, op.Value, func(c int) bool { return c < 0 }), nil
+	case Lte:
+		return matchMemoryCompare(value, found, op.Value, func(c int) bool { return c <= 0 }), nil
+	case In, Nin:
+		targets, ok := op.Value.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("%w: %s needs an array", ErrInvalidArgument, op.Key)
+		}
+		var matched bool
+		for _, target := range targets {
+			if matchMemoryEqual(value, found, target) {
+				matched = true
+				break
+			}
+		}
+		return matched == (op.Key == In), nil
+	case Exists:
+		exists, ok := op.Value.(bool)
+		if !ok {
+			f, isNumber := memoryFloat(op.Value)
+			exists = isNumber && f != 0
+		}
+		return exists == found, nil
+	}
+	return false, fmt.Errorf("%w: operator %s is not supported by MemoryCollection", ErrInvalidArgument, op.Key)
+}
+
+func matchMemoryEqual(value any, found bool, target any) bool {
+	if !found {
+		return target == nil
+	}
+	if memoryValuesEqual(value, target) {
+		return true
+	}
+	if arr, ok := value.(bson.A); ok {
+		for _, elem := range arr {
+			if memoryValuesEqual(elem, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchMemoryCompare(value any, found bool, target any, pred func(int) bool) bool {
+	if !found {
+		return false
+	}
+	candidates := bson.A{value}
+	if arr, ok := value.(bson.A); ok {
+		candidates = arr
+	}
+	for _, c := range candidates {
+		if res, ok := compareMemoryValues(c, target); ok && pred(res) {
+			return true
+		}
+	}
+	return false
+}
+
+func memoryValuesEqual(a, b any) bool {
+	if res, ok := compareMemoryValues(a, b); ok {
+		return res == 0
+	}
+	return reflect.DeepEqual(normalizeValue(a), normalizeValue(b))
+}
+
+// compareMemoryValues compares values of the same BSON type (all numbers are one type).
+// It returns false if values cannot be compared.
+func compareMemoryValues(a, b any) (int, bool) {
+	if ai, ok := memoryInt(a); ok {
+		if bi, ok := memoryInt(b); ok {
+			return cmp.Compare(ai, bi), true
+		}
+	}
+	if af, ok := memoryFloat(a); ok {
+		bf, ok := memoryFloat(b)
+		return cmp.Compare(af, bf), ok
+	}
+	switch av := a.(type) {
+	case nil:
+		return 0, b == nil
+	case string:
+		bv, ok := b.(string)
+		return strings.Compare(av, bv), ok
+	case bool:
+		bv, ok := b.(bool)
+		switch {
+		case !ok || av == bv:
+			return 0, ok
+		case av:
+			return 1, true
+		}
+		return -1, true
+	case bson.DateTime:
+		bv, ok := b.(bson.DateTime)
+		return cmp.Compare(av, bv), ok
+	case bson.ObjectID:
+		bv, ok := b.(bson.ObjectID)
+		return bytes.Compare(av[:], bv[:]), ok
+	}
+	return 0, false
+}
+
+// memoryTypeOrder returns the position of the value type in MongoDB comparison order.
+func memoryTypeOrder(v any) int {
+	if _, ok := memoryFloat(v); ok {
+		return 1
+	}
+	switch v.(type) {
+	case nil:
+		return 0
+	case string:
+		return 2
+	case bson.D:
+		return 3
+	case bson.A:
+		return 4
+	case bson.Binary:
+		return 5
+	case bson.ObjectID:
+		return 6
+	case bool:
+		return 7
+	case bson.DateTime:
+		return 8
+	}
+	return 9
+}
+
+func memoryInt(v any) (int64, bool) {
+	switch val := v.(type) {
+	case int32:
+		return int64(val), true
+	case int64:
+		return val, true
+	case int:
+		return int64(val), true
+	}
+	return 0, false
+}
+
+func memoryFloat(v any) (float64, bool) {
+	if i, ok := memoryInt(v); ok {
+		return float64(i), true
+	}
+	f, ok := v.(float64)
+	return f, ok
+}
+
+func memoryFindOptions(rawOpts []FindOptions) FindOptions {
+	if len(rawOpts) > 0 {
+		return rawOpts[0]
+	}
+	return FindOptions{}
+}
+
+func memorySort(opts FindOptions) bson.D {
+	switch {
+	case opts.StableSortField != "":
+		return stableSort(opts)
+	case len(opts.Sort) > 0:
+		return opts.Sort.Prepare()
+	}
+	return sortManyToD(opts.SortMany)
+}
+
+func sortMemoryDocuments(docs []bson.D, sortKeys bson.D) {
+	if len(sortKeys) == 0 {
+		return
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, key := range sortKeys {
+			a, _ := lookupMemoryPath(docs[i], key.Key)
+			b, _ := lookupMemoryPath(docs[j], key.Key)
+			res, ok := compareMemoryValues(a, b)
+			if !ok {
+				res = cmp.Compare(memoryTypeOrder(a), memoryTypeOrder(b))
+			}
+			if res == 0 {
+				continue
+			}
+			if direction, _ := memoryFloat(key.Value); direction < 0 {
+				return res > 0
+			}
+			return res < 0
+		}
+		return false
+	})
+}
+
+func applyMemoryUpdate(doc, update bson.D) (bson.D, error) {
+	if len(update) == 0 {
+		return nil, fmt.Errorf("%w: update document must have at least one element", ErrInvalidArgument)
+	}
+	for _, op := range update {
+		fields, ok := op.Value.(bson.D)
+		if !strings.HasPrefix(op.Key, "$") || !ok {
+			return nil, fmt.Errorf("%w: update document must contain key beginning with '$'", ErrInvalidArgument)
+		}
+		for _, f := range fields {
+			if f.Key == "_id" {
+				return nil, fmt.Errorf("%w: performing an update on the path '_id' would modify the immutable field '_id'", ErrImmutableField)
+			}
+			var err error
+			doc, err = applyMemoryOperator(doc, op.Key, f)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return doc, nil
+}
+
+func applyMemoryOperator(doc bson.D, op string, f bson.E) (bson.D, error) {
+	old, found := lookupMemoryPath(doc, f.Key)
+	switch op {
+	case Set:
+		return setMemoryPath(doc, f.Key, f.Value)
+	case SetOnInsert:
+		return doc, nil // applied by upsertUpdate only to inserted documents
+	case Unset:
+		return unsetMemoryPath(doc, f.Key), nil
+	case Inc, Mul:
+		if !found {
+			old = int32(0)
+		}
+		value, err := memoryArithmetic(op, old, f.Value)
+		if err != nil {
+			return nil, err
+		}
+		return setMemoryPath(doc, f.Key, value)
+	case Min, Max:
+		res, ok := compareMemoryValues(f.Value, old)
+		if !ok {
+			res = cmp.Compare(memoryTypeOrder(f.Value), memoryTypeOrder(old))
+		}
+		if !found || (op == Min && res < 0) || (op == Max && res > 0) {
+			return setMemoryPath(doc, f.Key, f.Value)
+		}
+		return doc, nil
+	case CurrentDate:
+		if spec, ok := f.Value.(bson.D); ok && len(spec) == 1 && spec[0].Key == "$type" && spec[0].Value == "timestamp" {
+			return setMemoryPath(doc, f.Key, bson.Timestamp{T: uint32(time.Now().Unix()), I: 1})
+		}
+		return setMemoryPath(doc, f.Key, bson.NewDateTimeFromTime(time.Now()))
+	case Push, AddToSet:
+		arr := bson.A{}
+		if found {
+			existing, ok := old.(bson.A)
+			if !ok {
+				return nil, fmt.Errorf("%w: the field '%s' must be an array but is of type %T", ErrBadValue, f.Key, old)
+			}
+			arr = append(arr, existing...)
+		}
+		values := bson.A{f.Value}
+		if modifiers, ok := f.Value.(bson.D); ok && len(modifiers) > 0 && modifiers[0].Key == Each {
+			if values, ok = modifiers[0].Value.(bson.A); !ok {
+				return nil, fmt.Errorf("%w: the argument to %s must be an array", ErrBadValue, Each)
+			}
+		}
+		for _, v := range values {
+			if op == AddToSet && matchMemoryEqual(arr, true, v) {
+				continue
+			}
+			arr = append(arr, v)
+		}
+		return setMemoryPath(doc, f.Key, arr)
+	}
+	return nil, fmt.Errorf("%w: operator %s is not supported by MemoryCollection", ErrInvalidArgument, op)
+}
+
+func memoryArithmetic(op string, a, b any) (any, error) {
+	_, aFloat := a.(float64)
+	_, bFloat := b.(float64)
+	if aFloat || bFloat {
+		af, aOK := memoryFloat(a)
+		bf, bOK := memoryFloat(b)
+		if !aOK || !bOK {
+			return nil, memoryTypeMismatch(op, a, b)
+		}
+		return lang.If(op == Inc, af+bf, af*bf), nil
+	}
+
+	ai, aOK := memoryInt(a)
+	bi, bOK := memoryInt(b)
+	if !aOK || !bOK {
+		return nil, memoryTypeMismatch(op, a, b)
+	}
+	res := lang.If(op == Inc, ai+bi, ai*bi)
+
+	_, aInt32 := a.(int32)
+	_, bInt32 := b.(int32)
+	if aInt32 && bInt32 && res >= math.MinInt32 && res <= math.MaxInt32 {
+		return int32(res), nil
+	}
+	return res, nil
+}
+
+func memoryTypeMismatch(op string, a, b any) error {
+	return fmt.Errorf("%w: cannot apply %s with %T argument to a value of type %T", ErrTypeMismatch, op, b, a)
+}
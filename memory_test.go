@@ -0,0 +1,224 @@
+package mongox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/maxbolgarin/mongox"
+)
+
+func TestMemoryCollection(t *testing.T) {
+	ctx := context.Background()
+
+	var coll mongox.CollectionAPI = mongox.NewMemoryCollection("memory")
+
+	entities := []any{newTestEntity("1"), newTestEntity("2"), newTestEntity("3")}
+	for i, e := range entities {
+		entity := e.(testEntity)
+		entity.Number = i + 1
+		entities[i] = entity
+	}
+
+	t.Run("Insert", func(t *testing.T) {
+		ids, err := coll.InsertMany(ctx, entities)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != len(entities) || ids[0].IsZero() {
+			t.Errorf("expected %d generated ids, got %v", len(entities), ids)
+		}
+
+		_, err = coll.InsertOne(ctx, map[string]any{"_id": ids[0], "id": "dup"})
+		if !errors.Is(err, mongox.ErrDuplicate) {
+			t.Errorf("expected error %v, got %v", mongox.ErrDuplicate, err)
+		}
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		var res testEntity
+		err := coll.FindOne(ctx, &res, mongox.M{"id": "2"})
+		if err != nil {
+			t.Error(err)
+		}
+		if res.ID != "2" || res.Number != 2 {
+			t.Errorf("expected %v, got %v", entities[1], res)
+		}
+
+		var many []testEntity
+		err = coll.Find(ctx, &many, mongox.M{"number": mongox.M{mongox.Gte: 2}}, mongox.FindOptions{
+			Sort: mongox.M{"number": mongox.Descending},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(many) != 2 || many[0].ID != "3" || many[1].ID != "2" {
+			t.Errorf("expected [3 2], got %v", many)
+		}
+
+		err = coll.Find(ctx, &many, mongox.OrFilter(mongox.M{"id": "1"}, mongox.M{"id": mongox.M{mongox.In: []string{"3"}}}))
+		if err != nil {
+			t.Error(err)
+		}
+		if len(many) != 2 {
+			t.Errorf("expected %v, got %v", 2, len(many))
+		}
+
+		count, err := coll.Count(ctx, mongox.M{"struct.number": mongox.M{mongox.Exists: true}})
+		if err != nil {
+			t.Error(err)
+		}
+		if count != 3 {
+			t.Errorf("expected %v, got %v", 3, count)
+		}
+
+		err = coll.FindOne(ctx, &res, mongox.M{"id": "not-found"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
+		err = coll.FindOne(ctx, &res, mongox.M{"id": mongox.M{mongox.Regex: "1"}})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		_, err := coll.SetFields(ctx, mongox.M{"id": "1"}, mongox.M{"name": "new-name"})
+		if err != nil {
+			t.Error(err)
+		}
+		err = coll.IncFields(ctx, mongox.M{"id": "1"}, map[string]int64{"number": 10})
+		if err != nil {
+			t.Error(err)
+		}
+		n, err := coll.UpdateMany(ctx, mongox.M{"number": mongox.M{mongox.Lt: 3}}, mongox.M{mongox.Push: mongox.M{"slice": 100}})
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 1 {
+			t.Errorf("expected %v, got %v", 1, n)
+		}
+
+		var res testEntity
+		if err := coll.FindOne(ctx, &res, mongox.M{"id": "1"}); err != nil {
+			t.Error(err)
+		}
+		if res.Name != "new-name" || res.Number != 11 {
+			t.Errorf("expected updated entity, got %v", res)
+		}
+		if err := coll.FindOne(ctx, &res, mongox.M{"id": "2"}); err != nil {
+			t.Error(err)
+		}
+		if len(res.Slice) == 0 || res.Slice[len(res.Slice)-1] != 100 {
+			t.Errorf("expected 100 to be pushed, got %v", res.Slice)
+		}
+
+		_, err = coll.UpdateOne(ctx, mongox.M{"id": "1"}, mongox.M{"name": "no-operator"})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+		_, err = coll.UpdateOne(ctx, mongox.M{"id": "1"}, mongox.M{mongox.Inc: mongox.M{"name": 1}})
+		if !errors.Is(err, mongox.ErrTypeMismatch) {
+			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		}
+		_, err = coll.UpdateOne(ctx, mongox.M{"id": "not-found"}, mongox.M{mongox.Set: mongox.M{"name": "x"}})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
+	})
+
+	t.Run("Upsert", func(t *testing.T) {
+		id, err := coll.Upsert(ctx, newTestEntity("4"), mongox.M{"id": "4"})
+		if err != nil {
+			t.Error(err)
+		}
+		if id == nil {
+			t.Error("expected inserted id")
+		}
+
+		replacement := newTestEntity("4")
+		replacement.Name = "replaced"
+		id, err = coll.Upsert(ctx, replacement, mongox.M{"id": "4"})
+		if err != nil {
+			t.Error(err)
+		}
+		if id != nil {
+			t.Errorf("expected nil id, got %v", id)
+		}
+
+		var names []string
+		if err := coll.Distinct(ctx, &names, "name", mongox.M{"id": "4"}); err != nil {
+			t.Error(err)
+		}
+		if len(names) != 1 || names[0] != "replaced" {
+			t.Errorf("expected [replaced], got %v", names)
+		}
+	})
+
+	t.Run("UpdateUpsert", func(t *testing.T) {
+		upsert := mongox.UpdateOptions{Upsert: true}
+		update := mongox.M{mongox.Inc: mongox.M{"number": 1}, mongox.SetOnInsert: mongox.M{"name": "new"}}
+		id, err := coll.UpdateOne(ctx, mongox.M{"id": "5"}, update, upsert)
+		if err != nil {
+			t.Error(err)
+		}
+		if id == nil {
+			t.Error("expected inserted id")
+		}
+		id, err = coll.UpdateOne(ctx, mongox.M{"id": "5"}, update, upsert)
+		if err != nil {
+			t.Error(err)
+		}
+		if id != nil {
+			t.Errorf("expected nil id, got %v", id)
+		}
+
+		var res testEntity
+		if err := coll.FindOne(ctx, &res, mongox.M{"id": "5"}); err != nil {
+			t.Error(err)
+		}
+		if res.Number != 2 || res.Name != "new" {
+			t.Errorf("expected number 2 and name new, got %+v", res)
+		}
+
+		if err := coll.DeleteOne(ctx, mongox.M{"id": "5"}); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		err := coll.DeleteOne(ctx, mongox.M{"id": "4"})
+		if err != nil {
+			t.Error(err)
+		}
+		n, err := coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.Nin: []string{"1"}}}, mongox.WriteOptions{DryRun: true})
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 2 {
+			t.Errorf("expected %v, got %v", 2, n)
+		}
+		n, err = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.Nin: []string{"1"}}})
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 2 {
+			t.Errorf("expected %v, got %v", 2, n)
+		}
+		err = coll.DeleteOne(ctx, mongox.M{"id": "4"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
+
+		if err := coll.Truncate(ctx); err != nil {
+			t.Error(err)
+		}
+		count, err := coll.Count(ctx, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if count != 0 {
+			t.Errorf("expected %v, got %v", 0, count)
+		}
+	})
+}
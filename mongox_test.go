@@ -1,15 +1,19 @@
 package mongox_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"log"
 	"log/slog"
 	"math"
+	"net/http"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -22,9 +26,15 @@ import (
 	"github.com/ory/dockertest/v3/docker"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
 )
 
-var client *mongox.Client
+var (
+	client     *mongox.Client
+	testConfig mongox.Config
+)
 
 const (
 	dbName = "mongox"
@@ -99,6 +109,181 @@ func TestIndexAndText(t *testing.T) {
 		}
 	})
 
+	t.Run("IndexPartialUnique", func(t *testing.T) {
+		coll := db.Collection("index_partial_unique")
+		err := coll.CreatePartialUniqueIndex(ctx, "email", mongox.M{"deleted": false})
+		if err != nil {
+			t.Error(err)
+		}
+
+		deleted := mongox.M{"email": "user@example.com", "deleted": true}
+		if _, err = coll.Insert(ctx, deleted); err != nil {
+			t.Error(err)
+		}
+		if _, err = coll.Insert(ctx, mongox.M{"email": "user@example.com", "deleted": true}); err != nil {
+			t.Error(err)
+		}
+
+		if _, err = coll.Insert(ctx, mongox.M{"email": "user@example.com", "deleted": false}); err != nil {
+			t.Error(err)
+		}
+		_, err = coll.Insert(ctx, mongox.M{"email": "user@example.com", "deleted": false})
+		if !errors.Is(err, mongox.ErrDuplicate) {
+			t.Errorf("expected error %v, got %v", mongox.ErrDuplicate, err)
+		}
+
+		err = mongox.CreatePartialUniqueIndex(ctx, coll, "email", nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+	})
+
+	t.Run("IndexDuplicateKeyError", func(t *testing.T) {
+		coll := db.Collection("index_duplicate_key")
+		if err := coll.CreateIndex(ctx, true, "id"); err != nil {
+			t.Error(err)
+		}
+		if err := coll.CreateIndex(ctx, true, "email"); err != nil {
+			t.Error(err)
+		}
+
+		if _, err := coll.Upsert(ctx, mongox.M{"id": "1", "email": "taken@example.com"}, mongox.M{"id": "1"}); err != nil {
+			t.Error(err)
+		}
+		_, err := coll.Upsert(ctx, mongox.M{"id": "2", "email": "taken@example.com"}, mongox.M{"id": "2"})
+		if !errors.Is(err, mongox.ErrDuplicate) {
+			t.Errorf("expected error %v, got %v", mongox.ErrDuplicate, err)
+		}
+		var dupErr *mongox.DuplicateKeyError
+		if !errors.As(err, &dupErr) {
+			t.Fatalf("expected DuplicateKeyError, got %T", err)
+		}
+		if !reflect.DeepEqual(dupErr.Keys, map[string]any{"email": "taken@example.com"}) {
+			t.Errorf("expected %v, got %v", map[string]any{"email": "taken@example.com"}, dupErr.Keys)
+		}
+
+		_, err = coll.Insert(ctx, mongox.M{"id": "1", "email": "other@example.com"})
+		if !errors.As(err, &dupErr) {
+			t.Fatalf("expected DuplicateKeyError, got %T", err)
+		}
+		if dupErr.Keys["id"] != "1" {
+			t.Errorf("expected %v, got %v", "1", dupErr.Keys["id"])
+		}
+	})
+
+	t.Run("IndexIdempotent", func(t *testing.T) {
+		coll := db.Collection("index_idempotent")
+		opts := mongox.IndexOptions{Unique: true, PartialFilter: mongox.M{"deleted": false, "age": mongox.M{mongox.Gt: 18}}}
+
+		for range 2 {
+			if err := coll.CreateIndexIdempotent(ctx, opts, "email"); err != nil {
+				t.Error(err)
+			}
+		}
+
+		// Same name, different partial filter
+		err := mongox.CreateIndexIdempotent(ctx, coll, mongox.IndexOptions{Unique: true, PartialFilter: mongox.M{"deleted": true}}, "email")
+		if !errors.Is(err, mongox.ErrIndexOptionsConflict) && !errors.Is(err, mongox.ErrIndexKeySpecsConflict) {
+			t.Errorf("expected index conflict error, got %v", err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "partial filter") {
+			t.Errorf("expected partial filter in error, got %v", err)
+		}
+
+		if err := coll.CreateIndexIdempotent(ctx, mongox.IndexOptions{Unique: true}, "name"); err != nil {
+			t.Error(err)
+		}
+
+		// Same keys, different uniqueness
+		err = coll.CreateIndexIdempotent(ctx, mongox.IndexOptions{}, "name")
+		if !errors.Is(err, mongox.ErrIndexOptionsConflict) && !errors.Is(err, mongox.ErrIndexKeySpecsConflict) {
+			t.Errorf("expected index conflict error, got %v", err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "unique") {
+			t.Errorf("expected unique in error, got %v", err)
+		}
+	})
+
+	t.Run("IndexCustomName", func(t *testing.T) {
+		coll := db.Collection("index_custom_name")
+		if _, err := coll.Collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetName("legacy_email"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Generated name differs from the existing one
+		err := coll.CreateIndexIdempotent(ctx, mongox.IndexOptions{}, "email")
+		if !errors.Is(err, mongox.ErrIndexOptionsConflict) {
+			t.Errorf("expected error %v, got %v", mongox.ErrIndexOptionsConflict, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "name") {
+			t.Errorf("expected name in error, got %v", err)
+		}
+
+		if err := coll.CreateIndexIdempotent(ctx, mongox.IndexOptions{Name: "legacy_email"}, "email"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("IndexHidden", func(t *testing.T) {
+		coll := db.Collection("index_hidden")
+		isHidden := func() bool {
+			cur, err := coll.Collection().Indexes().List(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var specs []struct {
+				Name   string `bson:"name"`
+				Hidden bool   `bson:"hidden"`
+			}
+			if err := cur.All(ctx, &specs); err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range specs {
+				if s.Name == "hidden_email" {
+					return s.Hidden
+				}
+			}
+			t.Fatal("index hidden_email not found")
+			return false
+		}
+
+		err := coll.CreateIndexWithOptions(ctx, mongox.IndexOptions{Name: "hidden_email", Hidden: true}, "email")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isHidden() {
+			t.Error("expected index to be hidden")
+		}
+
+		// Same options are still idempotent, visibility is a difference
+		if err := coll.CreateIndexIdempotent(ctx, mongox.IndexOptions{Name: "hidden_email", Hidden: true}, "email"); err != nil {
+			t.Error(err)
+		}
+		err = coll.CreateIndexIdempotent(ctx, mongox.IndexOptions{Name: "hidden_email"}, "email")
+		if !errors.Is(err, mongox.ErrIndexOptionsConflict) {
+			t.Errorf("expected error %v, got %v", mongox.ErrIndexOptionsConflict, err)
+		}
+
+		if err := mongox.SetIndexHidden(ctx, coll, "hidden_email", false); err != nil {
+			t.Fatal(err)
+		}
+		if isHidden() {
+			t.Error("expected index to be visible")
+		}
+
+		if err := coll.SetIndexHidden(ctx, "not_exists", true); !errors.Is(err, mongox.ErrIndexNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrIndexNotFound, err)
+		}
+
+		err = coll.CreateIndexWithOptions(ctx, mongox.IndexOptions{CommitQuorum: 1.5}, "name")
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+	})
+
 	t.Run("Text", func(t *testing.T) {
 		entity1 := newTestEntity("1")
 		entity1.Name = "Running tool: /usr/local/go/bin/go test -timeout 45s -run ^TestFind$ github.com/maxbolgarin/mongox"
@@ -151,6 +336,32 @@ func TestIndexAndText(t *testing.T) {
 			t.Errorf("expected not empty, got %v", res2.ID)
 		}
 	})
+
+	t.Run("DropTextIndex", func(t *testing.T) {
+		coll := db.Collection(textCollection + "_drop")
+		if _, err := coll.Insert(ctx, newTestEntity("1")); err != nil {
+			t.Error(err)
+		}
+
+		err := coll.DropTextIndex(ctx)
+		if !errors.Is(err, mongox.ErrIndexNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrIndexNotFound, err)
+		}
+
+		if err := coll.CreateTextIndex(ctx, "en", "name"); err != nil {
+			t.Error(err)
+		}
+		if err := coll.CreateTextIndex(ctx, "de", "name"); err == nil {
+			t.Error("expected error for the second text index")
+		}
+
+		if err := mongox.DropTextIndex(ctx, coll); err != nil {
+			t.Error(err)
+		}
+		if err := coll.CreateTextIndex(ctx, "de", "name"); err != nil {
+			t.Error(err)
+		}
+	})
 }
 
 func TestInsertFindDelete(t *testing.T) {
@@ -166,6 +377,89 @@ func TestInsertFindDelete(t *testing.T) {
 		t.Error(err)
 	}
 
+	t.Run("WithTransactionRetry", func(t *testing.T) {
+		isReplicaSet, err := db.IsReplicaSet(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isReplicaSet {
+			var calls int
+			_, err = db.WithTransactionRetry(ctx, 3, func(ctx context.Context) (any, error) {
+				calls++
+				return nil, nil
+			})
+			// Unsupported transactions are not transient, so there is no retry
+			if !errors.Is(err, mongox.ErrTransactionsUnsupported) || calls != 0 {
+				t.Errorf("expected error %v without calls, got %v after %d calls", mongox.ErrTransactionsUnsupported, err, calls)
+			}
+			if mongox.IsTransient(nil) || mongox.IsTransient(mongox.ErrDuplicate) || !mongox.IsTransient(mongox.ErrWriteConflict) {
+				t.Error("unexpected IsTransient classification")
+			}
+			return
+		}
+
+		var calls int
+		res, err := db.WithTransactionRetry(ctx, 3, func(ctx context.Context) (any, error) {
+			calls++
+			if calls == 1 {
+				return nil, fmt.Errorf("%w: conflict", mongox.ErrWriteConflict)
+			}
+			return "ok", nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if res != "ok" || calls != 2 {
+			t.Errorf("expected result ok after %d calls, got %v after %d calls", 2, res, calls)
+		}
+
+		// Raw server errors are mapped to errors of the package
+		_, err = db.WithTransaction(ctx, func(ctx context.Context) (any, error) {
+			return nil, mongo.CommandError{Code: 112, Name: "WriteConflict", Message: "conflict"}
+		})
+		if !errors.Is(err, mongox.ErrWriteConflict) || !mongox.IsTransient(err) {
+			t.Errorf("expected transient error %v, got %v", mongox.ErrWriteConflict, err)
+		}
+
+		calls = 0
+		_, err = db.WithTransactionRetry(ctx, 3, func(ctx context.Context) (any, error) {
+			calls++
+			return nil, mongox.ErrNoSuchTransaction
+		})
+		if !errors.Is(err, mongox.ErrNoSuchTransaction) || calls != 3 {
+			t.Errorf("expected error %v after %d calls, got %v after %d calls", mongox.ErrNoSuchTransaction, 3, err, calls)
+		}
+
+		// Not transient errors are not retried
+		calls = 0
+		_, err = db.WithTransactionRetry(ctx, 3, func(ctx context.Context) (any, error) {
+			calls++
+			return nil, mongox.ErrNotFound
+		})
+		if !errors.Is(err, mongox.ErrNotFound) || calls != 1 {
+			t.Errorf("expected error %v after %d calls, got %v after %d calls", mongox.ErrNotFound, 1, err, calls)
+		}
+
+		// Network errors may hide a committed transaction, so they are not retried
+		calls = 0
+		_, err = db.WithTransactionRetry(ctx, 3, func(ctx context.Context) (any, error) {
+			calls++
+			return nil, mongox.ErrNetwork
+		})
+		if !errors.Is(err, mongox.ErrNetwork) || calls != 1 {
+			t.Errorf("expected error %v after %d calls, got %v after %d calls", mongox.ErrNetwork, 1, err, calls)
+		}
+
+		_, err = db.WithTransactionRetry(ctx, 0, func(ctx context.Context) (any, error) { return nil, nil })
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		if mongox.IsTransient(nil) || mongox.IsTransient(mongox.ErrDuplicate) || !mongox.IsTransient(mongox.ErrWriteConflict) {
+			t.Error("unexpected IsTransient classification")
+		}
+	})
+
 	t.Run("FindOne_Replace_Upsert_DeleteOne", func(t *testing.T) {
 		entity1 := newTestEntity("1")
 		_, err := db.WithTransaction(ctx, func(ctx context.Context) (any, error) {
@@ -176,8 +470,8 @@ func TestInsertFindDelete(t *testing.T) {
 			return nil, nil
 		})
 		// Transaction is available only for replica sets or Sharded Clusters, not for standalone servers.
-		if !errors.Is(err, mongox.ErrIllegalOperation) {
-			t.Errorf("expected error %v, got %v", mongox.ErrIllegalOperation, err)
+		if !errors.Is(err, mongox.ErrTransactionsUnsupported) {
+			t.Errorf("expected error %v, got %v", mongox.ErrTransactionsUnsupported, err)
 		}
 
 		_, err = db.Collection(findOneCollection).Insert(ctx, entity1)
@@ -294,6 +588,47 @@ func TestInsertFindDelete(t *testing.T) {
 		}
 	})
 
+	t.Run("Find_PointerSlice", func(t *testing.T) {
+		coll := db.Collection("find_pointer_slice_test")
+		entity1, entity2 := newTestEntity("1"), newTestEntity("2")
+		if _, err := coll.Insert(ctx, entity1, entity2); err != nil {
+			t.Fatal(err)
+		}
+
+		var out []*testEntity
+		err := coll.Find(ctx, &out, nil, mongox.FindOptions{Sort: mongox.M{"id": mongox.Ascending}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 2 || out[0] == out[1] {
+			t.Fatalf("expected 2 distinct elements, got %v", out)
+		}
+		if !reflect.DeepEqual(*out[0], entity1) || !reflect.DeepEqual(*out[1], entity2) {
+			t.Errorf("expected %v, got %v", []testEntity{entity1, entity2}, out)
+		}
+
+		// Reused slice doesn't overwrite documents of the previous result
+		first := out[0]
+		err = coll.Find(ctx, &out, mongox.M{"id": "2"})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(out) != 1 || out[0].ID != "2" {
+			t.Errorf("expected [2], got %v", out)
+		}
+		if first.ID != "1" || out[0] == first {
+			t.Errorf("expected previous element to be untouched, got %v", first)
+		}
+
+		result, err := mongox.Find[*testEntity](ctx, coll, mongox.M{"id": "1"})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(result) != 1 || !reflect.DeepEqual(*result[0], entity1) {
+			t.Errorf("expected %v, got %v", entity1, result)
+		}
+	})
+
 	t.Run("FindAll_Count_Distinct", func(t *testing.T) {
 		var result []testEntity
 		err := db.Collection(findAllCollection).FindAll(ctx, &result)
@@ -335,6 +670,21 @@ func TestInsertFindDelete(t *testing.T) {
 			t.Errorf("expected 10, got %d", len(result))
 		}
 
+		estimated, err := db.Collection(findAllCollection).EstimatedCount(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		if estimated != 100 {
+			t.Errorf("expected 100, got %d", estimated)
+		}
+		estimated, err = mongox.EstimatedCount(ctx, db.Collection("estimated_count_not_exists"))
+		if err != nil {
+			t.Error(err)
+		}
+		if estimated != 0 {
+			t.Errorf("expected 0, got %d", estimated)
+		}
+
 		result, err = mongox.FindAll[testEntity](ctx, db.Collection(findAllCollection), mongox.FindOptions{Skip: 90})
 		if err != nil {
 			t.Error(err)
@@ -413,7 +763,7 @@ func TestUpdate(t *testing.T) {
 		}
 
 		testUpdate(t, ctx, db, entity, mongox.M{"name": entity.Name})
-		err = mongox.SetFields(ctx, coll, f, mongox.M{"name": "new-name"})
+		_, err = mongox.SetFields(ctx, coll, f, mongox.M{"name": "new-name"})
 		if err != nil {
 			t.Error(err)
 		}
@@ -425,7 +775,7 @@ func TestUpdate(t *testing.T) {
 		testUpdate(t, ctx, db, entity, mongox.M{"name": "new-name"})
 
 		testUpdate(t, ctx, db, entity, mongox.M{"number": entity.Number})
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Inc: mongox.M{"number": 10}})
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Inc: mongox.M{"number": 10}})
 		if err != nil {
 			t.Error(err)
 		}
@@ -574,6 +924,21 @@ func TestUpdate(t *testing.T) {
 		testUpdate(t, ctx, db, newEntity2, mongox.M{"struct.name": newEntity.Struct.Name})
 		testUpdate(t, ctx, db, newEntity2, mongox.M{"Struct.Name": "new-struct-name-2"})
 
+		err = mongox.UpdateOneFromDiff(ctx, coll, mongox.M{"id": "1"}, &updTestEntity2, mongox.DiffOptions{
+			FieldNameMapper: mongox.LowercaseFieldName,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+
+		newEntity2, err = mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "1"})
+		if err != nil {
+			t.Error(err)
+		}
+		if newEntity2.Struct.Name != "new-struct-name-2" {
+			t.Errorf("expected %s, got %s", "new-struct-name-2", newEntity2.Struct.Name)
+		}
+
 		updTestEntity3 := struct {
 			Name *testUpdateEntity `bson:"name"`
 		}{
@@ -592,2360 +957,5994 @@ func TestUpdate(t *testing.T) {
 
 		testUpdate(t, ctx, db, newEntity3, mongox.M{"name": "new-name-3"})
 	})
-}
 
-func TestBulk(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	t.Run("UpdateFromDiffTracked", func(t *testing.T) {
+		coll := db.Collection(updateCollection + "_diff_tracked")
+		if _, err := coll.Insert(ctx, newTestEntity("1")); err != nil {
+			t.Error(err)
+		}
 
-	db := client.Database(dbName)
+		diff := struct {
+			Name   *string `bson:"name"`
+			Number *int    `bson:"number"`
+			Struct *struct {
+				Number *int `bson:"number"`
+			} `bson:"struct"`
+		}{
+			Name: lang.Ptr("tracked-name"),
+			Struct: &struct {
+				Number *int `bson:"number"`
+			}{
+				Number: lang.Ptr(42),
+			},
+		}
 
-	t.Run("BulkSuccess", func(t *testing.T) {
-		for i := 0; i < 2; i++ {
-			var isOrdered bool
-			coll := db.Collection(bulkCollection)
-			if i == 1 {
-				coll = db.Collection(bulkCollection + "1")
-				isOrdered = true
-			}
+		changed, err := mongox.UpdateOneFromDiffTracked(ctx, coll, mongox.M{"id": "1"}, &diff)
+		if err != nil {
+			t.Error(err)
+		}
+		expected := []string{"name", "struct.number"}
+		if !reflect.DeepEqual(changed, expected) {
+			t.Errorf("expected %v, got %v", expected, changed)
+		}
 
-			bulker := mongox.NewBulkBuilder()
-			for i := 0; i < 10; i++ {
-				bulker.Insert(newTestEntity(fmt.Sprintf("%d", i+1)))
-			}
-			bulker.Upsert(newTestEntity("100"), mongox.M{"id": "11"})
-			bulker.ReplaceOne(newTestEntity("200"), mongox.M{"id": "2"})
-			bulker.SetFields(mongox.M{"id": "1"}, mongox.M{"name": "new-name"})
-			bulker.UpdateOne(mongox.M{"id": "1"}, mongox.M{"$set": mongox.M{"number": 228}})
-			bulker.UpdateMany(mongox.M{"id": mongox.M{mongox.Gte: "10"}}, mongox.M{"$set": mongox.M{"number": 322}})
-			bulker.UpdateOneFromDiff(mongox.M{"id": "10"}, struct {
-				Name *string `bson:"name"`
-			}{
-				Name: lang.Ptr("new-name-2"),
-			})
-			bulker.DeleteFields(mongox.M{"id": "10"}, "struct.name")
-			bulker.DeleteOne(mongox.M{"id": "3"})
-			bulker.DeleteMany(mongox.M{"id": mongox.M{mongox.In: []any{"4", "5"}}})
+		entity, err := mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "1"})
+		if err != nil {
+			t.Error(err)
+		}
+		if entity.Name != "tracked-name" || entity.Struct.Number != 42 {
+			t.Errorf("expected updated entity, got %v", entity)
+		}
 
-			_, err := mongox.BulkWrite(ctx, coll, bulker.Models(), isOrdered)
-			if err != nil {
-				t.Error(err)
-			}
+		changed, err = coll.UpdateOneFromDiffTracked(ctx, mongox.M{"id": "not-found"}, &diff)
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
+		if !reflect.DeepEqual(changed, expected) {
+			t.Errorf("expected %v, got %v", expected, changed)
+		}
 
-			n, err := coll.Count(ctx, nil)
-			if err != nil {
-				t.Error(err)
-			}
-			if n != 8 {
-				t.Errorf("expected %d, got %d", 8, n)
-			}
+		_, err = coll.UpdateOneFromDiffTracked(ctx, mongox.M{"id": "1"}, mongox.M{"name": "x"})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+	})
 
-			entity, err := mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "1"})
+	t.Run("PushBuilder", func(t *testing.T) {
+		coll := db.Collection(updateCollection + "_push")
+		f := mongox.M{"id": "push"}
+		if _, err := coll.Insert(ctx, mongox.M{"id": "push", "events": []any{}, "numbers": []int{1, 2}}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Keep only 3 most recent events
+		for i := range 5 {
+			upd, err := mongox.PushTo("events").
+				Each(mongox.M{"ts": i}).
+				Sort(mongox.M{"ts": mongox.Descending}).
+				Slice(3).
+				Build()
 			if err != nil {
-				t.Error(err)
+				t.Fatal(err)
 			}
-			if entity.Name != "new-name" {
-				t.Errorf("expected %s, got %s", "new-name", entity.Name)
+			if _, err := coll.UpdateOne(ctx, f, upd); err != nil {
+				t.Error(err)
 			}
-			if entity.Number != 228 {
-				t.Errorf("expected %d, got %d", 228, entity.Number)
-			}
-			entity10, err := mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "10"})
-			if err != nil {
-				t.Error(err)
-			}
-			if entity10.Name != "new-name-2" {
-				t.Errorf("expected %s, got %s", "new-name-2", entity10.Name)
-			}
-			if entity10.Number != 322 {
-				t.Errorf("expected %d, got %d", 322, entity10.Number)
-			}
-			if entity10.Struct.Name != "" {
-				t.Errorf("expected %s, got %s", "", entity10.Struct.Name)
-			}
-
-			testBulk(t, ctx, db, newTestEntity("1"), mongox.M{"id": "2"}, mongox.ErrNotFound)
-			testBulk(t, ctx, db, newTestEntity("1"), mongox.M{"id": "3"}, mongox.ErrNotFound)
-			testBulk(t, ctx, db, newTestEntity("1"), mongox.M{"id": "4"}, mongox.ErrNotFound)
-			testBulk(t, ctx, db, newTestEntity("1"), mongox.M{"id": "5"}, mongox.ErrNotFound)
 		}
-	})
-
-	t.Run("BulkError", func(t *testing.T) {
-		coll := db.Collection(bulkCollection)
-		bulker := mongox.NewBulkBuilder()
-		bulker.DeleteOne(mongox.M{"id": "1"})
 
-		_, err := mongox.BulkWrite(ctx, coll, bulker.Models(), false)
+		// Insert at the beginning and keep the last 3 elements
+		upd, err := mongox.PushTo("numbers").Each(0).Position(0).Build()
 		if err != nil {
 			t.Error(err)
 		}
-
-		_, err = mongox.BulkWrite(ctx, coll, bulker.Models(), true)
-		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
-		}
-
-		bulker.Insert(mongox.M{"id": "1"})
-
-		_, err = mongox.BulkWrite(ctx, coll, bulker.Models(), false)
+		upd2, err := mongox.PushTo("numbers").Each(3, 4).Slice(-3).Build()
 		if err != nil {
 			t.Error(err)
 		}
-
-		_, err = mongox.BulkWrite(ctx, coll, bulker.Models(), true)
-		if err != nil {
+		if _, err := coll.UpdateOne(ctx, f, upd); err != nil {
 			t.Error(err)
 		}
-
-		bulker = mongox.NewBulkBuilder()
-		bulker.Insert(mongox.M{"id": "1"})
-		bulker.DeleteOne(mongox.M{"id": "2"})
-
-		_, err = mongox.BulkWrite(ctx, coll, bulker.Models(), false)
-		if err != nil {
+		if _, err := coll.UpdateOne(ctx, f, upd2); err != nil {
 			t.Error(err)
 		}
 
-		_, err = mongox.BulkWrite(ctx, coll, bulker.Models(), true)
-		if err != nil {
+		var res struct {
+			Events []struct {
+				TS int `bson:"ts"`
+			} `bson:"events"`
+			Numbers []int `bson:"numbers"`
+		}
+		if err := coll.FindOne(ctx, &res, f); err != nil {
 			t.Error(err)
 		}
-	})
-}
-
-func TestError(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	db := client.Database(dbName)
-
-	t.Run("Error_NilArguments", func(t *testing.T) {
-		coll := db.Collection(errorNilArgCollection)
-		if coll.Name() != errorNilArgCollection {
-			t.Errorf("expected %v, got %v", errorNilArgCollection, coll.Name())
+		if len(res.Events) != 3 || res.Events[0].TS != 4 || res.Events[2].TS != 2 {
+			t.Errorf("expected 3 most recent events, got %v", res.Events)
+		}
+		if !reflect.DeepEqual(res.Numbers, []int{2, 3, 4}) {
+			t.Errorf("expected %v, got %v", []int{2, 3, 4}, res.Numbers)
 		}
 
-		_, err := coll.Insert(ctx, newTestEntity("1"))
+		single, err := mongox.PushTo("numbers").Value(5).Build()
 		if err != nil {
 			t.Error(err)
 		}
+		if !reflect.DeepEqual(single, mongox.M{mongox.Push: mongox.M{"numbers": 5}}) {
+			t.Errorf("unexpected fragment %v", single)
+		}
 
-		err = coll.CreateIndex(ctx, true)
+		_, err = mongox.PushTo("numbers").Value(5).Position(0).Build()
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
 			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-
-		err = coll.CreateTextIndex(ctx, "")
+		_, err = mongox.PushTo("").Each(1).Build()
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
 			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
+	})
 
-		err = coll.FindOne(ctx, nil, nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+	t.Run("UpdateFragments", func(t *testing.T) {
+		coll := db.Collection(updateCollection + "_fragments")
+		entity := newTestEntity("1")
+		entity.Number = 10
+
+		_, err := coll.Insert(ctx, entity)
+		if err != nil {
+			t.Error(err)
 		}
 
-		err = coll.Find(ctx, nil, nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		upd := mongox.Update(
+			mongox.SetField("name", "fragment-name"),
+			mongox.SetField("bool", false),
+			mongox.MaxField("number", 20),
+			mongox.CurrentDateField("time"),
+		)
+		if len(upd) != 3 {
+			t.Errorf("expected 3 operators, got %d", len(upd))
+		}
+		if set, ok := upd[mongox.Set].(mongox.M); !ok || len(set) != 2 {
+			t.Errorf("expected 2 grouped $set fields, got %v", upd[mongox.Set])
 		}
 
-		err = coll.FindAll(ctx, nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		_, err = coll.UpdateOne(ctx, mongox.M{"id": "1"}, upd)
+		if err != nil {
+			t.Error(err)
 		}
 
-		// No error
-		_, err = coll.Count(ctx, nil)
+		res, err := mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "1"})
 		if err != nil {
 			t.Error(err)
 		}
+		if res.Name != "fragment-name" || res.Bool || res.Number != 20 {
+			t.Errorf("unexpected result %+v", res)
+		}
+		if !res.Time.After(entity.Time) {
+			t.Errorf("expected current date, got %v", res.Time)
+		}
+	})
 
-		err = coll.Distinct(ctx, nil, "", nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+	t.Run("CurrentTimestampField", func(t *testing.T) {
+		coll := db.Collection(updateCollection + "_timestamp")
+		_, err := coll.Insert(ctx, newTestEntity("1"))
+		if err != nil {
+			t.Error(err)
 		}
 
-		// No error
-		err = coll.Distinct(ctx, nil, "id", nil)
+		_, err = coll.UpdateOne(ctx, mongox.M{"id": "1"}, mongox.Update(
+			mongox.CurrentTimestampField("ts"),
+			mongox.CurrentDateTimeField("date"),
+		))
 		if err != nil {
 			t.Error(err)
 		}
 
-		_, err = coll.Insert(ctx, nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		var res struct {
+			TS   bson.Timestamp `bson:"ts"`
+			Date time.Time      `bson:"date"`
+		}
+		if err := coll.FindOne(ctx, &res, mongox.M{"id": "1"}); err != nil {
+			t.Error(err)
+		}
+		if res.TS.T == 0 {
+			t.Errorf("expected timestamp, got %v", res.TS)
 		}
+		if res.Date.IsZero() {
+			t.Errorf("expected date, got %v", res.Date)
+		}
+	})
 
-		_, err = coll.Insert(ctx, []any{newTestEntity("2"), nil})
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+	t.Run("DiffToUpdate", func(t *testing.T) {
+		diff := struct {
+			Name   *string `bson:"name"`
+			Number *int    `bson:"number"`
+			Struct *struct {
+				Name *string `bson:"name"`
+			} `bson:"struct"`
+		}{
+			Name: lang.Ptr("diff-name"),
+			Struct: &struct {
+				Name *string `bson:"name"`
+			}{
+				Name: lang.Ptr("diff-struct-name"),
+			},
 		}
 
-		_, err = coll.Upsert(ctx, nil, nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		upd, err := mongox.DiffToUpdate(&diff)
+		if err != nil {
+			t.Error(err)
+		}
+		expected := mongox.M{mongox.Set: mongox.M{"name": "diff-name", "struct.name": "diff-struct-name"}}
+		if !reflect.DeepEqual(upd, expected) {
+			t.Errorf("expected %v, got %v", expected, upd)
 		}
 
-		err = coll.ReplaceOne(ctx, nil, nil)
+		_, err = mongox.DiffToUpdate(struct{ Name *string }{})
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
 			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-
-		err = coll.SetFields(ctx, nil, nil)
+		_, err = mongox.DiffToUpdate("not a struct")
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
 			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
+	})
 
-		err = coll.UpdateOne(ctx, nil, nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+	t.Run("DiffToUpdate_IncludeZeroValues", func(t *testing.T) {
+		type plainStruct struct {
+			Name string `bson:"name"`
+		}
+		plain := struct {
+			Name   string      `bson:"name,omitempty"`
+			Number int         `bson:"number,omitempty"`
+			Struct plainStruct `bson:"struct"`
+			Slice  []int       `bson:"slice"`
+		}{
+			Name:   "plain-name",
+			Struct: plainStruct{Name: "plain-struct-name"},
 		}
 
-		_, err = coll.UpdateMany(ctx, nil, nil)
+		_, err := mongox.DiffToUpdate(plain)
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
 			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
 
-		err = coll.UpdateOneFromDiff(ctx, nil, nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		upd, err := mongox.DiffToUpdate(plain, mongox.DiffOptions{IncludeZeroValues: true})
+		if err != nil {
+			t.Error(err)
+		}
+		expected := mongox.M{mongox.Set: mongox.M{"name": "plain-name", "number": 0, "struct.name": "plain-struct-name"}}
+		if !reflect.DeepEqual(upd, expected) {
+			t.Errorf("expected %v, got %v", expected, upd)
 		}
 
-		err = coll.DeleteFields(ctx, nil)
+		// Interface fields are skipped by default, nil interfaces are skipped with zero values
+		name := "pointer-name"
+		withAny := struct {
+			Name   *string `bson:"name"`
+			Any    any     `bson:"any"`
+			NilAny any     `bson:"nil_any"`
+		}{Name: &name, Any: "value"}
+
+		upd, err = mongox.DiffToUpdate(withAny)
 		if err != nil {
 			t.Error(err)
 		}
+		expected = mongox.M{mongox.Set: mongox.M{"name": "pointer-name"}}
+		if !reflect.DeepEqual(upd, expected) {
+			t.Errorf("expected %v, got %v", expected, upd)
+		}
 
-		// No error
-		err = coll.DeleteOne(ctx, nil)
+		upd, err = mongox.DiffToUpdate(withAny, mongox.DiffOptions{IncludeZeroValues: true})
 		if err != nil {
 			t.Error(err)
 		}
+		expected = mongox.M{mongox.Set: mongox.M{"name": "pointer-name", "any": "value"}}
+		if !reflect.DeepEqual(upd, expected) {
+			t.Errorf("expected %v, got %v", expected, upd)
+		}
+	})
 
-		err = coll.DeleteOne(ctx, nil)
-		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+	t.Run("IncMulFields", func(t *testing.T) {
+		var (
+			coll   = db.Collection(updateCollection + "_inc")
+			entity = newTestEntity("inc")
+			f      = mongox.M{"id": "inc"}
+		)
+		entity.Number = 10
+
+		_, err := coll.Insert(ctx, entity)
+		if err != nil {
+			t.Fatal(err)
 		}
 
-		_, err = coll.Insert(ctx, newTestEntity("1"))
+		err = coll.IncFields(ctx, f, map[string]int64{"number": 5, "struct.number": 1})
 		if err != nil {
 			t.Error(err)
 		}
-
-		// No error
-		_, err = coll.DeleteMany(ctx, nil)
+		err = mongox.MulFields(ctx, coll, f, map[string]int64{"number": 3})
 		if err != nil {
 			t.Error(err)
 		}
 
-		_, err = coll.DeleteMany(ctx, nil)
-		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		res, err := mongox.FindOne[testEntity](ctx, coll, f)
+		if err != nil {
+			t.Error(err)
 		}
-
-		_, err = coll.BulkWrite(ctx, nil, false)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		if res.Number != 45 {
+			t.Errorf("expected %v, got %v", 45, res.Number)
+		}
+		if res.Struct.Number != entity.Struct.Number+1 {
+			t.Errorf("expected %v, got %v", entity.Struct.Number+1, res.Struct.Number)
 		}
 
-		_, err = coll.BulkWrite(ctx, []mongo.WriteModel{mongo.NewDeleteManyModel(), nil}, false)
+		err = coll.IncFields(ctx, f, nil)
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
 			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
+		err = coll.MulFields(ctx, mongox.M{"id": "not-found"}, map[string]int64{"number": 2})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
 	})
 
-	t.Run("Error_InvalidArguments", func(t *testing.T) {
-		coll := db.Collection(errorInvalidArgCollection)
+	t.Run("AddToSetEach", func(t *testing.T) {
+		var (
+			coll   = db.Collection(updateCollection + "_add_to_set")
+			entity = newTestEntity("set")
+			f      = mongox.M{"id": "set"}
+		)
+		entity.Slice = []int{1, 2}
 
-		_, err := coll.Insert(ctx, newTestEntity("1"))
+		_, err := coll.Insert(ctx, entity)
 		if err != nil {
-			t.Error(err)
+			t.Fatal(err)
 		}
 
-		// Unexpected field, but it is ok for Mongo
-		err = coll.CreateIndex(ctx, true, "dafasdas()++*88*;;ыупаыва")
+		err = coll.AddToSetEach(ctx, f, "slice", []any{2, 3, 4, 3})
 		if err != nil {
 			t.Error(err)
 		}
-
-		err = coll.CreateTextIndex(ctx, "fasdas", "dafasdas()++*88*;;ыупаыва")
-		if !errors.Is(err, mongox.ErrUnsupportedLanguage) {
-			t.Errorf("expected error %v, got %v", mongox.ErrUnsupportedLanguage, err)
+		err = mongox.AddToSetEach(ctx, coll, f, "slice", nil)
+		if err != nil {
+			t.Error(err)
 		}
 
-		// Unexpected field, but it is ok for Mongo
-		err = coll.CreateTextIndex(ctx, "en", "dafasdas()++*88*;;ыупаыва")
+		res, err := mongox.FindOne[testEntity](ctx, coll, f)
 		if err != nil {
 			t.Error(err)
 		}
-
-		err = coll.FindOne(ctx, 1, nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		if !reflect.DeepEqual(res.Slice, []int{1, 2, 3, 4}) {
+			t.Errorf("expected %v, got %v", []int{1, 2, 3, 4}, res.Slice)
 		}
 
-		err = coll.Find(ctx, 1, nil)
+		err = coll.AddToSetEach(ctx, f, "", []any{1})
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
 			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-
-		err = coll.FindAll(ctx, 1)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		err = coll.AddToSetEach(ctx, mongox.M{"id": "not-found"}, "slice", []any{1})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
 		}
+	})
 
-		err = coll.Distinct(ctx, nil, "", nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
-		}
+	t.Run("UpsertUpdate", func(t *testing.T) {
+		coll := db.Collection(updateCollection + "_upsert_update")
 
-		// No error
-		err = coll.Distinct(ctx, 1, "id", nil)
+		res, err := mongox.UpsertWithDefaults(ctx, coll, mongox.M{"id": "1"},
+			mongox.M{mongox.Inc: mongox.M{"number": 1}},
+			mongox.M{"name": "default"},
+		)
 		if err != nil {
 			t.Error(err)
 		}
-
-		_, err = coll.Insert(ctx, 1)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		if !res.Inserted || res.ID == nil {
+			t.Errorf("expected inserted document, got %+v", res)
 		}
 
-		_, err = coll.Insert(ctx, []any{newTestEntity("2"), 1})
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		res, err = coll.UpsertWithDefaults(ctx, mongox.M{"id": "1"},
+			mongox.M{mongox.Inc: mongox.M{"number": 1}},
+			mongox.M{"name": "other"},
+		)
+		if err != nil {
+			t.Error(err)
 		}
-
-		_, err = coll.Upsert(ctx, 1, nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		if res.Inserted || res.ID != nil {
+			t.Errorf("expected updated document, got %+v", res)
 		}
 
-		err = coll.ReplaceOne(ctx, 1, nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		res, err = mongox.UpsertUpdate(ctx, coll, mongox.M{"id": "1"}, mongox.M{mongox.Inc: mongox.M{"number": 1}})
+		if err != nil {
+			t.Error(err)
 		}
-
-		var result testEntity
-		err = coll.FindOne(ctx, result, nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		if res.Inserted {
+			t.Errorf("expected updated document, got %+v", res)
 		}
 
-		var results []testEntity
-		err = coll.Find(ctx, results, nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		entity, err := mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "1"})
+		if err != nil {
+			t.Error(err)
 		}
-
-		err = coll.FindAll(ctx, results)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		if entity.Number != 3 || entity.Name != "default" {
+			t.Errorf("expected number 3 and name default, got %d and %s", entity.Number, entity.Name)
 		}
 
-		var distinct2 []testEntity
-		err = coll.Distinct(ctx, &distinct2, "id", nil)
+		_, err = coll.UpsertWithDefaults(ctx, mongox.M{"id": "1"},
+			mongox.M{mongox.Set: mongox.M{"name": "new"}},
+			mongox.M{"name": "default"},
+		)
+		if !errors.Is(err, mongox.ErrConflictingUpdateOperators) {
+			t.Errorf("expected error %v, got %v", mongox.ErrConflictingUpdateOperators, err)
+		}
+		_, err = coll.UpsertUpdate(ctx, mongox.M{"id": "1"}, mongox.M{"name": "no-operator"})
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
 			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
+	})
 
-		err = coll.Find(ctx, &results, nil, mongox.FindOptions{
-			Limit: -100,
-		})
+	t.Run("UpsertUpdate_Changed", func(t *testing.T) {
+		coll := db.Collection(updateCollection + "_upsert_changed")
+		filter := mongox.M{"id": "1"}
+
+		res, err := coll.UpsertUpdate(ctx, filter, mongox.M{mongox.Set: mongox.M{"name": "first"}})
 		if err != nil {
 			t.Error(err)
 		}
+		if !res.Inserted || !res.Changed {
+			t.Errorf("expected inserted and changed, got %+v", res)
+		}
 
-		err = coll.Find(ctx, &results, nil, mongox.FindOptions{
-			Skip: -100,
-		})
+		res, err = coll.UpsertUpdate(ctx, filter, mongox.M{mongox.Set: mongox.M{"name": "second"}})
 		if err != nil {
 			t.Error(err)
 		}
-
-		err = coll.Find(ctx, &results, nil, mongox.FindOptions{
-			Sort:                mongox.M{"fasdfdsafse": "asdasdea"},
-			AllowPartialResults: true,
-			AllowDiskUse:        true,
-		})
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
-		}
-
-		err = coll.Find(ctx, &results, nil, mongox.FindOptions{
-			Sort: mongox.M{"fasdfdsafse": mongox.M{"aaa": "bbb"}},
-		})
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		if res.Inserted || !res.Changed {
+			t.Errorf("expected modified, got %+v", res)
 		}
 
-		err = coll.FindOne(ctx, &result, nil, mongox.FindOptions{
-			Limit:               -100,
-			Skip:                -100,
-			Sort:                mongox.M{"fasdfdsafse": "asdasdea"},
-			AllowPartialResults: true,
-			AllowDiskUse:        true,
-		})
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		// The same values don't modify the document
+		res, err = mongox.UpsertUpdate(ctx, coll, filter, mongox.M{mongox.Set: mongox.M{"name": "second"}})
+		if err != nil {
+			t.Error(err)
 		}
-
-		_, err = coll.BulkWrite(ctx, []mongo.WriteModel{mongo.NewDeleteManyModel()}, false)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		if res.Inserted || res.Changed {
+			t.Errorf("expected unchanged, got %+v", res)
 		}
 	})
 
-	t.Run("Error_InvalidFilter", func(t *testing.T) {
-		coll := db.Collection(errorInvalidFilterCollection)
+	t.Run("UpdateOne_Upsert", func(t *testing.T) {
+		coll := db.Collection(updateCollection + "_update_upsert")
+		filter := mongox.M{"id": "1"}
 
-		_, err := coll.Insert(ctx, newTestEntity("1"))
-		if err != nil {
-			t.Error(err)
+		_, err := coll.UpdateOne(ctx, filter, mongox.M{mongox.Inc: mongox.M{"number": 1}})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
 		}
 
-		var (
-			result  testEntity
-			results []testEntity
-			entity  = newTestEntity("1")
-			upd     = mongox.M{mongox.Set: mongox.M{"id": "2"}}
-
-			f = mongox.M{"aesdfsdf": lang.Ptr([]any{map[string]testEntity{"1": entity}, "()()()()fs`dvsrfvпцфкуапму<>>>>>>]]"})}
-		)
-
-		if err = coll.FindOne(ctx, &result, f); !errors.Is(err, mongox.ErrNotFound) {
-			t.Error(err)
-		}
-		if err = coll.Find(ctx, &results, f); err != nil {
-			t.Error(err)
-		}
-		if _, err = coll.Count(ctx, f); err != nil {
+		upsert := mongox.UpdateOptions{Upsert: true}
+		id, err := coll.UpdateOne(ctx, filter, mongox.M{mongox.Inc: mongox.M{"number": 1}}, upsert)
+		if err != nil {
 			t.Error(err)
 		}
-		if err = coll.Distinct(ctx, nil, "f", f); err != nil {
-			t.Error(err)
+		if id == nil {
+			t.Error("expected inserted id")
 		}
-		if err = coll.ReplaceOne(ctx, entity, f); !errors.Is(err, mongox.ErrNotFound) {
+		id, err = coll.UpdateOne(ctx, filter, mongox.M{mongox.Inc: mongox.M{"number": 1}}, upsert)
+		if err != nil {
 			t.Error(err)
 		}
-		if err = coll.SetFields(ctx, f, upd); !errors.Is(err, mongox.ErrNotFound) {
-			t.Error(err)
+		if id != nil {
+			t.Errorf("expected nil id for updated document, got %v", id)
 		}
-		if err = coll.UpdateOne(ctx, f, upd); !errors.Is(err, mongox.ErrNotFound) {
+		id, err = mongox.SetFields(ctx, coll, mongox.M{"id": "2"}, mongox.M{"name": "set"}, upsert)
+		if err != nil {
 			t.Error(err)
 		}
-		if _, err = coll.UpdateMany(ctx, f, upd); !errors.Is(err, mongox.ErrNotFound) {
-			t.Error(err)
+		if id == nil {
+			t.Error("expected inserted id")
 		}
-		if err = mongox.DeleteFields(ctx, coll, f); !errors.Is(err, mongox.ErrNotFound) {
+
+		var first testEntity
+		if err := coll.FindOne(ctx, &first, filter); err != nil {
 			t.Error(err)
 		}
-		if err = mongox.DeleteOne(ctx, coll, f); !errors.Is(err, mongox.ErrNotFound) {
-			t.Error(err)
+		if first.Number != 2 {
+			t.Errorf("expected %v, got %v", 2, first.Number)
 		}
-		if _, err = mongox.DeleteMany(ctx, coll, f); !errors.Is(err, mongox.ErrNotFound) {
+		var second testEntity
+		if err := coll.FindOne(ctx, &second, mongox.M{"_id": id}); err != nil {
 			t.Error(err)
 		}
-		if _, err = coll.Upsert(ctx, entity, f); err != nil {
-			t.Error("expected no error, got", err)
+		if second.ID != "2" || second.Name != "set" {
+			t.Errorf("expected %v, got %v", "set", second.Name)
 		}
 	})
 
-	t.Run("Error_InvalidUpdate", func(t *testing.T) {
-		coll := db.Collection(errorInvalidUpdCollection)
-
-		_, err := coll.Insert(ctx, newTestEntity("1"))
+	t.Run("RenameField", func(t *testing.T) {
+		coll := db.Collection(updateCollection + "_rename_field")
+		_, err := coll.Insert(ctx, newTestEntity("1"), newTestEntity("2"), mongox.M{"id": "3"})
 		if err != nil {
 			t.Error(err)
 		}
 
-		var (
-			f = mongox.M{"id": "1"}
-		)
-
-		err = coll.SetFields(ctx, f, mongox.NewM("()()()()fs`dvsrfvпцфкуапму<>>>>>>]]", lang.Ptr([]any{map[string]testEntity{"1": newTestEntity("2")}})))
+		n, err := coll.RenameField(ctx, mongox.M{}, "number", "count")
 		if err != nil {
 			t.Error(err)
 		}
-
-		err = coll.SetFields(ctx, f, mongox.M{"number": mongox.CurrentDate})
+		if n != 2 {
+			t.Errorf("expected %v, got %v", 2, n)
+		}
+		count, err := coll.Count(ctx, mongox.M{"count": mongox.M{mongox.Exists: true}})
 		if err != nil {
 			t.Error(err)
 		}
+		if count != 2 {
+			t.Errorf("expected %v, got %v", 2, count)
+		}
 
-		//
-
-		err = coll.UpdateOne(ctx, f, mongox.M{"a": "b"})
+		_, err = mongox.RenameField(ctx, coll, mongox.M{"id": "1"}, "number", "count")
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
+		_, err = coll.RenameField(ctx, mongox.M{}, "count", "")
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
 			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-
-		err = coll.UpdateOne(ctx, f, mongox.M{"id": mongox.CurrentDate})
+		_, err = coll.RenameField(ctx, mongox.M{}, "count", "_id")
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
 			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
+	})
 
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Inc: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+	t.Run("SetFieldOnce", func(t *testing.T) {
+		coll := db.Collection(updateCollection + "_set_once")
+		_, err := coll.Insert(ctx, newTestEntity("1"))
+		if err != nil {
+			t.Error(err)
 		}
 
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Min: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		set, err := coll.SetFieldOnce(ctx, mongox.M{"id": "1"}, "created_by", "first")
+		if err != nil {
+			t.Error(err)
 		}
-
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Mul: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		if !set {
+			t.Error("expected field to be set")
 		}
-
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Rename: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		set, err = mongox.SetFieldOnce(ctx, coll, mongox.M{"id": "1"}, "created_by", "second")
+		if err != nil {
+			t.Error(err)
 		}
-
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Pop: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		if set {
+			t.Error("expected field not to be overwritten")
 		}
 
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Push: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		var res struct {
+			CreatedBy string `bson:"created_by"`
 		}
-
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.AddToSet: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		if err := coll.FindOne(ctx, &res, mongox.M{"id": "1"}); err != nil {
+			t.Error(err)
 		}
-
-		//
-
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Inc: mongox.M{"id": ""}})
-		if !errors.Is(err, mongox.ErrTypeMismatch) {
-			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		if res.CreatedBy != "first" {
+			t.Errorf("expected %v, got %v", "first", res.CreatedBy)
 		}
 
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Inc: mongox.M{"number": ""}})
-		if !errors.Is(err, mongox.ErrTypeMismatch) {
-			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		_, err = coll.SetFieldOnce(ctx, mongox.M{"id": "not-found"}, "created_by", "first")
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
 		}
-
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Inc: mongox.M{"number": "1"}})
-		if !errors.Is(err, mongox.ErrTypeMismatch) {
-			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		_, err = coll.SetFieldOnce(ctx, mongox.M{"id": "1"}, "", "first")
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
+	})
 
-		// No error
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Min: mongox.M{"number": ""}})
+	t.Run("UpdateMany_ExprFilter", func(t *testing.T) {
+		coll := db.Collection(updateCollection + "_expr")
+		_, err := coll.Insert(ctx,
+			mongox.M{"id": "1", "price": 120, "list_price": 100},
+			mongox.M{"id": "2", "price": 80, "list_price": 100},
+			mongox.M{"id": "3", "price": 150, "list_price": 90},
+		)
 		if err != nil {
-			t.Error(err)
-		}
-
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Mul: mongox.M{"number": ""}})
-		if !errors.Is(err, mongox.ErrTypeMismatch) {
-			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+			t.Fatal(err)
 		}
 
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Rename: mongox.M{"number": ""}})
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		filter := mongox.ExprFilter(mongox.M{mongox.Gt: []any{"$price", "$list_price"}})
+		n, err := coll.UpdateMany(ctx, filter, mongox.M{mongox.Set: mongox.M{"discounted": true}})
+		if err != nil {
+			t.Error(err)
 		}
-
-		err = mongox.UpdateOne(ctx, coll, f, mongox.M{mongox.Pop: mongox.M{"number": ""}})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		if n != 2 {
+			t.Errorf("expected %v, got %v", 2, n)
 		}
 
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.Push: mongox.M{"number": ""}})
-		if !errors.Is(err, mongox.ErrBadValue) {
-			t.Errorf("expected error %v, got %v", mongox.ErrBadValue, err)
+		var ids []string
+		if err := coll.Distinct(ctx, &ids, "id", mongox.M{"discounted": true}); err != nil {
+			t.Error(err)
 		}
-
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.AddToSet: mongox.M{"number": ""}})
-		if !errors.Is(err, mongox.ErrBadValue) {
-			t.Errorf("expected error %v, got %v", mongox.ErrBadValue, err)
+		sort.Strings(ids)
+		if !reflect.DeepEqual(ids, []string{"1", "3"}) {
+			t.Errorf("expected %v, got %v", []string{"1", "3"}, ids)
 		}
 
-		// No error
-		err = coll.UpdateOne(ctx, f, mongox.M{mongox.AddToSet: mongox.M{"slice": newTestEntity("1")}})
+		// Order of arguments matters: the reversed comparison matches the other document
+		n, err = coll.UpdateMany(ctx, mongox.ExprFilter(mongox.M{mongox.Gt: []any{"$list_price", "$price"}}),
+			mongox.M{mongox.Set: mongox.M{"discounted": false}})
 		if err != nil {
 			t.Error(err)
 		}
-
-		//
-
-		_, err = coll.UpdateMany(ctx, f, mongox.M{"a": "b"})
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
-		}
-
-		_, err = coll.UpdateMany(ctx, f, mongox.M{"a": "b"})
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		if n != 1 {
+			t.Errorf("expected %v, got %v", 1, n)
 		}
+	})
 
-		_, err = coll.UpdateMany(ctx, f, mongox.M{"id": mongox.CurrentDate})
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+	t.Run("WriteOptions_MaxTime", func(t *testing.T) {
+		coll := db.Collection(updateCollection + "_max_time")
+		_, err := coll.Insert(ctx, newTestEntity("1"), newTestEntity("2"), newTestEntity("3"))
+		if err != nil {
+			t.Fatal(err)
 		}
+		slowFilter := mongox.M{"$where": "function() { sleep(100); return true; }"}
 
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Inc: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		_, err = coll.UpdateMany(ctx, slowFilter, mongox.M{mongox.Set: mongox.M{"name": "slow"}}, mongox.WriteOptions{
+			MaxTime: 50 * time.Millisecond,
+		})
+		if !errors.Is(err, mongox.ErrMaxTimeMSExpired) {
+			t.Errorf("expected error %v, got %v", mongox.ErrMaxTimeMSExpired, err)
 		}
-
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Min: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		_, err = coll.DeleteMany(ctx, slowFilter, mongox.WriteOptions{MaxTime: 50 * time.Millisecond})
+		if !errors.Is(err, mongox.ErrMaxTimeMSExpired) {
+			t.Errorf("expected error %v, got %v", mongox.ErrMaxTimeMSExpired, err)
 		}
 
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Mul: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		n, err := coll.UpdateMany(ctx, mongox.M{"id": "1"}, mongox.M{mongox.Set: mongox.M{"name": "fast"}}, mongox.WriteOptions{
+			MaxTime: time.Minute,
+		})
+		if err != nil {
+			t.Error(err)
 		}
-
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Rename: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		if n != 1 {
+			t.Errorf("expected %v, got %v", 1, n)
 		}
-
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Pop: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		n, err = coll.DeleteMany(ctx, mongox.M{"id": "1"}, mongox.WriteOptions{MaxTime: time.Minute})
+		if err != nil {
+			t.Error(err)
 		}
-
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Push: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		if n != 1 {
+			t.Errorf("expected %v, got %v", 1, n)
 		}
+	})
 
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.AddToSet: "id"})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+	t.Run("PatchFields", func(t *testing.T) {
+		coll := db.Collection(updateCollection + "_patch")
+		_, err := coll.Insert(ctx, newTestEntity("1"))
+		if err != nil {
+			t.Error(err)
 		}
 
-		//
-
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Inc: mongox.M{"id": ""}})
-		if !errors.Is(err, mongox.ErrTypeMismatch) {
-			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		err = coll.PatchFields(ctx, mongox.M{"id": "1"}, mongox.M{"name": "patched"}, "slice", "struct.name")
+		if err != nil {
+			t.Error(err)
 		}
-
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Inc: mongox.M{"number": ""}})
-		if !errors.Is(err, mongox.ErrTypeMismatch) {
-			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		var res testEntity
+		if err := coll.FindOne(ctx, &res, mongox.M{"id": "1"}); err != nil {
+			t.Error(err)
 		}
-
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Inc: mongox.M{"number": "1"}})
-		if !errors.Is(err, mongox.ErrTypeMismatch) {
-			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		if res.Name != "patched" || len(res.Slice) != 0 || res.Struct.Name != "" {
+			t.Errorf("expected patched entity, got %v", res)
 		}
 
-		// No error
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Min: mongox.M{"number": ""}})
+		err = mongox.PatchFields(ctx, coll, mongox.M{"id": "1"}, nil, "name")
 		if err != nil {
 			t.Error(err)
 		}
-
-		_, err = mongox.UpdateMany(ctx, coll, f, mongox.M{mongox.Mul: mongox.M{"number": ""}})
-		if !errors.Is(err, mongox.ErrTypeMismatch) {
-			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		var unset testEntity
+		if err := coll.FindOne(ctx, &unset, mongox.M{"id": "1"}); err != nil {
+			t.Error(err)
+		}
+		if unset.Name != "" {
+			t.Errorf("expected empty name, got %v", unset.Name)
 		}
 
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Rename: mongox.M{"number": ""}})
+		err = coll.PatchFields(ctx, mongox.M{"id": "1"}, mongox.M{"name": "x"}, "name")
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
 			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Pop: mongox.M{"number": ""}})
-		if !errors.Is(err, mongox.ErrFailedToParse) {
-			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		err = coll.PatchFields(ctx, mongox.M{"id": "1"}, mongox.M{"struct.name": "x"}, "struct")
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Push: mongox.M{"number": ""}})
-		if !errors.Is(err, mongox.ErrBadValue) {
-			t.Errorf("expected error %v, got %v", mongox.ErrBadValue, err)
+		err = coll.PatchFields(ctx, mongox.M{"id": "1"}, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.AddToSet: mongox.M{"number": ""}})
-		if !errors.Is(err, mongox.ErrBadValue) {
-			t.Errorf("expected error %v, got %v", mongox.ErrBadValue, err)
+		err = coll.PatchFields(ctx, mongox.M{"id": "not-found"}, mongox.M{"name": "x"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
 		}
+	})
+}
 
-		// No error
-		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.AddToSet: mongox.M{"slice": newTestEntity("1")}})
-		if err != nil {
-			t.Error(err)
-		}
+func TestBulk(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-		//
+	db := client.Database(dbName)
 
-		err = coll.UpdateOneFromDiff(ctx, f, mongox.M{"a": "b"})
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+	t.Run("BulkSuccess", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			var isOrdered bool
+			coll := db.Collection(bulkCollection)
+			if i == 1 {
+				coll = db.Collection(bulkCollection + "1")
+				isOrdered = true
+			}
+
+			bulker := mongox.NewBulkBuilder()
+			for i := 0; i < 10; i++ {
+				bulker.Insert(newTestEntity(fmt.Sprintf("%d", i+1)))
+			}
+			bulker.Upsert(newTestEntity("100"), mongox.M{"id": "11"})
+			bulker.ReplaceOne(newTestEntity("200"), mongox.M{"id": "2"})
+			bulker.SetFields(mongox.M{"id": "1"}, mongox.M{"name": "new-name"})
+			bulker.UpdateOne(mongox.M{"id": "1"}, mongox.M{"$set": mongox.M{"number": 228}})
+			bulker.UpdateMany(mongox.M{"id": mongox.M{mongox.Gte: "10"}}, mongox.M{"$set": mongox.M{"number": 322}})
+			bulker.UpdateOneFromDiff(mongox.M{"id": "10"}, struct {
+				Name *string `bson:"name"`
+			}{
+				Name: lang.Ptr("new-name-2"),
+			})
+			bulker.DeleteFields(mongox.M{"id": "10"}, "struct.name")
+			bulker.DeleteOne(mongox.M{"id": "3"})
+			bulker.DeleteMany(mongox.M{"id": mongox.M{mongox.In: []any{"4", "5"}}})
+
+			_, err := mongox.BulkWrite(ctx, coll, bulker.Models(), isOrdered)
+			if err != nil {
+				t.Error(err)
+			}
+
+			n, err := coll.Count(ctx, nil)
+			if err != nil {
+				t.Error(err)
+			}
+			if n != 8 {
+				t.Errorf("expected %d, got %d", 8, n)
+			}
+
+			entity, err := mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "1"})
+			if err != nil {
+				t.Error(err)
+			}
+			if entity.Name != "new-name" {
+				t.Errorf("expected %s, got %s", "new-name", entity.Name)
+			}
+			if entity.Number != 228 {
+				t.Errorf("expected %d, got %d", 228, entity.Number)
+			}
+			entity10, err := mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "10"})
+			if err != nil {
+				t.Error(err)
+			}
+			if entity10.Name != "new-name-2" {
+				t.Errorf("expected %s, got %s", "new-name-2", entity10.Name)
+			}
+			if entity10.Number != 322 {
+				t.Errorf("expected %d, got %d", 322, entity10.Number)
+			}
+			if entity10.Struct.Name != "" {
+				t.Errorf("expected %s, got %s", "", entity10.Struct.Name)
+			}
+
+			testBulk(t, ctx, db, newTestEntity("1"), mongox.M{"id": "2"}, mongox.ErrNotFound)
+			testBulk(t, ctx, db, newTestEntity("1"), mongox.M{"id": "3"}, mongox.ErrNotFound)
+			testBulk(t, ctx, db, newTestEntity("1"), mongox.M{"id": "4"}, mongox.ErrNotFound)
+			testBulk(t, ctx, db, newTestEntity("1"), mongox.M{"id": "5"}, mongox.ErrNotFound)
 		}
+	})
 
-		err = coll.UpdateOneFromDiff(ctx, f, newTestEntity("1"))
+	t.Run("BulkUpsertFromDiff", func(t *testing.T) {
+		coll := db.Collection(bulkCollection + "_upsert_diff")
+		_, err := coll.Insert(ctx, newTestEntity("1"))
 		if err != nil {
 			t.Error(err)
 		}
-	})
 
-	t.Run("Error_InvalidState", func(t *testing.T) {
-		coll := db.Collection(errorInvalidStateCollection)
+		type diff struct {
+			Name *string `bson:"name"`
+		}
 
-		err := coll.CreateIndex(ctx, true, "id", "id", "id")
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		bulker := mongox.NewBulkBuilder()
+		if err := bulker.UpsertFromDiff(mongox.M{"id": "1"}, diff{Name: lang.Ptr("updated")}); err != nil {
+			t.Error(err)
 		}
-		err = coll.CreateIndex(ctx, false, "id", "id", "id")
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
+		if err := bulker.UpsertFromDiff(mongox.M{"id": "2"}, diff{Name: lang.Ptr("inserted")}); err != nil {
+			t.Error(err)
+		}
+		if err := bulker.UpsertFromDiff(mongox.M{"id": "3"}, diff{}); !errors.Is(err, mongox.ErrInvalidArgument) {
 			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-		err = coll.CreateIndex(ctx, true, "id")
+
+		res, err := coll.BulkWrite(ctx, bulker.Models(), true)
 		if err != nil {
 			t.Error(err)
 		}
-		err = coll.CreateIndex(ctx, false, "id")
-		if err != nil {
-			t.Error(err)
+		if res.ModifiedCount != 1 || res.UpsertedCount != 1 {
+			t.Errorf("expected 1 modified and 1 upserted, got %d and %d", res.ModifiedCount, res.UpsertedCount)
 		}
-		err = coll.CreateIndex(ctx, true, "id")
+		if len(res.UpsertedIDs) != 1 || res.Raw == nil || len(res.Raw.UpsertedIDs) != 1 {
+			t.Errorf("expected 1 upserted id, got %v", res.UpsertedIDs)
+		}
+
+		inserted, err := mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "2"})
 		if err != nil {
 			t.Error(err)
 		}
-		err = coll.CreateIndex(ctx, false, "id")
+		if inserted.Name != "inserted" {
+			t.Errorf("expected %s, got %s", "inserted", inserted.Name)
+		}
+	})
+
+	t.Run("BulkError", func(t *testing.T) {
+		coll := db.Collection(bulkCollection)
+		bulker := mongox.NewBulkBuilder()
+		bulker.DeleteOne(mongox.M{"id": "1"})
+
+		_, err := mongox.BulkWrite(ctx, coll, bulker.Models(), false)
 		if err != nil {
 			t.Error(err)
 		}
-		err = coll.CreateTextIndex(ctx, "en", "id", "id", "id")
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+
+		_, err = mongox.BulkWrite(ctx, coll, bulker.Models(), true)
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
 		}
-		err = coll.CreateTextIndex(ctx, "en", "id")
+
+		bulker.Insert(mongox.M{"id": "1"})
+
+		_, err = mongox.BulkWrite(ctx, coll, bulker.Models(), false)
 		if err != nil {
 			t.Error(err)
 		}
-		err = coll.CreateTextIndex(ctx, "en", "id")
+
+		_, err = mongox.BulkWrite(ctx, coll, bulker.Models(), true)
 		if err != nil {
 			t.Error(err)
 		}
-		_, err = coll.Insert(ctx, newTestEntity("1"))
+
+		bulker = mongox.NewBulkBuilder()
+		bulker.Insert(mongox.M{"id": "1"})
+		bulker.DeleteOne(mongox.M{"id": "2"})
+
+		_, err = mongox.BulkWrite(ctx, coll, bulker.Models(), false)
 		if err != nil {
 			t.Error(err)
 		}
-		_, err = coll.Insert(ctx, newTestEntity("1"))
-		if !errors.Is(err, mongox.ErrDuplicate) {
-			t.Errorf("expected error %v, got %v", mongox.ErrDuplicateKey, err)
+
+		_, err = mongox.BulkWrite(ctx, coll, bulker.Models(), true)
+		if err != nil {
+			t.Error(err)
 		}
 	})
-}
 
-func TestAsync(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	t.Run("BulkValidate", func(t *testing.T) {
+		coll := db.Collection("bulk_validate_test")
 
-	if err := client.Ping(ctx); err != nil {
-		t.Error(err)
-	}
+		bulker := mongox.NewBulkBuilder()
+		bulker.Insert(newTestEntity("1"))
+		bulker.UpdateOne(mongox.M{"id": "1"}, mongox.M{mongox.Set: mongox.M{"number": 1}})
+		if err := bulker.Validate(); err != nil {
+			t.Error(err)
+		}
 
-	db := client.Database(dbName)
-	asyncDB := client.AsyncDatabase(ctx, dbName, 0, slog.Default())
+		bulker.UpdateOne(mongox.M{"id": "1"}, nil)
+		err := bulker.Validate()
+		if !errors.Is(err, mongox.ErrInvalidArgument) || !strings.Contains(err.Error(), "model 2") {
+			t.Errorf("expected error %v for model 2, got %v", mongox.ErrInvalidArgument, err)
+		}
 
-	t.Run("Async", func(t *testing.T) {
-		coll := db.Collection(asyncCollection)
-		if coll.Name() != asyncCollection {
-			t.Errorf("expected %v, got %v", asyncCollection, coll.Name())
+		models := []mongo.WriteModel{
+			mongo.NewInsertOneModel().SetDocument(newTestEntity("1")),
+			mongo.NewInsertOneModel().SetDocument(newTestEntity("2")),
+			mongo.NewUpdateOneModel().SetFilter(mongox.M{"id": "1"}.Prepare()).SetUpdate(mongox.M{mongox.Set: mongox.M{"number": 1}}.Prepare()),
+			mongo.NewDeleteManyModel(),
 		}
-		asyncColl := asyncDB.AsyncCollection(asyncCollection)
-		queueColl := asyncColl.QueueCollection(asyncCollection)
-		if asyncColl.Name() != asyncCollection {
-			t.Errorf("expected %v, got %v", asyncCollection, asyncColl.Name())
+		_, err = coll.BulkWrite(ctx, models, false)
+		if !errors.Is(err, mongox.ErrInvalidArgument) || !strings.Contains(err.Error(), "model 3: DeleteMany requires a filter") {
+			t.Errorf("expected error %v for model 3, got %v", mongox.ErrInvalidArgument, err)
 		}
-		if queueColl.Name() != asyncCollection {
-			t.Errorf("expected %v, got %v", asyncCollection, queueColl.Name())
+		// Nothing is written, the error is returned before sending models to the server
+		count, err := coll.Count(ctx, nil)
+		if err != nil {
+			t.Error(err)
 		}
-		entity := newTestEntity("1")
-		entity2 := newTestEntity("2")
-		entity3 := newTestEntity("3")
-
-		// error
-		asyncDB.WithTransaction("", "", func(ctx context.Context) error {
-			return nil
-		})
-
-		// error
-		queueColl.Insert([]any{entity2, nil})
-
-		for i := 0; i < 3; i++ {
-			queueColl.Insert(entity)
+		if count != 0 {
+			t.Errorf("expected %d, got %d", 0, count)
 		}
 
-		bulk := mongox.NewBulkBuilder()
-		for i := 0; i < 3; i++ {
-			bulk.Insert(entity)
+		_, err = coll.BulkWrite(ctx, []mongo.WriteModel{mongo.NewReplaceOneModel().SetFilter(mongox.M{"id": "1"}.Prepare())}, false)
+		if !errors.Is(err, mongox.ErrInvalidArgument) || !strings.Contains(err.Error(), "model 0: ReplaceOne requires a replacement") {
+			t.Errorf("expected error %v for model 0, got %v", mongox.ErrInvalidArgument, err)
 		}
-		queueColl.BulkWrite(bulk.Models(), false)
 
-		queueColl.Upsert(entity2, mongox.M{"id": "2"})
-		queueColl.ReplaceOne(entity3, mongox.M{"id": "2"})
-		queueColl.SetFields(mongox.M{"id": "3"}, mongox.M{"name": "new-name"})
-		queueColl.UpdateOne(mongox.M{"id": "3"}, mongox.M{mongox.Inc: mongox.M{"number": 1}})
-		diff := struct {
-			Bool *bool `bson:"bool"`
-		}{
-			Bool: lang.Ptr(!entity3.Bool),
+		bulker = mongox.NewBulkBuilder()
+		bulker.UpdateOne(mongox.M{"id": "1"}, mongox.M{})
+		err = bulker.Validate()
+		if !errors.Is(err, mongox.ErrInvalidArgument) || !strings.Contains(err.Error(), "model 0: UpdateOne requires a non-empty update") {
+			t.Errorf("expected error %v for model 0, got %v", mongox.ErrInvalidArgument, err)
 		}
-		queueColl.UpdateOneFromDiff(mongox.M{"id": "3"}, diff)
-
-		queueColl.UpdateMany(mongox.M{"id": "1"}, mongox.M{mongox.Inc: mongox.M{"number": 1}})
-		queueColl.DeleteFields(mongox.M{"id": "3"}, "struct.name")
-		queueColl.DeleteOne(mongox.M{"number": entity.Number + 1})
-		queueColl.InsertMany([]any{entity, entity, entity})
-		queueColl.DeleteMany(mongox.M{"number": entity.Number})
 
-		var (
-			s  []int
-			mu sync.Mutex
-			wg sync.WaitGroup
-		)
+		// Empty replacement clears all fields except _id
+		if _, err := coll.Insert(ctx, newTestEntity("1")); err != nil {
+			t.Fatal(err)
+		}
+		_, err = coll.BulkWrite(ctx, []mongo.WriteModel{mongo.NewReplaceOneModel().SetFilter(mongox.M{"id": "1"}.Prepare()).SetReplacement(bson.D{})}, false)
+		if err != nil {
+			t.Error(err)
+		}
+		var replaced bson.M
+		if err := coll.FindOne(ctx, &replaced, nil); err != nil {
+			t.Error(err)
+		}
+		if _, ok := replaced["_id"]; !ok || len(replaced) != 1 {
+			t.Errorf("expected only _id, got %v", replaced)
+		}
+	})
 
-		wg.Add(3)
-		asyncDB.WithTask(asyncCollection, "", func(ctx context.Context) error {
-			defer wg.Done()
-			time.Sleep(500 * time.Millisecond)
-			mu.Lock()
-			defer mu.Unlock()
-			s = append(s, 1)
-			return nil
-		})
-		asyncDB.WithTask(asyncCollection, "", func(ctx context.Context) error {
-			defer wg.Done()
-			time.Sleep(100 * time.Millisecond)
-			mu.Lock()
-			defer mu.Unlock()
-			s = append(s, 2)
-			return nil
-		})
-		asyncDB.WithTask(asyncCollection, "", func(ctx context.Context) error {
-			defer wg.Done()
-			mu.Lock()
-			defer mu.Unlock()
-			s = append(s, 3)
-			return nil
-		})
+	t.Run("BulkUpdateManyUpsert", func(t *testing.T) {
+		coll := db.Collection("bulk_update_many_upsert_test")
+		_, err := coll.Insert(ctx, newTestEntity("1"), newTestEntity("1"))
+		if err != nil {
+			t.Error(err)
+		}
 
-		wg.Wait()
+		bulker := mongox.NewBulkBuilder()
+		bulker.UpdateManyUpsert(mongox.M{"id": "1"}, mongox.M{mongox.Set: mongox.M{"number": 1}})
+		bulker.UpdateManyUpsert(mongox.M{"id": "2"}, mongox.M{mongox.Set: mongox.M{"number": 2}})
+		bulker.UpdateMany(mongox.M{"id": "3"}, mongox.M{mongox.Set: mongox.M{"number": 3}})
 
-		if !reflect.DeepEqual(s, []int{1, 2, 3}) {
-			t.Errorf("expected %v, got %v", []int{1, 2, 3}, s)
+		res, err := coll.BulkWrite(ctx, bulker.Models(), true)
+		if err != nil {
+			t.Error(err)
+		}
+		if res.ModifiedCount != 2 || res.UpsertedCount != 1 {
+			t.Errorf("expected 2 modified and 1 upserted, got %d and %d", res.ModifiedCount, res.UpsertedCount)
 		}
 
-		n, err := coll.Count(ctx, mongox.M{"id": "1"})
+		upserted, err := mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "2"})
 		if err != nil {
 			t.Error(err)
 		}
-		if n != 5 {
-			t.Errorf("expected %v, got %v", 5, n)
+		if upserted.Number != 2 {
+			t.Errorf("expected %d, got %d", 2, upserted.Number)
 		}
-
-		entity.Number++
-		testAsync(t, ctx, db, entity, mongox.M{"id": "1"})
-		testAsync(t, ctx, db, entity2, mongox.M{"id": "2"}, mongox.ErrNotFound)
-
-		entity3.Name = "new-name"
-		entity3.Number++
-		entity3.Bool = !entity3.Bool
-		entity3.Struct.Name = ""
-		testAsync(t, ctx, db, entity3, mongox.M{"id": "3"})
-	})
-}
-
-func TestMain(m *testing.M) {
-	// Uses a sensible default on windows (tcp/http) and linux/osx (socket)
-	pool, err := dockertest.NewPool("")
-	if err != nil {
-		log.Fatalf("Could not connect to Docker: %s", err)
-	}
-
-	// Uses pool to try to connect to Docker
-	err = pool.Client.Ping()
-	if err != nil {
-		log.Fatalf("Could not connect to Docker: %s", err)
-	}
-
-	// Pulls an image, creates a container based on it and runs it
-	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
-		Repository: "mongo",
-		Tag:        "latest",
-		Env: []string{
-			// username and password for mongodb superuser
-			"MONGO_INITDB_ROOT_USERNAME=root",
-			"MONGO_INITDB_ROOT_PASSWORD=password",
-		},
-	}, func(config *docker.HostConfig) {
-		// set AutoRemove to true so that stopped container goes away by itself
-		config.AutoRemove = true
-		config.RestartPolicy = docker.RestartPolicy{
-			Name: "no",
+		count, err := coll.Count(ctx, mongox.M{"id": "3"})
+		if err != nil {
+			t.Error(err)
+		}
+		if count != 0 {
+			t.Errorf("expected %d, got %d", 0, count)
 		}
 	})
-	if err != nil {
-		log.Fatalf("Could not start resource: %s", err)
-	}
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
 
-	// exponential backoff-retry, because the application in the container might not be ready to accept connections yet
-	err = pool.Retry(func() error {
-		var err error
-		client, err = mongox.Connect(
-			ctx,
-			mongox.Config{
-				AppName: "mongox-test",
-				Hosts: []string{
-					"localhost:" + resource.GetPort("27017/tcp"),
-				},
-				Compressors: []string{
-					"snappy",
-				},
-				Connection: &mongox.ConnectionConfig{
-					ConnectTimeout:  lang.Ptr(10 * time.Second),
-					MaxConnIdleTime: lang.Ptr(10 * time.Second),
-					MaxConnecting:   lang.Ptr(uint64(10)),
-					MaxPoolSize:     lang.Ptr(uint64(10)),
-					MinPoolSize:     lang.Ptr(uint64(1)),
-					IsDirect:        true,
-				},
-				Auth: &mongox.AuthConfig{
-					Username:      "root",
-					Password:      "password",
-					AuthMechanism: "SCRAM-SHA-256",
-				},
-				BSONOptions: &mongox.BSONOptions{
-					ErrorOnInlineDuplicates: true, // test buildBSONOptions
-				},
-			},
-		)
+	t.Run("DryRun", func(t *testing.T) {
+		coll := db.Collection("bulk_dry_run_test")
+		_, err := coll.Insert(ctx, newTestEntity("1"), newTestEntity("2"), newTestEntity("3"))
 		if err != nil {
-			return err
+			t.Error(err)
 		}
-		return client.Ping(ctx)
-	})
-	if err != nil {
-		log.Fatalf("Could not connect to mongo container: %s", err)
-	}
+		dryRun := mongox.WriteOptions{DryRun: true}
 
-	defer func() {
-		// When you're done, kill and remove the container
-		if err = pool.Purge(resource); err != nil {
-			log.Fatalf("Could not purge resource: %s", err)
+		n, err := coll.UpdateMany(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"1", "2"}}}, mongox.M{mongox.Set: mongox.M{"number": 1}}, dryRun)
+		if err != nil {
+			t.Error(err)
 		}
-
-		// disconnect mongodb client
-		if err = client.Disconnect(ctx); err != nil {
-			panic(err)
+		if n != 2 {
+			t.Errorf("expected %d, got %d", 2, n)
+		}
+		n, err = mongox.DeleteMany(ctx, coll, mongox.M{}, dryRun)
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 3 {
+			t.Errorf("expected %d, got %d", 3, n)
+		}
+		_, err = coll.DeleteMany(ctx, mongox.M{"id": "not-found"}, dryRun)
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
 		}
-	}()
-
-	// run tests
-	m.Run()
-}
 
-func testFindOne(t *testing.T, ctx context.Context, db *mongox.Database, entity testEntity, filter mongox.M, err2 ...error) {
-	testOne(t, ctx, db.Collection(findOneCollection), entity, filter, err2...)
-}
+		bulker := mongox.NewBulkBuilder()
+		bulker.Insert(newTestEntity("4"))
+		bulker.UpdateMany(mongox.M{}, mongox.M{mongox.Set: mongox.M{"number": 1}})
+		bulker.Upsert(newTestEntity("5"), mongox.M{"id": "5"})
+		bulker.DeleteOne(mongox.M{"id": "1"})
 
-func testUpdate(t *testing.T, ctx context.Context, db *mongox.Database, entity testEntity, filter mongox.M, err2 ...error) {
-	testOne(t, ctx, db.Collection(updateCollection), entity, filter, err2...)
-}
+		res, err := coll.BulkWrite(ctx, bulker.Models(), true, dryRun)
+		if err != nil {
+			t.Error(err)
+		}
+		if res.InsertedCount != 1 || res.MatchedCount != 3 || res.UpsertedCount != 1 || res.DeletedCount != 1 {
+			t.Errorf("unexpected dry run result %+v", res)
+		}
 
-func testBulk(t *testing.T, ctx context.Context, db *mongox.Database, entity testEntity, filter mongox.M, err2 ...error) {
-	testOne(t, ctx, db.Collection(bulkCollection), entity, filter, err2...)
-}
+		count, err := coll.Count(ctx, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if count != 3 {
+			t.Errorf("expected %d, got %d", 3, count)
+		}
+	})
 
-func testAsync(t *testing.T, ctx context.Context, db *mongox.Database, entity testEntity, filter mongox.M, err2 ...error) {
-	testOne(t, ctx, db.Collection(asyncCollection), entity, filter, err2...)
-}
+	t.Run("BulkWriteTx", func(t *testing.T) {
+		coll := db.Collection(bulkCollection + "_tx")
 
-func testOne(t *testing.T, ctx context.Context, coll *mongox.Collection, entity testEntity, filter mongox.M, err2 ...error) {
-	var result testEntity
-	err := coll.FindOne(ctx, &result, filter)
-	if len(err2) > 0 {
-		if !errors.Is(err, err2[0]) {
-			t.Errorf("expected %v, got %v, query: %v", err2[0], err, filter)
+		bulker := mongox.NewBulkBuilder()
+		bulker.Insert(newTestEntity("tx1"))
+		_, err := coll.BulkWriteTx(ctx, bulker.Models(), true)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-		return
-	}
-	if err != nil {
-		t.Errorf("expected nil, got %v, query: %v", err, filter)
-		return
-	}
-	if !reflect.DeepEqual(entity, result) {
-		t.Errorf("expected %v, got %v, query: %v", entity, result, filter)
-	}
-}
 
-func testFind(t *testing.T, ctx context.Context, db *mongox.Database, entity []testEntity, filter mongox.M, err2 ...error) {
-	var result []testEntity
-	err := db.Collection(findCollection).Find(ctx, &result, filter)
-	if len(err2) > 0 {
-		if !errors.Is(err, err2[0]) {
-			t.Errorf("expected %v, got %v, query: %v", err2[0], err, filter)
+		isReplicaSet, err := db.IsReplicaSet(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isReplicaSet {
+			_, err = db.WithTransaction(ctx, func(sctx context.Context) (any, error) {
+				return coll.BulkWriteTx(sctx, bulker.Models(), true)
+			})
+			if !errors.Is(err, mongox.ErrTransactionsUnsupported) {
+				t.Errorf("expected error %v, got %v", mongox.ErrTransactionsUnsupported, err)
+			}
+			return
 		}
-		return
-	}
-	if err != nil {
-		t.Errorf("expected nil, got %v, query: %v", err, filter)
-		return
-	}
-	if len(result) != len(entity) {
-		t.Errorf("expected %d, got %d, query: %v", len(entity), len(result), filter)
-	}
-	if len(entity) == 0 {
-		return
-	}
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].ID < result[j].ID
-	})
-	sort.Slice(entity, func(i, j int) bool {
-		return entity[i].ID < entity[j].ID
-	})
-	if !reflect.DeepEqual(entity, result) {
-		t.Errorf("expected %v, got %v, query: %v", entity, &result, filter)
-	}
-}
-
-type testEntity struct {
-	ID           string         `bson:"id"`
-	Name         string         `bson:"name,omitempty"`
-	Number       int            `bson:"number,omitempty"`
-	Bool         bool           `bson:"bool,omitempty"`
-	Slice        []int          `bson:"slice,omitempty"`
-	Array        [3]int         `bson:"array,omitempty"`
-	Map          map[string]int `bson:"map,omitempty"`
-	Time         time.Time      `bson:"time,omitempty"`
-	Struct       innerStruct    `bson:"struct,omitempty"`
-	InlineStruct inlineStruct   `bson:"inline_struct,inline"`
-}
 
-type innerStruct struct {
-	Name   string `bson:"name"`
-	Number int    `bson:"number"`
-}
+		// Inserts and updates are committed together
+		bulker = mongox.NewBulkBuilder()
+		bulker.Insert(newTestEntity("tx1"), newTestEntity("tx2"))
+		bulker.SetFields(mongox.M{"id": "tx1"}, mongox.M{"name": "committed"})
+		_, err = db.WithTransaction(ctx, func(sctx context.Context) (any, error) {
+			return coll.BulkWriteTx(sctx, bulker.Models(), true)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		count, err := coll.Count(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"tx1", "tx2"}}})
+		if err != nil {
+			t.Error(err)
+		}
+		if count != 2 {
+			t.Errorf("expected %v, got %v", 2, count)
+		}
 
-type inlineStruct struct {
-	InlineField string `bson:"inline_field"`
-}
+		// Failed model rolls back all models, also in unordered mode
+		for _, isOrdered := range []bool{true, false} {
+			bulker = mongox.NewBulkBuilder()
+			bulker.Insert(newTestEntity("tx3"))
+			bulker.SetFields(mongox.M{"id": "tx1"}, mongox.M{"name": "rolled-back"})
+			bulker.UpdateOne(mongox.M{"id": "tx2"}, mongox.M{mongox.Inc: mongox.M{"name": 1}})
+			_, err = db.WithTransaction(ctx, func(sctx context.Context) (any, error) {
+				return coll.BulkWriteTx(sctx, bulker.Models(), isOrdered)
+			})
+			if err == nil {
+				t.Errorf("expected error, ordered %v", isOrdered)
+			}
 
-func newTestEntity(id string) testEntity {
-	randString := func() string {
-		b := make([]byte, 10)
-		for i := range b {
-			b[i] = byte(rand.Intn(26) + 'a')
+			var res testEntity
+			if err := coll.FindOne(ctx, &res, mongox.M{"id": "tx1"}); err != nil {
+				t.Error(err)
+			}
+			if res.Name != "committed" {
+				t.Errorf("expected %v, got %v", "committed", res.Name)
+			}
+			err = coll.FindOne(ctx, &testEntity{}, mongox.M{"id": "tx3"})
+			if !errors.Is(err, mongox.ErrNotFound) {
+				t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+			}
 		}
-		return string(b)
-	}
-	return testEntity{
-		ID:     id,
-		Name:   randString(),
-		Number: rand.Intn(math.MaxInt32) - 1,
-		Bool:   true,
-		Slice:  []int{rand.Intn(26), rand.Intn(26), rand.Intn(26)},
-		Array:  [3]int{rand.Intn(26), rand.Intn(26), rand.Intn(26)},
-		Map:    map[string]int{"1": rand.Intn(26), randString(): rand.Intn(26), randString(): rand.Intn(26)},
-		Time:   time.Date(2000, 1, rand.Intn(26), rand.Intn(20), rand.Intn(59), rand.Intn(59), 0, time.UTC),
-		Struct: innerStruct{Name: randString(), Number: rand.Intn(26)},
-	}
-}
+	})
 
-type testUpdateEntity struct {
-	name string
-}
+	t.Run("BulkWriteTracked", func(t *testing.T) {
+		coll := db.Collection("bulk_tracked_test")
+		if _, err := coll.Insert(ctx, newTestEntity("1"), mongox.M{"_id": "dup"}); err != nil {
+			t.Fatal(err)
+		}
 
-// func (t testUpdateEntity) MarshalBSONValue() (bson.Type, []byte, error) {
-// 	return bson.MarshalValue(t.name)
-// }
+		bulker := mongox.NewBulkBuilder()
+		bulker.Keyed("existing").Upsert(newTestEntity("1"), mongox.M{"id": "1"})
+		bulker.Keyed("new").Upsert(newTestEntity("2"), mongox.M{"id": "2"})
+		bulker.Insert(newTestEntity("3"))
+		bulker.Keyed("dup").Insert(mongox.M{"_id": "dup"})
 
-// func (t *testUpdateEntity) UnmarshalBSONValue(typ bson.Type, data []byte) error {
-// 	return bson.UnmarshalValue(typ, data, &t.name)
-// }
+		res, err := mongox.BulkWriteTracked(ctx, coll, bulker, false)
+		if !errors.Is(err, mongox.ErrDuplicate) {
+			t.Errorf("expected error %v, got %v", mongox.ErrDuplicate, err)
+		}
+		if len(res) != 3 {
+			t.Fatalf("expected %d outcomes, got %v", 3, res)
+		}
+		if res["existing"].Upserted || res["existing"].Err != nil {
+			t.Errorf("expected matched existing document, got %+v", res["existing"])
+		}
+		if !res["new"].Upserted || res["new"].UpsertedID == nil || res["new"].Err != nil {
+			t.Errorf("expected upserted new document, got %+v", res["new"])
+		}
+		if !errors.Is(res["dup"].Err, mongox.ErrDuplicate) {
+			t.Errorf("expected error %v, got %v", mongox.ErrDuplicate, res["dup"].Err)
+		}
 
-func (t testUpdateEntity) MarshalBSONValue() (byte, []byte, error) {
-	typ, data, err := bson.MarshalValue(t.name)
-	return byte(typ), data, err
-}
+		count, err := coll.Count(ctx, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if count != 3 {
+			t.Errorf("expected %d, got %d", 3, count)
+		}
 
-func (t *testUpdateEntity) UnmarshalBSONValue(typ byte, data []byte) error {
-	return bson.UnmarshalValue(bson.Type(typ), data, &t.name)
+		bulker = mongox.NewBulkBuilder()
+		bulker.Keyed("same").DeleteOne(mongox.M{"id": "1"})
+		bulker.Keyed("same").DeleteOne(mongox.M{"id": "2"})
+		_, err = coll.BulkWriteTracked(ctx, bulker, true)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+	})
 }
 
-func TestFindOneMethods(t *testing.T) {
+func TestError(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	db := client.Database(dbName)
-	coll := db.Collection("find_one_methods_test")
-
-	// Setup test data
-	entities := make([]testEntity, 5)
-	for i := 0; i < 5; i++ {
-		entities[i] = newTestEntity(fmt.Sprintf("%d", i+1))
-	}
+
+	t.Run("Error_NilArguments", func(t *testing.T) {
+		coll := db.Collection(errorNilArgCollection)
+		if coll.Name() != errorNilArgCollection {
+			t.Errorf("expected %v, got %v", errorNilArgCollection, coll.Name())
+		}
+
+		_, err := coll.Insert(ctx, newTestEntity("1"))
+		if err != nil {
+			t.Error(err)
+		}
+
+		err = coll.CreateIndex(ctx, true)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.CreateTextIndex(ctx, "")
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.FindOne(ctx, nil, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.Find(ctx, nil, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.FindAll(ctx, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		// No error
+		_, err = coll.Count(ctx, nil)
+		if err != nil {
+			t.Error(err)
+		}
+
+		err = coll.Distinct(ctx, nil, "", nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		// No error
+		err = coll.Distinct(ctx, nil, "id", nil)
+		if err != nil {
+			t.Error(err)
+		}
+
+		_, err = coll.Insert(ctx, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.Insert(ctx, []any{newTestEntity("2"), nil})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.Upsert(ctx, nil, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.ReplaceOne(ctx, nil, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.SetFields(ctx, nil, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, nil, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, nil, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.UpdateOneFromDiff(ctx, nil, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.DeleteFields(ctx, nil)
+		if err != nil {
+			t.Error(err)
+		}
+
+		// No error
+		err = coll.DeleteOne(ctx, nil)
+		if err != nil {
+			t.Error(err)
+		}
+
+		err = coll.DeleteOne(ctx, nil)
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
+
+		_, err = coll.Insert(ctx, newTestEntity("1"))
+		if err != nil {
+			t.Error(err)
+		}
+
+		// No error
+		_, err = coll.DeleteMany(ctx, nil)
+		if err != nil {
+			t.Error(err)
+		}
+
+		_, err = coll.DeleteMany(ctx, nil)
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
+
+		// Truncate of empty collection is not an error
+		if err := mongox.Truncate(ctx, coll); err != nil {
+			t.Error(err)
+		}
+
+		// Truncate of populated collection deletes documents and keeps indexes
+		truncated := db.Collection(coll.Name() + "_truncate")
+		if _, err := truncated.Insert(ctx, newTestEntity("1"), newTestEntity("2")); err != nil {
+			t.Fatal(err)
+		}
+		if err := truncated.CreateIndex(ctx, true, "id"); err != nil {
+			t.Fatal(err)
+		}
+		if err := truncated.Truncate(ctx); err != nil {
+			t.Error(err)
+		}
+		count, err := truncated.Count(ctx, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if count != 0 {
+			t.Errorf("expected %d, got %d", 0, count)
+		}
+		specs, err := truncated.Collection().Indexes().ListSpecifications(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(specs) != 2 {
+			t.Errorf("expected _id and id indexes, got %v", specs)
+		}
+
+		_, err = coll.BulkWrite(ctx, nil, false)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.BulkWrite(ctx, []mongo.WriteModel{mongo.NewDeleteManyModel(), nil}, false)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+	})
+
+	t.Run("Error_InvalidArguments", func(t *testing.T) {
+		coll := db.Collection(errorInvalidArgCollection)
+
+		_, err := coll.Insert(ctx, newTestEntity("1"))
+		if err != nil {
+			t.Error(err)
+		}
+
+		// Unexpected field, but it is ok for Mongo
+		err = coll.CreateIndex(ctx, true, "dafasdas()++*88*;;ыупаыва")
+		if err != nil {
+			t.Error(err)
+		}
+
+		err = coll.CreateTextIndex(ctx, "fasdas", "dafasdas()++*88*;;ыупаыва")
+		if !errors.Is(err, mongox.ErrUnsupportedLanguage) {
+			t.Errorf("expected error %v, got %v", mongox.ErrUnsupportedLanguage, err)
+		}
+
+		// Unexpected field, but it is ok for Mongo
+		err = coll.CreateTextIndex(ctx, "en", "dafasdas()++*88*;;ыупаыва")
+		if err != nil {
+			t.Error(err)
+		}
+
+		err = coll.FindOne(ctx, 1, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.Find(ctx, 1, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.FindAll(ctx, 1)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.Distinct(ctx, nil, "", nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		// No error
+		err = coll.Distinct(ctx, 1, "id", nil)
+		if err != nil {
+			t.Error(err)
+		}
+
+		_, err = coll.Insert(ctx, 1)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.Insert(ctx, []any{newTestEntity("2"), 1})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.Upsert(ctx, 1, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.ReplaceOne(ctx, 1, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		var result testEntity
+		err = coll.FindOne(ctx, result, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		var results []testEntity
+		err = coll.Find(ctx, results, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.FindAll(ctx, results)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		var distinct2 []testEntity
+		err = coll.Distinct(ctx, &distinct2, "id", nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.Find(ctx, &results, nil, mongox.FindOptions{
+			Limit: -100,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+
+		err = coll.Find(ctx, &results, nil, mongox.FindOptions{
+			Skip: -100,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+
+		err = coll.Find(ctx, &results, nil, mongox.FindOptions{
+			Sort:                mongox.M{"fasdfdsafse": "asdasdea"},
+			AllowPartialResults: true,
+			AllowDiskUse:        true,
+		})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.Find(ctx, &results, nil, mongox.FindOptions{
+			Sort: mongox.M{"fasdfdsafse": mongox.M{"aaa": "bbb"}},
+		})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.FindOne(ctx, &result, nil, mongox.FindOptions{
+			Limit:               -100,
+			Skip:                -100,
+			Sort:                mongox.M{"fasdfdsafse": "asdasdea"},
+			AllowPartialResults: true,
+			AllowDiskUse:        true,
+		})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.BulkWrite(ctx, []mongo.WriteModel{mongo.NewDeleteManyModel()}, false)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+	})
+
+	t.Run("Error_InvalidFilter", func(t *testing.T) {
+		coll := db.Collection(errorInvalidFilterCollection)
+
+		_, err := coll.Insert(ctx, newTestEntity("1"))
+		if err != nil {
+			t.Error(err)
+		}
+
+		var (
+			result  testEntity
+			results []testEntity
+			entity  = newTestEntity("1")
+			upd     = mongox.M{mongox.Set: mongox.M{"id": "2"}}
+
+			f = mongox.M{"aesdfsdf": lang.Ptr([]any{map[string]testEntity{"1": entity}, "()()()()fs`dvsrfvпцфкуапму<>>>>>>]]"})}
+		)
+
+		if err = coll.FindOne(ctx, &result, f); !errors.Is(err, mongox.ErrNotFound) {
+			t.Error(err)
+		}
+		if err = coll.Find(ctx, &results, f); err != nil {
+			t.Error(err)
+		}
+		if _, err = coll.Count(ctx, f); err != nil {
+			t.Error(err)
+		}
+		if err = coll.Distinct(ctx, nil, "f", f); err != nil {
+			t.Error(err)
+		}
+		if err = coll.ReplaceOne(ctx, entity, f); !errors.Is(err, mongox.ErrNotFound) {
+			t.Error(err)
+		}
+		if _, err = coll.SetFields(ctx, f, upd); !errors.Is(err, mongox.ErrNotFound) {
+			t.Error(err)
+		}
+		if _, err = coll.UpdateOne(ctx, f, upd); !errors.Is(err, mongox.ErrNotFound) {
+			t.Error(err)
+		}
+		if _, err = coll.UpdateMany(ctx, f, upd); !errors.Is(err, mongox.ErrNotFound) {
+			t.Error(err)
+		}
+		if err = mongox.DeleteFields(ctx, coll, f); !errors.Is(err, mongox.ErrNotFound) {
+			t.Error(err)
+		}
+		if err = mongox.DeleteOne(ctx, coll, f); !errors.Is(err, mongox.ErrNotFound) {
+			t.Error(err)
+		}
+		if _, err = mongox.DeleteMany(ctx, coll, f); !errors.Is(err, mongox.ErrNotFound) {
+			t.Error(err)
+		}
+		if _, err = coll.Upsert(ctx, entity, f); err != nil {
+			t.Error("expected no error, got", err)
+		}
+	})
+
+	t.Run("Error_InvalidUpdate", func(t *testing.T) {
+		coll := db.Collection(errorInvalidUpdCollection)
+
+		_, err := coll.Insert(ctx, newTestEntity("1"))
+		if err != nil {
+			t.Error(err)
+		}
+
+		var (
+			f = mongox.M{"id": "1"}
+		)
+
+		_, err = coll.SetFields(ctx, f, mongox.NewM("()()()()fs`dvsrfvпцфкуапму<>>>>>>]]", lang.Ptr([]any{map[string]testEntity{"1": newTestEntity("2")}})))
+		if err != nil {
+			t.Error(err)
+		}
+
+		_, err = coll.SetFields(ctx, f, mongox.M{"number": mongox.CurrentDate})
+		if err != nil {
+			t.Error(err)
+		}
+
+		//
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{"a": "b"})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{"id": mongox.CurrentDate})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Inc: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Min: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Mul: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Rename: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Pop: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Push: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.AddToSet: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		//
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Inc: mongox.M{"id": ""}})
+		if !errors.Is(err, mongox.ErrTypeMismatch) {
+			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Inc: mongox.M{"number": ""}})
+		if !errors.Is(err, mongox.ErrTypeMismatch) {
+			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Inc: mongox.M{"number": "1"}})
+		if !errors.Is(err, mongox.ErrTypeMismatch) {
+			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		}
+
+		// No error
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Min: mongox.M{"number": ""}})
+		if err != nil {
+			t.Error(err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Mul: mongox.M{"number": ""}})
+		if !errors.Is(err, mongox.ErrTypeMismatch) {
+			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Rename: mongox.M{"number": ""}})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = mongox.UpdateOne(ctx, coll, f, mongox.M{mongox.Pop: mongox.M{"number": ""}})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.Push: mongox.M{"number": ""}})
+		if !errors.Is(err, mongox.ErrBadValue) {
+			t.Errorf("expected error %v, got %v", mongox.ErrBadValue, err)
+		}
+
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.AddToSet: mongox.M{"number": ""}})
+		if !errors.Is(err, mongox.ErrBadValue) {
+			t.Errorf("expected error %v, got %v", mongox.ErrBadValue, err)
+		}
+
+		// No error
+		_, err = coll.UpdateOne(ctx, f, mongox.M{mongox.AddToSet: mongox.M{"slice": newTestEntity("1")}})
+		if err != nil {
+			t.Error(err)
+		}
+
+		//
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{"a": "b"})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{"a": "b"})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{"id": mongox.CurrentDate})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Inc: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Min: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Mul: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Rename: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Pop: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Push: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.AddToSet: "id"})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		//
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Inc: mongox.M{"id": ""}})
+		if !errors.Is(err, mongox.ErrTypeMismatch) {
+			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Inc: mongox.M{"number": ""}})
+		if !errors.Is(err, mongox.ErrTypeMismatch) {
+			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Inc: mongox.M{"number": "1"}})
+		if !errors.Is(err, mongox.ErrTypeMismatch) {
+			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		}
+
+		// No error
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Min: mongox.M{"number": ""}})
+		if err != nil {
+			t.Error(err)
+		}
+
+		_, err = mongox.UpdateMany(ctx, coll, f, mongox.M{mongox.Mul: mongox.M{"number": ""}})
+		if !errors.Is(err, mongox.ErrTypeMismatch) {
+			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Rename: mongox.M{"number": ""}})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Pop: mongox.M{"number": ""}})
+		if !errors.Is(err, mongox.ErrFailedToParse) {
+			t.Errorf("expected error %v, got %v", mongox.ErrFailedToParse, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.Push: mongox.M{"number": ""}})
+		if !errors.Is(err, mongox.ErrBadValue) {
+			t.Errorf("expected error %v, got %v", mongox.ErrBadValue, err)
+		}
+
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.AddToSet: mongox.M{"number": ""}})
+		if !errors.Is(err, mongox.ErrBadValue) {
+			t.Errorf("expected error %v, got %v", mongox.ErrBadValue, err)
+		}
+
+		// No error
+		_, err = coll.UpdateMany(ctx, f, mongox.M{mongox.AddToSet: mongox.M{"slice": newTestEntity("1")}})
+		if err != nil {
+			t.Error(err)
+		}
+
+		//
+
+		err = coll.UpdateOneFromDiff(ctx, f, mongox.M{"a": "b"})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		err = coll.UpdateOneFromDiff(ctx, f, newTestEntity("1"))
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Error_InvalidState", func(t *testing.T) {
+		coll := db.Collection(errorInvalidStateCollection)
+
+		err := coll.CreateIndex(ctx, true, "id", "id", "id")
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+		err = coll.CreateIndex(ctx, false, "id", "id", "id")
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+		err = coll.CreateIndex(ctx, true, "id")
+		if err != nil {
+			t.Error(err)
+		}
+		err = coll.CreateIndex(ctx, false, "id")
+		if err != nil {
+			t.Error(err)
+		}
+		err = coll.CreateIndex(ctx, true, "id")
+		if err != nil {
+			t.Error(err)
+		}
+		err = coll.CreateIndex(ctx, false, "id")
+		if err != nil {
+			t.Error(err)
+		}
+		err = coll.CreateTextIndex(ctx, "en", "id", "id", "id")
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+		err = coll.CreateTextIndex(ctx, "en", "id")
+		if err != nil {
+			t.Error(err)
+		}
+		err = coll.CreateTextIndex(ctx, "en", "id")
+		if err != nil {
+			t.Error(err)
+		}
+		_, err = coll.Insert(ctx, newTestEntity("1"))
+		if err != nil {
+			t.Error(err)
+		}
+		_, err = coll.Insert(ctx, newTestEntity("1"))
+		if !errors.Is(err, mongox.ErrDuplicate) {
+			t.Errorf("expected error %v, got %v", mongox.ErrDuplicateKey, err)
+		}
+		if status := mongox.HTTPStatus(err); status != http.StatusConflict {
+			t.Errorf("expected %v, got %v", http.StatusConflict, status)
+		}
+	})
+
+	t.Run("Error_DecodeError", func(t *testing.T) {
+		raw, err := bson.Marshal(bson.D{
+			{Key: "id", Value: "1"},
+			{Key: "struct", Value: bson.D{{Key: "number", Value: "ten"}}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var dest testEntity
+		err = mongox.HandleMongoError(bson.Unmarshal(raw, &dest))
+		var decodeErr *mongox.DecodeError
+		if !errors.As(err, &decodeErr) || !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Fatalf("expected decode error, got %v", err)
+		}
+		if decodeErr.Field != "struct.number" || decodeErr.Actual != "string" || decodeErr.Expected != "an integer type" {
+			t.Errorf("unexpected decode error %+v", decodeErr)
+		}
+
+		// Find decodes documents of the collection with the drifted schema
+		coll := db.Collection("error_decode_test")
+		if _, err := coll.Insert(ctx, mongox.M{"id": "1", "number": "not-a-number"}); err != nil {
+			t.Fatal(err)
+		}
+		err = coll.FindOne(ctx, &dest, mongox.M{"id": "1"})
+		if !errors.As(err, &decodeErr) || decodeErr.Field != "number" {
+			t.Errorf("expected decode error of field %q, got %v", "number", err)
+		}
+		var many []testEntity
+		err = coll.Find(ctx, &many, mongox.M{"id": "1"})
+		if !errors.As(err, &decodeErr) || decodeErr.Field != "number" {
+			t.Errorf("expected decode error of field %q, got %v", "number", err)
+		}
+	})
+
+	t.Run("Error_CappedConstraint", func(t *testing.T) {
+		const name = "capped_test"
+		err := db.Database().CreateCollection(ctx, name, options.CreateCollection().SetCapped(true).SetSizeInBytes(4096).SetMaxDocuments(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		coll := db.Collection(name)
+
+		// Inserts into the full capped collection evict the oldest documents instead of failing
+		for i := range 5 {
+			if _, err := coll.Insert(ctx, newTestEntity(strconv.Itoa(i))); err != nil {
+				t.Error(err)
+			}
+		}
+		count, err := coll.Count(ctx, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if count != 3 {
+			t.Errorf("expected %v, got %v", 3, count)
+		}
+		err = coll.FindOne(ctx, &testEntity{}, mongox.M{"id": "0"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
+
+		_, err = coll.InsertOne(ctx, mongox.M{"payload": strings.Repeat("x", 8192)})
+		if !errors.Is(err, mongox.ErrCappedConstraint) || !errors.Is(err, mongox.ErrDocTooLargeForCapped) {
+			t.Errorf("expected error %v, got %v", mongox.ErrCappedConstraint, err)
+		}
+		err = mongox.HandleMongoError(mongo.CommandError{Code: 10003, Name: "CannotGrowDocumentInCappedNamespace"})
+		if !errors.Is(err, mongox.ErrCappedConstraint) {
+			t.Errorf("expected error %v, got %v", mongox.ErrCappedConstraint, err)
+		}
+	})
+
+	t.Run("Error_WriteConcernTimeout", func(t *testing.T) {
+		errInfo, err := bson.Marshal(bson.D{{Key: "wtimeout", Value: true}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wtimeout := &mongo.WriteConcernError{Name: "WriteConcernFailed", Code: 64, Message: "waiting for replication timed out", Details: errInfo}
+
+		err = mongox.HandleMongoError(mongo.WriteException{WriteConcernError: wtimeout})
+		if !errors.Is(err, mongox.ErrWriteConcernTimeout) || !errors.Is(err, mongox.ErrWriteConcernFailed) {
+			t.Errorf("expected error %v, got %v", mongox.ErrWriteConcernTimeout, err)
+		}
+		err = mongox.HandleMongoError(mongo.BulkWriteException{WriteConcernError: wtimeout})
+		if !errors.Is(err, mongox.ErrWriteConcernTimeout) {
+			t.Errorf("expected error %v, got %v", mongox.ErrWriteConcernTimeout, err)
+		}
+
+		// Write concern error without wtimeout flag is a real failure
+		failed := &mongo.WriteConcernError{Name: "WriteConcernFailed", Code: 64, Message: "failed"}
+		err = mongox.HandleMongoError(mongo.WriteException{WriteConcernError: failed})
+		if !errors.Is(err, mongox.ErrWriteConcernFailed) || errors.Is(err, mongox.ErrWriteConcernTimeout) {
+			t.Errorf("expected error %v, got %v", mongox.ErrWriteConcernFailed, err)
+		}
+	})
+
+	t.Run("HTTPStatus", func(t *testing.T) {
+		coll := db.Collection(errorInvalidStateCollection)
+
+		err := coll.FindOne(ctx, &testEntity{}, mongox.M{"id": "not-found"})
+		if status := mongox.HTTPStatus(err); status != http.StatusNotFound {
+			t.Errorf("expected %v, got %v", http.StatusNotFound, status)
+		}
+		err = coll.FindOne(ctx, nil, nil)
+		if status := mongox.HTTPStatus(err); status != http.StatusBadRequest {
+			t.Errorf("expected %v, got %v", http.StatusBadRequest, status)
+		}
+
+		for err, expected := range map[error]int{
+			nil:                                           http.StatusOK,
+			mongox.ErrEmptyFilter:                         http.StatusBadRequest,
+			mongox.ErrWriteConflict:                       http.StatusConflict,
+			mongox.ErrDocumentValidationFailure:           http.StatusUnprocessableEntity,
+			mongox.ErrUnauthorized:                        http.StatusForbidden,
+			mongox.ErrMaxTimeMSExpired:                    http.StatusGatewayTimeout,
+			context.DeadlineExceeded:                      http.StatusGatewayTimeout,
+			mongox.ErrNetwork:                             http.StatusServiceUnavailable,
+			mongox.ErrNoSuchTransaction:                   http.StatusServiceUnavailable,
+			mongox.ErrTransactionsUnsupported:             http.StatusInternalServerError,
+			fmt.Errorf("wrapped: %w", mongox.ErrNotFound): http.StatusNotFound,
+		} {
+			if status := mongox.HTTPStatus(err); status != expected {
+				t.Errorf("expected %v for %v, got %v", expected, err, status)
+			}
+		}
+	})
+}
+
+func TestAsync(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := client.Ping(ctx); err != nil {
+		t.Error(err)
+	}
+
+	db := client.Database(dbName)
+	asyncDB := client.AsyncDatabase(ctx, dbName, 0, slog.Default())
+
+	t.Run("Async", func(t *testing.T) {
+		coll := db.Collection(asyncCollection)
+		if coll.Name() != asyncCollection {
+			t.Errorf("expected %v, got %v", asyncCollection, coll.Name())
+		}
+		asyncColl := asyncDB.AsyncCollection(asyncCollection)
+		queueColl := asyncColl.QueueCollection(asyncCollection)
+		if asyncColl.Name() != asyncCollection {
+			t.Errorf("expected %v, got %v", asyncCollection, asyncColl.Name())
+		}
+		if queueColl.Name() != asyncCollection {
+			t.Errorf("expected %v, got %v", asyncCollection, queueColl.Name())
+		}
+		entity := newTestEntity("1")
+		entity2 := newTestEntity("2")
+		entity3 := newTestEntity("3")
+
+		// error
+		asyncDB.WithTransaction("", "", func(ctx context.Context) error {
+			return nil
+		})
+
+		// error
+		queueColl.Insert([]any{entity2, nil})
+
+		for i := 0; i < 3; i++ {
+			queueColl.Insert(entity)
+		}
+
+		bulk := mongox.NewBulkBuilder()
+		for i := 0; i < 3; i++ {
+			bulk.Insert(entity)
+		}
+		queueColl.BulkWrite(bulk.Models(), false)
+
+		queueColl.Upsert(entity2, mongox.M{"id": "2"})
+		queueColl.ReplaceOne(entity3, mongox.M{"id": "2"})
+		queueColl.SetFields(mongox.M{"id": "3"}, mongox.M{"name": "new-name"})
+		queueColl.UpdateOne(mongox.M{"id": "3"}, mongox.M{mongox.Inc: mongox.M{"number": 1}})
+		diff := struct {
+			Bool *bool `bson:"bool"`
+		}{
+			Bool: lang.Ptr(!entity3.Bool),
+		}
+		queueColl.UpdateOneFromDiff(mongox.M{"id": "3"}, diff)
+
+		queueColl.UpdateMany(mongox.M{"id": "1"}, mongox.M{mongox.Inc: mongox.M{"number": 1}})
+		queueColl.DeleteFields(mongox.M{"id": "3"}, "struct.name")
+		queueColl.DeleteOne(mongox.M{"number": entity.Number + 1})
+		queueColl.InsertMany([]any{entity, entity, entity})
+		queueColl.DeleteMany(mongox.M{"number": entity.Number})
+
+		var (
+			s  []int
+			mu sync.Mutex
+			wg sync.WaitGroup
+		)
+
+		wg.Add(3)
+		asyncDB.WithTask(asyncCollection, "", func(ctx context.Context) error {
+			defer wg.Done()
+			time.Sleep(500 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			s = append(s, 1)
+			return nil
+		})
+		asyncDB.WithTask(asyncCollection, "", func(ctx context.Context) error {
+			defer wg.Done()
+			time.Sleep(100 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			s = append(s, 2)
+			return nil
+		})
+		asyncDB.WithTask(asyncCollection, "", func(ctx context.Context) error {
+			defer wg.Done()
+			mu.Lock()
+			defer mu.Unlock()
+			s = append(s, 3)
+			return nil
+		})
+
+		wg.Wait()
+
+		if !reflect.DeepEqual(s, []int{1, 2, 3}) {
+			t.Errorf("expected %v, got %v", []int{1, 2, 3}, s)
+		}
+
+		n, err := coll.Count(ctx, mongox.M{"id": "1"})
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 5 {
+			t.Errorf("expected %v, got %v", 5, n)
+		}
+
+		entity.Number++
+		testAsync(t, ctx, db, entity, mongox.M{"id": "1"})
+		testAsync(t, ctx, db, entity2, mongox.M{"id": "2"}, mongox.ErrNotFound)
+
+		entity3.Name = "new-name"
+		entity3.Number++
+		entity3.Bool = !entity3.Bool
+		entity3.Struct.Name = ""
+		testAsync(t, ctx, db, entity3, mongox.M{"id": "3"})
+	})
+
+	t.Run("BulkWriteCallback", func(t *testing.T) {
+		collName := asyncCollection + "_bulk_callback"
+		queueColl := asyncDB.AsyncCollection(collName).QueueCollection(collName)
+
+		bulk := mongox.NewBulkBuilder()
+		bulk.Insert(newTestEntity("1"), newTestEntity("2"), newTestEntity("3"))
+
+		done := make(chan mongox.BulkResult, 1)
+		queueColl.BulkWriteCallback(bulk.Models(), true, func(res mongox.BulkResult, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+			done <- res
+		})
+
+		select {
+		case res := <-done:
+			if res.InsertedCount != 3 {
+				t.Errorf("expected %v, got %v", 3, res.InsertedCount)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("callback was not called")
+		}
+
+		// Duplicate key is not retried, callback gets an error
+		bulk = mongox.NewBulkBuilder()
+		bulk.Insert(newTestEntity("4"))
+		bulk.DeleteOne(mongox.M{"id": "1"})
+		_, err := db.Collection(collName).InsertOne(ctx, mongox.M{"_id": "dup"})
+		if err != nil {
+			t.Error(err)
+		}
+		bulk.Insert(mongox.M{"_id": "dup"})
+
+		errs := make(chan error, 1)
+		queueColl.BulkWriteCallback(bulk.Models(), true, func(res mongox.BulkResult, err error) {
+			errs <- err
+		})
+
+		select {
+		case err := <-errs:
+			if !errors.Is(err, mongox.ErrDuplicate) {
+				t.Errorf("expected error %v, got %v", mongox.ErrDuplicate, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("callback was not called")
+		}
+
+		// Nil callback is allowed
+		queueColl.BulkWriteCallback(mongox.NewBulkBuilder().Models(), false, nil)
+	})
+}
+
+func TestMain(m *testing.M) {
+	// Uses a sensible default on windows (tcp/http) and linux/osx (socket)
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		log.Fatalf("Could not connect to Docker: %s", err)
+	}
+
+	// Uses pool to try to connect to Docker
+	err = pool.Client.Ping()
+	if err != nil {
+		log.Fatalf("Could not connect to Docker: %s", err)
+	}
+
+	// Pulls an image, creates a container based on it and runs it
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "mongo",
+		Tag:        "latest",
+		Env: []string{
+			// username and password for mongodb superuser
+			"MONGO_INITDB_ROOT_USERNAME=root",
+			"MONGO_INITDB_ROOT_PASSWORD=password",
+		},
+		// Test commands enable fail points, e.g. failCommand to simulate dropped cursors
+		Cmd: []string{"mongod", "--setParameter", "enableTestCommands=1"},
+	}, func(config *docker.HostConfig) {
+		// set AutoRemove to true so that stopped container goes away by itself
+		config.AutoRemove = true
+		config.RestartPolicy = docker.RestartPolicy{
+			Name: "no",
+		}
+	})
+	if err != nil {
+		log.Fatalf("Could not start resource: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// exponential backoff-retry, because the application in the container might not be ready to accept connections yet
+	err = pool.Retry(func() error {
+		var err error
+		testConfig = mongox.Config{
+			AppName: "mongox-test",
+			Hosts: []string{
+				"localhost:" + resource.GetPort("27017/tcp"),
+			},
+			Compressors: []string{
+				"snappy",
+			},
+			Connection: &mongox.ConnectionConfig{
+				ConnectTimeout:  lang.Ptr(10 * time.Second),
+				MaxConnIdleTime: lang.Ptr(10 * time.Second),
+				MaxConnecting:   lang.Ptr(uint64(10)),
+				MaxPoolSize:     lang.Ptr(uint64(10)),
+				MinPoolSize:     lang.Ptr(uint64(1)),
+				IsDirect:        true,
+			},
+			Auth: &mongox.AuthConfig{
+				Username:      "root",
+				Password:      "password",
+				AuthMechanism: "SCRAM-SHA-256",
+			},
+			BSONOptions: &mongox.BSONOptions{
+				ErrorOnInlineDuplicates: true, // test buildBSONOptions
+			},
+		}
+		client, err = mongox.Connect(ctx, testConfig)
+		if err != nil {
+			return err
+		}
+		return client.Ping(ctx)
+	})
+	if err != nil {
+		log.Fatalf("Could not connect to mongo container: %s", err)
+	}
+
+	defer func() {
+		// When you're done, kill and remove the container
+		if err = pool.Purge(resource); err != nil {
+			log.Fatalf("Could not purge resource: %s", err)
+		}
+
+		// disconnect mongodb client
+		if err = client.Disconnect(ctx); err != nil {
+			panic(err)
+		}
+	}()
+
+	// run tests
+	m.Run()
+}
+
+func testFindOne(t *testing.T, ctx context.Context, db *mongox.Database, entity testEntity, filter mongox.M, err2 ...error) {
+	testOne(t, ctx, db.Collection(findOneCollection), entity, filter, err2...)
+}
+
+func testUpdate(t *testing.T, ctx context.Context, db *mongox.Database, entity testEntity, filter mongox.M, err2 ...error) {
+	testOne(t, ctx, db.Collection(updateCollection), entity, filter, err2...)
+}
+
+func testBulk(t *testing.T, ctx context.Context, db *mongox.Database, entity testEntity, filter mongox.M, err2 ...error) {
+	testOne(t, ctx, db.Collection(bulkCollection), entity, filter, err2...)
+}
+
+func testAsync(t *testing.T, ctx context.Context, db *mongox.Database, entity testEntity, filter mongox.M, err2 ...error) {
+	testOne(t, ctx, db.Collection(asyncCollection), entity, filter, err2...)
+}
+
+func testOne(t *testing.T, ctx context.Context, coll *mongox.Collection, entity testEntity, filter mongox.M, err2 ...error) {
+	var result testEntity
+	err := coll.FindOne(ctx, &result, filter)
+	if len(err2) > 0 {
+		if !errors.Is(err, err2[0]) {
+			t.Errorf("expected %v, got %v, query: %v", err2[0], err, filter)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("expected nil, got %v, query: %v", err, filter)
+		return
+	}
+	if !reflect.DeepEqual(entity, result) {
+		t.Errorf("expected %v, got %v, query: %v", entity, result, filter)
+	}
+}
+
+func testFind(t *testing.T, ctx context.Context, db *mongox.Database, entity []testEntity, filter mongox.M, err2 ...error) {
+	var result []testEntity
+	err := db.Collection(findCollection).Find(ctx, &result, filter)
+	if len(err2) > 0 {
+		if !errors.Is(err, err2[0]) {
+			t.Errorf("expected %v, got %v, query: %v", err2[0], err, filter)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("expected nil, got %v, query: %v", err, filter)
+		return
+	}
+	if len(result) != len(entity) {
+		t.Errorf("expected %d, got %d, query: %v", len(entity), len(result), filter)
+	}
+	if len(entity) == 0 {
+		return
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	sort.Slice(entity, func(i, j int) bool {
+		return entity[i].ID < entity[j].ID
+	})
+	if !reflect.DeepEqual(entity, result) {
+		t.Errorf("expected %v, got %v, query: %v", entity, &result, filter)
+	}
+}
+
+type testEntity struct {
+	ID           string         `bson:"id"`
+	Name         string         `bson:"name,omitempty"`
+	Number       int            `bson:"number,omitempty"`
+	Bool         bool           `bson:"bool,omitempty"`
+	Slice        []int          `bson:"slice,omitempty"`
+	Array        [3]int         `bson:"array,omitempty"`
+	Map          map[string]int `bson:"map,omitempty"`
+	Time         time.Time      `bson:"time,omitempty"`
+	Struct       innerStruct    `bson:"struct,omitempty"`
+	InlineStruct inlineStruct   `bson:"inline_struct,inline"`
+}
+
+type innerStruct struct {
+	Name   string `bson:"name"`
+	Number int    `bson:"number"`
+}
+
+type inlineStruct struct {
+	InlineField string `bson:"inline_field"`
+}
+
+func newTestEntity(id string) testEntity {
+	randString := func() string {
+		b := make([]byte, 10)
+		for i := range b {
+			b[i] = byte(rand.Intn(26) + 'a')
+		}
+		return string(b)
+	}
+	return testEntity{
+		ID:     id,
+		Name:   randString(),
+		Number: rand.Intn(math.MaxInt32) - 1,
+		Bool:   true,
+		Slice:  []int{rand.Intn(26), rand.Intn(26), rand.Intn(26)},
+		Array:  [3]int{rand.Intn(26), rand.Intn(26), rand.Intn(26)},
+		Map:    map[string]int{"1": rand.Intn(26), randString(): rand.Intn(26), randString(): rand.Intn(26)},
+		Time:   time.Date(2000, 1, rand.Intn(26), rand.Intn(20), rand.Intn(59), rand.Intn(59), 0, time.UTC),
+		Struct: innerStruct{Name: randString(), Number: rand.Intn(26)},
+	}
+}
+
+type testUpdateEntity struct {
+	name string
+}
+
+// func (t testUpdateEntity) MarshalBSONValue() (bson.Type, []byte, error) {
+// 	return bson.MarshalValue(t.name)
+// }
+
+// func (t *testUpdateEntity) UnmarshalBSONValue(typ bson.Type, data []byte) error {
+// 	return bson.UnmarshalValue(typ, data, &t.name)
+// }
+
+func (t testUpdateEntity) MarshalBSONValue() (byte, []byte, error) {
+	typ, data, err := bson.MarshalValue(t.name)
+	return byte(typ), data, err
+}
+
+func (t *testUpdateEntity) UnmarshalBSONValue(typ byte, data []byte) error {
+	return bson.UnmarshalValue(bson.Type(typ), data, &t.name)
+}
+
+func TestFindOneMethods(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := client.Database(dbName)
+	coll := db.Collection("find_one_methods_test")
+
+	// Setup test data
+	entities := make([]testEntity, 5)
+	for i := 0; i < 5; i++ {
+		entities[i] = newTestEntity(fmt.Sprintf("%d", i+1))
+	}
 
 	// Insert test data
 	_, err := coll.Insert(ctx,
 		entities[0], entities[1], entities[2], entities[3], entities[4])
 	if err != nil {
-		t.Fatal(err)
+		t.Fatal(err)
+	}
+
+	t.Run("FindOne_BasicFilters", func(t *testing.T) {
+		// Test FindOne with basic filters
+		var result testEntity
+		err := coll.FindOne(ctx, &result, mongox.M{"id": "1"})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "1" {
+			t.Errorf("expected ID '1', got '%s'", result.ID)
+		}
+
+		// Test FindOne with complex filter
+		err = coll.FindOne(ctx, &result, mongox.M{"id": mongox.M{mongox.In: []string{"2", "3"}}})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "2" && result.ID != "3" {
+			t.Errorf("expected ID '2' or '3', got '%s'", result.ID)
+		}
+
+		// Test FindOne with no filter (should return any document)
+		err = coll.FindOne(ctx, &result, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID == "" {
+			t.Error("expected non-empty ID")
+		}
+	})
+
+	t.Run("FindOne_WithOptions", func(t *testing.T) {
+		var result testEntity
+
+		// Test FindOne with Skip option
+		err := coll.FindOne(ctx, &result, nil, mongox.FindOptions{
+			Skip: 2,
+			Sort: mongox.M{"id": 1},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "3" {
+			t.Errorf("expected ID '3' with skip=2, got '%s'", result.ID)
+		}
+
+		// Test FindOne with Sort option (ascending)
+		err = coll.FindOne(ctx, &result, nil, mongox.FindOptions{
+			Sort: mongox.M{"id": mongox.Ascending},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "1" {
+			t.Errorf("expected ID '1' with ascending sort, got '%s'", result.ID)
+		}
+
+		// Test FindOne with Sort option (descending)
+		err = coll.FindOne(ctx, &result, nil, mongox.FindOptions{
+			Sort: mongox.M{"id": mongox.Descending},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "5" {
+			t.Errorf("expected ID '5' with descending sort, got '%s'", result.ID)
+		}
+
+		// Test FindOne with SortMany option
+		err = coll.FindOne(ctx, &result, nil, mongox.FindOptions{
+			SortMany: []mongox.M{
+				{"id": mongox.Descending},
+				{"name": mongox.Ascending},
+			},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "5" {
+			t.Errorf("expected ID '5' with SortMany descending, got '%s'", result.ID)
+		}
+
+		// Test that Sort has priority over SortMany
+		err = coll.FindOne(ctx, &result, nil, mongox.FindOptions{
+			Sort: mongox.M{"id": mongox.Ascending},
+			SortMany: []mongox.M{
+				{"id": mongox.Descending},
+			},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "1" {
+			t.Errorf("expected ID '1' (Sort should override SortMany), got '%s'", result.ID)
+		}
+
+		// Test FindOne with AllowPartialResults option
+		err = coll.FindOne(ctx, &result, mongox.M{"id": "1"}, mongox.FindOptions{
+			AllowPartialResults: true,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "1" {
+			t.Errorf("expected ID '1' with AllowPartialResults, got '%s'", result.ID)
+		}
+	})
+
+	t.Run("Generic_DistinctValues", func(t *testing.T) {
+		products := db.Collection("distinct_values_test")
+		_, err := products.Insert(ctx,
+			mongox.M{"id": "1", "category": "books", "shop": "a"},
+			mongox.M{"id": "2", "category": "games", "shop": "a"},
+			mongox.M{"id": "3", "category": nil, "shop": "b"},
+			mongox.M{"id": "4", "shop": "c"},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		values, hasNull, err := mongox.DistinctValues[string](ctx, products, "category", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(values)
+		if !reflect.DeepEqual(values, []string{"books", "games"}) || !hasNull {
+			t.Errorf("expected [books games] with null, got %v, %v", values, hasNull)
+		}
+
+		// Absent field is reported as null
+		values, hasNull, err = mongox.DistinctValues[string](ctx, products, "category", mongox.M{"shop": "c"})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(values) != 0 || !hasNull {
+			t.Errorf("expected no values with null, got %v, %v", values, hasNull)
+		}
+
+		values, hasNull, err = mongox.DistinctValues[string](ctx, products, "category", mongox.M{"shop": "a"})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(values) != 2 || hasNull {
+			t.Errorf("expected 2 values without null, got %v, %v", values, hasNull)
+		}
+
+		_, _, err = mongox.DistinctValues[int](ctx, products, "category", nil)
+		if !errors.Is(err, mongox.ErrTypeMismatch) {
+			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		}
+	})
+
+	t.Run("Generic_DistinctArray", func(t *testing.T) {
+		tagsColl := db.Collection("distinct_array_test")
+		_, err := tagsColl.Insert(ctx,
+			mongox.M{"id": "1", "tags": []string{"go", "mongo"}},
+			mongox.M{"id": "2", "tags": []string{"go", "redis"}},
+			mongox.M{"id": "3", "tags": []any{"nested", []string{"go", "deep"}}},
+			mongox.M{"id": "4", "tags": []string{}},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tags, err := mongox.DistinctArray[string](ctx, tagsColl, "tags", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		sort.Strings(tags)
+		expected := []string{"deep", "go", "mongo", "nested", "redis"}
+		if !reflect.DeepEqual(tags, expected) {
+			t.Errorf("expected %v, got %v", expected, tags)
+		}
+
+		tags, err = mongox.DistinctArray[string](ctx, tagsColl, "tags", mongox.M{"id": "2"})
+		if err != nil {
+			t.Error(err)
+		}
+		sort.Strings(tags)
+		if !reflect.DeepEqual(tags, []string{"go", "redis"}) {
+			t.Errorf("expected %v, got %v", []string{"go", "redis"}, tags)
+		}
+
+		_, err = mongox.DistinctArray[int](ctx, tagsColl, "tags", nil)
+		if !errors.Is(err, mongox.ErrTypeMismatch) {
+			t.Errorf("expected error %v, got %v", mongox.ErrTypeMismatch, err)
+		}
+
+		numbers, err := mongox.DistinctArray[int](ctx, coll, "slice", mongox.M{"id": "1"})
+		if err != nil {
+			t.Error(err)
+		}
+		for _, n := range numbers {
+			if !slices.Contains(entities[0].Slice, n) {
+				t.Errorf("unexpected value %d, expected one of %v", n, entities[0].Slice)
+			}
+		}
+	})
+
+	t.Run("Generic_DistinctPaged", func(t *testing.T) {
+		pagedColl := db.Collection("distinct_paged_test")
+		records := make([]any, 0, 100)
+		for i := range 100 {
+			records = append(records, mongox.M{"id": strconv.Itoa(i), "group": i % 10, "tags": []string{"all", "tag" + strconv.Itoa(i%3)}})
+		}
+		if _, err := pagedColl.InsertMany(ctx, records); err != nil {
+			t.Fatal(err)
+		}
+
+		groups, err := mongox.DistinctPaged[int](ctx, pagedColl, "group", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		expected, err := mongox.Distinct[int](ctx, pagedColl, "group", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		sort.Ints(groups)
+		sort.Ints(expected)
+		if !reflect.DeepEqual(groups, expected) {
+			t.Errorf("expected %v, got %v", expected, groups)
+		}
+
+		// Null and missing values are excluded
+		if _, err := pagedColl.Insert(ctx, mongox.M{"id": "null", "group": nil}, mongox.M{"id": "missing"}); err != nil {
+			t.Fatal(err)
+		}
+		withNull, err := mongox.DistinctPaged[*int](ctx, pagedColl, "group", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(withNull) != len(expected) {
+			t.Errorf("expected %d values without null, got %d", len(expected), len(withNull))
+		}
+
+		tags, err := mongox.DistinctPaged[string](ctx, pagedColl, "tags", mongox.M{"group": mongox.M{mongox.Lt: 2}})
+		if err != nil {
+			t.Error(err)
+		}
+		sort.Strings(tags)
+		if !reflect.DeepEqual(tags, []string{"all", "tag0", "tag1", "tag2"}) {
+			t.Errorf("expected %v, got %v", []string{"all", "tag0", "tag1", "tag2"}, tags)
+		}
+
+		_, err = mongox.DistinctPaged[string](ctx, pagedColl, "", nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+	})
+
+	t.Run("Generic_DistinctWithOptions", func(t *testing.T) {
+		hintColl := db.Collection("distinct_options_test")
+		records := make([]any, 0, 20)
+		for i := range 20 {
+			records = append(records, mongox.M{"id": strconv.Itoa(i), "status": i % 2, "group": i % 5})
+		}
+		if _, err := hintColl.InsertMany(ctx, records); err != nil {
+			t.Fatal(err)
+		}
+		err := hintColl.CreateIndexWithOptions(ctx, mongox.IndexOptions{Name: "status_group"}, "status", "group")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		groups, err := mongox.DistinctWithOptions[int](ctx, hintColl, "group", mongox.M{"status": 0}, mongox.DistinctOptions{
+			Hint:    "status_group",
+			MaxTime: time.Minute,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		sort.Ints(groups)
+		if !reflect.DeepEqual(groups, []int{0, 2, 4}) {
+			t.Errorf("expected %v, got %v", []int{0, 2, 4}, groups)
+		}
+
+		_, err = mongox.DistinctWithOptions[int](ctx, hintColl, "group", nil, mongox.DistinctOptions{Hint: "not_found"})
+		if err == nil {
+			t.Error("expected error for unknown hint")
+		}
+
+		var slow []int
+		err = hintColl.DistinctWithOptions(ctx, &slow, "group", mongox.M{"$where": "function() { sleep(100); return true; }"}, mongox.DistinctOptions{
+			MaxTime: 50 * time.Millisecond,
+		})
+		if !errors.Is(err, mongox.ErrMaxTimeMSExpired) || !errors.Is(err, mongox.ErrTimeout) {
+			t.Errorf("expected error %v, got %v", mongox.ErrMaxTimeMSExpired, err)
+		}
+	})
+
+	t.Run("FindOne_StableSort", func(t *testing.T) {
+		var result testEntity
+
+		// All entities have bool == true, so sort by bool has ties
+		err := coll.FindOne(ctx, &result, nil, mongox.FindOptions{
+			Skip:            2,
+			Sort:            mongox.M{"bool": mongox.Descending},
+			StableSortField: "id",
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "3" {
+			t.Errorf("expected ID '3' with skip=2, got '%s'", result.ID)
+		}
+
+		results, err := mongox.Find[testEntity](ctx, coll, nil, mongox.FindOptions{
+			SortMany:        []mongox.M{{"bool": mongox.Ascending}},
+			StableSortField: "id",
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		for i, r := range results {
+			if r.ID != entities[i].ID {
+				t.Errorf("expected ID '%s' at %d, got '%s'", entities[i].ID, i, r.ID)
+			}
+		}
+
+		// Stable field is already in sort
+		err = coll.FindOne(ctx, &result, nil, mongox.FindOptions{
+			Sort:            mongox.M{"id": mongox.Descending},
+			StableSortField: "id",
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "5" {
+			t.Errorf("expected ID '5', got '%s'", result.ID)
+		}
+	})
+
+	t.Run("FindOne_ErrorCases", func(t *testing.T) {
+		var result testEntity
+
+		// Test FindOne with non-existent document
+		err := coll.FindOne(ctx, &result, mongox.M{"id": "999"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+
+		// Test FindOne with invalid filter that won't match anything
+		err = coll.FindOne(ctx, &result, mongox.M{"nonexistent_field": "value"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for non-matching filter, got %v", err)
+		}
+
+		// Test FindOne with nil destination (should error)
+		err = coll.FindOne(ctx, nil, mongox.M{"id": "1"})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument for nil destination, got %v", err)
+		}
+
+		// Test FindOne with non-pointer destination (should error)
+		err = coll.FindOne(ctx, result, mongox.M{"id": "1"})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument for non-pointer destination, got %v", err)
+		}
+	})
+
+	t.Run("Generic_FindOne", func(t *testing.T) {
+		// Test generic FindOne[T] method
+		result, err := mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "1"})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "1" {
+			t.Errorf("expected ID '1', got '%s'", result.ID)
+		}
+
+		// Test generic FindOne[T] with options
+		result, err = mongox.FindOne[testEntity](ctx, coll, nil, mongox.FindOptions{
+			Sort: mongox.M{"id": mongox.Descending},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "5" {
+			t.Errorf("expected ID '5' with descending sort, got '%s'", result.ID)
+		}
+
+		// Test generic FindOne[T] with skip and sort
+		result, err = mongox.FindOne[testEntity](ctx, coll, nil, mongox.FindOptions{
+			Skip: 1,
+			Sort: mongox.M{"id": mongox.Ascending},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "2" {
+			t.Errorf("expected ID '2' with skip=1 and ascending sort, got '%s'", result.ID)
+		}
+
+		// Test generic FindOne[T] error case
+		_, err = mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "999"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Generic_FindBy", func(t *testing.T) {
+		result, err := mongox.FindOneBy[testEntity](ctx, coll, "id", "3")
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "3" {
+			t.Errorf("expected ID '3', got '%s'", result.ID)
+		}
+
+		_, err = mongox.FindOneBy[testEntity](ctx, coll, "id", "999")
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+		_, err = mongox.FindOneBy[testEntity](ctx, coll, "", "3")
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, got %v", err)
+		}
+
+		results, err := mongox.FindBy[testEntity](ctx, coll, "id", "2", mongox.FindOptions{
+			Sort: mongox.M{"number": mongox.Ascending},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(results) != 1 || results[0].ID != "2" {
+			t.Errorf("expected IDs [2], got %v", results)
+		}
+
+		results, err = mongox.FindBy[testEntity](ctx, coll, "id", "999")
+		if err != nil {
+			t.Error(err)
+		}
+		if len(results) != 0 {
+			t.Errorf("expected no results, got %v", results)
+		}
+	})
+
+	t.Run("Generic_FindByIDs", func(t *testing.T) {
+		ids := []any{"4", "999", "1", "4"}
+		result, err := mongox.FindByIDs[testEntity](ctx, coll, "id", ids)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(result) != len(ids) {
+			t.Fatalf("expected %d, got %d", len(ids), len(result))
+		}
+		if !reflect.DeepEqual(result[0], entities[3]) {
+			t.Errorf("expected %v, got %v", entities[3], result[0])
+		}
+		if !reflect.DeepEqual(result[1], testEntity{}) {
+			t.Errorf("expected zero value for missing id, got %v", result[1])
+		}
+		if !reflect.DeepEqual(result[2], entities[0]) {
+			t.Errorf("expected %v, got %v", entities[0], result[2])
+		}
+		if !reflect.DeepEqual(result[3], entities[3]) {
+			t.Errorf("expected %v, got %v", entities[3], result[3])
+		}
+
+		result, err = mongox.FindByIDs[testEntity](ctx, coll, "id", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(result) != 0 {
+			t.Errorf("expected empty result, got %v", result)
+		}
+
+		_, err = mongox.FindByIDs[testEntity](ctx, coll, "", ids)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+	})
+
+	t.Run("FindOne_ComplexFilters", func(t *testing.T) {
+		var result testEntity
+
+		// Test FindOne with $and operator
+		err := coll.FindOne(ctx, &result, mongox.M{
+			mongox.And: []mongox.M{
+				{"id": mongox.M{mongox.Gte: "2"}},
+				{"id": mongox.M{mongox.Lte: "3"}},
+			},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "2" && result.ID != "3" {
+			t.Errorf("expected ID '2' or '3', got '%s'", result.ID)
+		}
+
+		// Test FindOne with $or operator
+		err = coll.FindOne(ctx, &result, mongox.M{
+			mongox.Or: []mongox.M{
+				{"id": "1"},
+				{"id": "5"},
+			},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "1" && result.ID != "5" {
+			t.Errorf("expected ID '1' or '5', got '%s'", result.ID)
+		}
+
+		// Test FindOne with nested field
+		err = coll.FindOne(ctx, &result, mongox.M{
+			"struct.name": entities[0].Struct.Name,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.Struct.Name != entities[0].Struct.Name {
+			t.Errorf("expected struct name '%s', got '%s'", entities[0].Struct.Name, result.Struct.Name)
+		}
+
+		// Test FindOne with array field
+		err = coll.FindOne(ctx, &result, mongox.M{
+			"slice": entities[1].Slice,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if !reflect.DeepEqual(result.Slice, entities[1].Slice) {
+			t.Errorf("expected slice %v, got %v", entities[1].Slice, result.Slice)
+		}
+	})
+
+	t.Run("Find_OrAndFilter", func(t *testing.T) {
+		conditions := []mongox.M{{"id": "1"}, {"id": "3"}, {"id": "999"}}
+		result, err := mongox.Find[testEntity](ctx, coll, mongox.OrFilter(conditions...), mongox.FindOptions{
+			Sort: mongox.M{"id": mongox.Ascending},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(result) != 2 || result[0].ID != "1" || result[1].ID != "3" {
+			t.Errorf("unexpected result %v", result)
+		}
+
+		result, err = mongox.Find[testEntity](ctx, coll, mongox.AndFilter(
+			mongox.M{"id": mongox.M{mongox.Gte: "2"}},
+			mongox.M{"id": mongox.M{mongox.Lte: "3"}},
+		), mongox.FindOptions{Sort: mongox.M{"id": mongox.Ascending}})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(result) != 2 || result[0].ID != "2" || result[1].ID != "3" {
+			t.Errorf("unexpected result %v", result)
+		}
+
+		single := mongox.M{"id": "1"}
+		if f := mongox.OrFilter(single); !reflect.DeepEqual(f, single) {
+			t.Errorf("expected %v, got %v", single, f)
+		}
+		if f := mongox.AndFilter(); len(f) != 0 {
+			t.Errorf("expected empty filter, got %v", f)
+		}
+		n, err := coll.Count(ctx, mongox.OrFilter())
+		if err != nil {
+			t.Error(err)
+		}
+		if n != int64(len(entities)) {
+			t.Errorf("expected %d, got %d", len(entities), n)
+		}
+	})
+
+	t.Run("Generic_FindMap", func(t *testing.T) {
+		type dto struct {
+			Key   string
+			Title string
+		}
+		result, err := mongox.FindMap(ctx, coll, mongox.M{"id": mongox.M{mongox.In: []string{"1", "2"}}}, func(e testEntity) dto {
+			return dto{Key: "entity-" + e.ID, Title: e.Name}
+		}, mongox.FindOptions{Sort: mongox.M{"id": mongox.Ascending}})
+		if err != nil {
+			t.Error(err)
+		}
+		expected := []dto{{Key: "entity-1", Title: entities[0].Name}, {Key: "entity-2", Title: entities[1].Name}}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("expected %v, got %v", expected, result)
+		}
+
+		ids, err := mongox.FindMap(ctx, coll, mongox.M{"id": "not-found"}, func(e testEntity) string { return e.ID })
+		if err != nil {
+			t.Error(err)
+		}
+		if len(ids) != 0 {
+			t.Errorf("expected empty result, got %v", ids)
+		}
+
+		_, err = mongox.FindMap[testEntity, string](ctx, coll, nil, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+	})
+
+	t.Run("Find_ArrayHelpers", func(t *testing.T) {
+		arrays := db.Collection("array_helpers_test")
+		_, err := arrays.Insert(ctx,
+			mongox.M{"id": "empty", "tags": []string{}},
+			mongox.M{"id": "one", "tags": []string{"a"}},
+			mongox.M{"id": "two", "tags": []string{"a", "b"}},
+			mongox.M{"id": "missing"},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := func(filter mongox.M) []string {
+			var res []mongox.M
+			if err := arrays.Find(ctx, &res, filter, mongox.FindOptions{Sort: mongox.M{"id": mongox.Ascending}}); err != nil {
+				t.Error(err)
+			}
+			out := make([]string, 0, len(res))
+			for _, r := range res {
+				out = append(out, r["id"].(string))
+			}
+			return out
+		}
+
+		sizeTwo, err := mongox.ArraySize("tags", 2)
+		if err != nil {
+			t.Error(err)
+		}
+		if got := ids(sizeTwo); !reflect.DeepEqual(got, []string{"two"}) {
+			t.Errorf("expected %v, got %v", []string{"two"}, got)
+		}
+		sizeZero, err := mongox.ArraySize("tags", 0)
+		if err != nil {
+			t.Error(err)
+		}
+		if got := ids(sizeZero); !reflect.DeepEqual(got, []string{"empty"}) {
+			t.Errorf("expected %v, got %v", []string{"empty"}, got)
+		}
+		if got := ids(mongox.ArrayNotEmpty("tags")); !reflect.DeepEqual(got, []string{"one", "two"}) {
+			t.Errorf("expected %v, got %v", []string{"one", "two"}, got)
+		}
+		if got := ids(mongox.ArrayContains("tags", "b")); !reflect.DeepEqual(got, []string{"two"}) {
+			t.Errorf("expected %v, got %v", []string{"two"}, got)
+		}
+
+		_, err = mongox.ArraySize("tags", -1)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+	})
+
+	t.Run("Find_ArrayOfSubdocuments", func(t *testing.T) {
+		orders := db.Collection("array_subdocuments_test")
+		_, err := orders.Insert(ctx,
+			mongox.M{"id": "same", "items": []mongox.M{{"sku": "a", "qty": 5}}},
+			mongox.M{"id": "split", "items": []mongox.M{{"sku": "a", "qty": 1}, {"sku": "b", "qty": 5}}},
+			mongox.M{"id": "other", "items": []mongox.M{{"sku": "b", "qty": 1}}},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := func(filter mongox.M) []string {
+			var res []mongox.M
+			if err := orders.Find(ctx, &res, filter, mongox.FindOptions{Sort: mongox.M{"id": mongox.Ascending}}); err != nil {
+				t.Error(err)
+			}
+			out := make([]string, 0, len(res))
+			for _, r := range res {
+				out = append(out, r["id"].(string))
+			}
+			return out
+		}
+
+		if got := ids(mongox.ArrayFieldEquals("items", "sku", "a")); !reflect.DeepEqual(got, []string{"same", "split"}) {
+			t.Errorf("expected %v, got %v", []string{"same", "split"}, got)
+		}
+
+		// Dotted conditions may be satisfied by different elements
+		dotted := mongox.AndFilter(mongox.ArrayFieldEquals("items", "sku", "a"), mongox.ArrayFieldEquals("items", "qty", 5))
+		if got := ids(dotted); !reflect.DeepEqual(got, []string{"same", "split"}) {
+			t.Errorf("expected %v, got %v", []string{"same", "split"}, got)
+		}
+
+		// $elemMatch requires the same element to satisfy all conditions
+		correlated := mongox.ElemMatchFilter("items", mongox.M{"sku": "a", "qty": 5})
+		if got := ids(correlated); !reflect.DeepEqual(got, []string{"same"}) {
+			t.Errorf("expected %v, got %v", []string{"same"}, got)
+		}
+	})
+
+	t.Run("Find_PrefixMatch", func(t *testing.T) {
+		prefixes := db.Collection("prefix_match_test")
+		_, err := prefixes.Insert(ctx,
+			mongox.M{"id": "1", "version": "v1.2[beta]"},
+			mongox.M{"id": "2", "version": "v1x2[beta]"},
+			mongox.M{"id": "3", "version": "v1.2"},
+			mongox.M{"id": "4", "version": "xv1.2["},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		filter := mongox.PrefixMatch("version", "v1.2[")
+		if regex := filter["version"].(mongox.M)[mongox.Regex]; regex != `^v1\.2\[` {
+			t.Errorf("expected %v, got %v", `^v1\.2\[`, regex)
+		}
+
+		var res []mongox.M
+		if err := prefixes.Find(ctx, &res, filter); err != nil {
+			t.Error(err)
+		}
+		if len(res) != 1 || res[0]["id"] != "1" {
+			t.Errorf("expected only document 1, got %v", res)
+		}
+
+		n, err := prefixes.Count(ctx, mongox.PrefixMatch("version", "v1.2"))
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 2 {
+			t.Errorf("expected %d, got %d", 2, n)
+		}
+	})
+
+	t.Run("FindOne_DateRange", func(t *testing.T) {
+		var result testEntity
+
+		target := entities[2].Time
+		err := coll.FindOne(ctx, &result, mongox.DateRange("time", target, target).Add("id", entities[2].ID))
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != entities[2].ID {
+			t.Errorf("expected ID '%s', got '%s'", entities[2].ID, result.ID)
+		}
+
+		// Open-ended ranges
+		from := mongox.DateRange("time", target, time.Time{})
+		if !reflect.DeepEqual(from, mongox.M{"time": mongox.M{mongox.Gte: target}}) {
+			t.Errorf("unexpected filter %v", from)
+		}
+		to := mongox.DateRange("time", time.Time{}, target)
+		if !reflect.DeepEqual(to, mongox.M{"time": mongox.M{mongox.Lte: target}}) {
+			t.Errorf("unexpected filter %v", to)
+		}
+		all := mongox.DateRange("time", time.Time{}, time.Time{})
+		if len(all) != 0 {
+			t.Errorf("expected empty filter, got %v", all)
+		}
+
+		count, err := coll.Count(ctx, mongox.DateRange("time", time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}))
+		if err != nil {
+			t.Error(err)
+		}
+		if count != int64(len(entities)) {
+			t.Errorf("expected %d, got %d", len(entities), count)
+		}
+	})
+
+	t.Run("FindOne_FieldProjection", func(t *testing.T) {
+		// MongoDB doesn't support field projection directly in FindOne options in this wrapper,
+		// but we can test that we get full documents
+		var result testEntity
+		err := coll.FindOne(ctx, &result, mongox.M{"id": "1"})
+		if err != nil {
+			t.Error(err)
+		}
+
+		// Verify all fields are populated
+		if result.ID == "" {
+			t.Error("ID should not be empty")
+		}
+		if result.Name == "" {
+			t.Error("Name should not be empty")
+		}
+		if result.Number == 0 {
+			t.Error("Number should not be zero")
+		}
+		if len(result.Slice) == 0 {
+			t.Error("Slice should not be empty")
+		}
+	})
+
+	t.Run("Generic_FindOneProjected", func(t *testing.T) {
+		result, err := mongox.FindOneProjected[testEntity](ctx, coll, mongox.M{"id": "1"}, []string{"name", "struct.number", "inline_field"})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.Name != entities[0].Name || result.Struct.Number != entities[0].Struct.Number ||
+			result.InlineStruct.InlineField != entities[0].InlineStruct.InlineField {
+			t.Errorf("expected projected fields of %v, got %v", entities[0], result)
+		}
+		// Only projected fields are populated
+		if result.ID != "" || result.Number != 0 || result.Struct.Name != "" || len(result.Slice) != 0 {
+			t.Errorf("expected other fields to be empty, got %v", result)
+		}
+
+		doc, err := mongox.FindOneProjected[mongox.M](ctx, coll, mongox.M{"id": "1"}, []string{"any.field"})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(doc) != 1 || doc["_id"] == nil {
+			t.Errorf("expected only _id, got %v", doc)
+		}
+
+		// Json tags are used only with UseJSONStructTags, like the driver does
+		type jsonEntity struct {
+			Name string `json:"full_name"`
+		}
+		_, err = mongox.FindOneProjected[jsonEntity](ctx, coll, mongox.M{"id": "1"}, []string{"full_name"})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+		jsonColl := coll.WithBSONOptions(mongox.BSONOptions{UseJSONStructTags: true})
+		if _, err := mongox.FindOneProjected[jsonEntity](ctx, jsonColl, mongox.M{"id": "1"}, []string{"full_name"}); err != nil {
+			t.Error(err)
+		}
+
+		_, err = mongox.FindOneProjected[testEntity](ctx, coll, mongox.M{"id": "1"}, []string{"struct.unknown"})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+		_, err = mongox.FindOneProjected[testEntity](ctx, coll, mongox.M{"id": "1"}, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+		_, err = mongox.FindOneProjected[testEntity](ctx, coll, mongox.M{"id": "not-found"}, []string{"name"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
+	})
+
+	t.Run("FindOne_TypeSafety", func(t *testing.T) {
+		// Test that FindOne properly handles different destination types
+
+		// Test with map destination
+		var mapResult map[string]interface{}
+		err := coll.FindOne(ctx, &mapResult, mongox.M{"id": "1"})
+		if err != nil {
+			t.Error(err)
+		}
+		if mapResult["id"] != "1" {
+			t.Errorf("expected map ID '1', got '%v'", mapResult["id"])
+		}
+
+		// Test with bson.M destination
+		var bsonResult bson.M
+		err = coll.FindOne(ctx, &bsonResult, mongox.M{"id": "1"})
+		if err != nil {
+			t.Error(err)
+		}
+		if bsonResult["id"] != "1" {
+			t.Errorf("expected bson.M ID '1', got '%v'", bsonResult["id"])
+		}
+	})
+
+	// Cleanup
+	_, err = coll.DeleteMany(ctx, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFindOneAndMethods(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := client.Database(dbName)
+	coll := db.Collection("find_one_and_methods_test")
+
+	t.Run("ReplaceOrInsert", func(t *testing.T) {
+		entity := newTestEntity("replace_or_insert")
+		entity.Name = "created"
+
+		res, created, err := mongox.ReplaceOrInsert(ctx, coll, mongox.M{"id": "replace_or_insert"}, entity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !created {
+			t.Error("expected document to be created")
+		}
+		if res.ID != "replace_or_insert" || res.Name != "created" {
+			t.Errorf("expected %v, got %v", entity, res)
+		}
+
+		entity.Name = "replaced"
+		res, created, err = mongox.ReplaceOrInsert(ctx, coll, mongox.M{"id": "replace_or_insert"}, entity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created {
+			t.Error("expected document to be replaced")
+		}
+		if res.Name != "replaced" {
+			t.Errorf("expected %v, got %v", "replaced", res.Name)
+		}
+
+		count, err := coll.Count(ctx, mongox.M{"id": "replace_or_insert"})
+		if err != nil {
+			t.Error(err)
+		}
+		if count != 1 {
+			t.Errorf("expected %v, got %v", 1, count)
+		}
+
+		_, _, err = mongox.ReplaceOrInsert(ctx, coll, mongox.M{"id": "replace_or_insert"}, mongox.M{mongox.Set: mongox.M{"name": "x"}})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		// BSON options of the collection are used for the replacement and the result
+		type jsonEntity struct {
+			ID   string `json:"id"`
+			Name string `json:"full_name"`
+		}
+		jsonColl := coll.WithBSONOptions(mongox.BSONOptions{UseJSONStructTags: true})
+		jsonRes, created, err := mongox.ReplaceOrInsert(ctx, jsonColl, mongox.M{"id": "replace_or_insert_json"}, jsonEntity{ID: "replace_or_insert_json", Name: "json"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !created || jsonRes.Name != "json" {
+			t.Errorf("expected created document with name json, got %v, %v", created, jsonRes)
+		}
+		count, err = coll.Count(ctx, mongox.M{"full_name": "json"})
+		if err != nil {
+			t.Error(err)
+		}
+		if count != 1 {
+			t.Errorf("expected %v, got %v", 1, count)
+		}
+	})
+
+	t.Run("FindOneAndDelete", func(t *testing.T) {
+		// Setup test data
+		entity1 := newTestEntity("delete1")
+		entity2 := newTestEntity("delete2")
+		entity3 := newTestEntity("delete3")
+
+		_, err := coll.Insert(ctx, entity1, entity2, entity3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Test FindOneAndDelete - should return the deleted document
+		var deletedEntity testEntity
+		err = coll.FindOneAndDelete(ctx, &deletedEntity, mongox.M{"id": "delete1"})
+		if err != nil {
+			t.Error(err)
+		}
+		if deletedEntity.ID != "delete1" {
+			t.Errorf("expected deleted entity ID 'delete1', got '%s'", deletedEntity.ID)
+		}
+		if deletedEntity.Name != entity1.Name {
+			t.Errorf("expected deleted entity name '%s', got '%s'", entity1.Name, deletedEntity.Name)
+		}
+
+		// Verify the document was actually deleted
+		var checkEntity testEntity
+		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "delete1"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected ErrNotFound after deletion, got %v", err)
+		}
+
+		// Test generic FindOneAndDelete[T]
+		deletedEntity2, err := mongox.FindOneAndDelete[testEntity](ctx, coll, mongox.M{"id": "delete2"})
+		if err != nil {
+			t.Error(err)
+		}
+		if deletedEntity2.ID != "delete2" {
+			t.Errorf("expected deleted entity ID 'delete2', got '%s'", deletedEntity2.ID)
+		}
+
+		// Verify the document was actually deleted
+		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "delete2"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected ErrNotFound after deletion, got %v", err)
+		}
+
+		// Test FindOneAndDelete with non-existent document
+		var nonExistentEntity testEntity
+		err = coll.FindOneAndDelete(ctx, &nonExistentEntity, mongox.M{"id": "nonexistent"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for non-existent document, got %v", err)
+		}
+
+		// Test generic FindOneAndDelete[T] with non-existent document
+		_, err = mongox.FindOneAndDelete[testEntity](ctx, coll, mongox.M{"id": "nonexistent"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for non-existent document, got %v", err)
+		}
+
+		// Test FindOneAndDelete with complex filter
+		err = coll.FindOneAndDelete(ctx, &deletedEntity, mongox.M{
+			"id": mongox.M{mongox.In: []string{"delete3", "delete4"}},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if deletedEntity.ID != "delete3" {
+			t.Errorf("expected deleted entity ID 'delete3', got '%s'", deletedEntity.ID)
+		}
+
+		// Cleanup remaining test data
+		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.Regex: "delete"}})
+	})
+
+	t.Run("FindOneAndDeleteIf", func(t *testing.T) {
+		jobs := make([]any, 0, 3)
+		for _, id := range []string{"job2", "job1", "job3"} {
+			entity := newTestEntity(id)
+			entity.Name = "queue"
+			jobs = append(jobs, entity)
+		}
+		if _, err := coll.InsertMany(ctx, jobs); err != nil {
+			t.Fatal(err)
+		}
+
+		// Pop in FIFO order by id
+		for _, expected := range []string{"job1", "job2", "job3"} {
+			job, err := mongox.FindOneAndDeleteIf[testEntity](ctx, coll, mongox.M{"name": "queue"}, []mongox.M{{"id": mongox.Ascending}})
+			if err != nil {
+				t.Error(err)
+			}
+			if job.ID != expected {
+				t.Errorf("expected ID '%s', got '%s'", expected, job.ID)
+			}
+		}
+
+		_, err := mongox.FindOneAndDeleteIf[testEntity](ctx, coll, mongox.M{"name": "queue"}, nil)
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for empty queue, got %v", err)
+		}
+	})
+
+	t.Run("FindOneAndReplace", func(t *testing.T) {
+		// Setup test data
+		originalEntity := newTestEntity("replace1")
+		entity2 := newTestEntity("replace2")
+
+		_, err := coll.Insert(ctx, originalEntity, entity2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create replacement entity
+		replacementEntity := newTestEntity("replace1") // Same ID but different data
+		replacementEntity.Name = "replaced-name"
+		replacementEntity.Number = 99999
+
+		// Test FindOneAndReplace - should return the original document
+		var returnedEntity testEntity
+		err = coll.FindOneAndReplace(ctx, &returnedEntity, mongox.M{"id": "replace1"}, replacementEntity)
+		if err != nil {
+			t.Error(err)
+		}
+		if returnedEntity.ID != "replace1" {
+			t.Errorf("expected returned entity ID 'replace1', got '%s'", returnedEntity.ID)
+		}
+		if returnedEntity.Name != originalEntity.Name {
+			t.Errorf("expected returned entity to be original, got name '%s', expected '%s'", returnedEntity.Name, originalEntity.Name)
+		}
+
+		// Verify the document was actually replaced
+		var checkEntity testEntity
+		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "replace1"})
+		if err != nil {
+			t.Error(err)
+		}
+		if checkEntity.Name != "replaced-name" {
+			t.Errorf("expected replaced entity name 'replaced-name', got '%s'", checkEntity.Name)
+		}
+		if checkEntity.Number != 99999 {
+			t.Errorf("expected replaced entity number 99999, got %d", checkEntity.Number)
+		}
+
+		// Test generic FindOneAndReplace[T]
+		anotherReplacement := newTestEntity("replace2")
+		anotherReplacement.Name = "another-replaced-name"
+
+		returnedEntity2, err := mongox.FindOneAndReplace[testEntity](ctx, coll, mongox.M{"id": "replace2"}, anotherReplacement)
+		if err != nil {
+			t.Error(err)
+		}
+		if returnedEntity2.ID != "replace2" {
+			t.Errorf("expected returned entity ID 'replace2', got '%s'", returnedEntity2.ID)
+		}
+		if returnedEntity2.Name == "another-replaced-name" {
+			t.Error("returned entity should be the original, not the replacement")
+		}
+
+		// Verify the document was actually replaced
+		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "replace2"})
+		if err != nil {
+			t.Error(err)
+		}
+		if checkEntity.Name != "another-replaced-name" {
+			t.Errorf("expected replaced entity name 'another-replaced-name', got '%s'", checkEntity.Name)
+		}
+
+		// Test FindOneAndReplace with non-existent document
+		var nonExistentEntity testEntity
+		err = coll.FindOneAndReplace(ctx, &nonExistentEntity, mongox.M{"id": "nonexistent"}, newTestEntity("nonexistent"))
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for non-existent document, got %v", err)
+		}
+
+		// Test generic FindOneAndReplace[T] with non-existent document
+		_, err = mongox.FindOneAndReplace[testEntity](ctx, coll, mongox.M{"id": "nonexistent"}, newTestEntity("nonexistent"))
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for non-existent document, got %v", err)
+		}
+
+		// Cleanup test data
+		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.Regex: "replace"}})
+	})
+
+	t.Run("FindOneAndUpdate", func(t *testing.T) {
+		// Setup test data
+		originalEntity := newTestEntity("update1")
+		entity2 := newTestEntity("update2")
+		entity3 := newTestEntity("update3")
+
+		_, err := coll.Insert(ctx, originalEntity, entity2, entity3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Test FindOneAndUpdate with $set operator - should return original document
+		var returnedEntity testEntity
+		err = coll.FindOneAndUpdate(ctx, &returnedEntity, mongox.M{"id": "update1"}, mongox.M{
+			mongox.Set: mongox.M{
+				"name":   "updated-name",
+				"number": 12345,
+			},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if returnedEntity.ID != "update1" {
+			t.Errorf("expected returned entity ID 'update1', got '%s'", returnedEntity.ID)
+		}
+		if returnedEntity.Name != originalEntity.Name {
+			t.Errorf("expected returned entity to be original, got name '%s', expected '%s'", returnedEntity.Name, originalEntity.Name)
+		}
+
+		// Verify the document was actually updated
+		var checkEntity testEntity
+		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "update1"})
+		if err != nil {
+			t.Error(err)
+		}
+		if checkEntity.Name != "updated-name" {
+			t.Errorf("expected updated entity name 'updated-name', got '%s'", checkEntity.Name)
+		}
+		if checkEntity.Number != 12345 {
+			t.Errorf("expected updated entity number 12345, got %d", checkEntity.Number)
+		}
+
+		// Test FindOneAndUpdate with $inc operator
+		err = coll.FindOneAndUpdate(ctx, &returnedEntity, mongox.M{"id": "update1"}, mongox.M{
+			mongox.Inc: mongox.M{"number": 100},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if returnedEntity.Number != 12345 {
+			t.Errorf("expected returned entity to have original number 12345, got %d", returnedEntity.Number)
+		}
+
+		// Verify the increment was applied
+		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "update1"})
+		if err != nil {
+			t.Error(err)
+		}
+		if checkEntity.Number != 12445 {
+			t.Errorf("expected incremented number 12445, got %d", checkEntity.Number)
+		}
+
+		// Test generic FindOneAndUpdate[T]
+		returnedEntity2, err := mongox.FindOneAndUpdate[testEntity](ctx, coll, mongox.M{"id": "update2"}, mongox.M{
+			mongox.Set: mongox.M{"name": "generic-updated-name"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if returnedEntity2.ID != "update2" {
+			t.Errorf("expected returned entity ID 'update2', got '%s'", returnedEntity2.ID)
+		}
+		if returnedEntity2.Name == "generic-updated-name" {
+			t.Error("returned entity should be the original, not the updated version")
+		}
+
+		// Verify the document was actually updated
+		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "update2"})
+		if err != nil {
+			t.Error(err)
+		}
+		if checkEntity.Name != "generic-updated-name" {
+			t.Errorf("expected updated entity name 'generic-updated-name', got '%s'", checkEntity.Name)
+		}
+
+		// Test FindOneAndUpdate with complex update using multiple operators
+		err = coll.FindOneAndUpdate(ctx, &returnedEntity, mongox.M{"id": "update3"}, mongox.M{
+			mongox.Set:  mongox.M{"name": "complex-updated"},
+			mongox.Inc:  mongox.M{"number": 500},
+			mongox.Push: mongox.M{"slice": 999},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+
+		// Verify complex update was applied
+		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "update3"})
+		if err != nil {
+			t.Error(err)
+		}
+		if checkEntity.Name != "complex-updated" {
+			t.Errorf("expected updated entity name 'complex-updated', got '%s'", checkEntity.Name)
+		}
+		if checkEntity.Number != entity3.Number+500 {
+			t.Errorf("expected incremented number %d, got %d", entity3.Number+500, checkEntity.Number)
+		}
+		found999 := false
+		for _, val := range checkEntity.Slice {
+			if val == 999 {
+				found999 = true
+				break
+			}
+		}
+		if !found999 {
+			t.Error("expected 999 to be pushed to slice")
+		}
+
+		// Test FindOneAndUpdate with non-existent document
+		var nonExistentEntity testEntity
+		err = coll.FindOneAndUpdate(ctx, &nonExistentEntity, mongox.M{"id": "nonexistent"}, mongox.M{
+			mongox.Set: mongox.M{"name": "wont-work"},
+		})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for non-existent document, got %v", err)
+		}
+
+		// Test generic FindOneAndUpdate[T] with non-existent document
+		_, err = mongox.FindOneAndUpdate[testEntity](ctx, coll, mongox.M{"id": "nonexistent"}, mongox.M{
+			mongox.Set: mongox.M{"name": "wont-work"},
+		})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for non-existent document, got %v", err)
+		}
+
+		// Test FindOneAndUpdate with invalid update document
+		err = coll.FindOneAndUpdate(ctx, &returnedEntity, mongox.M{"id": "update1"}, mongox.M{
+			"invalid": "no-dollar-operator",
+		})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument for invalid update document, got %v", err)
+		}
+
+		// Cleanup test data
+		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.Regex: "update"}})
+	})
+
+	t.Run("FindOneAnd_Options", func(t *testing.T) {
+		for i, id := range []string{"opts2", "opts1", "opts3"} {
+			entity := newTestEntity(id)
+			entity.Name = "opts"
+			entity.Number = i
+			if _, err := coll.Insert(ctx, entity); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Sort and projection
+		var updated testEntity
+		err := coll.FindOneAndUpdate(ctx, &updated, mongox.M{"name": "opts"}, mongox.M{mongox.Set: mongox.M{"number": 100}}, mongox.FindOneAndOptions{
+			Sort:          []mongox.M{{"id": mongox.Descending}},
+			Projection:    mongox.M{"id": 1, "number": 1},
+			ReturnUpdated: true,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if updated.ID != "opts3" {
+			t.Errorf("expected ID 'opts3', got '%s'", updated.ID)
+		}
+		if updated.Number != 100 {
+			t.Errorf("expected updated number 100, got %d", updated.Number)
+		}
+		if updated.Name != "" {
+			t.Errorf("expected name to be excluded by projection, got '%s'", updated.Name)
+		}
+
+		// Upsert with ReturnUpdated returns the inserted document
+		upserted, err := mongox.FindOneAndUpdate[testEntity](ctx, coll, mongox.M{"id": "opts4"}, mongox.M{mongox.Set: mongox.M{"name": "opts"}}, mongox.FindOneAndOptions{
+			Upsert:        true,
+			ReturnUpdated: true,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if upserted.ID != "opts4" || upserted.Name != "opts" {
+			t.Errorf("expected upserted entity 'opts4', got %+v", upserted)
+		}
+
+		// Replace returns the replaced document
+		replacement := newTestEntity("opts1")
+		replacement.Name = "opts-replaced"
+		replaced, err := mongox.FindOneAndReplace[testEntity](ctx, coll, mongox.M{"id": "opts1"}, replacement, mongox.FindOneAndOptions{ReturnUpdated: true})
+		if err != nil {
+			t.Error(err)
+		}
+		if replaced.Name != "opts-replaced" {
+			t.Errorf("expected replaced name 'opts-replaced', got '%s'", replaced.Name)
+		}
+
+		// Original document is returned by default
+		original, err := mongox.FindOneAndUpdate[testEntity](ctx, coll, mongox.M{"id": "opts4"}, mongox.M{mongox.Inc: mongox.M{"number": 1}})
+		if err != nil {
+			t.Error(err)
+		}
+		if original.Number != upserted.Number {
+			t.Errorf("expected original number %d, got %d", upserted.Number, original.Number)
+		}
+		counter, err := mongox.FindOneAndUpdate[testEntity](ctx, coll, mongox.M{"id": "opts4"}, mongox.M{mongox.Inc: mongox.M{"number": 1}}, mongox.FindOneAndOptions{
+			ReturnUpdated: true,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if counter.Number != upserted.Number+2 {
+			t.Errorf("expected number %d, got %d", upserted.Number+2, counter.Number)
+		}
+
+		// Replace with upsert inserts the replacement, the original doesn't exist without ReturnUpdated
+		inserted := newTestEntity("opts5")
+		inserted.Name = "opts"
+		_, err = mongox.FindOneAndReplace[testEntity](ctx, coll, mongox.M{"id": "opts5"}, inserted, mongox.FindOneAndOptions{Upsert: true})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
+		count, err := coll.Count(ctx, mongox.M{"id": "opts5"})
+		if err != nil {
+			t.Error(err)
+		}
+		if count != 1 {
+			t.Errorf("expected %v, got %v", 1, count)
+		}
+
+		// Delete chooses the document by sort
+		deleted, err := mongox.FindOneAndDelete[testEntity](ctx, coll, mongox.M{"name": "opts"}, mongox.FindOneAndOptions{
+			Sort: []mongox.M{{"id": mongox.Ascending}},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if deleted.ID != "opts2" {
+			t.Errorf("expected ID 'opts2', got '%s'", deleted.ID)
+		}
+
+		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.Regex: "opts"}})
+	})
+
+	t.Run("FindOneAnd_ErrorHandling", func(t *testing.T) {
+		// Test error handling for all FindOneAnd methods
+
+		// Test with nil destination - should get ErrNotFound since document doesn't exist
+		err := coll.FindOneAndDelete(ctx, nil, mongox.M{"id": "test"})
+		if err == nil {
+			t.Error("expected error for nil destination in FindOneAndDelete")
+		}
+
+		err = coll.FindOneAndReplace(ctx, nil, mongox.M{"id": "test"}, newTestEntity("test"))
+		if err == nil {
+			t.Error("expected error for nil destination in FindOneAndReplace")
+		}
+
+		err = coll.FindOneAndUpdate(ctx, nil, mongox.M{"id": "test"}, mongox.M{mongox.Set: mongox.M{"name": "test"}})
+		if err == nil {
+			t.Error("expected error for nil destination in FindOneAndUpdate")
+		}
+
+		// Test with non-pointer destination - should get error
+		var entity testEntity
+		err = coll.FindOneAndDelete(ctx, entity, mongox.M{"id": "test"})
+		if err == nil {
+			t.Error("expected error for non-pointer destination in FindOneAndDelete")
+		}
+
+		err = coll.FindOneAndReplace(ctx, entity, mongox.M{"id": "test"}, newTestEntity("test"))
+		if err == nil {
+			t.Error("expected error for non-pointer destination in FindOneAndReplace")
+		}
+
+		err = coll.FindOneAndUpdate(ctx, entity, mongox.M{"id": "test"}, mongox.M{mongox.Set: mongox.M{"name": "test"}})
+		if err == nil {
+			t.Error("expected error for non-pointer destination in FindOneAndUpdate")
+		}
+	})
+
+	t.Run("FindOneAnd_TypeSafety", func(t *testing.T) {
+		// Setup test data
+		testEntity := newTestEntity("typesafety")
+		_, err := coll.Insert(ctx, testEntity)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Test with map destination
+		var mapResult map[string]interface{}
+		err = coll.FindOneAndDelete(ctx, &mapResult, mongox.M{"id": "typesafety"})
+		if err != nil {
+			t.Error(err)
+		}
+		if mapResult["id"] != "typesafety" {
+			t.Errorf("expected map ID 'typesafety', got '%v'", mapResult["id"])
+		}
+
+		// Re-insert for next test
+		_, err = coll.Insert(ctx, testEntity)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Test with bson.M destination
+		var bsonResult bson.M
+		replacementMap := map[string]interface{}{
+			"id":     "typesafety",
+			"name":   "replaced-via-map",
+			"number": 777,
+		}
+		err = coll.FindOneAndReplace(ctx, &bsonResult, mongox.M{"id": "typesafety"}, replacementMap)
+		if err != nil {
+			t.Error(err)
+		}
+		if bsonResult["id"] != "typesafety" {
+			t.Errorf("expected bson.M ID 'typesafety', got '%v'", bsonResult["id"])
+		}
+
+		// Cleanup
+		_, _ = coll.DeleteMany(ctx, mongox.M{"id": "typesafety"})
+	})
+
+	// Final cleanup
+	_, err := coll.DeleteMany(ctx, nil)
+	if err != nil && !errors.Is(err, mongox.ErrNotFound) {
+		t.Error(err)
 	}
+}
 
-	t.Run("FindOne_BasicFilters", func(t *testing.T) {
-		// Test FindOne with basic filters
+func TestInsertMethods(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := client.Database(dbName)
+	coll := db.Collection("insert_methods_test")
+
+	t.Run("InsertOne_Basic", func(t *testing.T) {
+		// Test basic InsertOne functionality
+		entity := newTestEntity("insertone1")
+
+		id, err := coll.InsertOne(ctx, entity)
+		if err != nil {
+			t.Error(err)
+		}
+		if id.IsZero() {
+			t.Error("expected non-zero ObjectID")
+		}
+
+		// Verify the document was inserted
 		var result testEntity
-		err := coll.FindOne(ctx, &result, mongox.M{"id": "1"})
+		err = coll.FindOne(ctx, &result, mongox.M{"id": "insertone1"})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "insertone1" {
+			t.Errorf("expected ID 'insertone1', got '%s'", result.ID)
+		}
+
+		// Test generic InsertOne
+		entity2 := newTestEntity("insertone2")
+		id2, err := mongox.InsertOne(ctx, coll, entity2)
+		if err != nil {
+			t.Error(err)
+		}
+		if id2.IsZero() {
+			t.Error("expected non-zero ObjectID from generic InsertOne")
+		}
+
+		// Verify the document was inserted
+		err = coll.FindOne(ctx, &result, mongox.M{"id": "insertone2"})
+		if err != nil {
+			t.Error(err)
+		}
+		if result.ID != "insertone2" {
+			t.Errorf("expected ID 'insertone2', got '%s'", result.ID)
+		}
+
+		// Cleanup
+		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"insertone1", "insertone2"}}})
+	})
+
+	t.Run("InsertOne_StrictID", func(t *testing.T) {
+		// Test InsertOne with strict ID validation enabled
+		entity := newTestEntity("strictone1")
+
+		id, err := coll.InsertOne(ctx, entity, true)
+		if err != nil {
+			t.Error(err)
+		}
+		if id.IsZero() {
+			t.Error("expected non-zero ObjectID with strict validation")
+		}
+
+		// Test generic InsertOne with strict validation
+		entity2 := newTestEntity("strictone2")
+		id2, err := mongox.InsertOne(ctx, coll, entity2, true)
+		if err != nil {
+			t.Error(err)
+		}
+		if id2.IsZero() {
+			t.Error("expected non-zero ObjectID from generic InsertOne with strict validation")
+		}
+
+		// Cleanup
+		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"strictone1", "strictone2"}}})
+	})
+
+	t.Run("InsertOne_WithCustomID", func(t *testing.T) {
+		// Test InsertOne with a document that has a custom string ID
+		// This should succeed in non-strict mode but return empty ObjectID
+		entityWithCustomID := struct {
+			ID   string `bson:"_id"`
+			Name string `bson:"name"`
+		}{
+			ID:   "custom-string-id",
+			Name: "test-entity",
+		}
+
+		id, err := coll.InsertOne(ctx, entityWithCustomID)
+		if err != nil {
+			t.Error(err)
+		}
+		if !id.IsZero() {
+			t.Error("expected zero ObjectID when using custom string ID")
+		}
+
+		// Test with strict validation - should fail
+		entityWithCustomID2 := struct {
+			ID   string `bson:"_id"`
+			Name string `bson:"name"`
+		}{
+			ID:   "custom-string-id-2",
+			Name: "test-entity-2",
+		}
+
+		_, err = coll.InsertOne(ctx, entityWithCustomID2, true)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument with strict validation and custom string ID, got %v", err)
+		}
+
+		// Cleanup
+		_ = coll.DeleteOne(ctx, mongox.M{"_id": "custom-string-id"})
+	})
+
+	t.Run("InsertOne_ErrorHandling", func(t *testing.T) {
+		// Test InsertOne with nil record
+		_, err := coll.InsertOne(ctx, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument for nil record, got %v", err)
+		}
+
+		// Test InsertOne with invalid record type
+		_, err = coll.InsertOne(ctx, func() {})
+		if err == nil {
+			t.Error("expected error for invalid record type")
+		}
+
+		// Test duplicate key error with custom _id field
+		entityWithFixedID := struct {
+			ID   string `bson:"_id"`
+			Name string `bson:"name"`
+		}{
+			ID:   "fixed-duplicate-test",
+			Name: "test-entity",
+		}
+
+		_, err = coll.InsertOne(ctx, entityWithFixedID)
+		if err != nil {
+			t.Error(err)
+		}
+
+		// Try to insert the same entity again (should fail due to duplicate _id)
+		_, err = coll.InsertOne(ctx, entityWithFixedID)
+		if !errors.Is(err, mongox.ErrDuplicate) {
+			t.Errorf("expected ErrDuplicate for duplicate _id, got %v", err)
+		}
+
+		// Cleanup
+		_ = coll.DeleteOne(ctx, mongox.M{"_id": "fixed-duplicate-test"})
+	})
+
+	t.Run("Insert_Basic", func(t *testing.T) {
+		// Test basic Insert functionality (non-strict by default)
+		entity1 := newTestEntity("insert1")
+		entity2 := newTestEntity("insert2")
+		entity3 := newTestEntity("insert3")
+
+		ids, err := coll.Insert(ctx, entity1, entity2, entity3)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(ids) != 3 {
+			t.Errorf("expected 3 IDs, got %d", len(ids))
+		}
+		for i, id := range ids {
+			if id.IsZero() {
+				t.Errorf("expected non-zero ObjectID at index %d", i)
+			}
+		}
+
+		// Verify all documents were inserted
+		var results []testEntity
+		err = coll.Find(ctx, &results, mongox.M{"id": mongox.M{mongox.In: []string{"insert1", "insert2", "insert3"}}})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(results) != 3 {
+			t.Errorf("expected 3 documents found, got %d", len(results))
+		}
+
+		// Test generic Insert
+		entity4 := newTestEntity("insert4")
+		entity5 := newTestEntity("insert5")
+
+		ids2, err := mongox.Insert(ctx, coll, entity4, entity5)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(ids2) != 2 {
+			t.Errorf("expected 2 IDs from generic Insert, got %d", len(ids2))
+		}
+
+		// Cleanup
+		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"insert1", "insert2", "insert3", "insert4", "insert5"}}})
+	})
+
+	t.Run("Insert_WithCustomIDs", func(t *testing.T) {
+		// Test Insert with custom string IDs (non-strict mode)
+		entitiesWithCustomIDs := []any{
+			struct {
+				ID   string `bson:"_id"`
+				Name string `bson:"name"`
+			}{
+				ID:   "custom-1",
+				Name: "entity-1",
+			},
+			struct {
+				ID   string `bson:"_id"`
+				Name string `bson:"name"`
+			}{
+				ID:   "custom-2",
+				Name: "entity-2",
+			},
+		}
+
+		ids, err := coll.Insert(ctx, entitiesWithCustomIDs...)
 		if err != nil {
 			t.Error(err)
 		}
-		if result.ID != "1" {
-			t.Errorf("expected ID '1', got '%s'", result.ID)
+		if len(ids) != 2 {
+			t.Errorf("expected 2 IDs, got %d", len(ids))
+		}
+		// With custom string IDs, the returned ObjectIDs should be zero
+		for i, id := range ids {
+			if !id.IsZero() {
+				t.Errorf("expected zero ObjectID at index %d when using custom string ID", i)
+			}
 		}
 
-		// Test FindOne with complex filter
-		err = coll.FindOne(ctx, &result, mongox.M{"id": mongox.M{mongox.In: []string{"2", "3"}}})
+		// Verify documents were inserted
+		count, err := coll.Count(ctx, mongox.M{"_id": mongox.M{mongox.In: []string{"custom-1", "custom-2"}}})
 		if err != nil {
 			t.Error(err)
 		}
-		if result.ID != "2" && result.ID != "3" {
-			t.Errorf("expected ID '2' or '3', got '%s'", result.ID)
+		if count != 2 {
+			t.Errorf("expected 2 documents with custom IDs, got %d", count)
 		}
 
-		// Test FindOne with no filter (should return any document)
-		err = coll.FindOne(ctx, &result, nil)
+		// Cleanup
+		_, _ = coll.DeleteMany(ctx, mongox.M{"_id": mongox.M{mongox.In: []string{"custom-1", "custom-2"}}})
+	})
+
+	t.Run("Generic_InsertTyped", func(t *testing.T) {
+		ids, err := mongox.InsertTyped[string](ctx, coll, mongox.M{"_id": "typed-1"}, mongox.M{"_id": "typed-2"})
 		if err != nil {
 			t.Error(err)
 		}
-		if result.ID == "" {
-			t.Error("expected non-empty ID")
+		if !reflect.DeepEqual(ids, []string{"typed-1", "typed-2"}) {
+			t.Errorf("expected %v, got %v", []string{"typed-1", "typed-2"}, ids)
 		}
-	})
 
-	t.Run("FindOne_WithOptions", func(t *testing.T) {
-		var result testEntity
+		// int is converted to int64
+		intIDs, err := mongox.InsertTyped[int64](ctx, coll, mongox.M{"_id": 1001})
+		if err != nil {
+			t.Error(err)
+		}
+		if !reflect.DeepEqual(intIDs, []int64{1001}) {
+			t.Errorf("expected %v, got %v", []int64{1001}, intIDs)
+		}
 
-		// Test FindOne with Skip option
-		err := coll.FindOne(ctx, &result, nil, mongox.FindOptions{
-			Skip: 2,
-			Sort: mongox.M{"id": 1},
-		})
+		uuid := bson.Binary{Subtype: bson.TypeBinaryUUID, Data: []byte("0123456789abcdef")}
+		uuidIDs, err := mongox.InsertTyped[bson.Binary](ctx, coll, mongox.M{"_id": uuid})
 		if err != nil {
 			t.Error(err)
 		}
-		if result.ID != "3" {
-			t.Errorf("expected ID '3' with skip=2, got '%s'", result.ID)
+		if len(uuidIDs) != 1 || !reflect.DeepEqual(uuidIDs[0], uuid) {
+			t.Errorf("expected %v, got %v", uuid, uuidIDs)
 		}
 
-		// Test FindOne with Sort option (ascending)
-		err = coll.FindOne(ctx, &result, nil, mongox.FindOptions{
-			Sort: mongox.M{"id": mongox.Ascending},
-		})
+		// Generated ObjectID cannot be converted to int64
+		_, err = mongox.InsertTyped[int64](ctx, coll, mongox.M{"name": "typed-generated"})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		// Cleanup
+		_, _ = coll.DeleteMany(ctx, mongox.M{"_id": mongox.M{mongox.In: []any{"typed-1", "typed-2", 1001, uuid}}})
+		_, _ = coll.DeleteMany(ctx, mongox.M{"name": "typed-generated"})
+	})
+
+	t.Run("Generic_InsertAndRead", func(t *testing.T) {
+		entity := newTestEntity("read-after-write")
+		result, err := mongox.InsertAndRead(ctx, coll, entity, mongox.M{"id": entity.ID})
 		if err != nil {
 			t.Error(err)
 		}
-		if result.ID != "1" {
-			t.Errorf("expected ID '1' with ascending sort, got '%s'", result.ID)
+		if !reflect.DeepEqual(entity, result) {
+			t.Errorf("expected %v, got %v", entity, result)
 		}
 
-		// Test FindOne with Sort option (descending)
-		err = coll.FindOne(ctx, &result, nil, mongox.FindOptions{
-			Sort: mongox.M{"id": mongox.Descending},
-		})
+		// Empty filter reads the document by inserted _id
+		doc, err := mongox.InsertAndRead(ctx, coll, mongox.M{"name": "read-by-id"}, nil)
 		if err != nil {
 			t.Error(err)
 		}
-		if result.ID != "5" {
-			t.Errorf("expected ID '5' with descending sort, got '%s'", result.ID)
+		if doc["name"] != "read-by-id" || doc["_id"] == nil {
+			t.Errorf("expected inserted document, got %v", doc)
 		}
 
-		// Test FindOne with SortMany option
-		err = coll.FindOne(ctx, &result, nil, mongox.FindOptions{
-			SortMany: []mongox.M{
-				{"id": mongox.Descending},
-				{"name": mongox.Ascending},
-			},
-		})
+		_, err = mongox.InsertAndRead(ctx, coll, mongox.M{"name": "read-not-matched"}, mongox.M{"name": "not-found"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
+
+		// Cleanup
+		_, _ = coll.DeleteMany(ctx, mongox.M{"id": entity.ID})
+		_, _ = coll.DeleteMany(ctx, mongox.M{"name": mongox.M{mongox.In: []string{"read-by-id", "read-not-matched"}}})
+	})
+
+	t.Run("InsertStrict_Basic", func(t *testing.T) {
+		// Test InsertStrict functionality
+		entity1 := newTestEntity("strict1")
+		entity2 := newTestEntity("strict2")
+
+		ids, err := coll.InsertStrict(ctx, entity1, entity2)
 		if err != nil {
 			t.Error(err)
 		}
-		if result.ID != "5" {
-			t.Errorf("expected ID '5' with SortMany descending, got '%s'", result.ID)
+		if len(ids) != 2 {
+			t.Errorf("expected 2 IDs from InsertStrict, got %d", len(ids))
+		}
+		for i, id := range ids {
+			if id.IsZero() {
+				t.Errorf("expected non-zero ObjectID at index %d from InsertStrict", i)
+			}
 		}
 
-		// Test that Sort has priority over SortMany
-		err = coll.FindOne(ctx, &result, nil, mongox.FindOptions{
-			Sort: mongox.M{"id": mongox.Ascending},
-			SortMany: []mongox.M{
-				{"id": mongox.Descending},
-			},
-		})
+		// Test generic InsertStrict
+		entity3 := newTestEntity("strict3")
+		ids2, err := mongox.InsertStrict(ctx, coll, entity3)
 		if err != nil {
 			t.Error(err)
 		}
-		if result.ID != "1" {
-			t.Errorf("expected ID '1' (Sort should override SortMany), got '%s'", result.ID)
+		if len(ids2) != 1 {
+			t.Errorf("expected 1 ID from generic InsertStrict, got %d", len(ids2))
+		}
+		if ids2[0].IsZero() {
+			t.Error("expected non-zero ObjectID from generic InsertStrict")
 		}
 
-		// Test FindOne with AllowPartialResults option
-		err = coll.FindOne(ctx, &result, mongox.M{"id": "1"}, mongox.FindOptions{
-			AllowPartialResults: true,
-		})
+		// Verify all documents were inserted
+		var results []testEntity
+		err = coll.Find(ctx, &results, mongox.M{"id": mongox.M{mongox.In: []string{"strict1", "strict2", "strict3"}}})
 		if err != nil {
 			t.Error(err)
 		}
-		if result.ID != "1" {
-			t.Errorf("expected ID '1' with AllowPartialResults, got '%s'", result.ID)
+		if len(results) != 3 {
+			t.Errorf("expected 3 documents found, got %d", len(results))
 		}
+
+		// Cleanup
+		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"strict1", "strict2", "strict3"}}})
 	})
 
-	t.Run("FindOne_ErrorCases", func(t *testing.T) {
-		var result testEntity
+	t.Run("InsertStrict_WithCustomIDs", func(t *testing.T) {
+		// First cleanup any potentially existing documents with these IDs
+		_, _ = coll.DeleteMany(ctx, mongox.M{"_id": mongox.M{mongox.In: []string{"strict-custom-unique-1", "strict-custom-unique-2"}}})
 
-		// Test FindOne with non-existent document
-		err := coll.FindOne(ctx, &result, mongox.M{"id": "999"})
-		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected ErrNotFound, got %v", err)
+		// Test InsertStrict with custom string IDs - should fail
+		entitiesWithCustomIDs := []any{
+			struct {
+				ID   string `bson:"_id"`
+				Name string `bson:"name"`
+			}{
+				ID:   "strict-custom-unique-1",
+				Name: "entity-1",
+			},
+			struct {
+				ID   string `bson:"_id"`
+				Name string `bson:"name"`
+			}{
+				ID:   "strict-custom-unique-2",
+				Name: "entity-2",
+			},
 		}
 
-		// Test FindOne with invalid filter that won't match anything
-		err = coll.FindOne(ctx, &result, mongox.M{"nonexistent_field": "value"})
-		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected ErrNotFound for non-matching filter, got %v", err)
+		_, err := coll.InsertStrict(ctx, entitiesWithCustomIDs...)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument with InsertStrict and custom string IDs, got %v", err)
 		}
 
-		// Test FindOne with nil destination (should error)
-		err = coll.FindOne(ctx, nil, mongox.M{"id": "1"})
+		// Test generic InsertStrict with custom ID (use a different ID)
+		singleEntityWithCustomID := struct {
+			ID   string `bson:"_id"`
+			Name string `bson:"name"`
+		}{
+			ID:   "strict-generic-unique-1",
+			Name: "entity-generic",
+		}
+
+		_, err = mongox.InsertStrict(ctx, coll, singleEntityWithCustomID)
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected ErrInvalidArgument for nil destination, got %v", err)
+			t.Errorf("expected ErrInvalidArgument with generic InsertStrict and custom string ID, got %v", err)
 		}
+	})
 
-		// Test FindOne with non-pointer destination (should error)
-		err = coll.FindOne(ctx, result, mongox.M{"id": "1"})
+	t.Run("Insert_ErrorHandling", func(t *testing.T) {
+		// Test Insert with nil records
+		_, err := coll.Insert(ctx)
+		if err != nil {
+			t.Error("Insert with no records should not error")
+		}
+
+		_, err = coll.Insert(ctx, nil)
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected ErrInvalidArgument for non-pointer destination, got %v", err)
+			t.Errorf("expected ErrInvalidArgument for nil record in Insert, got %v", err)
+		}
+
+		// Test Insert with mixed valid and invalid records
+		entity := newTestEntity("mixed")
+		_, err = coll.Insert(ctx, entity, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument for mixed valid/nil records, got %v", err)
+		}
+
+		// Test InsertStrict with nil records
+		_, err = coll.InsertStrict(ctx, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument for nil record in InsertStrict, got %v", err)
 		}
 	})
 
-	t.Run("Generic_FindOne", func(t *testing.T) {
-		// Test generic FindOne[T] method
-		result, err := mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "1"})
+	t.Run("StrictID_Comparison", func(t *testing.T) {
+		// Compare behavior between strict and non-strict modes
+
+		// Create an entity with custom ID
+		entityWithCustomID := struct {
+			ID   string `bson:"_id"`
+			Name string `bson:"name"`
+		}{
+			ID:   "comparison-test",
+			Name: "test-entity",
+		}
+
+		// Non-strict mode should succeed and return zero ObjectID
+		ids, err := coll.Insert(ctx, entityWithCustomID)
 		if err != nil {
 			t.Error(err)
 		}
-		if result.ID != "1" {
-			t.Errorf("expected ID '1', got '%s'", result.ID)
+		if len(ids) != 1 || !ids[0].IsZero() {
+			t.Error("non-strict Insert should return zero ObjectID for custom string ID")
+		}
+
+		// Cleanup before next test
+		_ = coll.DeleteOne(ctx, mongox.M{"_id": "comparison-test"})
+
+		// Strict mode should fail
+		_, err = coll.InsertStrict(ctx, entityWithCustomID)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("strict Insert should fail with custom string ID, got error: %v", err)
+		}
+
+		// Test with regular testEntity (should work in both modes)
+		entity := newTestEntity("comparison-regular")
+
+		// Non-strict mode
+		ids, err = coll.Insert(ctx, entity)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(ids) != 1 || ids[0].IsZero() {
+			t.Error("non-strict Insert should return non-zero ObjectID for regular entity")
+		}
+
+		// Cleanup
+		_ = coll.DeleteOne(ctx, mongox.M{"id": "comparison-regular"})
+
+		// Strict mode
+		ids, err = coll.InsertStrict(ctx, entity)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(ids) != 1 || ids[0].IsZero() {
+			t.Error("strict Insert should return non-zero ObjectID for regular entity")
+		}
+
+		// Cleanup
+		_ = coll.DeleteOne(ctx, mongox.M{"id": "comparison-regular"})
+	})
+
+	t.Run("InsertMany_StrictID", func(t *testing.T) {
+		// Test InsertMany with strict ID parameter
+		entities := []any{
+			newTestEntity("many1"),
+			newTestEntity("many2"),
+			newTestEntity("many3"),
 		}
 
-		// Test generic FindOne[T] with options
-		result, err = mongox.FindOne[testEntity](ctx, coll, nil, mongox.FindOptions{
-			Sort: mongox.M{"id": mongox.Descending},
-		})
+		// Test InsertMany with strict=false
+		ids, err := coll.InsertMany(ctx, entities, false)
 		if err != nil {
 			t.Error(err)
 		}
-		if result.ID != "5" {
-			t.Errorf("expected ID '5' with descending sort, got '%s'", result.ID)
+		if len(ids) != 3 {
+			t.Errorf("expected 3 IDs from InsertMany, got %d", len(ids))
+		}
+		for i, id := range ids {
+			if id.IsZero() {
+				t.Errorf("expected non-zero ObjectID at index %d from InsertMany", i)
+			}
 		}
 
-		// Test generic FindOne[T] with skip and sort
-		result, err = mongox.FindOne[testEntity](ctx, coll, nil, mongox.FindOptions{
-			Skip: 1,
-			Sort: mongox.M{"id": mongox.Ascending},
-		})
+		// Cleanup
+		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"many1", "many2", "many3"}}})
+
+		// Test InsertMany with strict=true
+		ids, err = coll.InsertMany(ctx, entities, true)
 		if err != nil {
 			t.Error(err)
 		}
-		if result.ID != "2" {
-			t.Errorf("expected ID '2' with skip=1 and ascending sort, got '%s'", result.ID)
+		if len(ids) != 3 {
+			t.Errorf("expected 3 IDs from strict InsertMany, got %d", len(ids))
 		}
-
-		// Test generic FindOne[T] error case
-		_, err = mongox.FindOne[testEntity](ctx, coll, mongox.M{"id": "999"})
-		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected ErrNotFound, got %v", err)
+		for i, id := range ids {
+			if id.IsZero() {
+				t.Errorf("expected non-zero ObjectID at index %d from strict InsertMany", i)
+			}
 		}
-	})
 
-	t.Run("FindOne_ComplexFilters", func(t *testing.T) {
-		var result testEntity
+		// Cleanup
+		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"many1", "many2", "many3"}}})
 
-		// Test FindOne with $and operator
-		err := coll.FindOne(ctx, &result, mongox.M{
-			mongox.And: []mongox.M{
-				{"id": mongox.M{mongox.Gte: "2"}},
-				{"id": mongox.M{mongox.Lte: "3"}},
+		// Test InsertMany with custom IDs and strict=true (should fail)
+		entitiesWithCustomIDs := []any{
+			struct {
+				ID   string `bson:"_id"`
+				Name string `bson:"name"`
+			}{
+				ID:   "many-custom-unique-1",
+				Name: "entity-1",
+			},
+			struct {
+				ID   string `bson:"_id"`
+				Name string `bson:"name"`
+			}{
+				ID:   "many-custom-unique-2",
+				Name: "entity-2",
 			},
-		})
-		if err != nil {
-			t.Error(err)
 		}
-		if result.ID != "2" && result.ID != "3" {
-			t.Errorf("expected ID '2' or '3', got '%s'", result.ID)
+
+		_, err = coll.InsertMany(ctx, entitiesWithCustomIDs, true)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument with strict InsertMany and custom string IDs, got %v", err)
 		}
 
-		// Test FindOne with $or operator
-		err = coll.FindOne(ctx, &result, mongox.M{
-			mongox.Or: []mongox.M{
-				{"id": "1"},
-				{"id": "5"},
+		// Create new entities with different IDs for the non-strict test
+		entitiesWithCustomIDs2 := []any{
+			struct {
+				ID   string `bson:"_id"`
+				Name string `bson:"name"`
+			}{
+				ID:   "many-custom-nonstrict-1",
+				Name: "entity-nonstrict-1",
 			},
-		})
+			struct {
+				ID   string `bson:"_id"`
+				Name string `bson:"name"`
+			}{
+				ID:   "many-custom-nonstrict-2",
+				Name: "entity-nonstrict-2",
+			},
+		}
+
+		// Test InsertMany with custom IDs and strict=false (should succeed)
+		ids, err = coll.InsertMany(ctx, entitiesWithCustomIDs2, false)
 		if err != nil {
-			t.Error(err)
+			t.Errorf("non-strict InsertMany with custom IDs failed: %v", err)
 		}
-		if result.ID != "1" && result.ID != "5" {
-			t.Errorf("expected ID '1' or '5', got '%s'", result.ID)
+		if len(ids) != 2 {
+			t.Errorf("expected 2 IDs from non-strict InsertMany with custom IDs, got %d", len(ids))
+		}
+		for i, id := range ids {
+			if !id.IsZero() {
+				t.Errorf("expected zero ObjectID at index %d for custom string ID", i)
+			}
 		}
 
-		// Test FindOne with nested field
-		err = coll.FindOne(ctx, &result, mongox.M{
-			"struct.name": entities[0].Struct.Name,
-		})
-		if err != nil {
+		// Cleanup
+		_, _ = coll.DeleteMany(ctx, mongox.M{"_id": mongox.M{mongox.In: []string{"many-custom-nonstrict-1", "many-custom-nonstrict-2"}}})
+	})
+
+	t.Run("InsertIgnoreDuplicates", func(t *testing.T) {
+		dupColl := db.Collection("insert_ignore_duplicates_test")
+		if err := dupColl.CreateIndex(ctx, true, "id"); err != nil {
 			t.Error(err)
 		}
-		if result.Struct.Name != entities[0].Struct.Name {
-			t.Errorf("expected struct name '%s', got '%s'", entities[0].Struct.Name, result.Struct.Name)
+
+		_, err := dupColl.Insert(ctx, newTestEntity("dup1"))
+		if err != nil {
+			t.Error(err)
 		}
 
-		// Test FindOne with array field
-		err = coll.FindOne(ctx, &result, mongox.M{
-			"slice": entities[1].Slice,
+		inserted, skipped, err := mongox.InsertIgnoreDuplicates(ctx, dupColl, []any{
+			newTestEntity("dup1"), newTestEntity("dup2"), newTestEntity("dup3"), newTestEntity("dup2"),
 		})
 		if err != nil {
 			t.Error(err)
 		}
-		if !reflect.DeepEqual(result.Slice, entities[1].Slice) {
-			t.Errorf("expected slice %v, got %v", entities[1].Slice, result.Slice)
+		if inserted != 2 {
+			t.Errorf("expected 2 inserted documents, got %d", inserted)
+		}
+		if skipped != 2 {
+			t.Errorf("expected 2 skipped documents, got %d", skipped)
 		}
-	})
 
-	t.Run("FindOne_FieldProjection", func(t *testing.T) {
-		// MongoDB doesn't support field projection directly in FindOne options in this wrapper,
-		// but we can test that we get full documents
-		var result testEntity
-		err := coll.FindOne(ctx, &result, mongox.M{"id": "1"})
+		count, err := dupColl.Count(ctx, nil)
 		if err != nil {
 			t.Error(err)
 		}
-
-		// Verify all fields are populated
-		if result.ID == "" {
-			t.Error("ID should not be empty")
-		}
-		if result.Name == "" {
-			t.Error("Name should not be empty")
-		}
-		if result.Number == 0 {
-			t.Error("Number should not be zero")
+		if count != 3 {
+			t.Errorf("expected 3 documents, got %d", count)
 		}
-		if len(result.Slice) == 0 {
-			t.Error("Slice should not be empty")
+
+		inserted, skipped, err = dupColl.InsertIgnoreDuplicates(ctx, nil)
+		if err != nil || inserted != 0 || skipped != 0 {
+			t.Errorf("expected no-op for empty records, got %d, %d, %v", inserted, skipped, err)
 		}
 	})
 
-	t.Run("FindOne_TypeSafety", func(t *testing.T) {
-		// Test that FindOne properly handles different destination types
+	t.Run("BulkWrite_WithStrictID", func(t *testing.T) {
+		// Test bulk operations and verify ID handling
+		bulker := mongox.NewBulkBuilder()
 
-		// Test with map destination
-		var mapResult map[string]interface{}
-		err := coll.FindOne(ctx, &mapResult, mongox.M{"id": "1"})
+		// Add various insert operations
+		entity1 := newTestEntity("bulk1")
+		entity2 := newTestEntity("bulk2")
+		entityWithCustomID := struct {
+			ID   string `bson:"_id"`
+			Name string `bson:"name"`
+		}{
+			ID:   "bulk-custom",
+			Name: "bulk-entity",
+		}
+
+		bulker.Insert(entity1, entity2, entityWithCustomID)
+
+		result, err := coll.BulkWrite(ctx, bulker.Models(), false)
 		if err != nil {
 			t.Error(err)
 		}
-		if mapResult["id"] != "1" {
-			t.Errorf("expected map ID '1', got '%v'", mapResult["id"])
+		if result.InsertedCount != 3 {
+			t.Errorf("expected 3 inserted documents, got %d", result.InsertedCount)
 		}
 
-		// Test with bson.M destination
-		var bsonResult bson.M
-		err = coll.FindOne(ctx, &bsonResult, mongox.M{"id": "1"})
+		// Verify documents were inserted
+		count, err := coll.Count(ctx, mongox.M{"$or": []mongox.M{
+			{"id": mongox.M{mongox.In: []string{"bulk1", "bulk2"}}},
+			{"_id": "bulk-custom"},
+		}})
 		if err != nil {
 			t.Error(err)
 		}
-		if bsonResult["id"] != "1" {
-			t.Errorf("expected bson.M ID '1', got '%v'", bsonResult["id"])
+		if count != 3 {
+			t.Errorf("expected 3 documents in bulk write, got %d", count)
 		}
+
+		// Cleanup
+		_, _ = coll.DeleteMany(ctx, mongox.M{"$or": []mongox.M{
+			{"id": mongox.M{mongox.In: []string{"bulk1", "bulk2"}}},
+			{"_id": "bulk-custom"},
+		}})
 	})
 
-	// Cleanup
-	_, err = coll.DeleteMany(ctx, nil)
-	if err != nil {
+	// Final cleanup
+	_, err := coll.DeleteMany(ctx, nil)
+	if err != nil && !errors.Is(err, mongox.ErrNotFound) {
 		t.Error(err)
 	}
 }
 
-func TestFindOneAndMethods(t *testing.T) {
+func TestCollectionAdmin(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	db := client.Database(dbName)
-	coll := db.Collection("find_one_and_methods_test")
-
-	t.Run("FindOneAndDelete", func(t *testing.T) {
-		// Setup test data
-		entity1 := newTestEntity("delete1")
-		entity2 := newTestEntity("delete2")
-		entity3 := newTestEntity("delete3")
 
-		_, err := coll.Insert(ctx, entity1, entity2, entity3)
-		if err != nil {
+	t.Run("BatchReads", func(t *testing.T) {
+		coll := db.Collection("batch_reads_test")
+		entity := newTestEntity("1")
+		if _, err := coll.Insert(ctx, entity); err != nil {
 			t.Fatal(err)
 		}
 
-		// Test FindOneAndDelete - should return the deleted document
-		var deletedEntity testEntity
-		err = coll.FindOneAndDelete(ctx, &deletedEntity, mongox.M{"id": "delete1"})
+		var sessionIDs []bson.Raw
+		err := db.BatchReads(ctx, func(sctx context.Context) error {
+			for range 3 {
+				session := mongo.SessionFromContext(sctx)
+				if session == nil {
+					return errors.New("expected session in context")
+				}
+				sessionIDs = append(sessionIDs, session.ID())
+
+				result, err := mongox.FindOne[testEntity](sctx, coll, mongox.M{"id": "1"})
+				if err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(entity, result) {
+					t.Errorf("expected %v, got %v", entity, result)
+				}
+			}
+			return nil
+		})
 		if err != nil {
 			t.Error(err)
 		}
-		if deletedEntity.ID != "delete1" {
-			t.Errorf("expected deleted entity ID 'delete1', got '%s'", deletedEntity.ID)
-		}
-		if deletedEntity.Name != entity1.Name {
-			t.Errorf("expected deleted entity name '%s', got '%s'", entity1.Name, deletedEntity.Name)
+		if len(sessionIDs) != 3 || !bytes.Equal(sessionIDs[0], sessionIDs[2]) {
+			t.Errorf("expected one session for all reads, got %v", sessionIDs)
 		}
 
-		// Verify the document was actually deleted
-		var checkEntity testEntity
-		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "delete1"})
+		err = db.BatchReads(ctx, func(sctx context.Context) error {
+			_, err := mongox.FindOne[testEntity](sctx, coll, mongox.M{"id": "not-found"})
+			return err
+		})
 		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected ErrNotFound after deletion, got %v", err)
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
+		if err := db.BatchReads(ctx, nil); !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
+	})
 
-		// Test generic FindOneAndDelete[T]
-		deletedEntity2, err := mongox.FindOneAndDelete[testEntity](ctx, coll, mongox.M{"id": "delete2"})
+	t.Run("FindAfterClusterTime", func(t *testing.T) {
+		coll := db.Collection("after_cluster_time_test")
+
+		_, err := coll.Insert(ctx, newTestEntity("1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res []testEntity
+		err = coll.FindAfterClusterTime(ctx, &res, nil, bson.Timestamp{})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		isReplicaSet, err := db.IsReplicaSet(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isReplicaSet {
+			_, err = mongox.FindAfterClusterTime[testEntity](ctx, coll, nil, bson.Timestamp{T: 1})
+			if !errors.Is(err, mongox.ErrCausalConsistencyUnsupported) {
+				t.Errorf("expected error %v, got %v", mongox.ErrCausalConsistencyUnsupported, err)
+			}
+			return
+		}
+
+		// The operation time of a write made in a session is passed to another reader
+		var afterTime bson.Timestamp
+		err = db.BatchReads(ctx, func(sctx context.Context) error {
+			if _, err := coll.Insert(sctx, newTestEntity("2")); err != nil {
+				return err
+			}
+			afterTime = *mongo.SessionFromContext(sctx).OperationTime()
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		found, err := mongox.FindAfterClusterTime[testEntity](ctx, coll, mongox.M{"id": "2"}, afterTime)
 		if err != nil {
 			t.Error(err)
 		}
-		if deletedEntity2.ID != "delete2" {
-			t.Errorf("expected deleted entity ID 'delete2', got '%s'", deletedEntity2.ID)
+		if len(found) != 1 {
+			t.Errorf("expected %d, got %d", 1, len(found))
 		}
+	})
 
-		// Verify the document was actually deleted
-		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "delete2"})
-		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected ErrNotFound after deletion, got %v", err)
+	t.Run("SnapshotReads", func(t *testing.T) {
+		orders := db.Collection("snapshot_orders_test")
+		payments := db.Collection("snapshot_payments_test")
+		if _, err := orders.Insert(ctx, newTestEntity("1")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := payments.Insert(ctx, newTestEntity("1")); err != nil {
+			t.Fatal(err)
 		}
 
-		// Test FindOneAndDelete with non-existent document
-		var nonExistentEntity testEntity
-		err = coll.FindOneAndDelete(ctx, &nonExistentEntity, mongox.M{"id": "nonexistent"})
-		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected ErrNotFound for non-existent document, got %v", err)
+		if err := db.SnapshotReads(ctx, nil); !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
 
-		// Test generic FindOneAndDelete[T] with non-existent document
-		_, err = mongox.FindOneAndDelete[testEntity](ctx, coll, mongox.M{"id": "nonexistent"})
-		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected ErrNotFound for non-existent document, got %v", err)
+		isReplicaSet, err := db.IsReplicaSet(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isReplicaSet {
+			var calls int
+			err = db.SnapshotReads(ctx, func(sctx context.Context) error {
+				calls++
+				return nil
+			})
+			if !errors.Is(err, mongox.ErrTransactionsUnsupported) || calls != 0 {
+				t.Errorf("expected error %v without calls, got %v after %d calls", mongox.ErrTransactionsUnsupported, err, calls)
+			}
+			t.Skip("reading collections from one snapshot requires a replica set")
 		}
 
-		// Test FindOneAndDelete with complex filter
-		err = coll.FindOneAndDelete(ctx, &deletedEntity, mongox.M{
-			"id": mongox.M{mongox.In: []string{"delete3", "delete4"}},
+		// Both collections are read from the snapshot, a write between the reads is not visible
+		var ordersCount, paymentsCount int64
+		err = db.SnapshotReads(ctx, func(sctx context.Context) error {
+			var err error
+			if ordersCount, err = orders.Count(sctx, nil); err != nil {
+				return err
+			}
+			if _, err := payments.Insert(ctx, newTestEntity("2")); err != nil {
+				return err
+			}
+			paymentsCount, err = payments.Count(sctx, nil)
+			return err
 		})
 		if err != nil {
 			t.Error(err)
 		}
-		if deletedEntity.ID != "delete3" {
-			t.Errorf("expected deleted entity ID 'delete3', got '%s'", deletedEntity.ID)
+		if ordersCount != 1 || paymentsCount != 1 {
+			t.Errorf("expected %d orders and %d payments, got %d and %d", 1, 1, ordersCount, paymentsCount)
 		}
-
-		// Cleanup remaining test data
-		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.Regex: "delete"}})
 	})
 
-	t.Run("FindOneAndReplace", func(t *testing.T) {
-		// Setup test data
-		originalEntity := newTestEntity("replace1")
-		entity2 := newTestEntity("replace2")
-
-		_, err := coll.Insert(ctx, originalEntity, entity2)
-		if err != nil {
+	t.Run("Compact", func(t *testing.T) {
+		coll := db.Collection("compact_test")
+		records := make([]any, 0, 100)
+		for i := range 100 {
+			records = append(records, newTestEntity(strconv.Itoa(i)))
+		}
+		if _, err := coll.Insert(ctx, records...); err != nil {
 			t.Fatal(err)
 		}
-
-		// Create replacement entity
-		replacementEntity := newTestEntity("replace1") // Same ID but different data
-		replacementEntity.Name = "replaced-name"
-		replacementEntity.Number = 99999
-
-		// Test FindOneAndReplace - should return the original document
-		var returnedEntity testEntity
-		err = coll.FindOneAndReplace(ctx, &returnedEntity, mongox.M{"id": "replace1"}, replacementEntity)
-		if err != nil {
+		if _, err := coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.Exists: true}}); err != nil {
 			t.Error(err)
 		}
-		if returnedEntity.ID != "replace1" {
-			t.Errorf("expected returned entity ID 'replace1', got '%s'", returnedEntity.ID)
-		}
-		if returnedEntity.Name != originalEntity.Name {
-			t.Errorf("expected returned entity to be original, got name '%s', expected '%s'", returnedEntity.Name, originalEntity.Name)
-		}
 
-		// Verify the document was actually replaced
-		var checkEntity testEntity
-		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "replace1"})
-		if err != nil {
+		if err := coll.Compact(ctx); err != nil {
 			t.Error(err)
 		}
-		if checkEntity.Name != "replaced-name" {
-			t.Errorf("expected replaced entity name 'replaced-name', got '%s'", checkEntity.Name)
-		}
-		if checkEntity.Number != 99999 {
-			t.Errorf("expected replaced entity number 99999, got %d", checkEntity.Number)
+		if err := mongox.Compact(ctx, coll); err != nil {
+			t.Error(err)
 		}
 
-		// Test generic FindOneAndReplace[T]
-		anotherReplacement := newTestEntity("replace2")
-		anotherReplacement.Name = "another-replaced-name"
+		err := db.Collection("compact_not_exists_test").Compact(ctx)
+		if !errors.Is(err, mongox.ErrNamespaceNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNamespaceNotFound, err)
+		}
+	})
 
-		returnedEntity2, err := mongox.FindOneAndReplace[testEntity](ctx, coll, mongox.M{"id": "replace2"}, anotherReplacement)
+	t.Run("SetValidator", func(t *testing.T) {
+		coll := db.Collection("validator_test")
+		_, err := coll.Insert(ctx, newTestEntity("1"))
 		if err != nil {
 			t.Error(err)
 		}
-		if returnedEntity2.ID != "replace2" {
-			t.Errorf("expected returned entity ID 'replace2', got '%s'", returnedEntity2.ID)
-		}
-		if returnedEntity2.Name == "another-replaced-name" {
-			t.Error("returned entity should be the original, not the replacement")
-		}
 
-		// Verify the document was actually replaced
-		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "replace2"})
+		err = coll.SetValidator(ctx, mongox.M{
+			"bsonType": "object",
+			"required": []string{"id", "name"},
+		}, "strict", "error")
 		if err != nil {
 			t.Error(err)
 		}
-		if checkEntity.Name != "another-replaced-name" {
-			t.Errorf("expected replaced entity name 'another-replaced-name', got '%s'", checkEntity.Name)
+
+		_, err = coll.Insert(ctx, mongox.M{"id": "2"})
+		if !errors.Is(err, mongox.ErrDocumentValidationFailure) {
+			t.Errorf("expected error %v, got %v", mongox.ErrDocumentValidationFailure, err)
 		}
 
-		// Test FindOneAndReplace with non-existent document
-		var nonExistentEntity testEntity
-		err = coll.FindOneAndReplace(ctx, &nonExistentEntity, mongox.M{"id": "nonexistent"}, newTestEntity("nonexistent"))
-		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected ErrNotFound for non-existent document, got %v", err)
+		err = coll.SetValidator(ctx, nil, "dummy", "")
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
 
-		// Test generic FindOneAndReplace[T] with non-existent document
-		_, err = mongox.FindOneAndReplace[testEntity](ctx, coll, mongox.M{"id": "nonexistent"}, newTestEntity("nonexistent"))
-		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected ErrNotFound for non-existent document, got %v", err)
+		err = mongox.SetValidator(ctx, coll, nil, "off", "")
+		if err != nil {
+			t.Error(err)
 		}
 
-		// Cleanup test data
-		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.Regex: "replace"}})
+		_, err = coll.Insert(ctx, mongox.M{"id": "2"})
+		if err != nil {
+			t.Error(err)
+		}
 	})
 
-	t.Run("FindOneAndUpdate", func(t *testing.T) {
-		// Setup test data
-		originalEntity := newTestEntity("update1")
-		entity2 := newTestEntity("update2")
-		entity3 := newTestEntity("update3")
+	t.Run("WithReadPreference", func(t *testing.T) {
+		coll := db.Collection("read_preference_test")
+		entity := newTestEntity("1")
+		if _, err := coll.Insert(ctx, entity); err != nil {
+			t.Error(err)
+		}
 
-		_, err := coll.Insert(ctx, originalEntity, entity2, entity3)
+		secondary, err := coll.WithReadPreference("secondaryPreferred")
 		if err != nil {
 			t.Fatal(err)
 		}
+		if coll.Collection() == secondary.Collection() {
+			t.Error("expected original collection to keep its read preference")
+		}
 
-		// Test FindOneAndUpdate with $set operator - should return original document
-		var returnedEntity testEntity
-		err = coll.FindOneAndUpdate(ctx, &returnedEntity, mongox.M{"id": "update1"}, mongox.M{
-			mongox.Set: mongox.M{
-				"name":   "updated-name",
-				"number": 12345,
-			},
-		})
-		if err != nil {
+		var result testEntity
+		if err := secondary.FindOne(ctx, &result, mongox.M{"id": "1"}); err != nil {
 			t.Error(err)
 		}
-		if returnedEntity.ID != "update1" {
-			t.Errorf("expected returned entity ID 'update1', got '%s'", returnedEntity.ID)
+		if !reflect.DeepEqual(entity, result) {
+			t.Errorf("expected %v, got %v", entity, result)
 		}
-		if returnedEntity.Name != originalEntity.Name {
-			t.Errorf("expected returned entity to be original, got name '%s', expected '%s'", returnedEntity.Name, originalEntity.Name)
+
+		_, err = coll.WithReadPreference("secondary_preferred")
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
 
-		// Verify the document was actually updated
-		var checkEntity testEntity
-		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "update1"})
+		// Members are selected by tags, an empty tag set falls back to any member
+		zoned, err := coll.WithReadPreference("secondaryPreferred", map[string]string{"region": "eu"}, map[string]string{})
 		if err != nil {
+			t.Fatal(err)
+		}
+		if err := zoned.FindOne(ctx, &result, mongox.M{"id": "1"}); err != nil {
 			t.Error(err)
 		}
-		if checkEntity.Name != "updated-name" {
-			t.Errorf("expected updated entity name 'updated-name', got '%s'", checkEntity.Name)
+		_, err = coll.WithReadPreference("primary", map[string]string{"region": "eu"})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-		if checkEntity.Number != 12345 {
-			t.Errorf("expected updated entity number 12345, got %d", checkEntity.Number)
+		_, err = coll.WithReadPreference("nearest", map[string]string{"": "eu"})
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
+	})
 
-		// Test FindOneAndUpdate with $inc operator
-		err = coll.FindOneAndUpdate(ctx, &returnedEntity, mongox.M{"id": "update1"}, mongox.M{
-			mongox.Inc: mongox.M{"number": 100},
-		})
-		if err != nil {
+	t.Run("RunCommandOn", func(t *testing.T) {
+		var stats struct {
+			DB string `bson:"db"`
+		}
+		if err := db.RunCommandOn(ctx, "secondaryPreferred", mongox.M{"dbStats": 1}, &stats); err != nil {
 			t.Error(err)
 		}
-		if returnedEntity.Number != 12345 {
-			t.Errorf("expected returned entity to have original number 12345, got %d", returnedEntity.Number)
+		if stats.DB != dbName {
+			t.Errorf("expected %v, got %v", dbName, stats.DB)
 		}
 
-		// Verify the increment was applied
-		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "update1"})
-		if err != nil {
+		cmd := bson.D{{Key: "dbStats", Value: 1}, {Key: "scale", Value: 1024}}
+		if err := db.RunCommand(ctx, cmd, nil); err != nil {
 			t.Error(err)
 		}
-		if checkEntity.Number != 12445 {
-			t.Errorf("expected incremented number 12445, got %d", checkEntity.Number)
+
+		err := db.RunCommandOn(ctx, "secondary_preferred", mongox.M{"dbStats": 1}, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+		err = db.RunCommand(ctx, mongox.M{"dbStats": 1, "scale": 1024}, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+		err = db.RunCommand(ctx, mongox.M{"unknownCommand": 1}, nil)
+		if !errors.Is(err, mongox.ErrCommandNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrCommandNotFound, err)
 		}
+	})
 
-		// Test generic FindOneAndUpdate[T]
-		returnedEntity2, err := mongox.FindOneAndUpdate[testEntity](ctx, coll, mongox.M{"id": "update2"}, mongox.M{
-			mongox.Set: mongox.M{"name": "generic-updated-name"},
+	t.Run("ScanAll", func(t *testing.T) {
+		coll := db.Collection("scan_all_test")
+
+		var calls int
+		err := coll.ScanAll(ctx, "id", 3, func(batch []bson.Raw, lastKey any) error {
+			calls++
+			return nil
 		})
-		if err != nil {
-			t.Error(err)
+		if err != nil || calls != 0 {
+			t.Errorf("expected no error and no calls for empty collection, got %v after %d calls", err, calls)
 		}
-		if returnedEntity2.ID != "update2" {
-			t.Errorf("expected returned entity ID 'update2', got '%s'", returnedEntity2.ID)
+
+		entities := make([]any, 0, 7)
+		for i := range 7 {
+			entities = append(entities, newTestEntity(fmt.Sprintf("%02d", i+1)))
 		}
-		if returnedEntity2.Name == "generic-updated-name" {
-			t.Error("returned entity should be the original, not the updated version")
+		if _, err := coll.Insert(ctx, entities...); err != nil {
+			t.Fatal(err)
 		}
 
-		// Verify the document was actually updated
-		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "update2"})
-		if err != nil {
-			t.Error(err)
+		// The job crashes after the first batch
+		errCrash := errors.New("crash")
+		var checkpoint any
+		err = coll.ScanAll(ctx, "id", 3, func(batch []bson.Raw, lastKey any) error {
+			checkpoint = lastKey
+			return errCrash
+		})
+		if !errors.Is(err, errCrash) {
+			t.Errorf("expected error %v, got %v", errCrash, err)
 		}
-		if checkEntity.Name != "generic-updated-name" {
-			t.Errorf("expected updated entity name 'generic-updated-name', got '%s'", checkEntity.Name)
+		if checkpoint != "03" {
+			t.Errorf("expected %v, got %v", "03", checkpoint)
 		}
 
-		// Test FindOneAndUpdate with complex update using multiple operators
-		err = coll.FindOneAndUpdate(ctx, &returnedEntity, mongox.M{"id": "update3"}, mongox.M{
-			mongox.Set:  mongox.M{"name": "complex-updated"},
-			mongox.Inc:  mongox.M{"number": 500},
-			mongox.Push: mongox.M{"slice": 999},
-		})
+		var ids []string
+		var keys []any
+		err = mongox.ScanAll(ctx, coll, "id", 3, func(batch []bson.Raw, lastKey any) error {
+			for _, doc := range batch {
+				ids = append(ids, doc.Lookup("id").StringValue())
+			}
+			keys = append(keys, lastKey)
+			return nil
+		}, mongox.ScanOptions{After: checkpoint})
 		if err != nil {
 			t.Error(err)
 		}
+		if !reflect.DeepEqual(ids, []string{"04", "05", "06", "07"}) {
+			t.Errorf("expected %v, got %v", []string{"04", "05", "06", "07"}, ids)
+		}
+		if !reflect.DeepEqual(keys, []any{"06", "07"}) {
+			t.Errorf("expected %v, got %v", []any{"06", "07"}, keys)
+		}
 
-		// Verify complex update was applied
-		err = coll.FindOne(ctx, &checkEntity, mongox.M{"id": "update3"})
+		// Dropped cursor (CursorNotFound on getMore) of the second batch is re-read from the last key.
+		// Batches are larger than the first batch of find (101 documents), so every batch needs a getMore.
+		reconnectColl := db.Collection("scan_all_reconnect_test")
+		many := make([]any, 0, 300)
+		for i := range 300 {
+			many = append(many, mongox.M{"id": fmt.Sprintf("%03d", i)})
+		}
+		if _, err := reconnectColl.Insert(ctx, many...); err != nil {
+			t.Fatal(err)
+		}
+		admin := client.Client().Database("admin")
+		defer admin.RunCommand(ctx, bson.D{{Key: "configureFailPoint", Value: "failCommand"}, {Key: "mode", Value: "off"}})
+
+		scanned := make(map[string]int)
+		var batches int
+		err = reconnectColl.ScanAll(ctx, "id", 150, func(batch []bson.Raw, lastKey any) error {
+			for _, doc := range batch {
+				scanned[doc.Lookup("id").StringValue()]++
+			}
+			batches++
+			if batches > 1 {
+				return nil
+			}
+			return admin.RunCommand(ctx, bson.D{
+				{Key: "configureFailPoint", Value: "failCommand"},
+				{Key: "mode", Value: bson.D{{Key: "times", Value: 1}}},
+				{Key: "data", Value: bson.D{{Key: "failCommands", Value: []string{"getMore"}}, {Key: "errorCode", Value: 43}}},
+			}).Err()
+		}, mongox.ScanOptions{MaxRetries: 2})
 		if err != nil {
 			t.Error(err)
 		}
-		if checkEntity.Name != "complex-updated" {
-			t.Errorf("expected updated entity name 'complex-updated', got '%s'", checkEntity.Name)
-		}
-		if checkEntity.Number != entity3.Number+500 {
-			t.Errorf("expected incremented number %d, got %d", entity3.Number+500, checkEntity.Number)
+		if len(scanned) != 300 || batches != 2 {
+			t.Errorf("expected %d documents in %d batches, got %d in %d", 300, 2, len(scanned), batches)
 		}
-		found999 := false
-		for _, val := range checkEntity.Slice {
-			if val == 999 {
-				found999 = true
-				break
+		for id, n := range scanned {
+			if n != 1 {
+				t.Errorf("expected document %s to be scanned once, got %d", id, n)
 			}
 		}
-		if !found999 {
-			t.Error("expected 999 to be pushed to slice")
-		}
 
-		// Test FindOneAndUpdate with non-existent document
-		var nonExistentEntity testEntity
-		err = coll.FindOneAndUpdate(ctx, &nonExistentEntity, mongox.M{"id": "nonexistent"}, mongox.M{
-			mongox.Set: mongox.M{"name": "wont-work"},
-		})
-		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected ErrNotFound for non-existent document, got %v", err)
+		// Without retries the dropped cursor is returned
+		err = admin.RunCommand(ctx, bson.D{
+			{Key: "configureFailPoint", Value: "failCommand"},
+			{Key: "mode", Value: bson.D{{Key: "times", Value: 1}}},
+			{Key: "data", Value: bson.D{{Key: "failCommands", Value: []string{"getMore"}}, {Key: "errorCode", Value: 43}}},
+		}).Err()
+		if err != nil {
+			t.Fatal(err)
 		}
-
-		// Test generic FindOneAndUpdate[T] with non-existent document
-		_, err = mongox.FindOneAndUpdate[testEntity](ctx, coll, mongox.M{"id": "nonexistent"}, mongox.M{
-			mongox.Set: mongox.M{"name": "wont-work"},
-		})
-		if !errors.Is(err, mongox.ErrNotFound) {
-			t.Errorf("expected ErrNotFound for non-existent document, got %v", err)
+		err = reconnectColl.ScanAll(ctx, "id", 150, func(batch []bson.Raw, lastKey any) error { return nil })
+		if !errors.Is(err, mongox.ErrCursorNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrCursorNotFound, err)
 		}
 
-		// Test FindOneAndUpdate with invalid update document
-		err = coll.FindOneAndUpdate(ctx, &returnedEntity, mongox.M{"id": "update1"}, mongox.M{
-			"invalid": "no-dollar-operator",
-		})
+		err = coll.ScanAll(ctx, "", 3, func(batch []bson.Raw, lastKey any) error { return nil })
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected ErrInvalidArgument for invalid update document, got %v", err)
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+		err = coll.ScanAll(ctx, "no_field", 3, func(batch []bson.Raw, lastKey any) error { return nil })
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-
-		// Cleanup test data
-		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.Regex: "update"}})
 	})
 
-	t.Run("FindOneAnd_ErrorHandling", func(t *testing.T) {
-		// Test error handling for all FindOneAnd methods
+	t.Run("CopyTo", func(t *testing.T) {
+		source := db.Collection("copy_source_test")
+		archive := client.Database(dbName + "_archive").Collection("copy_target_test")
+
+		entities := make([]any, 0, 10)
+		for i := range 10 {
+			entities = append(entities, newTestEntity(strconv.Itoa(i)))
+		}
+		if _, err := source.InsertMany(ctx, entities); err != nil {
+			t.Fatal(err)
+		}
+
+		filter := mongox.M{"id": mongox.M{mongox.In: []string{"1", "2", "3"}}}
+		copied, err := source.CopyTo(ctx, archive, filter, mongox.CopyOptions{BatchSize: 2})
+		if err != nil {
+			t.Error(err)
+		}
+		if copied != 3 {
+			t.Errorf("expected %d, got %d", 3, copied)
+		}
 
-		// Test with nil destination - should get ErrNotFound since document doesn't exist
-		err := coll.FindOneAndDelete(ctx, nil, mongox.M{"id": "test"})
-		if err == nil {
-			t.Error("expected error for nil destination in FindOneAndDelete")
+		var src, dst []bson.M
+		if err := source.Find(ctx, &src, filter, mongox.FindOptions{Sort: mongox.M{"id": 1}}); err != nil {
+			t.Error(err)
+		}
+		if err := archive.Find(ctx, &dst, filter, mongox.FindOptions{Sort: mongox.M{"id": 1}}); err != nil {
+			t.Error(err)
+		}
+		if !reflect.DeepEqual(src, dst) {
+			t.Errorf("expected %v, got %v", src, dst)
 		}
 
-		err = coll.FindOneAndReplace(ctx, nil, mongox.M{"id": "test"}, newTestEntity("test"))
-		if err == nil {
-			t.Error("expected error for nil destination in FindOneAndReplace")
+		// Same _id is preserved, so second copy fails
+		_, err = mongox.CopyTo(ctx, source, archive, filter)
+		if !errors.Is(err, mongox.ErrDuplicate) {
+			t.Errorf("expected error %v, got %v", mongox.ErrDuplicate, err)
 		}
 
-		err = coll.FindOneAndUpdate(ctx, nil, mongox.M{"id": "test"}, mongox.M{mongox.Set: mongox.M{"name": "test"}})
-		if err == nil {
-			t.Error("expected error for nil destination in FindOneAndUpdate")
+		copied, err = source.CopyTo(ctx, archive, nil, mongox.CopyOptions{RegenerateID: true})
+		if err != nil {
+			t.Error(err)
+		}
+		if copied != 10 {
+			t.Errorf("expected %d, got %d", 10, copied)
+		}
+		n, err := archive.Count(ctx, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 13 {
+			t.Errorf("expected %d, got %d", 13, n)
 		}
 
-		// Test with non-pointer destination - should get error
-		var entity testEntity
-		err = coll.FindOneAndDelete(ctx, entity, mongox.M{"id": "test"})
-		if err == nil {
-			t.Error("expected error for non-pointer destination in FindOneAndDelete")
+		copied, err = source.CopyTo(ctx, archive, mongox.M{"id": "none"})
+		if err != nil || copied != 0 {
+			t.Errorf("expected no-op for empty result, got %d, %v", copied, err)
 		}
+	})
 
-		err = coll.FindOneAndReplace(ctx, entity, mongox.M{"id": "test"}, newTestEntity("test"))
-		if err == nil {
-			t.Error("expected error for non-pointer destination in FindOneAndReplace")
+	t.Run("WatchOperations", func(t *testing.T) {
+		coll := db.Collection("watch_test")
+
+		err := coll.WatchInserts(ctx, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
 
-		err = coll.FindOneAndUpdate(ctx, entity, mongox.M{"id": "test"}, mongox.M{mongox.Set: mongox.M{"name": "test"}})
+		// Change streams are not supported on standalone server
+		err = coll.WatchUpdates(ctx, func(doc bson.Raw) error { return nil })
 		if err == nil {
-			t.Error("expected error for non-pointer destination in FindOneAndUpdate")
+			t.Error("expected error for change stream on standalone server")
 		}
-	})
 
-	t.Run("FindOneAnd_TypeSafety", func(t *testing.T) {
-		// Setup test data
-		testEntity := newTestEntity("typesafety")
-		_, err := coll.Insert(ctx, testEntity)
+		canceledCtx, cancelWatch := context.WithCancel(ctx)
+		cancelWatch()
+		err = coll.WatchDeletes(canceledCtx, func(doc bson.Raw) error { return nil })
 		if err != nil {
-			t.Fatal(err)
+			t.Errorf("expected nil error on canceled context, got %v", err)
 		}
 
-		// Test with map destination
-		var mapResult map[string]interface{}
-		err = coll.FindOneAndDelete(ctx, &mapResult, mongox.M{"id": "typesafety"})
-		if err != nil {
-			t.Error(err)
+		// Not transient errors are not retried
+		start := time.Now()
+		err = coll.WatchInserts(ctx, func(doc bson.Raw) error { return nil }, mongox.WatchOptions{MaxRetries: 5})
+		if err == nil || mongox.IsTransient(err) {
+			t.Errorf("expected not transient error on standalone server, got %v", err)
 		}
-		if mapResult["id"] != "typesafety" {
-			t.Errorf("expected map ID 'typesafety', got '%v'", mapResult["id"])
+		if time.Since(start) > mongox.DefaultReconnectDelay {
+			t.Errorf("expected no reconnect, took %v", time.Since(start))
 		}
+	})
 
-		// Re-insert for next test
-		_, err = coll.Insert(ctx, testEntity)
-		if err != nil {
-			t.Fatal(err)
-		}
+	t.Run("Watch", func(t *testing.T) {
+		coll := db.Collection("watch_test")
+		pipeline := []mongox.M{{"$match": mongox.M{"operationType": "insert"}}}
+		fn := func(event mongox.ChangeEvent) error { return nil }
+		opts := mongox.WatchOptions{FullDocument: options.UpdateLookup}
 
-		// Test with bson.M destination
-		var bsonResult bson.M
-		replacementMap := map[string]interface{}{
-			"id":     "typesafety",
-			"name":   "replaced-via-map",
-			"number": 777,
+		if err := coll.Watch(ctx, pipeline, nil); !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-		err = coll.FindOneAndReplace(ctx, &bsonResult, mongox.M{"id": "typesafety"}, replacementMap)
-		if err != nil {
-			t.Error(err)
+		if err := db.Watch(ctx, nil, nil); !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-		if bsonResult["id"] != "typesafety" {
-			t.Errorf("expected bson.M ID 'typesafety', got '%v'", bsonResult["id"])
+
+		// Change streams are not supported on standalone server
+		if err := coll.Watch(ctx, pipeline, fn, opts); err == nil {
+			t.Error("expected error for change stream on standalone server")
+		}
+		if err := db.Watch(ctx, nil, fn, opts); err == nil {
+			t.Error("expected error for change stream on standalone server")
 		}
 
-		// Cleanup
-		_, _ = coll.DeleteMany(ctx, mongox.M{"id": "typesafety"})
+		canceledCtx, cancelWatch := context.WithCancel(ctx)
+		cancelWatch()
+		if err := coll.Watch(canceledCtx, nil, fn); err != nil {
+			t.Errorf("expected nil error on canceled context, got %v", err)
+		}
+		if err := db.Watch(canceledCtx, pipeline, fn); err != nil {
+			t.Errorf("expected nil error on canceled context, got %v", err)
+		}
 	})
-
-	// Final cleanup
-	_, err := coll.DeleteMany(ctx, nil)
-	if err != nil && !errors.Is(err, mongox.ErrNotFound) {
-		t.Error(err)
-	}
 }
 
-func TestInsertMethods(t *testing.T) {
+func TestCollectionModifiers(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	db := client.Database(dbName)
-	coll := db.Collection("insert_methods_test")
+	coll := db.Collection("collection_modifiers_test")
+	entity := newTestEntity("1")
+	if _, err := coll.Insert(ctx, entity); err != nil {
+		t.Fatal(err)
+	}
 
-	t.Run("InsertOne_Basic", func(t *testing.T) {
-		// Test basic InsertOne functionality
-		entity := newTestEntity("insertone1")
+	t.Run("WithOptions_IDField", func(t *testing.T) {
+		if coll.IDField() != mongox.DefaultIDField {
+			t.Errorf("expected %v, got %v", mongox.DefaultIDField, coll.IDField())
+		}
+		keyed := coll.WithOptions(mongox.CollectionOptions{IDField: "id"})
+		if keyed.IDField() != "id" || coll.IDField() != mongox.DefaultIDField {
+			t.Errorf("expected id field of the copy only, got %v and %v", keyed.IDField(), coll.IDField())
+		}
 
-		id, err := coll.InsertOne(ctx, entity)
+		result, err := mongox.FindByID[testEntity](ctx, keyed, entity.ID)
 		if err != nil {
 			t.Error(err)
 		}
-		if id.IsZero() {
-			t.Error("expected non-zero ObjectID")
+		if !reflect.DeepEqual(entity, result) {
+			t.Errorf("expected %v, got %v", entity, result)
 		}
 
-		// Verify the document was inserted
-		var result testEntity
-		err = coll.FindOne(ctx, &result, mongox.M{"id": "insertone1"})
-		if err != nil {
+		diff := struct {
+			Name *string `bson:"name"`
+		}{Name: lang.Ptr("keyed-name")}
+		if err := keyed.UpdateOneFromDiff(ctx, keyed.IDFilter(entity.ID), diff); err != nil {
 			t.Error(err)
 		}
-		if result.ID != "insertone1" {
-			t.Errorf("expected ID 'insertone1', got '%s'", result.ID)
+		var updated testEntity
+		if err := keyed.FindByID(ctx, &updated, entity.ID); err != nil {
+			t.Error(err)
+		}
+		if updated.Name != "keyed-name" {
+			t.Errorf("expected %v, got %v", "keyed-name", updated.Name)
+		}
+		if err := keyed.FindByID(ctx, &updated, "not-found"); !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
 		}
 
-		// Test generic InsertOne
-		entity2 := newTestEntity("insertone2")
-		id2, err := mongox.InsertOne(ctx, coll, entity2)
+		// InsertAndRead reads the record by its business key
+		inserted := newTestEntity("keyed")
+		read, err := mongox.InsertAndRead(ctx, keyed, inserted, nil)
 		if err != nil {
 			t.Error(err)
 		}
-		if id2.IsZero() {
-			t.Error("expected non-zero ObjectID from generic InsertOne")
+		if !reflect.DeepEqual(inserted, read) {
+			t.Errorf("expected %v, got %v", inserted, read)
+		}
+		_, err = mongox.InsertAndRead(ctx, keyed, mongox.M{"name": "no-id"}, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
 
-		// Verify the document was inserted
-		err = coll.FindOne(ctx, &result, mongox.M{"id": "insertone2"})
-		if err != nil {
+		if _, err := coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.Ne: entity.ID}}); err != nil {
 			t.Error(err)
 		}
-		if result.ID != "insertone2" {
-			t.Errorf("expected ID 'insertone2', got '%s'", result.ID)
+		if _, err := coll.SetFields(ctx, mongox.M{"id": entity.ID}, mongox.M{"name": entity.Name}); err != nil {
+			t.Error(err)
 		}
-
-		// Cleanup
-		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"insertone1", "insertone2"}}})
 	})
 
-	t.Run("InsertOne_StrictID", func(t *testing.T) {
-		// Test InsertOne with strict ID validation enabled
-		entity := newTestEntity("strictone1")
+	t.Run("WithOptions_DefaultCollation", func(t *testing.T) {
+		plain := client.Database(dbName).Collection("default_collation_test")
+		entity := newTestEntity("collation")
+		entity.Name = "Alice"
+		if _, err := plain.Insert(ctx, entity); err != nil {
+			t.Fatal(err)
+		}
 
-		id, err := coll.InsertOne(ctx, entity, true)
-		if err != nil {
+		err := plain.FindOne(ctx, &testEntity{}, mongox.M{"name": "alice"})
+		if !errors.Is(err, mongox.ErrNotFound) {
+			t.Errorf("expected error %v, got %v", mongox.ErrNotFound, err)
+		}
+
+		ci := plain.WithOptions(mongox.CollectionOptions{DefaultCollation: &options.Collation{Locale: "en", Strength: 2}})
+		var found testEntity
+		if err := ci.FindOne(ctx, &found, mongox.M{"name": "ALICE"}); err != nil {
 			t.Error(err)
 		}
-		if id.IsZero() {
-			t.Error("expected non-zero ObjectID with strict validation")
+		if found.ID != entity.ID {
+			t.Errorf("expected %v, got %v", entity.ID, found.ID)
 		}
-
-		// Test generic InsertOne with strict validation
-		entity2 := newTestEntity("strictone2")
-		id2, err := mongox.InsertOne(ctx, coll, entity2, true)
+		count, err := ci.Count(ctx, mongox.M{"name": "alice"})
 		if err != nil {
 			t.Error(err)
 		}
-		if id2.IsZero() {
-			t.Error("expected non-zero ObjectID from generic InsertOne with strict validation")
+		if count != 1 {
+			t.Errorf("expected %v, got %v", 1, count)
+		}
+		if _, err := ci.SetFields(ctx, mongox.M{"name": "aLiCe"}, mongox.M{"number": 42}); err != nil {
+			t.Error(err)
+		}
+		if err := ci.CreateIndex(ctx, false, "name"); err != nil {
+			t.Error(err)
+		}
+		if err := ci.DeleteOne(ctx, mongox.M{"name": "alice"}); err != nil {
+			t.Error(err)
 		}
-
-		// Cleanup
-		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"strictone1", "strictone2"}}})
 	})
 
-	t.Run("InsertOne_WithCustomID", func(t *testing.T) {
-		// Test InsertOne with a document that has a custom string ID
-		// This should succeed in non-strict mode but return empty ObjectID
-		entityWithCustomID := struct {
-			ID   string `bson:"_id"`
-			Name string `bson:"name"`
-		}{
-			ID:   "custom-string-id",
-			Name: "test-entity",
+	t.Run("WithComment", func(t *testing.T) {
+		if err := db.Database().RunCommand(ctx, bson.D{{Key: "profile", Value: 2}}).Err(); err != nil {
+			t.Fatal(err)
 		}
+		defer db.Database().RunCommand(ctx, bson.D{{Key: "profile", Value: 0}})
 
-		id, err := coll.InsertOne(ctx, entityWithCustomID)
+		commented := coll.WithComment("trace-123")
+		var result testEntity
+		if err := commented.FindOne(ctx, &result, mongox.M{"id": "1"}); err != nil {
+			t.Error(err)
+		}
+		if !reflect.DeepEqual(entity, result) {
+			t.Errorf("expected %v, got %v", entity, result)
+		}
+		if _, err := commented.SetFields(ctx, mongox.M{"id": "1"}, mongox.M{"name": entity.Name}); err != nil {
+			t.Error(err)
+		}
+
+		n, err := db.Collection("system.profile").Count(ctx, mongox.M{"command.comment": "trace-123"})
 		if err != nil {
 			t.Error(err)
 		}
-		if !id.IsZero() {
-			t.Error("expected zero ObjectID when using custom string ID")
+		if n < 2 {
+			t.Errorf("expected at least %d profiled operations with comment, got %d", 2, n)
 		}
+	})
 
-		// Test with strict validation - should fail
-		entityWithCustomID2 := struct {
-			ID   string `bson:"_id"`
-			Name string `bson:"name"`
-		}{
-			ID:   "custom-string-id-2",
-			Name: "test-entity-2",
+	t.Run("ContextWithComment", func(t *testing.T) {
+		if err := db.Database().RunCommand(ctx, bson.D{{Key: "profile", Value: 2}}).Err(); err != nil {
+			t.Fatal(err)
 		}
+		defer db.Database().RunCommand(ctx, bson.D{{Key: "profile", Value: 0}})
 
-		_, err = coll.InsertOne(ctx, entityWithCustomID2, true)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected ErrInvalidArgument with strict validation and custom string ID, got %v", err)
+		traceCtx := mongox.ContextWithComment(ctx, "request-42")
+		var result testEntity
+		if err := coll.FindOne(traceCtx, &result, mongox.M{"id": "1"}); err != nil {
+			t.Error(err)
+		}
+		if err := coll.WithComment("explicit").FindOne(traceCtx, &result, mongox.M{"id": "1"}); err != nil {
+			t.Error(err)
 		}
 
-		// Cleanup
-		_ = coll.DeleteOne(ctx, mongox.M{"_id": "custom-string-id"})
+		n, err := db.Collection("system.profile").Count(ctx, mongox.M{"command.comment": "request-42"})
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 1 {
+			t.Errorf("expected %d profiled operation with comment, got %d", 1, n)
+		}
+		n, err = db.Collection("system.profile").Count(ctx, mongox.M{"command.comment": "explicit"})
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 1 {
+			t.Errorf("expected %d profiled operation with comment, got %d", 1, n)
+		}
 	})
 
-	t.Run("InsertOne_ErrorHandling", func(t *testing.T) {
-		// Test InsertOne with nil record
-		_, err := coll.InsertOne(ctx, nil)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected ErrInvalidArgument for nil record, got %v", err)
+	t.Run("WithBSONOptions", func(t *testing.T) {
+		var result map[string]any
+		if err := coll.FindOne(ctx, &result, mongox.M{"id": "1"}); err != nil {
+			t.Error(err)
+		}
+		if _, ok := result["struct"].(bson.D); !ok {
+			t.Errorf("expected %T, got %T", bson.D{}, result["struct"])
 		}
 
-		// Test InsertOne with invalid record type
-		_, err = coll.InsertOne(ctx, func() {})
-		if err == nil {
-			t.Error("expected error for invalid record type")
+		withM := coll.WithBSONOptions(mongox.BSONOptions{DefaultDocumentM: true})
+		result = nil
+		if err := withM.FindOne(ctx, &result, mongox.M{"id": "1"}); err != nil {
+			t.Error(err)
+		}
+		if _, ok := result["struct"].(bson.M); !ok {
+			t.Errorf("expected %T, got %T", bson.M{}, result["struct"])
 		}
+		if withM.Name() != coll.Name() {
+			t.Errorf("expected %v, got %v", coll.Name(), withM.Name())
+		}
+	})
 
-		// Test duplicate key error with custom _id field
-		entityWithFixedID := struct {
-			ID   string `bson:"_id"`
-			Name string `bson:"name"`
-		}{
-			ID:   "fixed-duplicate-test",
-			Name: "test-entity",
+	t.Run("WithTimeout", func(t *testing.T) {
+		var result testEntity
+		err := coll.WithTimeout(time.Nanosecond).FindOne(ctx, &result, mongox.M{"id": "1"})
+		if !errors.Is(err, mongox.ErrTimeout) {
+			t.Errorf("expected error %v, got %v", mongox.ErrTimeout, err)
 		}
 
-		_, err = coll.InsertOne(ctx, entityWithFixedID)
-		if err != nil {
+		// Original collection is not affected
+		if err := coll.FindOne(ctx, &result, mongox.M{"id": "1"}); err != nil {
 			t.Error(err)
 		}
+		if err := coll.WithTimeout(time.Minute).WithComment("long").FindOne(ctx, &result, mongox.M{"id": "1"}); err != nil {
+			t.Error(err)
+		}
+	})
 
-		// Try to insert the same entity again (should fail due to duplicate _id)
-		_, err = coll.InsertOne(ctx, entityWithFixedID)
-		if !errors.Is(err, mongox.ErrDuplicate) {
-			t.Errorf("expected ErrDuplicate for duplicate _id, got %v", err)
+	t.Run("KillOp", func(t *testing.T) {
+		errCh := make(chan error, 1)
+		go func() {
+			var result []testEntity
+			errCh <- coll.WithComment("runaway").Find(ctx, &result, mongox.M{"$where": "function() { sleep(10000); return true; }"})
+		}()
+
+		var opid int64
+		for i := 0; i < 50 && opid == 0; i++ {
+			ops, err := db.CurrentOps(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, op := range ops {
+				if op.Comment == "runaway" {
+					opid = op.OpID
+				}
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if opid == 0 {
+			t.Fatal("expected to find operation by comment")
 		}
 
-		// Cleanup
-		_ = coll.DeleteOne(ctx, mongox.M{"_id": "fixed-duplicate-test"})
+		if err := db.KillOp(ctx, opid); err != nil {
+			t.Error(err)
+		}
+		select {
+		case err := <-errCh:
+			if err == nil {
+				t.Error("expected error for killed operation")
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("expected operation to be killed")
+		}
 	})
+}
 
-	t.Run("Insert_Basic", func(t *testing.T) {
-		// Test basic Insert functionality (non-strict by default)
-		entity1 := newTestEntity("insert1")
-		entity2 := newTestEntity("insert2")
-		entity3 := newTestEntity("insert3")
+func TestClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("Warmup", func(t *testing.T) {
+		if err := client.Warmup(ctx, 5); err != nil {
+			t.Error(err)
+		}
+		// More than MaxPoolSize is bounded
+		if err := client.Warmup(ctx, 1000); err != nil {
+			t.Error(err)
+		}
 
-		ids, err := coll.Insert(ctx, entity1, entity2, entity3)
+		canceledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		if err := client.Warmup(canceledCtx, 5); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected error %v, got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("FCV", func(t *testing.T) {
+		fcv, err := client.GetFCV(ctx)
 		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(fcv, ".") {
+			t.Errorf("expected version like 7.0, got %q", fcv)
+		}
+
+		// Setting the current version is a no-op
+		if err := client.SetFCV(ctx, fcv); err != nil {
 			t.Error(err)
 		}
-		if len(ids) != 3 {
-			t.Errorf("expected 3 IDs, got %d", len(ids))
+		err = client.SetFCV(ctx, "1.0")
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-		for i, id := range ids {
-			if id.IsZero() {
-				t.Errorf("expected non-zero ObjectID at index %d", i)
-			}
+		err = client.SetFCV(ctx, "")
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
+	})
 
-		// Verify all documents were inserted
-		var results []testEntity
-		err = coll.Find(ctx, &results, mongox.M{"id": mongox.M{mongox.In: []string{"insert1", "insert2", "insert3"}}})
-		if err != nil {
-			t.Error(err)
+	t.Run("WithConfig", func(t *testing.T) {
+		scoped := client.WithConfig(mongox.ConfigOverrides{
+			WriteConcern: writeconcern.Majority(),
+			ReadConcern:  readconcern.Majority(),
+		})
+		if scoped.Client() != client.Client() {
+			t.Error("expected scoped client to share the underlying client")
 		}
-		if len(results) != 3 {
-			t.Errorf("expected 3 documents found, got %d", len(results))
+		if scoped.Database(dbName) == client.Database(dbName) {
+			t.Error("expected scoped client to have its own databases")
 		}
 
-		// Test generic Insert
-		entity4 := newTestEntity("insert4")
-		entity5 := newTestEntity("insert5")
+		coll := scoped.Database(dbName).Collection("scoped_client_test")
+		entity := newTestEntity("1")
+		if _, err := coll.Insert(ctx, entity); err != nil {
+			t.Error(err)
+		}
 
-		ids2, err := mongox.Insert(ctx, coll, entity4, entity5)
+		result, err := mongox.FindOne[testEntity](ctx, client.Database(dbName).Collection("scoped_client_test"), mongox.M{"id": "1"})
 		if err != nil {
 			t.Error(err)
 		}
-		if len(ids2) != 2 {
-			t.Errorf("expected 2 IDs from generic Insert, got %d", len(ids2))
+		if !reflect.DeepEqual(entity, result) {
+			t.Errorf("expected %v, got %v", entity, result)
 		}
 
-		// Cleanup
-		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"insert1", "insert2", "insert3", "insert4", "insert5"}}})
+		nested := scoped.WithConfig(mongox.ConfigOverrides{WriteConcern: writeconcern.Unacknowledged()})
+		if err := nested.Ping(ctx); err != nil {
+			t.Error(err)
+		}
 	})
+}
 
-	t.Run("Insert_WithCustomIDs", func(t *testing.T) {
-		// Test Insert with custom string IDs (non-strict mode)
-		entitiesWithCustomIDs := []any{
-			struct {
-				ID   string `bson:"_id"`
-				Name string `bson:"name"`
-			}{
-				ID:   "custom-1",
-				Name: "entity-1",
-			},
-			struct {
-				ID   string `bson:"_id"`
-				Name string `bson:"name"`
-			}{
-				ID:   "custom-2",
-				Name: "entity-2",
-			},
+func TestSlowOperation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu    sync.Mutex
+		calls []string
+		last  mongox.M
+	)
+	cfg := testConfig
+	cfg.SlowQueryThreshold = time.Nanosecond
+	cfg.OnSlowOperation = func(op, collection string, dur time.Duration, filter mongox.M) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, collection+"."+op)
+		last = filter
+	}
+
+	slowClient, err := mongox.Connect(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slowClient.Disconnect(ctx)
+
+	coll := slowClient.Database(dbName).Collection("slow_operation_test")
+	filter := mongox.M{"id": "secret", "number": mongox.M{mongox.Gt: 10}}
+
+	_, err = coll.Insert(ctx, newTestEntity("secret"))
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = coll.Count(ctx, filter)
+	if err != nil {
+		t.Error(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(calls) != 2 || calls[0] != "slow_operation_test.insert_many" || calls[1] != "slow_operation_test.count" {
+		t.Errorf("unexpected calls %v", calls)
+	}
+	if last["id"] != "?" {
+		t.Errorf("expected redacted value, got %v", last["id"])
+	}
+	if gt, ok := last["number"].(mongox.M); !ok || gt[mongox.Gt] != "?" {
+		t.Errorf("expected redacted operator value, got %v", last["number"])
+	}
+	if filter["id"] != "secret" {
+		t.Errorf("original filter is modified: %v", filter)
+	}
+}
+
+func TestGuardUnboundedWrites(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := testConfig
+	cfg.GuardUnboundedWrites = true
+
+	guardedClient, err := mongox.Connect(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer guardedClient.Disconnect(ctx)
+
+	coll := guardedClient.Database(dbName).Collection("guard_unbounded_writes_test")
+	_, err = coll.Insert(ctx, newTestEntity("1"), newTestEntity("2"), newTestEntity("3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = coll.UpdateMany(ctx, nil, mongox.M{mongox.Set: mongox.M{"name": "all"}})
+	if !errors.Is(err, mongox.ErrEmptyFilter) || !errors.Is(err, mongox.ErrInvalidArgument) {
+		t.Errorf("expected error %v, got %v", mongox.ErrEmptyFilter, err)
+	}
+	_, err = coll.DeleteMany(ctx, mongox.M{})
+	if !errors.Is(err, mongox.ErrEmptyFilter) {
+		t.Errorf("expected error %v, got %v", mongox.ErrEmptyFilter, err)
+	}
+
+	// Single document writes are guarded too
+	singleWrites := map[string]error{
+		"UpdateOne": func() error {
+			_, err := coll.UpdateOne(ctx, nil, mongox.M{mongox.Set: mongox.M{"name": "any"}})
+			return err
+		}(),
+		"SetFields": func() error {
+			_, err := coll.SetFields(ctx, mongox.M{}, mongox.M{"name": "any"})
+			return err
+		}(),
+		"ReplaceOne": coll.ReplaceOne(ctx, newTestEntity("4"), nil),
+		"DeleteOne":  coll.DeleteOne(ctx, nil),
+		"IncFields":  coll.IncFields(ctx, nil, map[string]int64{"number": 1}),
+		"FindOneAndDelete": func() error {
+			var res testEntity
+			return coll.FindOneAndDelete(ctx, &res, nil)
+		}(),
+	}
+	for op, err := range singleWrites {
+		if !errors.Is(err, mongox.ErrEmptyFilter) {
+			t.Errorf("%s: expected error %v, got %v", op, mongox.ErrEmptyFilter, err)
 		}
+	}
 
-		ids, err := coll.Insert(ctx, entitiesWithCustomIDs...)
+	// Reads are permissive
+	var first testEntity
+	if err := coll.FindOne(ctx, &first, nil); err != nil {
+		t.Error(err)
+	}
+
+	n, err := coll.Count(ctx, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 3 {
+		t.Errorf("expected %v, got %v", 3, n)
+	}
+
+	// Bounded writes are not affected
+	n2, err := coll.DeleteMany(ctx, mongox.M{"id": "3"})
+	if err != nil {
+		t.Error(err)
+	}
+	if n2 != 1 {
+		t.Errorf("expected %v, got %v", 1, n2)
+	}
+
+	// Explicitly allowed
+	updated, err := coll.UpdateMany(mongox.AllowUnboundedWrites(ctx), nil, mongox.M{mongox.Set: mongox.M{"name": "all"}})
+	if err != nil {
+		t.Error(err)
+	}
+	if updated != 2 {
+		t.Errorf("expected %v, got %v", 2, updated)
+	}
+	deleted, err := mongox.DeleteMany(mongox.AllowUnboundedWrites(ctx), coll, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected %v, got %v", 2, deleted)
+	}
+}
+
+func TestCheckDocumentSize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := testConfig
+	cfg.CheckDocumentSize = true
+	cfg.MaxDocumentSize = 1024
+
+	checkedClient, err := mongox.Connect(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checkedClient.Disconnect(ctx)
+
+	coll := checkedClient.Database(dbName).Collection("check_document_size_test")
+	large := mongox.M{"id": "large", "data": strings.Repeat("x", 2048)}
+
+	_, err = coll.Insert(ctx, newTestEntity("1"))
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = coll.Insert(ctx, newTestEntity("2"), large)
+	if !errors.Is(err, mongox.ErrInvalidArgument) || !errors.Is(err, mongox.ErrBSONObjectTooLarge) {
+		t.Errorf("expected error %v, got %v", mongox.ErrBSONObjectTooLarge, err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "data=") {
+		t.Errorf("expected size of data field in error, got %v", err)
+	}
+
+	bulker := mongox.NewBulkBuilder()
+	bulker.Insert(large)
+	_, err = coll.BulkWrite(ctx, bulker.Models(), true)
+	if !errors.Is(err, mongox.ErrBSONObjectTooLarge) {
+		t.Errorf("expected error %v, got %v", mongox.ErrBSONObjectTooLarge, err)
+	}
+
+	// Nothing is inserted after failed checks
+	n, err := coll.Count(ctx, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 1 {
+		t.Errorf("expected %d, got %d", 1, n)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := client.Database(dbName)
+	coll := db.Collection("pipeline_test")
+
+	t.Run("Group", func(t *testing.T) {
+		stage, err := mongox.Group("$country").Sum("total", "$amount").Avg("mean", "$score").Count("n").Stage()
 		if err != nil {
 			t.Error(err)
 		}
-		if len(ids) != 2 {
-			t.Errorf("expected 2 IDs, got %d", len(ids))
+		group, ok := stage[mongox.StageGroup].(mongox.M)
+		if !ok {
+			t.Fatalf("expected $group stage, got %v", stage)
 		}
-		// With custom string IDs, the returned ObjectIDs should be zero
-		for i, id := range ids {
-			if !id.IsZero() {
-				t.Errorf("expected zero ObjectID at index %d when using custom string ID", i)
-			}
+		if group["_id"] != "$country" {
+			t.Errorf("expected %s, got %v", "$country", group["_id"])
+		}
+		if !reflect.DeepEqual(group["total"], mongox.M{mongox.AccSum: "$amount"}) ||
+			!reflect.DeepEqual(group["mean"], mongox.M{mongox.AccAvg: "$score"}) ||
+			!reflect.DeepEqual(group["n"], mongox.M{mongox.AccSum: 1}) {
+			t.Errorf("unexpected group stage %v", group)
 		}
 
-		// Verify documents were inserted
-		count, err := coll.Count(ctx, mongox.M{"_id": mongox.M{mongox.In: []string{"custom-1", "custom-2"}}})
+		_, err = mongox.Group(nil).Sum("", "$amount").Stage()
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		_, err = mongox.NewPipelineBuilder().
+			Match(mongox.M{"status": "active"}).
+			Group(mongox.Group("$country").Count("")).
+			Build()
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+
+		pipeline, err := mongox.NewPipelineBuilder().
+			Match(mongox.M{"status": "active"}).
+			Group(mongox.Group("$country").Count("n")).
+			Sort(mongox.M{"n": mongox.Descending}).
+			Limit(10).
+			Build()
 		if err != nil {
 			t.Error(err)
 		}
-		if count != 2 {
-			t.Errorf("expected 2 documents with custom IDs, got %d", count)
+		if len(pipeline) != 4 {
+			t.Errorf("expected 4 stages, got %d", len(pipeline))
 		}
-
-		// Cleanup
-		_, _ = coll.DeleteMany(ctx, mongox.M{"_id": mongox.M{mongox.In: []string{"custom-1", "custom-2"}}})
 	})
 
-	t.Run("InsertStrict_Basic", func(t *testing.T) {
-		// Test InsertStrict functionality
-		entity1 := newTestEntity("strict1")
-		entity2 := newTestEntity("strict2")
+	t.Run("Generic_TopNPerGroup", func(t *testing.T) {
+		topColl := db.Collection("pipeline_top_n_test")
+		_, err := topColl.Insert(ctx,
+			mongox.M{"user": "alice", "score": 10},
+			mongox.M{"user": "alice", "score": 30},
+			mongox.M{"user": "alice", "score": 20},
+			mongox.M{"user": "alice", "score": 5},
+			mongox.M{"user": "bob", "score": 7},
+			mongox.M{"score": 100},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
 
-		ids, err := coll.InsertStrict(ctx, entity1, entity2)
+		type score struct {
+			User  string `bson:"user"`
+			Score int    `bson:"score"`
+		}
+		top, err := mongox.TopNPerGroup[score](ctx, topColl, mongox.M{"score": mongox.M{mongox.Lt: 100}}, "user", "score", 2)
 		if err != nil {
 			t.Error(err)
 		}
-		if len(ids) != 2 {
-			t.Errorf("expected 2 IDs from InsertStrict, got %d", len(ids))
+		expected := map[string][]score{
+			"alice": {{User: "alice", Score: 30}, {User: "alice", Score: 20}},
+			"bob":   {{User: "bob", Score: 7}},
 		}
-		for i, id := range ids {
-			if id.IsZero() {
-				t.Errorf("expected non-zero ObjectID at index %d from InsertStrict", i)
-			}
+		if !reflect.DeepEqual(top, expected) {
+			t.Errorf("expected %v, got %v", expected, top)
 		}
 
-		// Test generic InsertStrict
-		entity3 := newTestEntity("strict3")
-		ids2, err := mongox.InsertStrict(ctx, coll, entity3)
+		top, err = mongox.TopNPerGroup[score](ctx, topColl, nil, "user", "score", 1)
 		if err != nil {
 			t.Error(err)
 		}
-		if len(ids2) != 1 {
-			t.Errorf("expected 1 ID from generic InsertStrict, got %d", len(ids2))
-		}
-		if ids2[0].IsZero() {
-			t.Error("expected non-zero ObjectID from generic InsertStrict")
+		if len(top) != 3 || len(top[""]) != 1 || top[""][0].Score != 100 {
+			t.Errorf("expected group with empty key, got %v", top)
 		}
 
-		// Verify all documents were inserted
-		var results []testEntity
-		err = coll.Find(ctx, &results, mongox.M{"id": mongox.M{mongox.In: []string{"strict1", "strict2", "strict3"}}})
-		if err != nil {
-			t.Error(err)
+		// Values of different types with the same string form are not merged silently
+		collideColl := db.Collection("pipeline_top_n_collide_test")
+		if _, err := collideColl.Insert(ctx, mongox.M{"user": 1, "score": 1}, mongox.M{"user": "1", "score": 2}); err != nil {
+			t.Fatal(err)
 		}
-		if len(results) != 3 {
-			t.Errorf("expected 3 documents found, got %d", len(results))
+		_, err = mongox.TopNPerGroup[mongox.M](ctx, collideColl, nil, "user", "score", 1)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
 
-		// Cleanup
-		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"strict1", "strict2", "strict3"}}})
+		_, err = mongox.TopNPerGroup[score](ctx, topColl, nil, "user", "score", 0)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
+		_, err = mongox.TopNPerGroup[score](ctx, topColl, nil, "", "score", 1)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
+		}
 	})
 
-	t.Run("InsertStrict_WithCustomIDs", func(t *testing.T) {
-		// First cleanup any potentially existing documents with these IDs
-		_, _ = coll.DeleteMany(ctx, mongox.M{"_id": mongox.M{mongox.In: []string{"strict-custom-unique-1", "strict-custom-unique-2"}}})
-
-		// Test InsertStrict with custom string IDs - should fail
-		entitiesWithCustomIDs := []any{
-			struct {
-				ID   string `bson:"_id"`
-				Name string `bson:"name"`
-			}{
-				ID:   "strict-custom-unique-1",
-				Name: "entity-1",
-			},
-			struct {
-				ID   string `bson:"_id"`
-				Name string `bson:"name"`
-			}{
-				ID:   "strict-custom-unique-2",
-				Name: "entity-2",
-			},
+	t.Run("Generic_GroupByMany", func(t *testing.T) {
+		groupColl := db.Collection("pipeline_group_many_test")
+		_, err := groupColl.Insert(ctx,
+			mongox.M{"country": "US", "plan": "pro", "amount": 10},
+			mongox.M{"country": "US", "plan": "pro", "amount": 20},
+			mongox.M{"country": "US", "plan": "free", "amount": 0},
+			mongox.M{"country": "DE", "plan": "pro", "amount": 15},
+			mongox.M{"country": "DE", "amount": 5},
+		)
+		if err != nil {
+			t.Fatal(err)
 		}
 
-		_, err := coll.InsertStrict(ctx, entitiesWithCustomIDs...)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected ErrInvalidArgument with InsertStrict and custom string IDs, got %v", err)
+		type stats struct {
+			N     int `bson:"n"`
+			Total int `bson:"total"`
 		}
-
-		// Test generic InsertStrict with custom ID (use a different ID)
-		singleEntityWithCustomID := struct {
-			ID   string `bson:"_id"`
-			Name string `bson:"name"`
-		}{
-			ID:   "strict-generic-unique-1",
-			Name: "entity-generic",
+		result, err := mongox.GroupByMany[stats](ctx, groupColl, nil, []string{"country", "plan"}, mongox.M{
+			"n":     mongox.M{mongox.AccSum: 1},
+			"total": mongox.M{mongox.AccSum: "$amount"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]stats{
+			`["US","pro"]`:  {N: 2, Total: 30},
+			`["US","free"]`: {N: 1, Total: 0},
+			`["DE","pro"]`:  {N: 1, Total: 15},
+			`["DE",null]`:   {N: 1, Total: 5},
 		}
-
-		_, err = mongox.InsertStrict(ctx, coll, singleEntityWithCustomID)
-		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected ErrInvalidArgument with generic InsertStrict and custom string ID, got %v", err)
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("expected %v, got %v", expected, result)
 		}
-	})
 
-	t.Run("Insert_ErrorHandling", func(t *testing.T) {
-		// Test Insert with nil records
-		_, err := coll.Insert(ctx)
+		result, err = mongox.GroupByMany[stats](ctx, groupColl, mongox.M{"country": "DE"}, []string{"plan"}, mongox.M{
+			"n": mongox.M{mongox.AccSum: 1},
+		})
 		if err != nil {
-			t.Error("Insert with no records should not error")
+			t.Error(err)
+		}
+		if len(result) != 2 || result[`["pro"]`].N != 1 {
+			t.Errorf("unexpected result %v", result)
 		}
 
-		_, err = coll.Insert(ctx, nil)
+		oid := bson.NewObjectID()
+		collideColl := db.Collection("pipeline_group_many_collide_test")
+		if _, err := collideColl.Insert(ctx, mongox.M{"ref": oid}, mongox.M{"ref": oid.Hex()}); err != nil {
+			t.Fatal(err)
+		}
+		_, err = mongox.GroupByMany[stats](ctx, collideColl, nil, []string{"ref"}, mongox.M{"n": mongox.M{mongox.AccSum: 1}})
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected ErrInvalidArgument for nil record in Insert, got %v", err)
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
 
-		// Test Insert with mixed valid and invalid records
-		entity := newTestEntity("mixed")
-		_, err = coll.Insert(ctx, entity, nil)
+		_, err = mongox.GroupByMany[stats](ctx, groupColl, nil, nil, nil)
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected ErrInvalidArgument for mixed valid/nil records, got %v", err)
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
-
-		// Test InsertStrict with nil records
-		_, err = coll.InsertStrict(ctx, nil)
+		_, err = mongox.GroupByMany[stats](ctx, groupColl, nil, []string{"country"}, mongox.M{"_id": 1})
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected ErrInvalidArgument for nil record in InsertStrict, got %v", err)
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
 	})
 
-	t.Run("StrictID_Comparison", func(t *testing.T) {
-		// Compare behavior between strict and non-strict modes
+	t.Run("AggregateEach", func(t *testing.T) {
+		eachColl := db.Collection("pipeline_each_test")
+		records := make([]any, 0, 100)
+		for i := range 100 {
+			records = append(records, mongox.M{"i": i, "group": i % 10})
+		}
+		if _, err := eachColl.InsertMany(ctx, records); err != nil {
+			t.Fatal(err)
+		}
 
-		// Create an entity with custom ID
-		entityWithCustomID := struct {
-			ID   string `bson:"_id"`
-			Name string `bson:"name"`
-		}{
-			ID:   "comparison-test",
-			Name: "test-entity",
+		pipeline, err := mongox.NewPipelineBuilder().
+			Group(mongox.Group("$group").Count("n").Sum("total", "$i")).
+			Sort(mongox.M{"_id": mongox.Ascending}).
+			Build()
+		if err != nil {
+			t.Fatal(err)
 		}
 
-		// Non-strict mode should succeed and return zero ObjectID
-		ids, err := coll.Insert(ctx, entityWithCustomID)
+		type row struct {
+			Group int `bson:"_id"`
+			N     int `bson:"n"`
+			Total int `bson:"total"`
+		}
+		var rows []row
+		err = eachColl.AggregateEach(ctx, pipeline, func(decode func(any) error) error {
+			var r row
+			if err := decode(&r); err != nil {
+				return err
+			}
+			rows = append(rows, r)
+			return nil
+		}, mongox.AggregateOptions{BatchSize: 3})
 		if err != nil {
 			t.Error(err)
 		}
-		if len(ids) != 1 || !ids[0].IsZero() {
-			t.Error("non-strict Insert should return zero ObjectID for custom string ID")
+		if len(rows) != 10 {
+			t.Fatalf("expected %d, got %d", 10, len(rows))
+		}
+		for i, r := range rows {
+			if r.Group != i || r.N != 10 || r.Total != 10*i+450 {
+				t.Errorf("unexpected row %d: %+v", i, r)
+			}
 		}
 
-		// Cleanup before next test
-		_ = coll.DeleteOne(ctx, mongox.M{"_id": "comparison-test"})
+		// Stop on callback error
+		errStop := errors.New("stop")
+		calls := 0
+		err = eachColl.AggregateEach(ctx, pipeline, func(decode func(any) error) error {
+			calls++
+			return errStop
+		})
+		if !errors.Is(err, errStop) {
+			t.Errorf("expected error %v, got %v", errStop, err)
+		}
+		if calls != 1 {
+			t.Errorf("expected %d, got %d", 1, calls)
+		}
 
-		// Strict mode should fail
-		_, err = coll.InsertStrict(ctx, entityWithCustomID)
+		err = eachColl.AggregateEach(ctx, pipeline, nil)
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("strict Insert should fail with custom string ID, got error: %v", err)
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
+	})
 
-		// Test with regular testEntity (should work in both modes)
-		entity := newTestEntity("comparison-regular")
-
-		// Non-strict mode
-		ids, err = coll.Insert(ctx, entity)
+	t.Run("Generic_Aggregate", func(t *testing.T) {
+		sales := db.Collection("generic_aggregate_test")
+		_, err := sales.Insert(ctx,
+			mongox.M{"country": "de", "amount": 10},
+			mongox.M{"country": "de", "amount": 20},
+			mongox.M{"country": "fr", "amount": 5},
+		)
 		if err != nil {
-			t.Error(err)
+			t.Fatal(err)
 		}
-		if len(ids) != 1 || ids[0].IsZero() {
-			t.Error("non-strict Insert should return non-zero ObjectID for regular entity")
+
+		pipeline, err := mongox.NewPipelineBuilder().
+			Group(mongox.Group("$country").Sum("total", "$amount")).
+			Sort(mongox.M{"_id": mongox.Ascending}).
+			Build()
+		if err != nil {
+			t.Fatal(err)
 		}
 
-		// Cleanup
-		_ = coll.DeleteOne(ctx, mongox.M{"id": "comparison-regular"})
+		type total struct {
+			Country string `bson:"_id"`
+			Total   int    `bson:"total"`
+		}
+		res, err := mongox.Aggregate[total](ctx, sales, pipeline, mongox.AggregateOptions{AllowDiskUse: true, MaxTime: time.Minute})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []total{{Country: "de", Total: 30}, {Country: "fr", Total: 5}}
+		if !reflect.DeepEqual(res, expected) {
+			t.Errorf("expected %v, got %v", expected, res)
+		}
 
-		// Strict mode
-		ids, err = coll.InsertStrict(ctx, entity)
+		empty, err := mongox.Aggregate[total](ctx, sales, []mongox.M{{mongox.StageMatch: mongox.M{"country": "it"}}})
 		if err != nil {
 			t.Error(err)
 		}
-		if len(ids) != 1 || ids[0].IsZero() {
-			t.Error("strict Insert should return non-zero ObjectID for regular entity")
+		if len(empty) != 0 {
+			t.Errorf("expected empty result, got %v", empty)
 		}
 
-		// Cleanup
-		_ = coll.DeleteOne(ctx, mongox.M{"id": "comparison-regular"})
+		_, err = mongox.Aggregate[total](ctx, sales, pipeline, mongox.AggregateOptions{MaxTime: time.Nanosecond})
+		if !errors.Is(err, mongox.ErrMaxTimeMSExpired) {
+			t.Errorf("expected error %v, got %v", mongox.ErrMaxTimeMSExpired, err)
+		}
+		_, err = mongox.Aggregate[total](ctx, sales, []mongox.M{{"$unknownStage": 1}})
+		if err == nil {
+			t.Error("expected error for unknown stage")
+		}
 	})
 
-	t.Run("InsertMany_StrictID", func(t *testing.T) {
-		// Test InsertMany with strict ID parameter
-		entities := []any{
-			newTestEntity("many1"),
-			newTestEntity("many2"),
-			newTestEntity("many3"),
+	t.Run("Generic_Sample", func(t *testing.T) {
+		users := db.Collection("sample_test")
+		records := make([]any, 0, 20)
+		for i := range 20 {
+			records = append(records, mongox.M{"id": strconv.Itoa(i), "active": i%2 == 0})
+		}
+		if _, err := users.Insert(ctx, records...); err != nil {
+			t.Fatal(err)
 		}
 
-		// Test InsertMany with strict=false
-		ids, err := coll.InsertMany(ctx, entities, false)
+		type user struct {
+			ID     string `bson:"id"`
+			Active bool   `bson:"active"`
+		}
+		res, err := mongox.Sample[user](ctx, users, 5, mongox.M{"active": true})
 		if err != nil {
-			t.Error(err)
+			t.Fatal(err)
 		}
-		if len(ids) != 3 {
-			t.Errorf("expected 3 IDs from InsertMany, got %d", len(ids))
+		if len(res) != 5 {
+			t.Errorf("expected %d, got %d", 5, len(res))
 		}
-		for i, id := range ids {
-			if id.IsZero() {
-				t.Errorf("expected non-zero ObjectID at index %d from InsertMany", i)
+		seen := make(map[string]bool, len(res))
+		for _, u := range res {
+			if !u.Active || seen[u.ID] {
+				t.Errorf("expected distinct active users, got %v", res)
 			}
+			seen[u.ID] = true
 		}
 
-		// Cleanup
-		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"many1", "many2", "many3"}}})
-
-		// Test InsertMany with strict=true
-		ids, err = coll.InsertMany(ctx, entities, true)
+		// Size greater than the number of matching documents returns all of them
+		res, err = mongox.Sample[user](ctx, users, 100, nil)
 		if err != nil {
 			t.Error(err)
 		}
-		if len(ids) != 3 {
-			t.Errorf("expected 3 IDs from strict InsertMany, got %d", len(ids))
+		if len(res) != 20 {
+			t.Errorf("expected %d, got %d", 20, len(res))
 		}
-		for i, id := range ids {
-			if id.IsZero() {
-				t.Errorf("expected non-zero ObjectID at index %d from strict InsertMany", i)
-			}
+
+		_, err = mongox.Sample[user](ctx, users, 0, nil)
+		if !errors.Is(err, mongox.ErrInvalidArgument) {
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
+	})
 
-		// Cleanup
-		_, _ = coll.DeleteMany(ctx, mongox.M{"id": mongox.M{mongox.In: []string{"many1", "many2", "many3"}}})
+	t.Run("LookupPipeline", func(t *testing.T) {
+		users := db.Collection("lookup_users_test")
+		orders := db.Collection("lookup_orders_test")
+		if _, err := users.Insert(ctx, mongox.M{"id": "u1"}, mongox.M{"id": "u2"}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := orders.Insert(ctx,
+			mongox.M{"user_id": "u1", "amount": 10},
+			mongox.M{"user_id": "u1", "amount": 200},
+			mongox.M{"user_id": "u1", "amount": 300},
+			mongox.M{"user_id": "u2", "amount": 5},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
 
-		// Test InsertMany with custom IDs and strict=true (should fail)
-		entitiesWithCustomIDs := []any{
-			struct {
-				ID   string `bson:"_id"`
-				Name string `bson:"name"`
-			}{
-				ID:   "many-custom-unique-1",
-				Name: "entity-1",
-			},
-			struct {
-				ID   string `bson:"_id"`
-				Name string `bson:"name"`
-			}{
-				ID:   "many-custom-unique-2",
-				Name: "entity-2",
-			},
+		pipeline, err := mongox.NewPipelineBuilder().
+			Sort(mongox.M{"id": mongox.Ascending}).
+			LookupPipeline(orders.Name(), mongox.M{"userID": "$id"}, []mongox.M{
+				{mongox.StageMatch: mongox.M{mongox.Expr: mongox.M{"$and": []any{
+					mongox.M{"$eq": []any{"$user_id", "$$userID"}},
+					mongox.M{"$gte": []any{"$amount", 100}},
+				}}}},
+				{mongox.StageSort: mongox.M{"amount": mongox.Descending}},
+				{mongox.StageProject: mongox.M{"_id": 0, "amount": 1}},
+			}, "big_orders").
+			Build()
+		if err != nil {
+			t.Fatal(err)
 		}
 
-		_, err = coll.InsertMany(ctx, entitiesWithCustomIDs, true)
+		var res []struct {
+			ID        string `bson:"id"`
+			BigOrders []struct {
+				Amount int `bson:"amount"`
+			} `bson:"big_orders"`
+		}
+		if err := users.Aggregate(ctx, &res, pipeline); err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 2 {
+			t.Fatalf("expected %d, got %d", 2, len(res))
+		}
+		if len(res[0].BigOrders) != 2 || res[0].BigOrders[0].Amount != 300 || res[0].BigOrders[1].Amount != 200 {
+			t.Errorf("expected orders [300 200] of u1, got %v", res[0].BigOrders)
+		}
+		if len(res[1].BigOrders) != 0 {
+			t.Errorf("expected no orders of u2, got %v", res[1].BigOrders)
+		}
+
+		_, err = mongox.NewPipelineBuilder().LookupPipeline("", nil, nil, "joined").Build()
 		if !errors.Is(err, mongox.ErrInvalidArgument) {
-			t.Errorf("expected ErrInvalidArgument with strict InsertMany and custom string IDs, got %v", err)
+			t.Errorf("expected error %v, got %v", mongox.ErrInvalidArgument, err)
 		}
+	})
 
-		// Create new entities with different IDs for the non-strict test
-		entitiesWithCustomIDs2 := []any{
-			struct {
-				ID   string `bson:"_id"`
-				Name string `bson:"name"`
-			}{
-				ID:   "many-custom-nonstrict-1",
-				Name: "entity-nonstrict-1",
-			},
-			struct {
-				ID   string `bson:"_id"`
-				Name string `bson:"name"`
-			}{
-				ID:   "many-custom-nonstrict-2",
-				Name: "entity-nonstrict-2",
-			},
+	t.Run("CountPipeline", func(t *testing.T) {
+		eachColl := db.Collection("pipeline_each_test")
+		pipeline, err := mongox.NewPipelineBuilder().
+			Match(mongox.M{"i": mongox.M{mongox.Gte: 50}}).
+			Group(mongox.Group("$group").Count("n")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
 		}
 
-		// Test InsertMany with custom IDs and strict=false (should succeed)
-		ids, err = coll.InsertMany(ctx, entitiesWithCustomIDs2, false)
+		n, err := eachColl.CountPipeline(ctx, pipeline)
 		if err != nil {
-			t.Errorf("non-strict InsertMany with custom IDs failed: %v", err)
+			t.Error(err)
 		}
-		if len(ids) != 2 {
-			t.Errorf("expected 2 IDs from non-strict InsertMany with custom IDs, got %d", len(ids))
+		if n != 10 {
+			t.Errorf("expected %v, got %v", 10, n)
 		}
-		for i, id := range ids {
-			if !id.IsZero() {
-				t.Errorf("expected zero ObjectID at index %d for custom string ID", i)
-			}
+		if len(pipeline) != 2 {
+			t.Errorf("expected pipeline not to be modified, got %v", pipeline)
 		}
 
-		// Cleanup
-		_, _ = coll.DeleteMany(ctx, mongox.M{"_id": mongox.M{mongox.In: []string{"many-custom-nonstrict-1", "many-custom-nonstrict-2"}}})
+		n, err = mongox.CountPipeline(ctx, eachColl, []mongox.M{{mongox.StageMatch: mongox.M{"i": mongox.M{mongox.Lt: 0}}}})
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 0 {
+			t.Errorf("expected %v, got %v", 0, n)
+		}
 	})
 
-	t.Run("BulkWrite_WithStrictID", func(t *testing.T) {
-		// Test bulk operations and verify ID handling
-		bulker := mongox.NewBulkBuilder()
+	t.Run("AggregateEach_ManyBatches", func(t *testing.T) {
+		eachColl := db.Collection("pipeline_each_test")
+		pipeline, err := mongox.NewPipelineBuilder().
+			Match(mongox.M{"group": mongox.M{mongox.Lt: 5}}).
+			Sort(mongox.M{"i": mongox.Ascending}).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
 
-		// Add various insert operations
-		entity1 := newTestEntity("bulk1")
-		entity2 := newTestEntity("bulk2")
-		entityWithCustomID := struct {
-			ID   string `bson:"_id"`
-			Name string `bson:"name"`
-		}{
-			ID:   "bulk-custom",
-			Name: "bulk-entity",
+		var seen []int
+		err = eachColl.AggregateEach(ctx, pipeline, func(decode func(any) error) error {
+			var r struct {
+				I int `bson:"i"`
+			}
+			if err := decode(&r); err != nil {
+				return err
+			}
+			seen = append(seen, r.I)
+			return nil
+		}, mongox.AggregateOptions{BatchSize: 2})
+		if err != nil {
+			t.Error(err)
+		}
+		// 50 documents in batches of 2 need many getMore commands
+		if len(seen) != 50 || !sort.IntsAreSorted(seen) {
+			t.Errorf("expected %d sorted documents, got %v", 50, seen)
 		}
 
-		bulker.Insert(entity1, entity2, entityWithCustomID)
+		if !mongox.IsRetryableRead(mongox.ErrCursorNotFound) || !mongox.IsRetryableRead(mongo.CommandError{Code: 43}) {
+			t.Errorf("expected %v to be retryable", mongox.ErrCursorNotFound)
+		}
+		// A killed cursor doesn't make a transaction retryable
+		if mongox.IsTransient(mongox.ErrCursorNotFound) || mongox.IsTransient(mongo.CommandError{Code: 43}) {
+			t.Errorf("expected %v not to be transient", mongox.ErrCursorNotFound)
+		}
+	})
 
-		result, err := coll.BulkWrite(ctx, bulker.Models(), false)
+	t.Run("Aggregate_MemoryLimit", func(t *testing.T) {
+		admin := client.Client().Database("admin")
+		setParams := func(allowDiskUse bool, sortMemory int) error {
+			return admin.RunCommand(ctx, bson.D{
+				{Key: "setParameter", Value: 1},
+				{Key: "allowDiskUseByDefault", Value: allowDiskUse},
+				{Key: "internalQueryMaxBlockingSortMemoryUsageBytes", Value: sortMemory},
+			}).Err()
+		}
+		if err := setParams(false, 1<<20); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := setParams(true, 100<<20); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		payload := strings.Repeat("x", 1024)
+		records := make([]any, 0, 2048)
+		for i := range 2048 {
+			records = append(records, mongox.M{"n": rand.Int(), "i": i, "payload": payload})
+		}
+		if _, err := coll.InsertMany(ctx, records); err != nil {
+			t.Fatal(err)
+		}
+		defer coll.Truncate(ctx)
+
+		pipeline, err := mongox.NewPipelineBuilder().Sort(mongox.M{"n": mongox.Ascending}).Build()
 		if err != nil {
-			t.Error(err)
+			t.Fatal(err)
 		}
-		if result.InsertedCount != 3 {
-			t.Errorf("expected 3 inserted documents, got %d", result.InsertedCount)
+
+		var result []mongox.M
+		err = coll.Aggregate(ctx, &result, pipeline)
+		if !errors.Is(err, mongox.ErrQueryExceededMemoryLimitNoDiskUseAllowed) {
+			t.Errorf("expected error %v, got %v", mongox.ErrQueryExceededMemoryLimitNoDiskUseAllowed, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "AllowDiskUse") {
+			t.Errorf("expected AllowDiskUse hint in error, got %v", err)
 		}
 
-		// Verify documents were inserted
-		count, err := coll.Count(ctx, mongox.M{"$or": []mongox.M{
-			{"id": mongox.M{mongox.In: []string{"bulk1", "bulk2"}}},
-			{"_id": "bulk-custom"},
-		}})
+		err = coll.Aggregate(ctx, &result, pipeline, mongox.AggregateOptions{AllowDiskUse: true})
 		if err != nil {
 			t.Error(err)
 		}
-		if count != 3 {
-			t.Errorf("expected 3 documents in bulk write, got %d", count)
+		if len(result) != len(records) {
+			t.Errorf("expected %d, got %d", len(records), len(result))
 		}
-
-		// Cleanup
-		_, _ = coll.DeleteMany(ctx, mongox.M{"$or": []mongox.M{
-			{"id": mongox.M{mongox.In: []string{"bulk1", "bulk2"}}},
-			{"_id": "bulk-custom"},
-		}})
 	})
-
-	// Final cleanup
-	_, err := coll.DeleteMany(ctx, nil)
-	if err != nil && !errors.Is(err, mongox.ErrNotFound) {
-		t.Error(err)
-	}
 }
@@ -0,0 +1,284 @@
+package mongox
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/maxbolgarin/lang"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Aggregation Pipeline Stages
+// https://www.mongodb.com/docs/manual/reference/operator/aggregation-pipeline/
+const (
+	// StageCount passes a document to the next stage that contains a count of the documents input to the stage.
+	StageCount = "$count"
+
+	// StageGroup separates documents into groups according to a group key.
+	StageGroup = "$group"
+
+	// StageLookup performs a left outer join to a collection in the same database.
+	StageLookup = "$lookup"
+
+	// StageLimit limits the number of documents passed to the next stage in the pipeline.
+	StageLimit = "$limit"
+
+	// StageMatch filters the documents to pass only the documents that match the specified conditions.
+	StageMatch = "$match"
+
+	// StageProject passes along the documents with the requested fields to the next stage in the pipeline.
+	StageProject = "$project"
+
+	// StageSample randomly selects the specified number of documents from the input documents.
+	StageSample = "$sample"
+
+	// StageSkip skips over the specified number of documents that pass into the stage.
+	StageSkip = "$skip"
+
+	// StageSort sorts all input documents and returns them to the pipeline in sorted order.
+	StageSort = "$sort"
+
+	// StageUnwind deconstructs an array field from the input documents to output a document for each element.
+	StageUnwind = "$unwind"
+)
+
+// Accumulator Operators
+// https://www.mongodb.com/docs/manual/reference/operator/aggregation/group/#accumulator-operator
+const (
+	// AccAddToSet returns an array of unique expression values for each group.
+	AccAddToSet = "$addToSet"
+
+	// AccAvg returns an average of numerical values.
+	AccAvg = "$avg"
+
+	// AccFirst returns a value from the first document for each group.
+	AccFirst = "$first"
+
+	// AccLast returns a value from the last document for each group.
+	AccLast = "$last"
+
+	// AccMax returns the highest expression value for each group.
+	AccMax = "$max"
+
+	// AccMin returns the lowest expression value for each group.
+	AccMin = "$min"
+
+	// AccPush returns an array of expression values for documents in each group.
+	AccPush = "$push"
+
+	// AccSum returns a sum of numerical values.
+	AccSum = "$sum"
+)
+
+// Accumulator is a builder for the $group aggregation stage.
+// Create it with [Group] and add accumulators with chained calls, e.g.
+//
+//	mongox.Group("$country").Sum("total", "$amount").Avg("mean", "$score").Count("n")
+//
+// It produces {$group: {_id: "$country", total: {$sum: "$amount"}, mean: {$avg: "$score"}, n: {$sum: 1}}}.
+// It is NOT thread-safe, use it in a single goroutine.
+type Accumulator struct {
+	fields M
+	err    error
+}
+
+// Group returns a new [Accumulator] for the $group stage with the provided group key expression.
+// Use nil idExpr to calculate accumulated values for all the input documents as a whole.
+func Group(idExpr any) *Accumulator {
+	return &Accumulator{fields: M{"_id": idExpr}}
+}
+
+// Sum adds {field: {$sum: expr}} accumulator to the group.
+func (a *Accumulator) Sum(field string, expr any) *Accumulator {
+	return a.Add(field, AccSum, expr)
+}
+
+// Avg adds {field: {$avg: expr}} accumulator to the group.
+func (a *Accumulator) Avg(field string, expr any) *Accumulator {
+	return a.Add(field, AccAvg, expr)
+}
+
+// Min adds {field: {$min: expr}} accumulator to the group.
+func (a *Accumulator) Min(field string, expr any) *Accumulator {
+	return a.Add(field, AccMin, expr)
+}
+
+// Max adds {field: {$max: expr}} accumulator to the group.
+func (a *Accumulator) Max(field string, expr any) *Accumulator {
+	return a.Add(field, AccMax, expr)
+}
+
+// First adds {field: {$first: expr}} accumulator to the group.
+func (a *Accumulator) First(field string, expr any) *Accumulator {
+	return a.Add(field, AccFirst, expr)
+}
+
+// Last adds {field: {$last: expr}} accumulator to the group.
+func (a *Accumulator) Last(field string, expr any) *Accumulator {
+	return a.Add(field, AccLast, expr)
+}
+
+// Push adds {field: {$push: expr}} accumulator to the group.
+func (a *Accumulator) Push(field string, expr any) *Accumulator {
+	return a.Add(field, AccPush, expr)
+}
+
+// AddToSet adds {field: {$addToSet: expr}} accumulator to the group.
+func (a *Accumulator) AddToSet(field string, expr any) *Accumulator {
+	return a.Add(field, AccAddToSet, expr)
+}
+
+// Count adds {field: {$sum: 1}} accumulator to the group, that counts documents in each group.
+func (a *Accumulator) Count(field string) *Accumulator {
+	return a.Add(field, AccSum, 1)
+}
+
+// Add adds {field: {op: expr}} accumulator to the group.
+// Use it for accumulators that don't have a dedicated method.
+func (a *Accumulator) Add(field, op string, expr any) *Accumulator {
+	switch {
+	case a.err != nil:
+		return a
+	case field == "":
+		a.err = fmt.Errorf("%w: empty output field name for %s accumulator", ErrInvalidArgument, op)
+		return a
+	case field == "_id":
+		a.err = fmt.Errorf("%w: _id cannot be used as an output field name for %s accumulator", ErrInvalidArgument, op)
+		return a
+	}
+	a.fields[field] = M{op: expr}
+	return a
+}
+
+// Stage returns the $group stage of the pipeline.
+// It returns ErrInvalidArgument if any accumulator has an invalid output field name.
+func (a *Accumulator) Stage() (M, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	return M{StageGroup: a.fields}, nil
+}
+
+// PipelineBuilder is a builder for aggregation pipelines.
+// It is thread-safe. Empty builder is ready to use.
+type PipelineBuilder struct {
+	stages []M
+	errs   []error
+	mu     sync.Mutex
+}
+
+// NewPipelineBuilder returns a new instance of [PipelineBuilder].
+func NewPipelineBuilder() *PipelineBuilder {
+	return &PipelineBuilder{}
+}
+
+// Match adds {$match: filter} stage to the pipeline.
+func (b *PipelineBuilder) Match(filter M) *PipelineBuilder {
+	return b.Stage(M{StageMatch: filter})
+}
+
+// Group adds $group stage built by [Accumulator] to the pipeline.
+// An error of the accumulator will be returned from [PipelineBuilder.Build].
+func (b *PipelineBuilder) Group(acc *Accumulator) *PipelineBuilder {
+	stage, err := acc.Stage()
+	if err != nil {
+		b.addError(err)
+		return b
+	}
+	return b.Stage(stage)
+}
+
+// Sort adds {$sort: {...}} stage to the pipeline.
+// Every M should contain one field, fields will be sorted in the provided order.
+// Example: Sort(mongox.M{"name": mongox.Ascending}, mongox.M{"age": mongox.Descending}).
+func (b *PipelineBuilder) Sort(sort ...M) *PipelineBuilder {
+	return b.Stage(M{StageSort: sortManyToD(sort)})
+}
+
+// Skip adds {$skip: n} stage to the pipeline.
+func (b *PipelineBuilder) Skip(n int) *PipelineBuilder {
+	return b.Stage(M{StageSkip: n})
+}
+
+// Limit adds {$limit: n} stage to the pipeline.
+func (b *PipelineBuilder) Limit(n int) *PipelineBuilder {
+	return b.Stage(M{StageLimit: n})
+}
+
+// Project adds {$project: projection} stage to the pipeline.
+func (b *PipelineBuilder) Project(projection M) *PipelineBuilder {
+	return b.Stage(M{StageProject: projection})
+}
+
+// Unwind adds {$unwind: "$field"} stage to the pipeline.
+// Field is a field path without the "$" prefix.
+func (b *PipelineBuilder) Unwind(field string) *PipelineBuilder {
+	return b.Stage(M{StageUnwind: "$" + field})
+}
+
+// LookupPipeline adds the pipeline form of $lookup stage to the pipeline: it joins documents of the from collection
+// that pass the sub-pipeline into the as array field. Unlike the equality $lookup it can filter, sort and project
+// the joined side. The let variables define fields of the input document available in the sub-pipeline as "$$name",
+// use them in $match with $expr for correlated conditions, e.g.
+//
+//	LookupPipeline("orders", mongox.M{"userID": "$id"}, []mongox.M{
+//		{mongox.StageMatch: mongox.M{mongox.Expr: mongox.M{"$eq": []any{"$user_id", "$$userID"}}}},
+//		{mongox.StageLimit: 10},
+//	}, "orders")
+//
+// Nil let means an uncorrelated sub-pipeline. An ErrInvalidArgument will be returned from [PipelineBuilder.Build]
+// if from or as is empty.
+func (b *PipelineBuilder) LookupPipeline(from string, let M, pipeline []M, as string) *PipelineBuilder {
+	if from == "" || as == "" {
+		b.addError(fmt.Errorf("%w: $lookup requires from and as", ErrInvalidArgument))
+		return b
+	}
+	lookup := M{"from": from, "pipeline": lang.If(pipeline != nil, pipeline, []M{}), "as": as}
+	lang.IfF(len(let) > 0, func() { lookup["let"] = let })
+	return b.Stage(M{StageLookup: lookup})
+}
+
+// Stage adds a raw stage to the pipeline, e.g. mongox.M{"$count": "n"}.
+func (b *PipelineBuilder) Stage(stage M) *PipelineBuilder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stages = append(b.stages, stage)
+	return b
+}
+
+// Build returns the list of stages added to the builder.
+// It returns ErrInvalidArgument if any stage is invalid.
+func (b *PipelineBuilder) Build() ([]M, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.errs) > 0 {
+		return nil, errors.Join(b.errs...)
+	}
+	return b.stages, nil
+}
+
+func (b *PipelineBuilder) addError(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.errs = append(b.errs, err)
+}
+
+func preparePipeline(pipeline []M) mongo.Pipeline {
+	out := make(mongo.Pipeline, 0, len(pipeline))
+	for _, stage := range pipeline {
+		out = append(out, stage.Prepare())
+	}
+	return out
+}
+
+func sortManyToD(sorts []M) bson.D {
+	out := make(bson.D, 0, len(sorts))
+	for _, sort := range sorts {
+		for k, v := range sort {
+			out = append(out, bson.E{Key: k, Value: v})
+		}
+	}
+	return out
+}
@@ -2,10 +2,14 @@ package mongox
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/maxbolgarin/lang"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
@@ -38,6 +42,375 @@ func (f M) String() string {
 	return f.Prepare().String()
 }
 
+// DateRange returns a filter that matches documents with the field in the closed range [from, to]:
+// {field: {$gte: from, $lte: to}}. Zero-value endpoint is treated as open-ended and the bound is omitted,
+// e.g. DateRange("created_at", weekAgo, time.Time{}) becomes {created_at: {$gte: weekAgo}}.
+// If both endpoints are zero, it returns an empty filter that matches all documents.
+func DateRange(field string, from, to time.Time) M {
+	bounds := make(M, 2)
+	if !from.IsZero() {
+		bounds[Gte] = from
+	}
+	if !to.IsZero() {
+		bounds[Lte] = to
+	}
+	if len(bounds) == 0 {
+		return M{}
+	}
+	return M{field: bounds}
+}
+
+// OrFilter returns a filter that matches documents satisfying at least one of the conditions: {$or: [c1, c2, ...]}.
+// It always uses the array form of $or, so it is safe to build it from a dynamic list of conditions.
+// It returns the condition itself if there is exactly one and an empty filter if there are none.
+// It is named OrFilter, because Or is the name of the operator constant.
+func OrFilter(conditions ...M) M {
+	return logicalFilter(Or, conditions)
+}
+
+// AndFilter returns a filter that matches documents satisfying all of the conditions: {$and: [c1, c2, ...]}.
+// It always uses the array form of $and, so it is safe to build it from a dynamic list of conditions.
+// It returns the condition itself if there is exactly one and an empty filter if there are none.
+// It is named AndFilter, because And is the name of the operator constant.
+func AndFilter(conditions ...M) M {
+	return logicalFilter(And, conditions)
+}
+
+// ExprFilter returns a filter with an aggregation expression: {$expr: expr}. Use it to compare two fields
+// of the same document, e.g. to match documents where price is greater than list_price:
+//
+//	mongox.ExprFilter(mongox.M{mongox.Gt: []any{"$price", "$list_price"}})
+//
+// Arguments of expression operators are arrays, so their order is preserved when the filter is prepared.
+// It is named ExprFilter, because Expr is the name of the operator constant.
+func ExprFilter(expr M) M {
+	return M{Expr: expr}
+}
+
+func logicalFilter(op string, conditions []M) M {
+	switch len(conditions) {
+	case 0:
+		return M{}
+	case 1:
+		return conditions[0]
+	}
+	return M{op: append([]M(nil), conditions...)}
+}
+
+// ArraySize returns a filter that matches documents with the array field of exactly n elements: {field: {$size: n}}.
+// It returns ErrInvalidArgument if n is negative, because $size cannot be negative.
+// Use [ArrayNotEmpty] to match arrays of any non-zero length.
+func ArraySize(field string, n int) (M, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("%w: negative array size %d", ErrInvalidArgument, n)
+	}
+	return M{field: M{Size: n}}, nil
+}
+
+// ArrayNotEmpty returns a filter that matches documents with the existing non-empty array field:
+// {field: {$exists: true, $ne: []}}.
+func ArrayNotEmpty(field string) M {
+	return M{field: M{Exists: true, Ne: []any{}}}
+}
+
+// ArrayContains returns a filter that matches documents with the array field containing the value: {field: value}.
+// If the value is a slice itself, it matches arrays equal to it or arrays containing it as an element.
+func ArrayContains(field string, value any) M {
+	return M{field: value}
+}
+
+// ArrayFieldEquals returns a filter that matches documents with the array of subdocuments containing an element
+// with the subField equal to the value: {"arrayField.subField": value}. Conditions on the dotted path are not
+// correlated: combined with another condition on the same array, e.g. in [AndFilter], each condition may be
+// satisfied by a different element, so {items.sku: "a", items.qty: 5} matches [{sku: "a", qty: 1}, {sku: "b", qty: 5}].
+// Use [ElemMatchFilter] when all conditions must hold for the same element.
+func ArrayFieldEquals(arrayField, subField string, value any) M {
+	return M{arrayField + "." + subField: value}
+}
+
+// ElemMatchFilter returns a filter that matches documents with the array field containing at least one element
+// satisfying all conditions together: {arrayField: {$elemMatch: cond}}, e.g.
+// ElemMatchFilter("items", mongox.M{"sku": "a", "qty": mongox.M{mongox.Gte: 5}}) matches only if the same item
+// has sku "a" and qty >= 5. Use [ArrayFieldEquals] for a single condition on a field of any element.
+// It is named ElemMatchFilter, because ElemMatch is the name of the operator constant.
+func ElemMatchFilter(arrayField string, cond M) M {
+	return M{arrayField: M{ElemMatch: cond}}
+}
+
+// PrefixMatch returns a filter that matches documents with the string field starting with the prefix:
+// {field: {$regex: "^prefix"}}. Regex metacharacters of the prefix are escaped, so it is safe to use user input,
+// e.g. "v1.2[" matches only strings starting with "v1.2[" literally.
+// A case-sensitive anchored regex is the only one that can use an index on the field, instead of a full scan.
+func PrefixMatch(field, prefix string) M {
+	return M{field: M{Regex: "^" + regexp.QuoteMeta(prefix)}}
+}
+
+// SetField returns an update fragment that sets the value of a field: {$set: {field: v}}.
+// Use [Update] to combine it with other fragments.
+func SetField(field string, v any) M {
+	return M{Set: M{field: v}}
+}
+
+// SetOnInsertField returns an update fragment that sets the value of a field
+// only if an update results in an insert of a document: {$setOnInsert: {field: v}}.
+// Use [Update] to combine it with other fragments.
+func SetOnInsertField(field string, v any) M {
+	return M{SetOnInsert: M{field: v}}
+}
+
+// MaxField returns an update fragment that updates the field only if
+// the specified value is greater than the existing one: {$max: {field: v}}.
+// Use [Update] to combine it with other fragments.
+func MaxField(field string, v any) M {
+	return M{Max: M{field: v}}
+}
+
+// CurrentDateField returns an update fragment that sets the value of a field
+// to the current date: {$currentDate: {field: true}}.
+// Use [Update] to combine it with other fragments.
+func CurrentDateField(field string) M {
+	return M{CurrentDate: M{field: true}}
+}
+
+// CurrentDateTimeField returns an update fragment that explicitly sets the value of a field
+// to the current date as a BSON Date: {$currentDate: {field: {$type: "date"}}}.
+// Use [Update] to combine it with other fragments.
+func CurrentDateTimeField(field string) M {
+	return M{CurrentDate: M{field: M{"$type": "date"}}}
+}
+
+// CurrentTimestampField returns an update fragment that sets the value of a field
+// to the current time as a BSON Timestamp: {$currentDate: {field: {$type: "timestamp"}}}.
+// Timestamps are unique and increasing within a server, so use it for fields that need monotonic ordering.
+// Decode the field into bson.Timestamp. Use [Update] to combine it with other fragments.
+func CurrentTimestampField(field string) M {
+	return M{CurrentDate: M{field: M{"$type": "timestamp"}}}
+}
+
+// PushBuilder is a builder for the $push update fragment with modifiers.
+// Create it with [PushTo] and configure it with chained calls, e.g. keep the 10 most recent events:
+//
+//	mongox.PushTo("events").Each(event).Sort(mongox.M{"ts": mongox.Descending}).Slice(10).Build()
+//
+// It produces {$push: {events: {$each: [event], $sort: {ts: -1}, $slice: 10}}}.
+// It is NOT thread-safe, use it in a single goroutine.
+type PushBuilder struct {
+	field    string
+	value    any
+	each     []any
+	isEach   bool
+	slice    *int
+	sort     any
+	position *int
+}
+
+// PushTo returns a new [PushBuilder] for the array field.
+func PushTo(field string) *PushBuilder {
+	return &PushBuilder{field: field}
+}
+
+// Value sets a single value to append without modifiers: {$push: {field: value}}.
+// Use Each to use $slice, $sort or $position modifiers.
+func (b *PushBuilder) Value(value any) *PushBuilder {
+	b.value = value
+	return b
+}
+
+// Each sets values to append with the $each modifier: {$push: {field: {$each: values}}}.
+func (b *PushBuilder) Each(values ...any) *PushBuilder {
+	b.each = append(b.each, values...)
+	b.isEach = true
+	return b
+}
+
+// Slice limits the size of the array after the push with the $slice modifier.
+// Positive n keeps the first n elements, negative n keeps the last n elements and zero empties the array.
+func (b *PushBuilder) Slice(n int) *PushBuilder {
+	b.slice = &n
+	return b
+}
+
+// Sort orders the array after the push with the $sort modifier before $slice is applied.
+// Use M with a single field for arrays of documents, e.g. mongox.M{"ts": mongox.Descending},
+// and Ascending or Descending for arrays of scalars.
+func (b *PushBuilder) Sort(order any) *PushBuilder {
+	b.sort = order
+	return b
+}
+
+// Position inserts values at the index of the array with the $position modifier instead of appending them.
+// Negative n counts from the end of the array, e.g. -1 inserts values before the last element.
+func (b *PushBuilder) Position(n int) *PushBuilder {
+	b.position = &n
+	return b
+}
+
+// Build returns the $push update fragment. Use [Update] to combine it with other fragments.
+// It returns ErrInvalidArgument if the field is empty or modifiers are used without Each.
+func (b *PushBuilder) Build() (M, error) {
+	if b.field == "" {
+		return nil, fmt.Errorf("%w: empty field", ErrInvalidArgument)
+	}
+	if !b.isEach {
+		if b.slice != nil || b.sort != nil || b.position != nil {
+			return nil, fmt.Errorf("%w: $slice, $sort and $position modifiers require $each", ErrInvalidArgument)
+		}
+		return M{Push: M{b.field: b.value}}, nil
+	}
+	if b.value != nil {
+		return nil, fmt.Errorf("%w: Value cannot be used together with Each", ErrInvalidArgument)
+	}
+
+	modifiers := M{Each: lang.If(b.each == nil, []any{}, b.each)}
+	if b.slice != nil {
+		modifiers[Slice] = *b.slice
+	}
+	if b.sort != nil {
+		modifiers[Sort] = b.sort
+	}
+	if b.position != nil {
+		modifiers[Position] = *b.position
+	}
+	return M{Push: M{b.field: modifiers}}, nil
+}
+
+// Update merges update fragments into a single update document.
+// Fields of the same operator are grouped together, e.g.
+//
+//	Update(SetField("a", 1), SetField("b", 2), MaxField("c", 3))
+//
+// becomes {$set: {a: 1, b: 2}, $max: {c: 3}}. If the same field of the same operator
+// appears several times, the last value wins. Fragments are not modified.
+func Update(fragments ...M) M {
+	out := make(M, len(fragments))
+	for _, fragment := range fragments {
+		for op, value := range fragment {
+			fields, ok := asMap(value)
+			if !ok {
+				// Not a field document (e.g. pipeline stage), keep as is
+				out[op] = value
+				continue
+			}
+			merged, ok := out[op].(M)
+			if !ok {
+				merged = make(M, len(fields))
+				out[op] = merged
+			}
+			for k, v := range fields {
+				merged[k] = v
+			}
+		}
+	}
+	return out
+}
+
+// DiffOptions is used to configure how a diff structure is converted to an update document.
+type DiffOptions struct {
+	// FieldNameMapper returns the name of the document field for a struct field without a bson tag.
+	// Default is the Go field name as is, e.g. "Struct.Name", use [LowercaseFieldName] to get the names
+	// the driver uses when it marshals such fields, e.g. "struct.name". Fields with a bson tag are not affected.
+	FieldNameMapper func(reflect.StructField) string
+
+	// IncludeZeroValues makes non-pointer fields part of the update, e.g. a struct of plain values
+	// can be used as a full update. Zero values are set explicitly even if the field has omitempty tag.
+	// Nil pointers, slices, maps and interfaces are still omitted. Default is to update only non-nil pointer,
+	// slice and map fields.
+	IncludeZeroValues bool
+}
+
+// LowercaseFieldName returns the lowercased name of the struct field, e.g. "createdat" for CreatedAt.
+// The driver uses this name for struct fields without a bson tag, so use it as DiffOptions.FieldNameMapper
+// to update the same fields the document has.
+func LowercaseFieldName(field reflect.StructField) string {
+	return strings.ToLower(field.Name)
+}
+
+// DiffToUpdate returns an update document that UpdateOneFromDiff would apply for the diff structure,
+// e.g. {$set: {name: "new name", "struct.number": 2}}. Nil fields of the diff are omitted.
+// Use it to log, inspect or modify the update before writing it with UpdateOne.
+// It returns ErrInvalidArgument if diff structure is invalid.
+func DiffToUpdate(diff any, opts ...DiffOptions) (M, error) {
+	update, err := diffToUpdates(diff, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	out := make(M, len(update))
+	for _, op := range update {
+		fields, ok := op.Value.(bson.D)
+		if !ok {
+			out[op.Key] = op.Value
+			continue
+		}
+		m := make(M, len(fields))
+		for _, f := range fields {
+			m[f.Key] = f.Value
+		}
+		out[op.Key] = m
+	}
+	return out, nil
+}
+
+func asMap(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case M:
+		return m, true
+	case bson.M:
+		return m, true
+	case map[string]any:
+		return m, true
+	}
+	return nil, false
+}
+
+// redactedValue replaces values in a redacted filter.
+const redactedValue = "?"
+
+// redactFilter returns a deep copy of the filter with keys and operators, but with all values replaced.
+// It is safe to pass it to user callbacks: it doesn't share memory with the original filter and doesn't leak data.
+func redactFilter(filter M) M {
+	if filter == nil {
+		return nil
+	}
+	out := make(M, len(filter))
+	for k, v := range filter {
+		out[k] = redactValue(v)
+	}
+	return out
+}
+
+func redactValue(v any) any {
+	if m, ok := asMap(v); ok {
+		return redactFilter(m)
+	}
+	switch val := v.(type) {
+	case []M:
+		out := make([]any, 0, len(val))
+		for _, m := range val {
+			out = append(out, redactFilter(m))
+		}
+		return out
+	case bson.D:
+		out := make(M, len(val))
+		for _, e := range val {
+			out[e.Key] = redactValue(e.Value)
+		}
+		return out
+	case bson.A:
+		return redactSlice(val)
+	case []any:
+		return redactSlice(val)
+	}
+	return redactedValue
+}
+
+func redactSlice(s []any) []any {
+	out := make([]any, 0, len(s))
+	for _, v := range s {
+		out = append(out, redactValue(v))
+	}
+	return out
+}
+
 func newMapFromPairs(pairs ...any) map[string]any {
 	out := make(map[string]any, len(pairs)/2)
 	addPairs(out, pairs...)
@@ -61,15 +434,44 @@ func prepareUpdates(upd map[string]any, op string) bson.D {
 	return bson.D{bson.E{Key: op, Value: res}}
 }
 
-func diffToUpdates(diff any) (bson.D, error) {
-	upd, err := processDiffStruct(diff, "")
+func typedUpdate[T any](op string, fields map[string]T) (bson.D, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: empty fields for %s", ErrInvalidArgument, op)
+	}
+	upd := make(map[string]any, len(fields))
+	for k, v := range fields {
+		upd[k] = v
+	}
+	return prepareUpdates(upd, op), nil
+}
+
+func diffToUpdates(diff any, opts ...DiffOptions) (bson.D, error) {
+	var opt DiffOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	upd, err := processDiffStruct(diff, "", opt)
 	if err != nil {
 		return nil, err
 	}
 	return prepareUpdates(upd, Set), nil
 }
 
-func processDiffStruct(diff any, parentField string) (map[string]any, error) {
+// updatedFields returns the sorted list of fields of all operators in the update document.
+func updatedFields(update bson.D) []string {
+	var fields []string
+	for _, op := range update {
+		if doc, ok := op.Value.(bson.D); ok {
+			for _, e := range doc {
+				fields = append(fields, e.Key)
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func processDiffStruct(diff any, parentField string, opts DiffOptions) (map[string]any, error) {
 	req := reflect.ValueOf(diff)
 	if req.Kind() == reflect.Pointer {
 		req = req.Elem()
@@ -88,9 +490,12 @@ func processDiffStruct(diff any, parentField string) (map[string]any, error) {
 			continue
 		}
 
-		// There is no bson tag, use the actual field name
+		// There is no bson tag, use the actual field name or the name from the mapper
 		if fieldName == "" {
 			fieldName = req.Type().Field(n).Name
+			if opts.FieldNameMapper != nil {
+				fieldName = opts.FieldNameMapper(req.Type().Field(n))
+			}
 		}
 
 		if parentField != "" {
@@ -104,12 +509,15 @@ func processDiffStruct(diff any, parentField string) (map[string]any, error) {
 		}
 
 		kind := field.Kind()
-		if kind != reflect.Ptr && kind != reflect.Slice && kind != reflect.Map {
+		// Interfaces are skipped by default like other non-pointer fields, a nil interface is omitted only with zero values
+		nillable := kind == reflect.Ptr || kind == reflect.Slice || kind == reflect.Map ||
+			(kind == reflect.Interface && opts.IncludeZeroValues)
+		if !nillable && !opts.IncludeZeroValues {
 			// expect pointers or slice/
 			continue
 		}
 
-		if field.IsNil() {
+		if nillable && field.IsNil() {
 			// nil == no update for field
 			continue
 		}
@@ -139,7 +547,7 @@ func processDiffStruct(diff any, parentField string) (map[string]any, error) {
 			if len(fieldNameRaw) > 1 && strings.Contains(fieldNameRaw[1], "inline") {
 				parentField = ""
 			}
-			structUpd, err := processDiffStruct(field.Interface(), parentField)
+			structUpd, err := processDiffStruct(field.Interface(), parentField, opts)
 			if err != nil {
 				continue
 			}
@@ -158,3 +566,60 @@ func processDiffStruct(diff any, parentField string) (map[string]any, error) {
 
 	return upd, nil
 }
+
+var (
+	rawDocumentType = reflect.TypeOf(bson.Raw{})
+	documentType    = reflect.TypeOf(bson.D{})
+)
+
+// hasBSONPath reports whether the dotted path can be decoded into the type, e.g. "struct.name" for testEntity.
+// Field names are taken from bson tags or lowercased Go names as the driver does, json tags are used for fields
+// without bson tags only if useJSONTags is set, like BSONOptions.UseJSONStructTags does for the driver.
+// Maps, interfaces and BSON documents accept any path, slices and arrays are checked by the element type.
+func hasBSONPath(t reflect.Type, path []string, useJSONTags bool) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if len(path) == 0 {
+		return true
+	}
+
+	switch {
+	case t == rawDocumentType || t == documentType:
+		return true
+	case t.Kind() == reflect.Map || t.Kind() == reflect.Interface:
+		return true
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return hasBSONPath(t.Elem(), path, useJSONTags)
+	case t.Kind() != reflect.Struct:
+		return false
+	}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag, ok := field.Tag.Lookup("bson")
+		if !ok && useJSONTags {
+			tag = field.Tag.Get("json")
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			if hasBSONPath(field.Type, path, useJSONTags) {
+				return true
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if name == path[0] && hasBSONPath(field.Type, path[1:], useJSONTags) {
+			return true
+		}
+	}
+	return false
+}